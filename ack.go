@@ -0,0 +1,69 @@
+package parallel_csv
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// AckSink is a sink delivering the rows in the background, such as a message queue producer.
+// The chunks written to it through the processor count as processed, and are left behind by
+// checkpoints, only once the sink acknowledges their rows: after a crash, the rows which were
+// not acknowledged are processed again when resuming, so that none is lost on the way to the
+// queue. Some may then be delivered twice
+type AckSink interface {
+	Sink
+	// WriteAck is Write calling ack once the rows have been delivered, or with the error which
+	// made the delivery fail. ack may be called from any goroutine, at the latest by Close, and
+	// is not called if WriteAck returns an error
+	WriteAck(rows [][]string, ack func(err error)) error
+}
+
+// chunkAck holds the completion of a chunk until both its worker and the sink are done with it
+type chunkAck struct {
+	pending   int32
+	mu        sync.Mutex
+	completed bool
+	err       error
+	// finish is called once nothing holds the chunk anymore
+	finish func(completed bool, err error)
+}
+
+func newChunkAck(finish func(completed bool, err error)) *chunkAck {
+	// the worker holds the chunk until it has been processed
+	return &chunkAck{pending: 1, finish: finish}
+}
+
+// hold delays the completion of the chunk until the returned function is called
+func (a *chunkAck) hold() func(err error) {
+	atomic.AddInt32(&a.pending, 1)
+	once := sync.Once{}
+	return func(err error) {
+		once.Do(func() {
+			if err != nil {
+				a.mu.Lock()
+				a.err = fmt.Errorf("delivery failed: %w", err)
+				a.mu.Unlock()
+			}
+			a.release()
+		})
+	}
+}
+
+// processed is called by the worker once the chunk has been processed
+func (a *chunkAck) processed(completed bool) {
+	a.mu.Lock()
+	a.completed = completed
+	a.mu.Unlock()
+	a.release()
+}
+
+func (a *chunkAck) release() {
+	if atomic.AddInt32(&a.pending, -1) > 0 {
+		return
+	}
+	a.mu.Lock()
+	completed, err := a.completed, a.err
+	a.mu.Unlock()
+	a.finish(completed && err == nil, err)
+}
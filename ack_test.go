@@ -0,0 +1,72 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// ackingSink keeps the rows written, acknowledging them at once unless hold is set. The rows
+// holding fail are acknowledged with errTransient
+type ackingSink struct {
+	memorySink
+	hold bool
+	fail string
+	mu   sync.Mutex
+	acks []func(err error)
+}
+
+func (s *ackingSink) WriteAck(rows [][]string, ack func(err error)) error {
+	if err := s.Write(rows); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if row[0] == s.fail {
+			ack(errTransient)
+			return nil
+		}
+	}
+	if s.hold {
+		s.mu.Lock()
+		s.acks = append(s.acks, ack)
+		s.mu.Unlock()
+	} else {
+		ack(nil)
+	}
+	return nil
+}
+
+func ackedCheckpoint(t *testing.T, sink *ackingSink) (*Checkpoint, error) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	config := GetDefaultConfig()
+	config.NumberOfWorkers = 4
+	config.BytesPerWorker = 64
+	config.CheckpointPath = path
+	err := NewProcessor(strings.NewReader(numbers(100)), &config).Copy(sink)
+
+	checkpoint, loadErr := LoadCheckpoint(path)
+	assert.Nil(t, loadErr)
+	return checkpoint, err
+}
+
+func TestAckSinkCheckpoint(t *testing.T) {
+	checkpoint, err := ackedCheckpoint(t, &ackingSink{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(100), checkpoint.Rows)
+
+	// the rows never acknowledged are processed again on resume
+	sink := &ackingSink{hold: true}
+	checkpoint, err = ackedCheckpoint(t, sink)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), checkpoint.Rows)
+	assert.Len(t, sink.rows, 100)
+	assert.NotEmpty(t, sink.acks)
+}
+
+func TestAckSinkDeliveryFailure(t *testing.T) {
+	checkpoint, err := ackedCheckpoint(t, &ackingSink{fail: "50"})
+	assert.ErrorIs(t, err, errTransient)
+	assert.Less(t, checkpoint.Rows, int64(50))
+}
@@ -0,0 +1,100 @@
+package parallel_csv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// AppendSink appends CSV rows to the file at Path, creating it if needed. The header is written
+// only to a new or empty file: the header of an existing file must match the one of the rows,
+// otherwise Open fails with ColumnMismatchError. It suits incremental runs building a single file
+type AppendSink struct {
+	Path      string
+	Separator string
+	// Quoting and QuoteChar are passed to the CSVSink writing the rows
+	Quoting   QuotingPolicy
+	QuoteChar string
+	file      *os.File
+	csv       *CSVSink
+}
+
+// NewAppendSink creates a sink appending to the file at path, using separator between fields
+func NewAppendSink(path string, separator string) *AppendSink {
+	return &AppendSink{Path: path, Separator: separator}
+}
+
+func (s *AppendSink) Open(header []string) error {
+	file, err := os.OpenFile(s.Path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	s.file = file
+	s.csv = NewCSVSink(file, s.Separator)
+	s.csv.Quoting, s.csv.QuoteChar = s.Quoting, s.QuoteChar
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return s.csv.Open(header)
+	}
+
+	if len(header) > 0 {
+		if err := s.checkHeader(header, info.Size()); err != nil {
+			return err
+		}
+	}
+	// the last row of the file may not end with a line break
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+		return err
+	}
+	if last[0] != LineBreak[0] {
+		_, err = s.csv.w.WriteString(LineBreak)
+	}
+	return err
+}
+
+// checkHeader compares the first line of the file, size bytes long, with header
+func (s *AppendSink) checkHeader(header []string, size int64) error {
+	line, err := bufio.NewReader(io.NewSectionReader(s.file, 0, size)).ReadString(LineBreak[0])
+	if err != nil && err != io.EOF {
+		return err
+	}
+	line = strings.TrimSuffix(strings.TrimSuffix(line, LineBreak), "\r")
+
+	existing, err := CSVParser{Separator: s.Separator}.Fields(line)
+	if err != nil {
+		return err
+	}
+	if len(existing) != len(header) {
+		return fmt.Errorf("%w: %s has the header %v, the rows %v", ColumnMismatchError, s.Path, existing, header)
+	}
+	for i := range header {
+		if existing[i] != header[i] {
+			return fmt.Errorf("%w: %s has the header %v, the rows %v", ColumnMismatchError, s.Path, existing, header)
+		}
+	}
+	return nil
+}
+
+func (s *AppendSink) Write(rows [][]string) error {
+	return s.csv.Write(rows)
+}
+
+// Close flushes the rows and closes the file
+func (s *AppendSink) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.csv.Close()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	s.file, s.csv = nil, nil
+	return err
+}
@@ -0,0 +1,45 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func appendRows(t *testing.T, path string, input string) error {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 8
+	return NewProcessor(strings.NewReader(input), &config).Copy(NewAppendSink(path, ","))
+}
+
+func TestAppendSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "all.csv")
+
+	assert.Nil(t, appendRows(t, path, "id,name\n1,anna\n"))
+	assert.Nil(t, appendRows(t, path, "id,name\n2,bob\n3,carla\n"))
+	content, _ := os.ReadFile(path)
+	assert.Equal(t, "id,name\n1,anna\n2,bob\n3,carla\n", string(content))
+
+	err := appendRows(t, path, "id,email\n4,d@example.com\n")
+	assert.ErrorIs(t, err, ColumnMismatchError)
+	content, _ = os.ReadFile(path)
+	assert.Equal(t, "id,name\n1,anna\n2,bob\n3,carla\n", string(content))
+}
+
+func TestAppendSinkExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "all.csv")
+	// written by another tool, without the last line break
+	assert.Nil(t, os.WriteFile(path, []byte("\"id\",name\r\n1,anna"), 0o644))
+
+	assert.Nil(t, appendRows(t, path, "id,name\n2,bob\n"))
+	content, _ := os.ReadFile(path)
+	assert.Equal(t, "\"id\",name\r\n1,anna\n2,bob\n", string(content))
+
+	// an empty file gets the header
+	assert.Nil(t, os.WriteFile(path, nil, 0o644))
+	assert.Nil(t, appendRows(t, path, "id,name\n2,bob\n"))
+	content, _ = os.ReadFile(path)
+	assert.Equal(t, "id,name\n2,bob\n", string(content))
+}
@@ -0,0 +1,156 @@
+package parallel_csv
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"strings"
+	"time"
+)
+
+// avroEncoder writes the values converted by bindTypes as Avro records, in the binary encoding.
+// Columns are longs, doubles, booleans or timestamp-millis according to the schema and strings
+// otherwise. Columns which are not required are unions with null
+type avroEncoder struct {
+	fields []avroField
+}
+
+type avroField struct {
+	name     string
+	kind     string
+	nullable bool
+}
+
+func newAvroEncoder(header []string, schema *Schema, count int) (*avroEncoder, error) {
+	kinds := map[int]compiledColumn{}
+	if schema != nil {
+		columns, err := compileColumns(header, *schema)
+		if err != nil {
+			return nil, err
+		}
+		for _, column := range columns {
+			kinds[column.index] = column
+		}
+	}
+
+	avroTypes := map[ColumnType]string{
+		IntegerType: "long",
+		FloatType:   "double",
+		BooleanType: "boolean",
+		TimeType:    "timestamp-millis",
+	}
+	e := &avroEncoder{fields: make([]avroField, count)}
+	for i := range e.fields {
+		name := columnName(i)
+		if i < len(header) {
+			name = header[i]
+		}
+		column, typed := kinds[i]
+		e.fields[i] = avroField{name: avroName(name), kind: "string", nullable: !typed || !column.Required}
+		if kind, ok := avroTypes[column.Type]; ok {
+			e.fields[i].kind = kind
+		}
+	}
+	return e, nil
+}
+
+// avroName turns a column name into a valid Avro name, replacing the characters not allowed
+func avroName(name string) string {
+	b := strings.Builder{}
+	for i, r := range name {
+		valid := r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9'
+		if i == 0 && r >= '0' && r <= '9' {
+			b.WriteByte('_')
+			valid = true
+		}
+		if valid {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+// Schema returns the Avro schema of the records, as JSON
+func (e *avroEncoder) Schema() string {
+	fields := make([]map[string]interface{}, len(e.fields))
+	for i, field := range e.fields {
+		var kind interface{} = field.kind
+		if field.kind == "timestamp-millis" {
+			kind = map[string]string{"type": "long", "logicalType": "timestamp-millis"}
+		}
+		fields[i] = map[string]interface{}{"name": field.name, "type": kind}
+		if field.nullable {
+			fields[i]["type"] = []interface{}{"null", kind}
+			fields[i]["default"] = nil
+		}
+	}
+
+	schema, _ := json.Marshal(map[string]interface{}{"type": "record", "name": "Row", "fields": fields})
+	return string(schema)
+}
+
+// encode appends the record of the values to b. Missing values are null
+func (e *avroEncoder) encode(b []byte, values []interface{}) []byte {
+	for i, field := range e.fields {
+		var value interface{}
+		if i < len(values) {
+			value = values[i]
+		}
+
+		if field.nullable {
+			if value == nil {
+				b = appendAvroLong(b, 0)
+				continue
+			}
+			b = appendAvroLong(b, 1)
+		}
+
+		switch v := value.(type) {
+		case int64:
+			b = appendAvroLong(b, v)
+		case float64:
+			bits := make([]byte, 8)
+			binary.LittleEndian.PutUint64(bits, math.Float64bits(v))
+			b = append(b, bits...)
+		case bool:
+			if v {
+				b = append(b, 1)
+			} else {
+				b = append(b, 0)
+			}
+		case time.Time:
+			b = appendAvroLong(b, v.UnixNano()/int64(time.Millisecond))
+		case string:
+			b = appendAvroLong(b, int64(len(v)))
+			b = append(b, v...)
+		default:
+			// a required value is missing
+			b = appendAvroZero(b, field.kind)
+		}
+	}
+	return b
+}
+
+// appendAvroZero appends the zero value of a kind
+func appendAvroZero(b []byte, kind string) []byte {
+	switch kind {
+	case "double":
+		return append(b, 0, 0, 0, 0, 0, 0, 0, 0)
+	case "boolean":
+		return append(b, 0)
+	default:
+		// longs, timestamps and empty strings
+		return appendAvroLong(b, 0)
+	}
+}
+
+// appendAvroLong appends a zig-zag encoded variable length integer
+func appendAvroLong(b []byte, n int64) []byte {
+	varint := make([]byte, binary.MaxVarintLen64)
+	return append(b, varint[:binary.PutUvarint(varint, uint64((n<<1)^(n>>63)))]...)
+}
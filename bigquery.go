@@ -0,0 +1,261 @@
+package parallel_csv
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// BigQueryFormat is the format of the files staged for a BigQuery load
+type BigQueryFormat string
+
+const (
+	BigQueryJSON BigQueryFormat = "NEWLINE_DELIMITED_JSON"
+	BigQueryCSV  BigQueryFormat = "CSV"
+)
+
+// DefaultObjectBytes is the size of the data staged in a single object when not set, before
+// compression
+const DefaultObjectBytes = 1 * GB
+
+// BigQueryClient stages files in Cloud Storage and loads them into BigQuery. With the Google Cloud
+// clients, Stage wraps storage.ObjectHandle.NewWriter and Load configures a bigquery.GCSReference
+// from the BigQueryLoad, then runs the loader of the table and waits for its job
+type BigQueryClient interface {
+	// Stage creates the named object in the staging bucket, returning its writer and gs:// URI
+	Stage(ctx context.Context, name string) (io.WriteCloser, string, error)
+	// Load runs a load job and waits for it to end
+	Load(ctx context.Context, load BigQueryLoad) error
+}
+
+// BigQueryLoad describes the load job of the staged files
+type BigQueryLoad struct {
+	Table  string
+	URIs   []string
+	Format BigQueryFormat
+	// Compression is always GZIP
+	Compression string
+	// SkipLeadingRows is 1 for CSV files starting with the header
+	SkipLeadingRows int
+	// Schema holds the columns of the table, nil to let BigQuery detect them
+	Schema []BigQueryField
+}
+
+// BigQueryField is a column of a BigQuery table
+type BigQueryField struct {
+	Name string
+	// Type is STRING, INT64, FLOAT64, BOOL or TIMESTAMP
+	Type string
+	// Mode is REQUIRED or NULLABLE
+	Mode string
+}
+
+// BigQuerySink stages the rows as gzipped files in Cloud Storage and loads them into a BigQuery
+// table once closed, with a single job. Rows are written to a new object every ObjectBytes
+type BigQuerySink struct {
+	// Table is the destination, as project.dataset.table or dataset.table
+	Table string
+	// Format is the format of the staged files, BigQueryJSON if empty
+	Format BigQueryFormat
+	// Schema converts the fields to the types of its columns and gives the schema of the table,
+	// which is detected by BigQuery when nil
+	Schema *Schema
+	// Prefix is the beginning of the names of the staged objects, a unique one if empty
+	Prefix string
+	// ObjectBytes is the size of the data of an object before compression, DefaultObjectBytes if 0
+	ObjectBytes int
+	ctx         context.Context
+	client      BigQueryClient
+	header      []string
+	types       typedRow
+	object      *stagedObject
+	uris        []string
+	err         error
+}
+
+// stagedObject is the gzipped object being written
+type stagedObject struct {
+	writer  io.WriteCloser
+	gzip    *gzip.Writer
+	buffer  *bufio.Writer
+	written int
+}
+
+// NewBigQuerySink creates a sink loading the rows into table through client
+func NewBigQuerySink(ctx context.Context, client BigQueryClient, table string) *BigQuerySink {
+	return &BigQuerySink{Table: table, ctx: ctx, client: client}
+}
+
+func (s *BigQuerySink) Open(header []string) error {
+	if s.Format == "" {
+		s.Format = BigQueryJSON
+	}
+	if s.Format != BigQueryJSON && s.Format != BigQueryCSV {
+		return fmt.Errorf("unknown BigQuery format %q", s.Format)
+	}
+	if s.Prefix == "" {
+		s.Prefix = "pcsv/" + time.Now().UTC().Format("20060102T150405.000000000") + "/"
+	}
+	if s.ObjectBytes <= 0 {
+		s.ObjectBytes = DefaultObjectBytes
+	}
+
+	s.header = header
+	var err error
+	s.types, err = bindTypes(header, s.Schema)
+	return err
+}
+
+// Write stages the rows, after a failure the load is cancelled
+func (s *BigQuerySink) Write(rows [][]string) error {
+	for _, row := range rows {
+		values, err := s.types(row)
+		var line []byte
+		if err == nil {
+			line, err = s.encode(values)
+		}
+		if err == nil {
+			err = s.write(line)
+		}
+		if err != nil {
+			s.err = err
+			return err
+		}
+	}
+	return nil
+}
+
+// encode writes the values as a line of the staged files
+func (s *BigQuerySink) encode(values []interface{}) ([]byte, error) {
+	if s.Format == BigQueryJSON {
+		line, err := json.Marshal(typedObject(s.header, values))
+		return append(line, LineBreak...), err
+	}
+
+	fields := make([]string, len(values))
+	for i, value := range values {
+		fields[i] = quoteField(formatValue(value, time.RFC3339Nano), ",")
+	}
+	return []byte(strings.Join(fields, ",") + LineBreak), nil
+}
+
+// write appends a line to the current object, staging a new one when it is full
+func (s *BigQuerySink) write(line []byte) error {
+	if s.object != nil && s.object.written+len(line) > s.ObjectBytes {
+		if err := s.object.close(); err != nil {
+			return err
+		}
+		s.object = nil
+	}
+
+	if s.object == nil {
+		extension := ".json.gz"
+		if s.Format == BigQueryCSV {
+			extension = ".csv.gz"
+		}
+		name := fmt.Sprintf("%s%06d%s", s.Prefix, len(s.uris)+1, extension)
+		writer, uri, err := s.client.Stage(s.ctx, name)
+		if err != nil {
+			return err
+		}
+		s.uris = append(s.uris, uri)
+		s.object = &stagedObject{writer: writer, gzip: gzip.NewWriter(writer)}
+		s.object.buffer = bufio.NewWriter(s.object.gzip)
+
+		if s.Format == BigQueryCSV && len(s.header) > 0 {
+			header := make([]string, len(s.header))
+			for i, column := range s.header {
+				header[i] = quoteField(column, ",")
+			}
+			if _, err := s.object.buffer.WriteString(strings.Join(header, ",") + LineBreak); err != nil {
+				return err
+			}
+		}
+	}
+
+	s.object.written += len(line)
+	_, err := s.object.buffer.Write(line)
+	return err
+}
+
+func (o *stagedObject) close() error {
+	err := o.buffer.Flush()
+	if closeErr := o.gzip.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := o.writer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Close finishes the last object and runs the load job, unless writing failed or no row has been
+// written. The staged objects are left in the bucket
+func (s *BigQuerySink) Close() error {
+	if s.object != nil {
+		if err := s.object.close(); s.err == nil {
+			s.err = err
+		}
+		s.object = nil
+	}
+	if s.err != nil || len(s.uris) == 0 {
+		return s.err
+	}
+
+	load := BigQueryLoad{
+		Table:       s.Table,
+		URIs:        s.uris,
+		Format:      s.Format,
+		Compression: "GZIP",
+		Schema:      s.fields(),
+	}
+	if s.Format == BigQueryCSV && len(s.header) > 0 {
+		load.SkipLeadingRows = 1
+	}
+	if err := s.client.Load(s.ctx, load); err != nil {
+		return fmt.Errorf("load into %s: %w", s.Table, err)
+	}
+	return nil
+}
+
+// URIs returns the staged objects
+func (s *BigQuerySink) URIs() []string {
+	return s.uris
+}
+
+// fields translates the schema to the BigQuery one, the columns out of the schema being strings.
+// The schema of files without header is left to BigQuery
+func (s *BigQuerySink) fields() []BigQueryField {
+	if s.Schema == nil || len(s.header) == 0 {
+		return nil
+	}
+	types := map[ColumnType]string{
+		IntegerType: "INT64",
+		FloatType:   "FLOAT64",
+		BooleanType: "BOOL",
+		TimeType:    "TIMESTAMP",
+	}
+
+	columns := map[string]ColumnSchema{}
+	for _, column := range s.Schema.Columns {
+		columns[column.Name] = column
+	}
+	fields := make([]BigQueryField, len(s.header))
+	for i, name := range s.header {
+		fields[i] = BigQueryField{Name: name, Type: "STRING", Mode: "NULLABLE"}
+		if column, ok := columns[name]; ok {
+			if t, ok := types[column.Type]; ok {
+				fields[i].Type = t
+			}
+			if column.Required {
+				fields[i].Mode = "REQUIRED"
+			}
+		}
+	}
+	return fields
+}
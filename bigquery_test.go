@@ -0,0 +1,96 @@
+package parallel_csv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+// fakeBigQuery keeps the staged objects in memory
+type fakeBigQuery struct {
+	objects map[string]*closingBuffer
+	loads   []BigQueryLoad
+}
+
+type closingBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *closingBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func (c *fakeBigQuery) Stage(ctx context.Context, name string) (io.WriteCloser, string, error) {
+	uri := "gs://bucket/" + name
+	c.objects[uri] = &closingBuffer{}
+	return c.objects[uri], uri, nil
+}
+
+func (c *fakeBigQuery) Load(ctx context.Context, load BigQueryLoad) error {
+	c.loads = append(c.loads, load)
+	return nil
+}
+
+// object returns the uncompressed content of a staged object
+func (c *fakeBigQuery) object(uri string) string {
+	r, _ := gzip.NewReader(&c.objects[uri].Buffer)
+	content, _ := io.ReadAll(r)
+	return string(content)
+}
+
+func TestBigQuerySinkJSON(t *testing.T) {
+	client := &fakeBigQuery{objects: map[string]*closingBuffer{}}
+	sink := NewBigQuerySink(context.Background(), client, "dataset.people")
+	sink.Prefix = "load/"
+	sink.Schema = &Schema{Columns: []ColumnSchema{
+		{Name: "age", Type: IntegerType, Required: true},
+		{Name: "active", Type: BooleanType},
+	}}
+
+	input := "name,age,active\nMario,42,true\nLuigi,40,\n"
+	assert.Nil(t, NewProcessor(strings.NewReader(input), nil).Copy(sink))
+
+	assert.Equal(t, []string{"gs://bucket/load/000001.json.gz"}, sink.URIs())
+	assert.True(t, client.objects["gs://bucket/load/000001.json.gz"].closed)
+	assert.Equal(t, `{"name":"Mario","age":42,"active":true}`+"\n"+`{"name":"Luigi","age":40,"active":null}`+"\n",
+		client.object("gs://bucket/load/000001.json.gz"))
+
+	assert.Len(t, client.loads, 1)
+	load := client.loads[0]
+	assert.Equal(t, "dataset.people", load.Table)
+	assert.Equal(t, BigQueryJSON, load.Format)
+	assert.Equal(t, "GZIP", load.Compression)
+	assert.Equal(t, []BigQueryField{
+		{Name: "name", Type: "STRING", Mode: "NULLABLE"},
+		{Name: "age", Type: "INT64", Mode: "REQUIRED"},
+		{Name: "active", Type: "BOOL", Mode: "NULLABLE"},
+	}, load.Schema)
+}
+
+func TestBigQuerySinkCSVObjects(t *testing.T) {
+	client := &fakeBigQuery{objects: map[string]*closingBuffer{}}
+	sink := NewBigQuerySink(context.Background(), client, "dataset.numbers")
+	sink.Format = BigQueryCSV
+	sink.Prefix = "load/"
+	sink.ObjectBytes = 100
+
+	assert.Nil(t, NewProcessor(strings.NewReader(numbers(100)), nil).Copy(sink))
+	assert.Len(t, sink.URIs(), 3)
+
+	rows := 0
+	for _, uri := range sink.URIs() {
+		assert.True(t, strings.HasSuffix(uri, ".csv.gz"))
+		lines := strings.Split(strings.TrimSpace(client.object(uri)), LineBreak)
+		assert.Equal(t, "n", lines[0])
+		rows += len(lines) - 1
+	}
+	assert.Equal(t, 100, rows)
+	assert.Equal(t, 1, client.loads[0].SkipLeadingRows)
+	assert.Nil(t, client.loads[0].Schema)
+}
@@ -0,0 +1,58 @@
+package parallel_csv
+
+import "sync/atomic"
+
+// budget is what is left of Config.MaxRows and Config.MaxBytes during a run, negative values
+// not being limited
+type budget struct {
+	rows  int64
+	bytes int64
+}
+
+func newBudget(config *Config) *budget {
+	b := &budget{rows: -1, bytes: -1}
+	if config.MaxRows > 0 {
+		b.rows = config.MaxRows
+	}
+	if config.MaxBytes > 0 {
+		b.bytes = config.MaxBytes
+	}
+	return b
+}
+
+func (b *budget) limited() bool {
+	return b.rows >= 0 || b.bytes >= 0
+}
+
+// take returns the length of the records at the start of block fitting in the budget, and
+// whether the budget has been exhausted by them. The budget is reduced accordingly. The last
+// block of the input may end with a record without delimiter, which only costs its own bytes
+func (b *budget) take(parser RecordParser, block []byte) (int, bool) {
+	end := len(block)
+	if b.bytes >= 0 && int64(end) > b.bytes {
+		end = parser.RecordsEnd(block[:b.bytes])
+	}
+	if b.rows >= 0 && end > 0 {
+		end = recordsPrefix(parser, block[:end], b.rows)
+	}
+
+	exhausted := end < len(block)
+	if end > 0 {
+		if b.rows >= 0 {
+			b.rows -= int64(parser.Count(block[:end]))
+			exhausted = exhausted || b.rows == 0
+		}
+		if b.bytes >= 0 {
+			b.bytes -= int64(end)
+			exhausted = exhausted || b.bytes == 0
+		}
+	}
+	return end, exhausted
+}
+
+// exhaust records that the reader stopped at the budget
+func (p processor) exhaust(state *runState) {
+	state.exhausted = true
+	atomic.StoreInt32(&p.counters.exhausted, 1)
+	p.config.log(p.config.LogLevels.Run, LogInfo, "budget exhausted", "max_rows", p.config.MaxRows, "max_bytes", p.config.MaxBytes)
+}
@@ -0,0 +1,125 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestMaxRows(t *testing.T) {
+	for _, chunk := range []int{1, 7, 64, 1 * KB} {
+		config := GetDefaultConfig()
+		config.BytesPerWorker = chunk
+		config.MaxRows = 25
+
+		p := NewProcessor(strings.NewReader(numbers(100)), &config)
+		sink := &memorySink{}
+		assert.Nil(t, p.Copy(sink), chunk)
+		assert.Len(t, sink.rows, 25, chunk)
+		assert.Equal(t, []string{"25"}, sink.rows[24])
+
+		stats := p.Stats()
+		assert.True(t, stats.Exhausted)
+		assert.Equal(t, int64(25), stats.RowsRead)
+		assert.Equal(t, int64(len(numbers(25))-len("n\n")), stats.BytesDispatched)
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 8
+	// the rows 1 to 9 take 2 bytes each, 10 and 11 take 3
+	config.MaxBytes = 23
+
+	p := NewProcessor(strings.NewReader(numbers(20)), &config)
+	sink := &memorySink{}
+	assert.Nil(t, p.Copy(sink))
+	assert.Len(t, sink.rows, 10)
+	assert.Equal(t, int64(21), p.Stats().BytesDispatched)
+	assert.True(t, p.Stats().Exhausted)
+}
+
+func TestBudgetNotReached(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 4
+	config.MaxRows = 10
+	config.MaxBytes = 1024
+
+	// the last row has no line break
+	p := NewProcessor(strings.NewReader("n\n1\n2\n3"), &config)
+	sink := &memorySink{}
+	assert.Nil(t, p.Copy(sink))
+	assert.Equal(t, [][]string{{"1"}, {"2"}, {"3"}}, sink.rows)
+	assert.False(t, p.Stats().Exhausted)
+}
+
+func TestBudgetLastRow(t *testing.T) {
+	config := GetDefaultConfig()
+	config.MaxRows = 2
+
+	p := NewProcessor(strings.NewReader("n\n1\n2\n3"), &config)
+	sink := &memorySink{}
+	assert.Nil(t, p.Copy(sink))
+	assert.Equal(t, [][]string{{"1"}, {"2"}}, sink.rows)
+
+	config.MaxRows = 0
+	config.MaxBytes = 1
+	sink = &memorySink{}
+	assert.Nil(t, NewProcessor(strings.NewReader("n\n100\n"), &config).Copy(sink))
+	assert.Empty(t, sink.rows)
+}
+
+func TestBudgetExactFit(t *testing.T) {
+	// the last row has no line break and takes exactly the bytes left
+	for _, chunk := range []int{1, 3, 1 * KB} {
+		config := GetDefaultConfig()
+		config.BytesPerWorker = chunk
+		config.MaxBytes = int64(len("1\n22"))
+
+		p := NewProcessor(strings.NewReader("n\n1\n22"), &config)
+		sink := &memorySink{}
+		assert.Nil(t, p.Copy(sink), chunk)
+		assert.Equal(t, [][]string{{"1"}, {"22"}}, sink.rows, chunk)
+		assert.Equal(t, config.MaxBytes, p.Stats().BytesDispatched, chunk)
+
+		// one byte less leaves it out
+		config.MaxBytes--
+		sink = &memorySink{}
+		assert.Nil(t, NewProcessor(strings.NewReader("n\n1\n22"), &config).Copy(sink), chunk)
+		assert.Equal(t, [][]string{{"1"}}, sink.rows, chunk)
+	}
+}
+
+func TestSkipDataRows(t *testing.T) {
+	for _, chunk := range []int{1, 7, 64, 1024} {
+		config := GetDefaultConfig()
+		config.BytesPerWorker = chunk
+		config.SkipDataRows = 13
+		config.MaxRows = 5
+		trace := NewTrace()
+		config.Trace = trace
+
+		p := NewProcessor(strings.NewReader(numbers(100)), &config)
+		sink := &memorySink{}
+		assert.Nil(t, p.Copy(sink), chunk)
+		assert.Equal(t, [][]string{{"14"}, {"15"}, {"16"}, {"17"}, {"18"}}, sink.rows, chunk)
+
+		stats := p.Stats()
+		assert.Equal(t, int64(13), stats.RowsIgnored)
+		assert.Equal(t, int64(len(numbers(13))-len("n\n")), stats.BytesIgnored)
+		// the line numbers account for the rows ignored
+		assert.Equal(t, 15, trace.Chunks()[0].FirstLine, chunk)
+	}
+}
+
+func TestSkipDataRowsPastTheEnd(t *testing.T) {
+	config := GetDefaultConfig()
+	config.SkipDataRows = 5
+
+	// the last row has no line break
+	p := NewProcessor(strings.NewReader("n\n1\n2\n3"), &config)
+	sink := &memorySink{}
+	assert.Nil(t, p.Copy(sink))
+	assert.Empty(t, sink.rows)
+	assert.Equal(t, int64(3), p.Stats().RowsIgnored)
+}
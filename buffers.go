@@ -0,0 +1,88 @@
+package parallel_csv
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// sharedBuffer is a block buffer recycled once every holder has released it
+type sharedBuffer struct {
+	data []byte
+	refs int32
+	pool *sync.Pool
+	// size is the capacity of the buffers of the pool
+	size int
+}
+
+// newBuffer returns an empty buffer held by the caller. Buffers come from the pool
+// when Config.ReuseBuffers is set, otherwise they are simply allocated
+func (p processor) newBuffer() *sharedBuffer {
+	if p.pool == nil {
+		return &sharedBuffer{data: make([]byte, 0, p.config.BytesPerWorker), refs: 1}
+	}
+
+	b := p.pool.Get().(*sharedBuffer)
+	b.data = b.data[:0]
+	b.refs = 1
+	return b
+}
+
+func newBufferPool(size int) *sync.Pool {
+	pool := &sync.Pool{}
+	pool.New = func() interface{} {
+		return &sharedBuffer{data: make([]byte, 0, size), pool: pool, size: size}
+	}
+	return pool
+}
+
+func (b *sharedBuffer) retain() {
+	atomic.AddInt32(&b.refs, 1)
+}
+
+// release gives the buffer back to the pool after the last holder is done with it. A buffer
+// grown for a line longer than the chunks is dropped instead: reused, it would make the chunks
+// read into it longer, and their boundaries would depend on the buffers handed by the pool
+func (b *sharedBuffer) release() {
+	if atomic.AddInt32(&b.refs, -1) == 0 && b.pool != nil && cap(b.data) == b.size {
+		b.pool.Put(b)
+	}
+}
+
+// Retain keeps the rows of the chunk valid after the job returns when Config.ReuseBuffers is set.
+// The returned function must be called once the rows are no longer used
+func (c Chunk) Retain() (release func()) {
+	if c.buffer == nil || c.buffer.pool == nil {
+		return func() {}
+	}
+
+	c.buffer.retain()
+	once := sync.Once{}
+	return func() {
+		once.Do(c.buffer.release)
+	}
+}
+
+// bytesToString converts without copying, the string is only valid as long as b is not modified
+func bytesToString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return *(*string)(unsafe.Pointer(&b))
+}
+
+// stringToBytes converts without copying, the bytes must not be modified
+func stringToBytes(s string) []byte {
+	if s == "" {
+		return nil
+	}
+	return *(*[]byte)(unsafe.Pointer(&struct {
+		string
+		int
+	}{s, len(s)}))
+}
+
+// cloneString copies s so that it can outlive the buffer it points into
+func cloneString(s string) string {
+	return string([]byte(s))
+}
@@ -0,0 +1,86 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestReuseBuffersRetain(t *testing.T) {
+	content, err := os.ReadFile("testdata/mid.csv")
+	assert.Nil(t, err)
+	expected := strings.Split(strings.TrimSuffix(string(content), LineBreak), LineBreak)[1:]
+
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 4 * KB
+	config.ReuseBuffers = true
+	p := NewProcessor(strings.NewReader(string(content)), &config)
+
+	var mu sync.Mutex
+	var rows []string
+	var releases []func()
+	err = p.RunChunks(func(chunk Chunk) error {
+		release := chunk.Retain()
+
+		mu.Lock()
+		defer mu.Unlock()
+		rows = append(rows, chunk.Rows...)
+		releases = append(releases, release)
+		return nil
+	})
+	assert.Nil(t, err)
+
+	// retained rows are still intact after the run
+	sort.Strings(rows)
+	sort.Strings(expected)
+	assert.Equal(t, expected, rows)
+
+	for _, release := range releases {
+		release()
+	}
+}
+
+func TestSharedBufferRelease(t *testing.T) {
+	pool := newBufferPool(16)
+	b := pool.Get().(*sharedBuffer)
+	b.refs = 1
+
+	release := Chunk{buffer: b}.Retain()
+	b.release()
+	assert.Equal(t, int32(1), b.refs)
+
+	release()
+	release()
+	assert.Equal(t, int32(0), b.refs)
+}
+
+func TestReuseBuffersLongLine(t *testing.T) {
+	// a line longer than the chunks grows a buffer, which must not change the following chunks
+	input := "n\n1\n" + strings.Repeat("x", 100) + "\n" + strings.TrimPrefix(numbers(200), "n\n")
+	offsets := func(reuse bool) []int64 {
+		config := GetDefaultConfig()
+		config.NumberOfWorkers = 1
+		config.BytesPerWorker = 16
+		config.ReuseBuffers = reuse
+		p := NewProcessor(strings.NewReader(input), &config)
+
+		var mu sync.Mutex
+		var offsets []int64
+		assert.Nil(t, p.RunChunks(func(chunk Chunk) error {
+			mu.Lock()
+			defer mu.Unlock()
+			offsets = append(offsets, chunk.Offset)
+			return nil
+		}))
+		sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+		return offsets
+	}
+
+	expected := offsets(false)
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, expected, offsets(true))
+	}
+}
@@ -0,0 +1,169 @@
+package parallel_csv
+
+import (
+	"io"
+	"time"
+)
+
+// Builder configures a processor step by step, as an alternative to filling a Config:
+//
+//	p, err := From(r).Workers(16).Separator(';').NoHeader().Build()
+//
+// Errors, such as an invalid Where expression, are returned by Build
+type Builder struct {
+	reader io.Reader
+	config Config
+	err    error
+}
+
+// From starts building a processor reading r with the default config
+func From(r io.Reader) *Builder {
+	return &Builder{reader: r, config: GetDefaultConfig()}
+}
+
+// Config replaces the config built so far
+func (b *Builder) Config(config Config) *Builder {
+	b.config = config
+	return b
+}
+
+func (b *Builder) Workers(n int) *Builder {
+	b.config.NumberOfWorkers = n
+	return b
+}
+
+// ChunkSize sets the number of bytes read for each chunk, such as 10 * MB
+func (b *Builder) ChunkSize(bytes int) *Builder {
+	b.config.BytesPerWorker = bytes
+	return b
+}
+
+func (b *Builder) Separator(separator rune) *Builder {
+	b.config.HeaderConfig.Separator = string(separator)
+	return b
+}
+
+// NoHeader reads the first line as a row
+func (b *Builder) NoHeader() *Builder {
+	b.config.HeaderConfig.HasHeader = false
+	return b
+}
+
+// ValidateFieldCount checks that every row has as many fields as the header
+func (b *Builder) ValidateFieldCount() *Builder {
+	b.config.ValidateFieldCount = true
+	return b
+}
+
+// Strict rejects the rows which are not RFC 4180 compliant
+func (b *Builder) Strict() *Builder {
+	b.config.Strict = true
+	return b
+}
+
+// SkipErrors drops the offending rows and chunks instead of aborting, passing the errors to
+// handler when not nil
+func (b *Builder) SkipErrors(handler func(err error)) *Builder {
+	b.config.ErrorPolicy = SkipOnError
+	b.config.ErrorHandler = handler
+	return b
+}
+
+// Filter drops the raw rows for which filter returns false, before any other step
+func (b *Builder) Filter(filter func(row []byte) bool) *Builder {
+	b.config.Filter = filter
+	return b
+}
+
+// FilterRegex drops the rows whose column, or raw line when column is empty, does not match the
+// pattern. Several filters must all match
+func (b *Builder) FilterRegex(column string, pattern string) *Builder {
+	filter, err := FilterRegex(column, pattern)
+	if err != nil && b.err == nil {
+		b.err = err
+	}
+	if err == nil {
+		b.config.Regexps = append(b.config.Regexps, filter)
+	}
+	return b
+}
+
+// Where drops the rows not matching the expression
+func (b *Builder) Where(expr string) *Builder {
+	where, err := ParseWhere(expr)
+	if err != nil && b.err == nil {
+		b.err = err
+	}
+	b.config.Where = where
+	return b
+}
+
+// Transform adds transforms applied by Copy, after the ones added before
+func (b *Builder) Transform(transforms ...Transform) *Builder {
+	b.config.Transforms = append(b.config.Transforms, transforms...)
+	return b
+}
+
+// Compute adds the computed columns defined like `total = price * qty; year = substr(date, 0, 4)`
+// to the transforms
+func (b *Builder) Compute(definitions string) *Builder {
+	columns, err := ParseComputedColumns(definitions)
+	if err != nil && b.err == nil {
+		b.err = err
+	}
+	for _, column := range columns {
+		b.config.Transforms = append(b.config.Transforms, column)
+	}
+	return b
+}
+
+func (b *Builder) ReuseBuffers() *Builder {
+	b.config.ReuseBuffers = true
+	return b
+}
+
+func (b *Builder) MaxRowsPerSecond(n int) *Builder {
+	b.config.MaxRowsPerSecond = n
+	return b
+}
+
+func (b *Builder) Retry(policy RetryPolicy) *Builder {
+	b.config.Retry = policy
+	return b
+}
+
+// Checkpoint saves the progress of the runs to path every interval, DefaultCheckpointInterval
+// if 0
+func (b *Builder) Checkpoint(path string, interval time.Duration) *Builder {
+	b.config.CheckpointPath = path
+	b.config.CheckpointInterval = interval
+	return b
+}
+
+func (b *Builder) Parser(parser RecordParser) *Builder {
+	b.config.Parser = parser
+	return b
+}
+
+func (b *Builder) Logger(logger Logger) *Builder {
+	b.config.Logger = logger
+	return b
+}
+
+func (b *Builder) Trace(trace *Trace) *Builder {
+	b.config.Trace = trace
+	return b
+}
+
+// Build validates the config and creates the processor, reading the header if any
+func (b *Builder) Build() (Processor, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	config := b.config
+	p, err := newProcessor(b.reader, &config)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
@@ -0,0 +1,44 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	p, err := From(strings.NewReader("n;v\n1;a\n2;b\n3;c\n")).Workers(2).ChunkSize(4).Separator(';').
+		Where("n >= 2").Build()
+	assert.Nil(t, err)
+
+	config := p.GetConfig()
+	assert.Equal(t, 2, config.NumberOfWorkers)
+	assert.Equal(t, 4, config.BytesPerWorker)
+	assert.Equal(t, []string{"n", "v"}, p.GetHeader())
+
+	var rows int64
+	assert.Nil(t, p.Run(func(header []string, chunk []string) {
+		atomic.AddInt64(&rows, int64(len(chunk)))
+	}))
+	assert.Equal(t, int64(2), rows)
+
+	p, err = From(strings.NewReader("1;a\n")).Separator(';').NoHeader().Build()
+	assert.Nil(t, err)
+	assert.Equal(t, HeaderConfig{HasHeader: false, Separator: ";"}, p.GetConfig().HeaderConfig)
+}
+
+func TestBuilderErrors(t *testing.T) {
+	_, err := From(strings.NewReader("a\n1\n")).Workers(0).Build()
+	assert.ErrorIs(t, err, InvalidConfigError)
+
+	_, err = From(strings.NewReader("a\n1\n")).Where("a >").Build()
+	assert.NotNil(t, err)
+
+	_, err = From(strings.NewReader("a\n1\n")).Compute("b = a +").Build()
+	assert.ErrorIs(t, err, ExpressionSyntaxError)
+
+	p, err := From(nil).Build()
+	assert.Nil(t, p)
+	assert.ErrorIs(t, err, InvalidReaderError)
+}
@@ -0,0 +1,150 @@
+package parallel_csv
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultCheckpointInterval is used when Config.CheckpointInterval is not set
+const DefaultCheckpointInterval = 10 * time.Second
+
+// Checkpoint records how far a run got: every row before Offset has been processed
+type Checkpoint struct {
+	// Offset is the position in bytes where processing should resume, header included
+	Offset int64 `json:"offset"`
+	// Line is the source line number of the row at Offset
+	Line int `json:"line"`
+	// Rows counts the rows processed before Offset, including those of previous runs
+	Rows      int64     `json:"rows"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LoadCheckpoint reads a checkpoint file written by a previous run
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoint := &Checkpoint{}
+	if err := json.Unmarshal(content, checkpoint); err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+// save writes the checkpoint to a temporary file renamed over path, so that a crash never
+// leaves a truncated checkpoint behind
+func (c Checkpoint) save(path string) error {
+	content, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	file, err := createTemp(path)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(content)
+	return commitTemp(file, path, err)
+}
+
+// ResumeFrom makes the next run start where the checkpoint was taken. The input is sought to the
+// checkpoint offset if it is an io.Seeker, otherwise the bytes before it are read and discarded
+func (p *processor) ResumeFrom(checkpoint *Checkpoint) error {
+	return p.startFrom(checkpoint.Offset, checkpoint.Line, checkpoint.Rows)
+}
+
+// startFrom positions the input at offset, which must be at the beginning of a row
+func (p *processor) startFrom(offset int64, line int, rows int64) error {
+	if offset < p.headerBytes {
+		offset = p.headerBytes
+	}
+
+	if seeker, ok := p.source.(io.Seeker); ok {
+		if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		p.reader.Reset(p.source)
+	} else if _, err := io.CopyN(io.Discard, p.reader, offset-p.headerBytes); err != nil {
+		return err
+	}
+
+	p.start = Checkpoint{Offset: offset, Line: line, Rows: rows}
+	return nil
+}
+
+// completedChunk is the extent of a processed chunk
+type completedChunk struct {
+	end  int64
+	line int
+	rows int64
+}
+
+// progress follows the completion of chunks, which happens out of order, to find the longest
+// sequence of chunks processed since the start of the run
+type progress struct {
+	mu         sync.Mutex
+	next       int
+	completed  map[int]completedChunk
+	checkpoint Checkpoint
+}
+
+func newProgress(start Checkpoint) *progress {
+	return &progress{
+		completed:  map[int]completedChunk{},
+		checkpoint: start,
+	}
+}
+
+func (t *progress) complete(index int, chunk completedChunk) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.completed[index] = chunk
+	for {
+		chunk, ok := t.completed[t.next]
+		if !ok {
+			return
+		}
+		delete(t.completed, t.next)
+		t.next++
+
+		t.checkpoint.Offset = chunk.end
+		t.checkpoint.Line = chunk.line
+		t.checkpoint.Rows += chunk.rows
+	}
+}
+
+func (t *progress) snapshot() Checkpoint {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	checkpoint := t.checkpoint
+	checkpoint.UpdatedAt = time.Now()
+	return checkpoint
+}
+
+// saveCheckpoints writes a checkpoint every interval until stop is closed
+func (p processor) saveCheckpoints(state *runState, stop chan struct{}) {
+	interval := p.config.CheckpointInterval
+	if interval <= 0 {
+		interval = DefaultCheckpointInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := state.progress.snapshot().save(p.config.CheckpointPath); err != nil {
+				state.fail(err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package parallel_csv
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 16 * KB
+	config.CheckpointPath = path
+	p := NewProcessor(openFile("testdata/mid.csv"), &config)
+
+	err := p.Run(func(header []string, rows []string) {})
+	assert.Nil(t, err)
+
+	checkpoint, err := LoadCheckpoint(path)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(633344), checkpoint.Offset)
+	assert.Equal(t, 25002, checkpoint.Line)
+	assert.Equal(t, int64(25000), checkpoint.Rows)
+}
+
+func TestResumeFromCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 16 * KB
+	config.CheckpointPath = path
+
+	// the first run crashes on the sixth chunk
+	var mu sync.Mutex
+	seen := map[string]int{}
+	collect := func(chunk Chunk) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, row := range chunk.Rows {
+			seen[row]++
+		}
+	}
+
+	crash := errors.New("crash")
+	p := NewProcessor(openFile("testdata/mid.csv"), &config)
+	err := p.RunChunks(func(chunk Chunk) error {
+		if chunk.Index == 5 {
+			return crash
+		}
+		collect(chunk)
+		return nil
+	})
+	assert.ErrorIs(t, err, crash)
+
+	checkpoint, err := LoadCheckpoint(path)
+	assert.Nil(t, err)
+	assert.Less(t, checkpoint.Rows, int64(25000))
+
+	p = NewProcessor(openFile("testdata/mid.csv"), &config)
+	assert.Nil(t, p.ResumeFrom(checkpoint))
+
+	mismatches := 0
+	err = p.RunChunks(func(chunk Chunk) error {
+		for i, row := range chunk.Rows {
+			index, _ := strconv.Atoi(strings.Split(row, ",")[0])
+			if index+1 != chunk.Line(i) {
+				mismatches++
+			}
+		}
+		collect(chunk)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Zero(t, mismatches)
+	assert.Len(t, seen, 25000)
+
+	checkpoint, err = LoadCheckpoint(path)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(25000), checkpoint.Rows)
+}
+
+func TestResumeWithoutSeeker(t *testing.T) {
+	input := "a\n1\n2\n3\n"
+	p := NewProcessor(strings.NewReader(input), nil).(*processor)
+	p.source = nil
+	assert.Nil(t, p.ResumeFrom(&Checkpoint{Offset: 6, Line: 4, Rows: 2}))
+
+	var rows []string
+	err := p.Run(func(header []string, chunk []string) {
+		rows = append(rows, chunk...)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"3"}, rows)
+}
+
+func TestStartOffset(t *testing.T) {
+	input := "a,b\n1,x\n2,y\n3,z\n"
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 4
+	// the row "2,y"
+	config.StartOffset = 8
+
+	for _, reader := range []io.Reader{strings.NewReader(input), bytes.NewBufferString(input)} {
+		p := NewProcessor(reader, &config)
+		assert.Equal(t, []string{"a", "b"}, p.GetHeader())
+
+		sink := &memorySink{}
+		assert.Nil(t, p.Copy(sink))
+		assert.Equal(t, [][]string{{"2", "y"}, {"3", "z"}}, sink.rows)
+	}
+
+	// before the end of the header means right after it
+	config.StartOffset = 2
+	sink := &memorySink{}
+	assert.Nil(t, NewProcessor(strings.NewReader(input), &config).Copy(sink))
+	assert.Len(t, sink.rows, 3)
+}
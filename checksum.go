@@ -0,0 +1,110 @@
+package parallel_csv
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"io"
+)
+
+// ChecksumAlgorithm is the hash function of a ChecksumSink
+type ChecksumAlgorithm int
+
+const (
+	ChecksumSHA256 ChecksumAlgorithm = iota
+	ChecksumMD5
+)
+
+func (a ChecksumAlgorithm) new() hash.Hash {
+	if a == ChecksumMD5 {
+		return md5.New()
+	}
+	return sha256.New()
+}
+
+// ChecksumSink wraps a sink writing to an io.Writer and computes the checksum of the bytes it
+// writes, to be compared with the one of the file once transferred. With HashRows it also computes
+// the checksum of the rows themselves, which does not depend on the output format. The checksums
+// are complete once the sink is closed:
+//
+//	file, _ := os.Create("out.csv")
+//	sink := NewChecksumSink(ChecksumSHA256, file, func(w io.Writer) Sink { return NewCSVSink(w, ",") })
+//	err := p.Copy(sink)
+//	fmt.Println(sink.Sum(), sink.Bytes)
+type ChecksumSink struct {
+	Sink
+	// HashRows computes RowsSum, the rows being hashed field by field with their lengths
+	HashRows bool
+	// Bytes and Rows count what has been written
+	Bytes int64
+	Rows  int64
+	bytes hash.Hash
+	rows  hash.Hash
+}
+
+// NewChecksumSink creates the sink returned by newSink, writing to w through the checksum
+func NewChecksumSink(algorithm ChecksumAlgorithm, w io.Writer, newSink func(w io.Writer) Sink) *ChecksumSink {
+	s := &ChecksumSink{bytes: algorithm.new(), rows: algorithm.new()}
+	s.Sink = newSink(checksumWriter{w: w, sink: s})
+	return s
+}
+
+// checksumWriter hashes the bytes written to w
+type checksumWriter struct {
+	w    io.Writer
+	sink *ChecksumSink
+}
+
+func (c checksumWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.sink.bytes.Write(b[:n])
+	c.sink.Bytes += int64(n)
+	return n, err
+}
+
+func (s *ChecksumSink) Open(header []string) error {
+	if s.HashRows {
+		s.hashRow(header)
+	}
+	return s.Sink.Open(header)
+}
+
+func (s *ChecksumSink) Write(rows [][]string) error {
+	if err := s.Sink.Write(rows); err != nil {
+		return err
+	}
+	// a failed write may be retried, the rows are hashed once written
+	if s.HashRows {
+		for _, row := range rows {
+			s.hashRow(row)
+		}
+	}
+	s.Rows += int64(len(rows))
+	return nil
+}
+
+func (s *ChecksumSink) hashRow(row []string) {
+	var length [binary.MaxVarintLen64]byte
+	s.rows.Write(length[:binary.PutUvarint(length[:], uint64(len(row)))])
+	for _, field := range row {
+		s.rows.Write(length[:binary.PutUvarint(length[:], uint64(len(field)))])
+		io.WriteString(s.rows, field)
+	}
+}
+
+func (s *ChecksumSink) Abort(err error) {
+	abortSink(s.Sink, err)
+}
+
+// Sum returns the checksum of the bytes written, in hexadecimal
+func (s *ChecksumSink) Sum() string {
+	return hex.EncodeToString(s.bytes.Sum(nil))
+}
+
+// RowsSum returns the checksum of the header and the rows written when HashRows is set, in
+// hexadecimal
+func (s *ChecksumSink) RowsSum() string {
+	return hex.EncodeToString(s.rows.Sum(nil))
+}
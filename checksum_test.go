@@ -0,0 +1,43 @@
+package parallel_csv
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestChecksumSink(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 16
+
+	out := &bytes.Buffer{}
+	sink := NewChecksumSink(ChecksumSHA256, out, func(w io.Writer) Sink { return NewCSVSink(w, ",") })
+	sink.HashRows = true
+	assert.Nil(t, NewProcessor(strings.NewReader(customers), &config).Copy(sink))
+
+	sum := sha256.Sum256(out.Bytes())
+	assert.Equal(t, hex.EncodeToString(sum[:]), sink.Sum())
+	assert.Equal(t, int64(out.Len()), sink.Bytes)
+	assert.Equal(t, int64(3), sink.Rows)
+
+	// the checksum of the rows does not depend on the format
+	lines := NewChecksumSink(ChecksumSHA256, io.Discard, func(w io.Writer) Sink { return NewJSONLinesSink(w) })
+	lines.HashRows = true
+	assert.Nil(t, NewProcessor(strings.NewReader(customers), &config).Copy(lines))
+	assert.Equal(t, sink.RowsSum(), lines.RowsSum())
+	assert.NotEqual(t, sink.Sum(), lines.Sum())
+}
+
+func TestChecksumSinkMD5(t *testing.T) {
+	out := &bytes.Buffer{}
+	sink := NewChecksumSink(ChecksumMD5, out, func(w io.Writer) Sink { return NewCSVSink(w, ";") })
+	assert.Nil(t, NewProcessor(strings.NewReader(customers), nil).Copy(sink))
+
+	sum := md5.Sum(out.Bytes())
+	assert.Equal(t, hex.EncodeToString(sum[:]), sink.Sum())
+}
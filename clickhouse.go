@@ -0,0 +1,206 @@
+package parallel_csv
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// ClickHouseConn inserts a batch given column by column, each values[i] being a slice holding the
+// values of columns[i]. With clickhouse-go, whose connections are safe for concurrent use:
+//
+//	type clickHouseConn struct{ driver.Conn }
+//
+//	func (c clickHouseConn) InsertColumns(ctx context.Context, table string, columns []string, values []interface{}) error {
+//		batch, err := c.PrepareBatch(ctx, "INSERT INTO "+table+" ("+strings.Join(columns, ", ")+")")
+//		if err != nil {
+//			return err
+//		}
+//		for i, column := range values {
+//			if err := batch.Column(i).Append(column); err != nil {
+//				batch.Abort()
+//				return err
+//			}
+//		}
+//		return batch.Send()
+//	}
+type ClickHouseConn interface {
+	InsertColumns(ctx context.Context, table string, columns []string, values []interface{}) error
+}
+
+// ClickHouseSink inserts the rows into a ClickHouse table through the native protocol. Batches are
+// turned into columns by several goroutines, which insert them at the same time and not in source
+// order. String and untyped columns are []string, the other columns of the schema are []int64,
+// []float64, []bool or []time.Time when required, and a slice of pointers, nil for empty values,
+// when not
+type ClickHouseSink struct {
+	// Table is the name of the table, used verbatim in the statement
+	Table string
+	// Columns are the table columns receiving the fields of the rows, the header if empty
+	Columns []string
+	// Schema converts the fields to the types of its columns
+	Schema *Schema
+	// BatchRows is the number of rows of an insert, DefaultBatchRows if 0
+	BatchRows int
+	// Inserts is the number of inserts running at once, 1 if 0
+	Inserts int
+	ctx     context.Context
+	conn    ClickHouseConn
+	columns []string
+	// kinds holds the schema column of each field, the zero value for untyped ones
+	kinds  []compiledColumn
+	types  typedRow
+	loader *parallelLoader
+	rows   int64
+}
+
+// NewClickHouseSink creates a sink inserting the rows into table with conn
+func NewClickHouseSink(ctx context.Context, conn ClickHouseConn, table string) *ClickHouseSink {
+	return &ClickHouseSink{Table: table, ctx: ctx, conn: conn}
+}
+
+func (s *ClickHouseSink) Open(header []string) error {
+	s.columns = s.Columns
+	if len(s.columns) == 0 {
+		s.columns = header
+	}
+	if len(s.columns) == 0 {
+		return fmt.Errorf("clickhouse sink for %s needs the columns of files without header", s.Table)
+	}
+
+	s.kinds = make([]compiledColumn, len(s.columns))
+	if s.Schema != nil {
+		columns, err := compileColumns(header, *s.Schema)
+		if err != nil {
+			return err
+		}
+		for _, column := range columns {
+			if column.index < len(s.kinds) {
+				s.kinds[column.index] = column
+			}
+		}
+	}
+
+	var err error
+	if s.types, err = bindTypes(header, s.Schema); err != nil {
+		return err
+	}
+	inserts := s.Inserts
+	if inserts <= 0 {
+		inserts = 1
+	}
+	s.loader = newParallelLoader(inserts, s.BatchRows, s.insert)
+	return nil
+}
+
+func (s *ClickHouseSink) Write(rows [][]string) error {
+	for _, row := range rows {
+		if len(row) != len(s.columns) {
+			return fmt.Errorf("%w: %d fields for %d columns", FieldCountError, len(row), len(s.columns))
+		}
+		values, err := s.types(row)
+		if err != nil {
+			return err
+		}
+		if err := s.loader.add(values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close inserts the last batch and waits for every insert to end, the connection is left open
+func (s *ClickHouseSink) Close() error {
+	if s.loader == nil {
+		return nil
+	}
+	return s.loader.close()
+}
+
+// Rows returns the number of rows inserted so far
+func (s *ClickHouseSink) Rows() int64 {
+	return atomic.LoadInt64(&s.rows)
+}
+
+func (s *ClickHouseSink) insert(worker int, batch [][]interface{}) error {
+	values := make([]interface{}, len(s.columns))
+	for i, kind := range s.kinds {
+		values[i] = kind.column(batch, i)
+	}
+
+	if err := s.conn.InsertColumns(s.ctx, s.Table, s.columns, values); err != nil {
+		return fmt.Errorf("insert into %s: %w", s.Table, err)
+	}
+	atomic.AddInt64(&s.rows, int64(len(batch)))
+	return nil
+}
+
+// column gathers the values of field i of the batch in a slice of the column type
+func (c compiledColumn) column(batch [][]interface{}, i int) interface{} {
+	switch {
+	case c.Type == IntegerType && c.Required:
+		column := make([]int64, len(batch))
+		for j, row := range batch {
+			column[j], _ = row[i].(int64)
+		}
+		return column
+	case c.Type == IntegerType:
+		column := make([]*int64, len(batch))
+		for j, row := range batch {
+			if v, ok := row[i].(int64); ok {
+				column[j] = &v
+			}
+		}
+		return column
+	case c.Type == FloatType && c.Required:
+		column := make([]float64, len(batch))
+		for j, row := range batch {
+			column[j], _ = row[i].(float64)
+		}
+		return column
+	case c.Type == FloatType:
+		column := make([]*float64, len(batch))
+		for j, row := range batch {
+			if v, ok := row[i].(float64); ok {
+				column[j] = &v
+			}
+		}
+		return column
+	case c.Type == BooleanType && c.Required:
+		column := make([]bool, len(batch))
+		for j, row := range batch {
+			column[j], _ = row[i].(bool)
+		}
+		return column
+	case c.Type == BooleanType:
+		column := make([]*bool, len(batch))
+		for j, row := range batch {
+			if v, ok := row[i].(bool); ok {
+				column[j] = &v
+			}
+		}
+		return column
+	case c.Type == TimeType && c.Required:
+		column := make([]time.Time, len(batch))
+		for j, row := range batch {
+			column[j], _ = row[i].(time.Time)
+		}
+		return column
+	case c.Type == TimeType:
+		column := make([]*time.Time, len(batch))
+		for j, row := range batch {
+			if v, ok := row[i].(time.Time); ok {
+				column[j] = &v
+			}
+		}
+		return column
+	default:
+		// strings and untyped values, empty for nil
+		column := make([]string, len(batch))
+		for j, row := range batch {
+			column[j], _ = row[i].(string)
+		}
+		return column
+	}
+}
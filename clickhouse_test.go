@@ -0,0 +1,66 @@
+package parallel_csv
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClickHouse keeps the columns inserted
+type fakeClickHouse struct {
+	mu      sync.Mutex
+	columns []string
+	inserts [][]interface{}
+}
+
+func (c *fakeClickHouse) InsertColumns(ctx context.Context, table string, columns []string, values []interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.columns = columns
+	c.inserts = append(c.inserts, values)
+	return nil
+}
+
+func TestClickHouseSink(t *testing.T) {
+	conn := &fakeClickHouse{}
+	sink := NewClickHouseSink(context.Background(), conn, "numbers")
+	sink.BatchRows = 10
+	sink.Inserts = 4
+
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 64
+	assert.Nil(t, NewProcessor(strings.NewReader(numbers(95)), &config).Copy(sink))
+	assert.Equal(t, int64(95), sink.Rows())
+	assert.Len(t, conn.inserts, 10)
+
+	total := 0
+	for _, insert := range conn.inserts {
+		total += len(insert[0].([]string))
+	}
+	assert.Equal(t, 95, total)
+}
+
+func TestClickHouseSinkColumns(t *testing.T) {
+	conn := &fakeClickHouse{}
+	sink := NewClickHouseSink(context.Background(), conn, "events")
+	sink.Schema = &Schema{Columns: []ColumnSchema{
+		{Name: "id", Type: IntegerType, Required: true},
+		{Name: "score", Type: FloatType},
+		{Name: "at", Type: TimeType},
+	}}
+
+	input := "id,score,at,name\n1,0.5,2021-01-02T03:04:05Z,a\n2,,2021-01-03T00:00:00Z,\n"
+	assert.Nil(t, NewProcessor(strings.NewReader(input), nil).Copy(sink))
+	assert.Equal(t, []string{"id", "score", "at", "name"}, conn.columns)
+
+	insert := conn.inserts[0]
+	assert.Equal(t, []int64{1, 2}, insert[0])
+	scores := insert[1].([]*float64)
+	assert.Equal(t, 0.5, *scores[0])
+	assert.Nil(t, scores[1])
+	assert.Equal(t, time.Date(2021, 1, 3, 0, 0, 0, 0, time.UTC), *insert[2].([]*time.Time)[1])
+	assert.Equal(t, []string{"a", ""}, insert[3])
+}
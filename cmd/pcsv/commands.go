@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	pcsv "github.com/jacopoRufini/parallel-csv"
+)
+
+func stats(c *command) error {
+	config := c.config()
+	switch c.histogram {
+	case "none":
+	case "width":
+		config.Histogram = pcsv.EquiWidthHistogram
+	case "depth":
+		config.Histogram = pcsv.EquiDepthHistogram
+	default:
+		return fmt.Errorf("unknown histogram %q", c.histogram)
+	}
+	config.HistogramBins = c.bins
+	return c.single(config, func(p pcsv.Processor, out io.Writer) error {
+		report, err := p.Profile()
+		if err != nil {
+			return err
+		}
+		return c.writeJSON(out, report)
+	})
+}
+
+func quality(c *command) error {
+	config := pcsv.QualityConfig{}
+	var err error
+	if config.Schema, err = c.loadSchema(); err != nil {
+		return err
+	}
+	if config.Rules, err = c.loadRules(); err != nil {
+		return err
+	}
+	if c.keys != "" {
+		config.KeyColumns = strings.Split(c.keys, ",")
+	}
+	if c.format != "json" && c.format != "html" {
+		return fmt.Errorf("unknown format %q", c.format)
+	}
+
+	return c.single(c.config(), func(p pcsv.Processor, out io.Writer) error {
+		report, err := p.Quality(config)
+		if err != nil {
+			return err
+		}
+		if c.format == "html" {
+			return report.WriteHTML(out)
+		}
+		return report.WriteJSON(out)
+	})
+}
+
+// loadSchema reads the -schema file, if any
+func (c *command) loadSchema() (*pcsv.Schema, error) {
+	if c.schema == "" {
+		return nil, nil
+	}
+	content, err := os.ReadFile(c.schema)
+	if err != nil {
+		return nil, err
+	}
+	schema := &pcsv.Schema{}
+	if err := json.Unmarshal(content, schema); err != nil {
+		return nil, fmt.Errorf("%s: %w", c.schema, err)
+	}
+	return schema, nil
+}
+
+// loadRules reads the -rules file, if any
+func (c *command) loadRules() (pcsv.Rules, error) {
+	if c.rules == "" {
+		return nil, nil
+	}
+	content, err := os.ReadFile(c.rules)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := pcsv.ParseRules(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.rules, err)
+	}
+	return rules, nil
+}
+
+func validate(c *command) error {
+	if (c.schema == "") == (c.rules == "") {
+		return errors.New("either -schema or -rules is required")
+	}
+
+	var check func(p pcsv.Processor) (*pcsv.ValidationReport, error)
+	if c.schema != "" {
+		schema, err := c.loadSchema()
+		if err != nil {
+			return err
+		}
+		check = func(p pcsv.Processor) (*pcsv.ValidationReport, error) {
+			return p.Validate(*schema)
+		}
+	} else {
+		rules, err := c.loadRules()
+		if err != nil {
+			return err
+		}
+		check = func(p pcsv.Processor) (*pcsv.ValidationReport, error) {
+			return p.ValidateRules(rules)
+		}
+	}
+
+	return c.single(c.config(), func(p pcsv.Processor, out io.Writer) error {
+		report, err := check(p)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "rows: %d, invalid rows: %d\n", report.Rows, report.InvalidRows)
+		for _, v := range report.Samples {
+			fmt.Fprintf(out, "line %d, column %s: %v (%q)\n", v.Line, v.Column, v.Err, v.Value)
+		}
+		if !report.Valid() {
+			return errInvalid
+		}
+		return nil
+	})
+}
+
+func filter(c *command) error {
+	if c.where == "" && c.match == "" {
+		return errors.New("-where or -match is required")
+	}
+
+	config := c.config()
+	if c.where != "" {
+		where, err := pcsv.ParseWhere(c.where)
+		if err != nil {
+			return err
+		}
+		config.Where = where
+	}
+	if c.match != "" {
+		regex, err := pcsv.FilterRegex(c.column, c.match)
+		if err != nil {
+			return err
+		}
+		regex.Invert = c.invert
+		config.Regexps = []pcsv.RegexFilter{regex}
+	}
+	return c.single(config, func(p pcsv.Processor, out io.Writer) error {
+		return p.Copy(pcsv.NewCSVSink(out, c.sep))
+	})
+}
+
+func convert(c *command) error {
+	config := c.config()
+	if c.fields != "" {
+		config.Transforms = append(config.Transforms, pcsv.ColumnMapping{Ranges: c.fields})
+	}
+	if c.compute != "" {
+		columns, err := pcsv.ParseComputedColumns(c.compute)
+		if err != nil {
+			return err
+		}
+		for _, column := range columns {
+			config.Transforms = append(config.Transforms, column)
+		}
+	}
+	var quoting pcsv.QuotingPolicy
+	switch c.quote {
+	case "minimal":
+	case "all":
+		quoting = pcsv.QuoteAll
+	case "nonnumeric":
+		quoting = pcsv.QuoteNonNumeric
+	default:
+		return fmt.Errorf("unknown quoting %q", c.quote)
+	}
+	sep := c.toSep
+	if sep == "" {
+		sep = c.sep
+	}
+
+	if c.append {
+		if c.output == "" || c.format != "csv" {
+			return errors.New("-append needs -o and the csv format")
+		}
+		sink := pcsv.NewAppendSink(c.output, sep)
+		sink.Quoting, sink.QuoteChar = quoting, c.quoteBy
+		return c.input(config, func(p pcsv.Processor) error {
+			return p.Copy(sink)
+		})
+	}
+
+	return c.single(config, func(p pcsv.Processor, out io.Writer) error {
+		switch c.format {
+		case "csv":
+			sink := pcsv.NewCSVSink(out, sep)
+			sink.Quoting, sink.QuoteChar = quoting, c.quoteBy
+			return p.Copy(sink)
+		case "jsonl":
+			return p.Copy(pcsv.NewJSONLinesSink(out))
+		default:
+			return fmt.Errorf("unknown format %q", c.format)
+		}
+	})
+}
+
+func split(c *command) error {
+	if c.rows < 0 || c.maxBytes < 0 {
+		return errors.New("-rows and -bytes cannot be negative")
+	}
+	if c.rows == 0 && c.maxBytes == 0 {
+		return errors.New("-rows or -bytes is required")
+	}
+	return c.input(c.config(), func(p pcsv.Processor) error {
+		return p.Copy(pcsv.NewShardedSink(c.prefix, c.sep, c.rows, c.maxBytes))
+	})
+}
+
+func merge(c *command) error {
+	out, closeOutput, err := c.out()
+	if err != nil {
+		return err
+	}
+
+	sink := &mergeSink{CSVSink: pcsv.NewCSVSink(out, c.sep)}
+	for _, file := range c.files {
+		if err = mergeFile(c, file, sink); err != nil {
+			break
+		}
+	}
+
+	if flushErr := sink.CSVSink.Close(); err == nil {
+		err = flushErr
+	}
+	return closeOutput(err)
+}
+
+func mergeFile(c *command, file string, sink *mergeSink) error {
+	p, closeInput, err := c.processor(file, c.config())
+	if err == nil {
+		defer closeInput()
+		err = p.Copy(sink)
+	}
+
+	// empty files have nothing to merge
+	if errors.Is(err, pcsv.EmptyFileError) || errors.Is(err, pcsv.HeaderNotFoundError) {
+		return nil
+	}
+	return err
+}
+
+// mergeSink writes the header of the first file only and stays open across files
+type mergeSink struct {
+	*pcsv.CSVSink
+	header []string
+	opened bool
+}
+
+func (s *mergeSink) Open(header []string) error {
+	if !s.opened {
+		s.opened, s.header = true, header
+		return s.CSVSink.Open(header)
+	}
+	if len(header) != len(s.header) {
+		return fmt.Errorf("header %v does not match %v", header, s.header)
+	}
+	for i := range header {
+		if header[i] != s.header[i] {
+			return fmt.Errorf("header %v does not match %v", header, s.header)
+		}
+	}
+	return nil
+}
+
+// Close is a no-op, the sink is flushed once every file has been merged
+func (s *mergeSink) Close() error {
+	return nil
+}
@@ -0,0 +1,325 @@
+// Command pcsv exposes the parallel csv processor on the command line.
+//
+// Usage:
+//
+//	pcsv <command> [flags] [file...]
+//
+// Commands:
+//
+//	stats     print the statistics of every column as JSON
+//	quality   print a data-quality report as JSON or HTML
+//	validate  check the rows against a JSON schema or YAML rules, exits with status 1 if any is invalid
+//	filter    print the rows matching a where expression or a regular expression
+//	convert   change the separator or the quoting, select or add columns, or convert to JSON lines
+//	split     split a file in parts with at most -rows rows or -bytes bytes each
+//	merge     concatenate files sharing the same header
+//
+// Files default to the standard input, results go to the standard output unless -o is given.
+// The -o file is replaced only once the command succeeds.
+// -trace saves the boundaries of the chunks processed as JSON, to debug rows missing near them.
+// -cpuprofile and -memprofile save the profiles of the run, to be read by go tool pprof.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	pcsv "github.com/jacopoRufini/parallel-csv"
+)
+
+const usage = `usage: pcsv <command> [flags] [file...]
+
+commands: stats, quality, validate, filter, convert, split, merge
+run pcsv <command> -h for the flags of a command
+`
+
+// errInvalid makes the command exit with status 1 without printing anything else
+var errInvalid = errors.New("invalid rows found")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes a command and returns the exit status
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	commands := map[string]func(*command) error{
+		"stats":    stats,
+		"quality":  quality,
+		"validate": validate,
+		"filter":   filter,
+		"convert":  convert,
+		"split":    split,
+		"merge":    merge,
+	}
+	fn, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n%s", args[0], usage)
+		return 2
+	}
+
+	c := newCommand(args[0], stdin, stdout, stderr)
+	c.flags.SetOutput(stderr)
+	if err := c.parse(args[1:]); err != nil {
+		return 2
+	}
+
+	err := fn(c)
+	// the trace is most useful when the run failed
+	if traceErr := c.writeTrace(); err == nil {
+		err = traceErr
+	}
+	if errors.Is(err, errInvalid) {
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "pcsv %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+// command holds the flags shared by every command and the extra ones it defines
+type command struct {
+	flags    *flag.FlagSet
+	workers  int
+	chunk    int
+	sep      string
+	noHeader bool
+	// blank divides the fields by runs of spaces and tabs instead of sep
+	blank  bool
+	output string
+	// trace is the file receiving the chunks processed as JSON, tracer records them
+	trace  string
+	tracer *pcsv.Trace
+
+	schema   string
+	rules    string
+	where    string
+	toSep    string
+	quote    string
+	quoteBy  string
+	format   string
+	keys     string
+	rows     int
+	prefix   string
+	files    []string
+	stdin    io.Reader
+	stdout   io.Writer
+	stderr   io.Writer
+	defaults pcsv.Config
+
+	// histogram is the kind of histograms of stats, made of bins
+	histogram string
+	bins      int
+	// match is the regular expression of filter, applied to column or the whole line
+	match  string
+	column string
+	invert bool
+	// compute defines the columns added by convert
+	compute string
+	// fields selects the columns of convert by position, like cut -f
+	fields string
+	// append adds the rows of convert to the end of -o, header excluded
+	append bool
+	// maxBytes is the maximum size of the parts of split
+	maxBytes int64
+	// cpuProfile and heapProfile receive the pprof profiles of the run
+	cpuProfile  string
+	heapProfile string
+}
+
+func newCommand(name string, stdin io.Reader, stdout io.Writer, stderr io.Writer) *command {
+	c := &command{
+		flags:    flag.NewFlagSet("pcsv "+name, flag.ContinueOnError),
+		stdin:    stdin,
+		stdout:   stdout,
+		stderr:   stderr,
+		defaults: pcsv.GetDefaultConfig(),
+	}
+
+	c.flags.IntVar(&c.workers, "workers", c.defaults.NumberOfWorkers, "number of workers")
+	c.flags.IntVar(&c.chunk, "chunk", c.defaults.BytesPerWorker, "bytes read per chunk")
+	c.flags.StringVar(&c.sep, "sep", c.defaults.HeaderConfig.Separator, "field separator")
+	c.flags.BoolVar(&c.noHeader, "no-header", false, "the input has no header line")
+	c.flags.BoolVar(&c.blank, "whitespace", false, "input fields are divided by runs of spaces and tabs, -sep being the output separator")
+	c.flags.StringVar(&c.output, "o", "", "output file, the standard output if empty")
+	c.flags.StringVar(&c.trace, "trace", "", "file receiving the boundaries of the chunks processed as JSON")
+	c.flags.StringVar(&c.cpuProfile, "cpuprofile", "", "file receiving the CPU profile of the run")
+	c.flags.StringVar(&c.heapProfile, "memprofile", "", "file receiving the heap profile taken at the end of the run")
+
+	switch name {
+	case "stats":
+		c.flags.StringVar(&c.histogram, "histogram", "none", "histograms of numeric and time columns: none, width or depth")
+		c.flags.IntVar(&c.bins, "bins", pcsv.DefaultHistogramBins, "number of bins of the histograms")
+	case "validate":
+		c.flags.StringVar(&c.schema, "schema", "", "JSON schema file")
+		c.flags.StringVar(&c.rules, "rules", "", "YAML rules file, such as \"amount: range(0, 1e6)\"")
+	case "quality":
+		c.flags.StringVar(&c.schema, "schema", "", "JSON schema file")
+		c.flags.StringVar(&c.rules, "rules", "", "YAML rules file")
+		c.flags.StringVar(&c.keys, "keys", "", "comma separated key columns whose duplicates are counted")
+		c.flags.StringVar(&c.format, "format", "json", "output format: json or html")
+	case "filter":
+		c.flags.StringVar(&c.where, "where", "", "where expression, such as \"age > 30 AND country = 'IT'\"")
+		c.flags.StringVar(&c.match, "match", "", "regular expression the rows must match, like grep")
+		c.flags.StringVar(&c.column, "column", "", "column matched by -match, the whole line if empty")
+		c.flags.BoolVar(&c.invert, "invert", false, "print the rows not matching -match instead")
+	case "convert":
+		c.flags.StringVar(&c.toSep, "to-sep", "", "separator of the output, the input one if empty")
+		c.flags.StringVar(&c.format, "format", "csv", "output format: csv or jsonl")
+		c.flags.StringVar(&c.fields, "fields", "", "columns kept by position, like cut: \"1-5, 8, -1\"")
+		c.flags.StringVar(&c.compute, "compute", "", "computed columns, such as \"total = price * qty; year = substr(date, 0, 4)\"")
+		c.flags.StringVar(&c.quote, "quote", "minimal", "fields quoted in csv: minimal, all or nonnumeric")
+		c.flags.StringVar(&c.quoteBy, "quote-char", pcsv.Quote, "character quoting the csv fields")
+		c.flags.BoolVar(&c.append, "append", false, "append the csv rows to -o, writing the header only if it is empty")
+	case "split":
+		c.flags.IntVar(&c.rows, "rows", 100000, "maximum number of rows per part, 0 for no limit")
+		c.flags.Int64Var(&c.maxBytes, "bytes", 0, "maximum size of a part in bytes, header included, 0 for no limit")
+		c.flags.StringVar(&c.prefix, "prefix", "part-", "prefix of the part files, numbered from 000001")
+	}
+	return c
+}
+
+func (c *command) parse(args []string) error {
+	if err := c.flags.Parse(args); err != nil {
+		return err
+	}
+	c.files = c.flags.Args()
+	if len(c.files) == 0 {
+		c.files = []string{"-"}
+	}
+	return nil
+}
+
+func (c *command) config() *pcsv.Config {
+	config := c.defaults
+	config.NumberOfWorkers = c.workers
+	config.BytesPerWorker = c.chunk
+	config.HeaderConfig = pcsv.HeaderConfig{
+		HasHeader: !c.noHeader,
+		Separator: c.sep,
+	}
+	if c.blank {
+		config.Parser = pcsv.WhitespaceParser{}
+	}
+	config.CPUProfile, config.HeapProfile = c.cpuProfile, c.heapProfile
+	if c.trace != "" {
+		if c.tracer == nil {
+			c.tracer = pcsv.NewTrace()
+		}
+		config.Trace = c.tracer
+	}
+	return &config
+}
+
+// writeTrace dumps the chunks recorded, if asked to
+func (c *command) writeTrace() error {
+	if c.tracer == nil {
+		return nil
+	}
+	f, err := os.Create(c.trace)
+	if err != nil {
+		return err
+	}
+	err = c.tracer.WriteJSON(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// processor opens the input file, "-" being the standard input
+func (c *command) processor(file string, config *pcsv.Config) (p pcsv.Processor, closer func(), err error) {
+	var input io.Reader = c.stdin
+	closer = func() {}
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		input, closer = f, func() { f.Close() }
+	}
+
+	// NewProcessor panics on invalid input
+	defer func() {
+		if r := recover(); r != nil {
+			closer()
+			p, err = nil, fmt.Errorf("%s: %v", file, r)
+			if cause, ok := r.(error); ok {
+				err = fmt.Errorf("%s: %w", file, cause)
+			}
+		}
+	}()
+	return pcsv.NewProcessor(input, config), closer, nil
+}
+
+// out opens the output file, the standard output if none has been given. The file is written
+// under a temporary name, renamed by closeOutput unless the command failed with err
+func (c *command) out() (out io.Writer, closeOutput func(err error) error, err error) {
+	if c.output == "" {
+		return c.stdout, func(err error) error { return err }, nil
+	}
+	f, err := os.CreateTemp(filepath.Dir(c.output), filepath.Base(c.output)+".tmp-")
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, func(err error) error {
+		// the report of invalid rows is a complete output
+		failed := err != nil && !errors.Is(err, errInvalid)
+		closeErr := f.Close()
+		if !failed && closeErr == nil {
+			closeErr = os.Chmod(f.Name(), 0o644)
+		}
+		if !failed && closeErr == nil {
+			closeErr = os.Rename(f.Name(), c.output)
+		}
+		if failed || closeErr != nil {
+			os.Remove(f.Name())
+		}
+		if err == nil {
+			err = closeErr
+		}
+		return err
+	}, nil
+}
+
+// input runs fn on the only input file
+func (c *command) input(config *pcsv.Config, fn func(p pcsv.Processor) error) error {
+	if len(c.files) > 1 {
+		return fmt.Errorf("expected a single input file, found %d", len(c.files))
+	}
+
+	p, closeInput, err := c.processor(c.files[0], config)
+	if err != nil {
+		return err
+	}
+	defer closeInput()
+	return fn(p)
+}
+
+// single runs fn on the only input file and the output
+func (c *command) single(config *pcsv.Config, fn func(p pcsv.Processor, out io.Writer) error) error {
+	return c.input(config, func(p pcsv.Processor) error {
+		out, closeOutput, err := c.out()
+		if err != nil {
+			return err
+		}
+		return closeOutput(fn(p, out))
+	})
+}
+
+func (c *command) writeJSON(out io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
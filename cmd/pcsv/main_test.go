@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const people = "name,age,country\nanna,34,IT\nbob,28,FR\ncarla,41,IT\n"
+
+func execute(t *testing.T, input string, args ...string) (int, string, string) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run(args, strings.NewReader(input), stdout, stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestFilter(t *testing.T) {
+	code, out, _ := execute(t, people, "filter", "-chunk", "8", "-where", "country = 'IT'")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "name,age,country\nanna,34,IT\ncarla,41,IT\n", out)
+}
+
+func TestFilterMatch(t *testing.T) {
+	code, out, _ := execute(t, people, "filter", "-match", "^[ab]", "-column", "name", "-where", "age > 30")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "name,age,country\nanna,34,IT\n", out)
+
+	code, out, _ = execute(t, people, "filter", "-match", "IT$", "-invert")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "name,age,country\nbob,28,FR\n", out)
+}
+
+func TestFilterWhitespace(t *testing.T) {
+	code, out, _ := execute(t, "name  age\nanna   34\nbob\t28\n", "filter", "-whitespace", "-where", "age > 30")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "name,age\nanna,34\n", out)
+}
+
+func TestFilterTrace(t *testing.T) {
+	trace := filepath.Join(t.TempDir(), "trace.json")
+	code, _, _ := execute(t, people, "filter", "-chunk", "12", "-trace", trace, "-where", "country = 'IT'")
+	assert.Equal(t, 0, code)
+
+	data, err := os.ReadFile(trace)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"first_line": 2`)
+	assert.Contains(t, string(data), `"last_line": 4`)
+}
+
+func TestConvert(t *testing.T) {
+	code, out, _ := execute(t, people, "convert", "-to-sep", ";")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "name;age;country\nanna;34;IT\nbob;28;FR\ncarla;41;IT\n", out)
+
+	code, out, _ = execute(t, "a;b\n1;2\n", "convert", "-sep", ";", "-format", "jsonl")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "{\"a\":\"1\",\"b\":\"2\"}\n", out)
+
+	code, out, _ = execute(t, "a,b\nx,2\n", "convert", "-quote", "nonnumeric", "-quote-char", "'")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "'a','b'\n'x',2\n", out)
+
+	code, _, _ = execute(t, people, "convert", "-quote", "some")
+	assert.Equal(t, 1, code)
+
+	code, out, _ = execute(t, people, "convert", "-compute", "age = age + 1; initial = upper(substr(name, 0, 1))")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "name,age,country,initial\nanna,35,IT,A\nbob,29,FR,B\ncarla,42,IT,C\n", out)
+}
+
+func TestConvertFields(t *testing.T) {
+	code, out, errOut := execute(t, people, "convert", "-fields", "-1, 1")
+	assert.Equal(t, 0, code, errOut)
+	assert.Equal(t, "country,name\nIT,anna\nFR,bob\nIT,carla\n", out)
+}
+
+func TestConvertAppend(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "all.csv")
+
+	code, _, errOut := execute(t, "name,age,country\nanna,34,IT\n", "convert", "-append", "-o", output)
+	assert.Equal(t, 0, code, errOut)
+	code, _, errOut = execute(t, "name,age,country\nbob,28,FR\ncarla,41,IT\n", "convert", "-append", "-o", output)
+	assert.Equal(t, 0, code, errOut)
+
+	content, _ := os.ReadFile(output)
+	assert.Equal(t, people, string(content))
+}
+
+func TestOutputReplacedOnSuccess(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "out.csv")
+	assert.Nil(t, os.WriteFile(output, []byte("old\n"), 0o644))
+
+	code, _, _ := execute(t, people, "filter", "-where", "missing = 1", "-o", output)
+	assert.Equal(t, 1, code)
+	content, _ := os.ReadFile(output)
+	assert.Equal(t, "old\n", string(content))
+
+	code, _, errOut := execute(t, people, "filter", "-where", "age < 30", "-o", output)
+	assert.Equal(t, 0, code, errOut)
+	content, _ = os.ReadFile(output)
+	assert.Equal(t, "name,age,country\nbob,28,FR\n", string(content))
+
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 1)
+}
+
+func TestStats(t *testing.T) {
+	code, out, _ := execute(t, people, "stats")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, `"name": "age"`)
+	assert.NotContains(t, out, `"histogram"`)
+
+	code, out, _ = execute(t, people, "stats", "-histogram", "depth", "-bins", "2")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, `"kind": "equi-depth"`)
+}
+
+func TestQuality(t *testing.T) {
+	code, out, _ := execute(t, people+"anna,,IT\n", "quality", "-keys", "name")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, `"duplicate_keys": 1`)
+
+	code, out, _ = execute(t, people, "quality", "-format", "html")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "<td>country</td>")
+}
+
+func TestValidate(t *testing.T) {
+	schema := filepath.Join(t.TempDir(), "schema.json")
+	assert.Nil(t, os.WriteFile(schema, []byte(`{"columns":[{"name":"age","type":"integer"}]}`), 0o644))
+
+	code, out, _ := execute(t, people, "validate", "-schema", schema)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "rows: 3, invalid rows: 0\n", out)
+
+	code, out, _ = execute(t, "name,age\nanna,old\n", "validate", "-schema", schema)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, out, "invalid rows: 1")
+}
+
+func TestValidateRules(t *testing.T) {
+	rules := filepath.Join(t.TempDir(), "rules.yaml")
+	assert.Nil(t, os.WriteFile(rules, []byte("age: range(30, 40)\ncountry: enum(IT, FR)\n"), 0o644))
+
+	code, out, _ := execute(t, people, "validate", "-rules", rules)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "rows: 3, invalid rows: 2\nline 3, column age: value is out of range (\"28\")\nline 4, column age: value is out of range (\"41\")\n", out)
+
+	code, _, errOut := execute(t, people, "validate")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "either -schema or -rules")
+}
+
+func TestSplitAndMerge(t *testing.T) {
+	dir := t.TempDir()
+	prefix := filepath.Join(dir, "part-")
+
+	code, _, errOut := execute(t, people, "split", "-rows", "2", "-chunk", "8", "-prefix", prefix)
+	assert.Equal(t, 0, code, errOut)
+
+	first, _ := os.ReadFile(prefix + "000001.csv")
+	second, _ := os.ReadFile(prefix + "000002.csv")
+	assert.Equal(t, "name,age,country\nanna,34,IT\nbob,28,FR\n", string(first))
+	assert.Equal(t, "name,age,country\ncarla,41,IT\n", string(second))
+
+	code, out, errOut := execute(t, "", "merge", prefix+"000001.csv", prefix+"000002.csv")
+	assert.Equal(t, 0, code, errOut)
+	assert.Equal(t, people, out)
+}
+
+func TestSplitBytes(t *testing.T) {
+	prefix := filepath.Join(t.TempDir(), "part-")
+
+	code, _, errOut := execute(t, people, "split", "-rows", "0", "-bytes", "30", "-prefix", prefix)
+	assert.Equal(t, 0, code, errOut)
+
+	first, _ := os.ReadFile(prefix + "000001.csv")
+	second, _ := os.ReadFile(prefix + "000002.csv")
+	assert.Equal(t, "name,age,country\nanna,34,IT\n", string(first))
+	assert.Equal(t, "name,age,country\nbob,28,FR\n", string(second))
+
+	code, _, errOut = execute(t, people, "split", "-rows", "0")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "-rows or -bytes")
+}
+
+func TestMergeHeaderMismatch(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.csv"), filepath.Join(dir, "b.csv")
+	assert.Nil(t, os.WriteFile(a, []byte("x,y\n1,2\n"), 0o644))
+	assert.Nil(t, os.WriteFile(b, []byte("x,z\n3,4\n"), 0o644))
+
+	code, _, errOut := execute(t, "", "merge", a, b)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "does not match")
+}
+
+func TestUnknownCommand(t *testing.T) {
+	code, _, errOut := execute(t, "", "explode")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, errOut, "unknown command")
+}
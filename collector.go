@@ -0,0 +1,99 @@
+package parallel_csv
+
+import (
+	"sort"
+	"sync"
+)
+
+// Collector gathers the results produced by the jobs of RunChunks. Each worker appends to its own
+// shard, chosen by Chunk.Worker, so that workers never wait for each other, and the shards are
+// merged in source order by Items:
+//
+//	collector := NewCollector[Order](config.NumberOfWorkers)
+//	err := p.RunChunks(func(chunk Chunk) error {
+//		for _, row := range chunk.Rows {
+//			collector.Add(chunk, parseOrder(row))
+//		}
+//		return nil
+//	})
+//	orders := collector.Items()
+type Collector[T any] struct {
+	shards []collectorShard[T]
+}
+
+// collectorShard holds the results of a worker, grouped by chunk. Its lock is only contended
+// when a collector is shared by several runs at once
+type collectorShard[T any] struct {
+	mu      sync.Mutex
+	batches []collectedBatch[T]
+}
+
+type collectedBatch[T any] struct {
+	index int
+	items []T
+}
+
+// NewCollector creates a collector with a shard per worker, workers being the NumberOfWorkers
+// of the config
+func NewCollector[T any](workers int) *Collector[T] {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Collector[T]{shards: make([]collectorShard[T], workers)}
+}
+
+// Add appends items to the results of chunk. It can be called by several jobs at once. The items
+// added by a job which then fails are kept, even if the job is retried
+func (c *Collector[T]) Add(chunk Chunk, items ...T) {
+	shard := &c.shards[chunk.Worker%len(c.shards)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if n := len(shard.batches); n > 0 && shard.batches[n-1].index == chunk.Index {
+		shard.batches[n-1].items = append(shard.batches[n-1].items, items...)
+		return
+	}
+	shard.batches = append(shard.batches, collectedBatch[T]{index: chunk.Index, items: append([]T(nil), items...)})
+}
+
+// Len returns the number of items collected
+func (c *Collector[T]) Len() int {
+	n := 0
+	for i := range c.shards {
+		c.shards[i].mu.Lock()
+		for _, batch := range c.shards[i].batches {
+			n += len(batch.items)
+		}
+		c.shards[i].mu.Unlock()
+	}
+	return n
+}
+
+// Items merges the shards, returning the items of the chunks in source order, and of each chunk
+// in the order they were added. It is meant to be called once the run is over
+func (c *Collector[T]) Items() []T {
+	var batches []collectedBatch[T]
+	for i := range c.shards {
+		c.shards[i].mu.Lock()
+		batches = append(batches, c.shards[i].batches...)
+		c.shards[i].mu.Unlock()
+	}
+	sort.SliceStable(batches, func(i, j int) bool {
+		return batches[i].index < batches[j].index
+	})
+
+	items := make([]T, 0, c.Len())
+	for _, batch := range batches {
+		items = append(items, batch.items...)
+	}
+	return items
+}
+
+// Reset drops the items collected, so that the collector can be used for another run
+func (c *Collector[T]) Reset() {
+	for i := range c.shards {
+		c.shards[i].mu.Lock()
+		c.shards[i].batches = nil
+		c.shards[i].mu.Unlock()
+	}
+}
@@ -0,0 +1,48 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCollector(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 16
+	config.NumberOfWorkers = 4
+	p := NewProcessor(strings.NewReader(numbers(1000)), &config)
+
+	collector := NewCollector[int](config.NumberOfWorkers)
+	err := p.RunChunks(func(chunk Chunk) error {
+		for _, row := range chunk.Rows {
+			n, err := strconv.Atoi(row)
+			if err != nil {
+				return err
+			}
+			if n%2 == 0 {
+				collector.Add(chunk, n)
+			}
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+
+	assert.Equal(t, 500, collector.Len())
+	items := collector.Items()
+	for i, n := range items {
+		assert.Equal(t, 2*(i+1), n)
+	}
+
+	collector.Reset()
+	assert.Empty(t, collector.Items())
+}
+
+func TestCollectorOrder(t *testing.T) {
+	collector := NewCollector[string](2)
+	collector.Add(Chunk{Index: 1, Worker: 0}, "c")
+	collector.Add(Chunk{Index: 0, Worker: 1}, "a")
+	collector.Add(Chunk{Index: 2, Worker: 5}, "d", "e")
+	collector.Add(Chunk{Index: 0, Worker: 1}, "b")
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, collector.Items())
+}
@@ -0,0 +1,331 @@
+package parallel_csv
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const InvalidConfigError = Error("invalid config")
+
+// Validate checks that the config can run a processor. The error lists every problem found
+func (c *Config) Validate() error {
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.NumberOfWorkers < 1 {
+		problem("NumberOfWorkers must be at least 1, got %d", c.NumberOfWorkers)
+	}
+	if c.BytesPerWorker < 1 {
+		problem("BytesPerWorker must be at least 1, got %d: use a size such as 10 * MB", c.BytesPerWorker)
+	}
+
+	// a custom parser handles the separator itself
+	if separator := c.HeaderConfig.Separator; c.Parser == nil {
+		switch {
+		case separator == "":
+			problem("HeaderConfig.Separator is empty, use \",\" for comma separated values")
+		case strings.Contains(separator, Quote):
+			problem("HeaderConfig.Separator %q cannot contain the quote character %s", separator, Quote)
+		case strings.ContainsAny(separator, "\r\n"):
+			problem("HeaderConfig.Separator %q cannot contain a line break", separator)
+		}
+	}
+
+	if c.ErrorPolicy != AbortOnError && c.ErrorPolicy != SkipOnError {
+		problem("ErrorPolicy %d is unknown, use AbortOnError or SkipOnError", c.ErrorPolicy)
+	}
+	if c.CheckpointInterval < 0 {
+		problem("CheckpointInterval cannot be negative, got %s", c.CheckpointInterval)
+	}
+	if c.MaxRows < 0 || c.MaxBytes < 0 {
+		problem("MaxRows and MaxBytes cannot be negative: use 0 for no limit")
+	}
+	if c.SkipDataRows < 0 {
+		problem("SkipDataRows cannot be negative, got %d", c.SkipDataRows)
+	}
+	if c.StartOffset < 0 {
+		problem("StartOffset cannot be negative, got %d", c.StartOffset)
+	}
+	if c.QueueSampleInterval < 0 {
+		problem("QueueSampleInterval cannot be negative, got %s", c.QueueSampleInterval)
+	}
+	if c.MaxRowsPerSecond < 0 {
+		problem("MaxRowsPerSecond cannot be negative, got %d: use 0 for no limit", c.MaxRowsPerSecond)
+	}
+	if c.HistogramBins < 0 {
+		problem("HistogramBins cannot be negative, got %d: use 0 for %d bins", c.HistogramBins, DefaultHistogramBins)
+	}
+	if c.Retry.Attempts < 0 || c.Retry.Backoff < 0 || c.Retry.MaxBackoff < 0 {
+		problem("Retry cannot have negative attempts or backoff")
+	}
+	if c.Redispatch < 0 {
+		problem("Redispatch cannot be negative, got %d", c.Redispatch)
+	}
+	if c.Redispatch > 0 && !c.Idempotent {
+		problem("Redispatch needs Idempotent, jobs run again on a chunk must not repeat its effects")
+	}
+
+	levels := []LogLevel{c.LogLevels.Run, c.LogLevels.Chunk, c.LogLevels.Retry, c.LogLevels.Skip, c.LogLevels.Error}
+	for _, level := range levels {
+		if level < LogDefault || level > LogOff {
+			problem("LogLevels holds the unknown level %d", level)
+			break
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", InvalidConfigError, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// configKeys set the fields of a config from the keys of LoadConfig and ConfigFromEnv
+var configKeys = map[string]func(c *Config, value string) error{
+	"workers": func(c *Config, value string) (err error) {
+		c.NumberOfWorkers, err = strconv.Atoi(value)
+		return err
+	},
+	"bytes_per_worker": func(c *Config, value string) (err error) {
+		c.BytesPerWorker, err = ParseSize(value)
+		return err
+	},
+	"has_header": func(c *Config, value string) (err error) {
+		c.HeaderConfig.HasHeader, err = strconv.ParseBool(value)
+		return err
+	},
+	"auto_header": func(c *Config, value string) (err error) {
+		c.AutoHeader, err = strconv.ParseBool(value)
+		return err
+	},
+	"generate_header": func(c *Config, value string) (err error) {
+		c.HeaderConfig.Generate, err = strconv.ParseBool(value)
+		return err
+	},
+	"separator": func(c *Config, value string) error {
+		// a tab is hard to write in an environment variable
+		if value == `\t` {
+			value = "\t"
+		}
+		c.HeaderConfig.Separator = value
+		return nil
+	},
+	"validate_field_count": func(c *Config, value string) (err error) {
+		c.ValidateFieldCount, err = strconv.ParseBool(value)
+		return err
+	},
+	"error_policy": func(c *Config, value string) error {
+		switch strings.ToLower(value) {
+		case "abort":
+			c.ErrorPolicy = AbortOnError
+		case "skip":
+			c.ErrorPolicy = SkipOnError
+		default:
+			return fmt.Errorf("expected abort or skip, got %q", value)
+		}
+		return nil
+	},
+	"reuse_buffers": func(c *Config, value string) (err error) {
+		c.ReuseBuffers, err = strconv.ParseBool(value)
+		return err
+	},
+	"strict": func(c *Config, value string) (err error) {
+		c.Strict, err = strconv.ParseBool(value)
+		return err
+	},
+	"checkpoint_path": func(c *Config, value string) error {
+		c.CheckpointPath = value
+		return nil
+	},
+	"checkpoint_interval": func(c *Config, value string) (err error) {
+		c.CheckpointInterval, err = time.ParseDuration(value)
+		return err
+	},
+	"start_offset": func(c *Config, value string) (err error) {
+		c.StartOffset, err = strconv.ParseInt(value, 10, 64)
+		return err
+	},
+	"cpu_profile": func(c *Config, value string) error {
+		c.CPUProfile = value
+		return nil
+	},
+	"heap_profile": func(c *Config, value string) error {
+		c.HeapProfile = value
+		return nil
+	},
+	"where": func(c *Config, value string) (err error) {
+		c.Where, err = ParseWhere(value)
+		return err
+	},
+	"computed": func(c *Config, value string) error {
+		columns, err := ParseComputedColumns(value)
+		for _, column := range columns {
+			c.Transforms = append(c.Transforms, column)
+		}
+		return err
+	},
+	"spill_dir": func(c *Config, value string) error {
+		c.SpillDir = value
+		return nil
+	},
+	"max_rows_per_second": func(c *Config, value string) (err error) {
+		c.MaxRowsPerSecond, err = strconv.Atoi(value)
+		return err
+	},
+	"skip_data_rows": func(c *Config, value string) (err error) {
+		c.SkipDataRows, err = strconv.Atoi(value)
+		return err
+	},
+	"max_rows": func(c *Config, value string) (err error) {
+		c.MaxRows, err = strconv.ParseInt(value, 10, 64)
+		return err
+	},
+	"max_bytes": func(c *Config, value string) error {
+		size, err := ParseSize(value)
+		c.MaxBytes = int64(size)
+		return err
+	},
+	"retry_attempts": func(c *Config, value string) (err error) {
+		c.Retry.Attempts, err = strconv.Atoi(value)
+		return err
+	},
+	"retry_backoff": func(c *Config, value string) (err error) {
+		c.Retry.Backoff, err = time.ParseDuration(value)
+		return err
+	},
+	"retry_max_backoff": func(c *Config, value string) (err error) {
+		c.Retry.MaxBackoff, err = time.ParseDuration(value)
+		return err
+	},
+	"idempotent": func(c *Config, value string) (err error) {
+		c.Idempotent, err = strconv.ParseBool(value)
+		return err
+	},
+	"redispatch": func(c *Config, value string) (err error) {
+		c.Redispatch, err = strconv.Atoi(value)
+		return err
+	},
+}
+
+// LoadConfig reads a config from a JSON or YAML file mapping keys to values, such as:
+//
+//	workers: 4
+//	bytes_per_worker: 64MB
+//	separator: ";"
+//	error_policy: skip
+//	retry_attempts: 3
+//	retry_backoff: 500ms
+//	computed: total = price * qty; year = substr(date, 0, 4)
+//
+// The keys are workers, bytes_per_worker, has_header, auto_header, generate_header, separator,
+// validate_field_count, error_policy, reuse_buffers, strict, checkpoint_path,
+// checkpoint_interval, start_offset, skip_data_rows, cpu_profile, heap_profile, where, computed,
+// spill_dir, max_rows, max_bytes, max_rows_per_second, retry_attempts, retry_backoff,
+// retry_max_backoff, idempotent and redispatch. The missing ones keep the value of GetDefaultConfig.
+// Computed columns, parsed by ParseComputedColumns, are added to the transforms
+func LoadConfig(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	document := yaml.Node{}
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", InvalidConfigError, path, err)
+	}
+	config := GetDefaultConfig()
+	if len(document.Content) == 0 {
+		return &config, nil
+	}
+
+	mapping := document.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%w: %s: line %d: expected a mapping of keys to values", InvalidConfigError, path, mapping.Line)
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key, value := mapping.Content[i], mapping.Content[i+1]
+		if value.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("%w: %s: line %d: %s must be a single value", InvalidConfigError, path, value.Line, key.Value)
+		}
+		if err := config.set(key.Value, value.Value); err != nil {
+			return nil, fmt.Errorf("%s: line %d: %w", path, key.Line, err)
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// ConfigFromEnv reads a config from the environment variables named like the keys of LoadConfig
+// in upper case, after prefix and an underscore: PCSV_WORKERS or PCSV_BYTES_PER_WORKER for
+// prefix PCSV. The missing ones keep the value of GetDefaultConfig
+func ConfigFromEnv(prefix string) (*Config, error) {
+	config := GetDefaultConfig()
+	keys := make([]string, 0, len(configKeys))
+	for key := range configKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		name := strings.ToUpper(key)
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+		if value, ok := os.LookupEnv(name); ok {
+			if err := config.set(key, value); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// set sets the field of key from its text
+func (c *Config) set(key string, value string) error {
+	setter, ok := configKeys[key]
+	if !ok {
+		return fmt.Errorf("%w: unknown key %s", InvalidConfigError, key)
+	}
+	if err := setter(c, strings.TrimSpace(value)); err != nil {
+		return fmt.Errorf("%w: %s: %v", InvalidConfigError, key, err)
+	}
+	return nil
+}
+
+// ParseSize parses a number of bytes such as "512", "64KB", "10 MB" or "1GB". Units are powers
+// of 1024 and case insensitive
+func ParseSize(size string) (int, error) {
+	text := strings.ToUpper(strings.TrimSpace(size))
+	unit := 1
+	for _, suffix := range []struct {
+		name string
+		size int
+	}{{"KB", KB}, {"MB", MB}, {"GB", GB}, {"TB", TB}, {"B", 1}} {
+		if strings.HasSuffix(text, suffix.name) {
+			text, unit = strings.TrimSpace(strings.TrimSuffix(text, suffix.name)), suffix.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(text, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	if n*float64(unit) > math.MaxInt {
+		return 0, fmt.Errorf("size %q is too large", size)
+	}
+	return int(n * float64(unit)), nil
+}
@@ -0,0 +1,114 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(t *testing.T) {
+	config := GetDefaultConfig()
+	assert.Nil(t, config.Validate())
+
+	config.NumberOfWorkers = 0
+	config.BytesPerWorker = -1
+	config.HeaderConfig.Separator = Quote
+	config.MaxRowsPerSecond = -5
+	err := config.Validate()
+	assert.ErrorIs(t, err, InvalidConfigError)
+	assert.Equal(t, "invalid config: NumberOfWorkers must be at least 1, got 0; "+
+		"BytesPerWorker must be at least 1, got -1: use a size such as 10 * MB; "+
+		"HeaderConfig.Separator \"\\\"\" cannot contain the quote character \"; "+
+		"MaxRowsPerSecond cannot be negative, got -5: use 0 for no limit", err.Error())
+
+	config = GetDefaultConfig()
+	config.HeaderConfig.Separator = ""
+	assert.ErrorIs(t, config.Validate(), InvalidConfigError)
+	config.Parser = CSVParser{Separator: ";"}
+	assert.Nil(t, config.Validate())
+
+	config.LogLevels.Chunk = LogOff + 1
+	assert.ErrorIs(t, config.Validate(), InvalidConfigError)
+
+	config = GetDefaultConfig()
+	config.Redispatch = 2
+	assert.ErrorIs(t, config.Validate(), InvalidConfigError)
+	config.Idempotent = true
+	assert.Nil(t, config.Validate())
+}
+
+func TestNewProcessorInvalidConfig(t *testing.T) {
+	config := GetDefaultConfig()
+	config.NumberOfWorkers = -1
+	_, err := newProcessor(strings.NewReader("a\n1\n"), &config)
+	assert.ErrorIs(t, err, InvalidConfigError)
+
+	assert.Panics(t, func() { NewProcessor(strings.NewReader("a\n1\n"), &config) })
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	assert.Nil(t, os.WriteFile(path, []byte("workers: 4\nbytes_per_worker: 64MB\nseparator: ';'\n"+
+		"error_policy: skip\nwhere: amount > 10\nretry_attempts: 3\nretry_backoff: 500ms\n"+
+		"computed: total = price * qty; year = substr(date, 0, 4)\n"), 0o644))
+
+	config, err := LoadConfig(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, config.NumberOfWorkers)
+	assert.Equal(t, 64*MB, config.BytesPerWorker)
+	assert.Equal(t, ";", config.HeaderConfig.Separator)
+	assert.True(t, config.HeaderConfig.HasHeader)
+	assert.Equal(t, SkipOnError, config.ErrorPolicy)
+	assert.NotNil(t, config.Where)
+	assert.Len(t, config.Transforms, 2)
+	assert.Equal(t, RetryPolicy{Attempts: 3, Backoff: 500 * time.Millisecond}, config.Retry)
+
+	path = filepath.Join(dir, "config.json")
+	assert.Nil(t, os.WriteFile(path, []byte(`{"workers": 2, "has_header": false}`), 0o644))
+	config, err = LoadConfig(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, config.NumberOfWorkers)
+	assert.False(t, config.HeaderConfig.HasHeader)
+
+	assert.Nil(t, os.WriteFile(path, []byte("workers: 2\nbytes: 1KB\n"), 0o644))
+	_, err = LoadConfig(path)
+	assert.ErrorIs(t, err, InvalidConfigError)
+	assert.Contains(t, err.Error(), "line 2: invalid config: unknown key bytes")
+
+	assert.Nil(t, os.WriteFile(path, []byte("workers: 0\n"), 0o644))
+	_, err = LoadConfig(path)
+	assert.ErrorIs(t, err, InvalidConfigError)
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("PCSV_WORKERS", "3")
+	t.Setenv("PCSV_BYTES_PER_WORKER", "1.5 kb")
+	t.Setenv("PCSV_SEPARATOR", `\t`)
+	t.Setenv("WORKERS", "5")
+
+	config, err := ConfigFromEnv("PCSV")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, config.NumberOfWorkers)
+	assert.Equal(t, 1536, config.BytesPerWorker)
+	assert.Equal(t, "\t", config.HeaderConfig.Separator)
+
+	t.Setenv("PCSV_ERROR_POLICY", "ignore")
+	_, err = ConfigFromEnv("PCSV")
+	assert.EqualError(t, err, `PCSV_ERROR_POLICY: invalid config: error_policy: expected abort or skip, got "ignore"`)
+}
+
+func TestParseSize(t *testing.T) {
+	for text, size := range map[string]int{"512": 512, "512B": 512, "64KB": 64 * KB, "10 MB": 10 * MB, "1gb": GB} {
+		parsed, err := ParseSize(text)
+		assert.Nil(t, err)
+		assert.Equal(t, size, parsed, text)
+	}
+	for _, text := range []string{"", "MB", "-1KB", "ten"} {
+		_, err := ParseSize(text)
+		assert.NotNil(t, err, text)
+	}
+}
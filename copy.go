@@ -0,0 +1,54 @@
+package parallel_csv
+
+import "strconv"
+
+// Copy writes every row delivered by the processor to the sink, in source order, after
+// applying Config.Transforms and prepending Config.RowNumber. Rows rejected by validation, not
+// matching Config.Where or dropped by a transform are left out. A transform failing on a row
+// fails its chunk
+func (p processor) Copy(sink Sink) error {
+	header, transform, err := bindTransforms(p.header, p.config.Transforms)
+	if err != nil {
+		return err
+	}
+
+	numbering := p.config.RowNumber
+	if numbering != NoRowNumber {
+		column := p.config.RowNumberColumn
+		if column == "" {
+			column = numbering.defaultColumn()
+		}
+		// files without header keep having none
+		if len(header) > 0 {
+			header = append([]string{column}, header...)
+		}
+		if numbering == RowNumberSequence {
+			sink = &numberingSink{Sink: sink}
+		}
+	}
+
+	return p.runSink(sink, header, func(chunk Chunk) ([][]string, error) {
+		rows := make([][]string, 0, len(chunk.Rows))
+		for i, row := range chunk.Rows {
+			if chunk.Cancelled() {
+				return nil, errCancelled
+			}
+			fields, err := transform(p.split(row))
+			if err != nil {
+				return nil, &ParseError{Line: chunk.Line(i), Err: err}
+			}
+			if fields == nil {
+				continue
+			}
+			switch numbering {
+			case RowNumberLine:
+				fields = append([]string{strconv.Itoa(chunk.Line(i))}, fields...)
+			case RowNumberSequence:
+				// the number is set once the row is written
+				fields = append([]string{""}, fields...)
+			}
+			rows = append(rows, fields)
+		}
+		return rows, nil
+	})
+}
@@ -0,0 +1,127 @@
+package parallel_csv
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCopy(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 8
+	p := NewProcessor(strings.NewReader(customers), &config)
+
+	out := &bytes.Buffer{}
+	assert.Nil(t, p.Copy(NewCSVSink(out, ";")))
+	assert.Equal(t, "id;name;country\nc1;anna;IT\nc2;bob, jr;FR\nc3;carla;DE\n", out.String())
+}
+
+func TestCopyWhere(t *testing.T) {
+	config := GetDefaultConfig()
+	config.Where = MustParseWhere("amount >= 20")
+	p := NewProcessor(strings.NewReader(orders), &config)
+
+	sink := &memorySink{}
+	assert.Nil(t, p.Copy(sink))
+	assert.Equal(t, []string{"order", "customer", "amount"}, sink.header)
+	assert.Equal(t, [][]string{{"2", "c2", "20"}, {"3", "c9", "30"}, {"4", "c1", "40"}}, sink.rows)
+	assert.True(t, sink.closed)
+}
+
+func TestJSONLinesSink(t *testing.T) {
+	p := NewProcessor(strings.NewReader(customers), nil)
+
+	out := &bytes.Buffer{}
+	assert.Nil(t, p.Copy(NewJSONLinesSink(out)))
+	assert.Equal(t, `{"id":"c1","name":"anna","country":"IT"}
+{"id":"c2","name":"bob, jr","country":"FR"}
+{"id":"c3","name":"carla","country":"DE"}
+`, out.String())
+}
+
+func TestJSONLinesSinkWithoutHeader(t *testing.T) {
+	config := GetDefaultConfig()
+	config.HeaderConfig.HasHeader = false
+	p := NewProcessor(strings.NewReader("a,\"b\"\"c\"\n"), &config)
+
+	out := &bytes.Buffer{}
+	assert.Nil(t, p.Copy(NewJSONLinesSink(out)))
+	assert.Equal(t, `{"col_1":"a","col_2":"b\"c"}`+"\n", out.String())
+}
+
+func TestTransformed(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 8
+	p := NewProcessor(strings.NewReader(customers), &config)
+
+	out, err := io.ReadAll(p.Transformed(func(fields []string) ([]string, error) {
+		fields[1] = strings.ToUpper(fields[1])
+		return fields, nil
+	}))
+	assert.Nil(t, err)
+	assert.Equal(t, "id,name,country\nc1,ANNA,IT\nc2,\"BOB, JR\",FR\nc3,CARLA,DE\n", string(out))
+}
+
+func TestTransformedError(t *testing.T) {
+	p := NewProcessor(strings.NewReader(customers), nil)
+
+	_, err := io.ReadAll(p.Transformed(func(fields []string) ([]string, error) {
+		if fields[0] == "c2" {
+			return nil, errors.New("bad customer")
+		}
+		return fields, nil
+	}))
+	parseErr := &ParseError{}
+	assert.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, 3, parseErr.Line)
+}
+
+func TestTransformedClose(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 64
+	p := NewProcessor(strings.NewReader(numbers(100000)), &config)
+
+	r := p.Transformed(nil)
+	line := make([]byte, 2)
+	_, err := io.ReadFull(r, line)
+	assert.Nil(t, err)
+	assert.Equal(t, "n\n", string(line))
+	assert.Nil(t, r.Close())
+}
+
+func TestCopyRowNumber(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 8
+	config.Where = MustParseWhere("amount >= 20")
+	config.RowNumber = RowNumberSequence
+
+	sink := &memorySink{}
+	assert.Nil(t, NewProcessor(strings.NewReader(orders), &config).Copy(sink))
+	assert.Equal(t, []string{"row_number", "order", "customer", "amount"}, sink.header)
+	assert.Equal(t, [][]string{{"1", "2", "c2", "20"}, {"2", "3", "c9", "30"}, {"3", "4", "c1", "40"}}, sink.rows)
+
+	config.RowNumber = RowNumberLine
+	config.RowNumberColumn = "source_line"
+	sink = &memorySink{}
+	assert.Nil(t, NewProcessor(strings.NewReader(orders), &config).Copy(sink))
+	assert.Equal(t, []string{"source_line", "order", "customer", "amount"}, sink.header)
+	assert.Equal(t, [][]string{{"3", "2", "c2", "20"}, {"4", "3", "c9", "30"}, {"5", "4", "c1", "40"}}, sink.rows)
+}
+
+func TestCopyRowNumberRetried(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 4
+	config.RowNumber = RowNumberSequence
+	config.Retry = RetryPolicy{Attempts: 2}
+
+	sink := &flakySink{failures: 1}
+	assert.Nil(t, NewProcessor(strings.NewReader(numbers(20)), &config).Copy(sink))
+	assert.Len(t, sink.rows, 20)
+	for i, row := range sink.rows {
+		assert.Equal(t, []string{strconv.Itoa(i + 1), strconv.Itoa(i + 1)}, row)
+	}
+}
@@ -0,0 +1,63 @@
+package parallel_csv
+
+import (
+	"io"
+	"strings"
+	"sync"
+)
+
+// DeadLetterColumn is the name of the column appended to the rows written to Config.DeadLetter
+const DeadLetterColumn = "error"
+
+// deadLetter writes the rejected rows verbatim, followed by a column with the reason
+type deadLetter struct {
+	mu        sync.Mutex
+	w         io.Writer
+	header    []string
+	separator string
+	started   bool
+}
+
+func newDeadLetter(w io.Writer, header []string, separator string) *deadLetter {
+	return &deadLetter{
+		w:         w,
+		header:    header,
+		separator: separator,
+	}
+}
+
+// write appends a rejected row, the header goes first if the file has one
+func (d *deadLetter) write(row string, reason error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	b := strings.Builder{}
+	if !d.started && len(d.header) > 0 {
+		for _, column := range d.header {
+			b.WriteString(quoteField(column, d.separator))
+			b.WriteString(d.separator)
+		}
+		b.WriteString(DeadLetterColumn)
+		b.WriteString(LineBreak)
+	}
+	d.started = true
+
+	b.WriteString(row)
+	b.WriteString(d.separator)
+	b.WriteString(quoteField(reason.Error(), d.separator))
+	b.WriteString(LineBreak)
+
+	_, err := io.WriteString(d.w, b.String())
+	return err
+}
+
+// reject sends an invalid row to the dead letter, if any. A failing write is handled by the
+// error policy like any other error
+func (p processor) reject(state *runState, row string, reason error) {
+	if p.deadLetter == nil {
+		return
+	}
+	if err := p.deadLetter.write(row, reason); err != nil {
+		state.fail(err)
+	}
+}
@@ -0,0 +1,40 @@
+package parallel_csv
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestDeadLetter(t *testing.T) {
+	deadLetter := &bytes.Buffer{}
+	config := malformedConfig(SkipOnError, nil)
+	config.DeadLetter = deadLetter
+	p := NewProcessor(strings.NewReader(malformed), config)
+
+	err := p.Run(func(header []string, rows []string) {})
+	assert.Nil(t, err)
+	assert.Equal(t, "a,b,error\n3,line 3: wrong number of fields\n6,7,8,line 5: wrong number of fields\n", deadLetter.String())
+}
+
+func TestDeadLetterSchema(t *testing.T) {
+	deadLetter := &bytes.Buffer{}
+	config := GetDefaultConfig()
+	config.DeadLetter = deadLetter
+	p := NewProcessor(strings.NewReader("id,name\n1,anna\nx,\n"), &config)
+
+	_, err := p.Validate(Schema{Columns: []ColumnSchema{
+		{Name: "id", Type: IntegerType},
+		{Name: "name", Required: true},
+	}})
+	assert.Nil(t, err)
+	assert.Equal(t, "id,name,error\nx,,id: value has the wrong type; name: value is required\n", deadLetter.String())
+}
+
+func TestQuoteField(t *testing.T) {
+	assert.Equal(t, "plain", quoteField("plain", ","))
+	assert.Equal(t, `"a,b"`, quoteField("a,b", ","))
+	assert.Equal(t, `"say ""hi"""`, quoteField(`say "hi"`, ","))
+	assert.Equal(t, "a,b", quoteField("a,b", ";"))
+}
@@ -0,0 +1,236 @@
+package parallel_csv
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// dedupeBatch is the number of rows written to the sink at once by Dedupe
+const dedupeBatch = 1024
+
+type keepStrategy int
+
+const (
+	keepFirst keepStrategy = iota
+	keepLast
+	keepMax
+)
+
+// Keep decides which row Dedupe keeps among those sharing a key
+type Keep struct {
+	strategy keepStrategy
+	column   string
+}
+
+// KeepFirst keeps the row appearing first in the file
+func KeepFirst() Keep {
+	return Keep{strategy: keepFirst}
+}
+
+// KeepLast keeps the row appearing last in the file
+func KeepLast() Keep {
+	return Keep{strategy: keepLast}
+}
+
+// KeepMax keeps the row with the greatest value in column, compared as numbers when both values
+// are numbers and as strings otherwise. Ties are won by the first row
+func KeepMax(column string) Keep {
+	return Keep{strategy: keepMax, column: column}
+}
+
+// dedupeRow is the row currently kept for a key
+type dedupeRow struct {
+	row   string
+	line  int
+	value string
+}
+
+// wins tells whether row should replace the kept one
+func (k Keep) wins(row dedupeRow, kept dedupeRow) bool {
+	switch k.strategy {
+	case keepLast:
+		return row.line > kept.line
+	case keepMax:
+		c := compareValues(row.value, kept.value)
+		return c > 0 || c == 0 && row.line < kept.line
+	default:
+		return row.line < kept.line
+	}
+}
+
+// dedupeRows maps each key to its kept row
+type dedupeRows map[string]dedupeRow
+
+func (d dedupeRows) add(keep Keep, key string, row dedupeRow) {
+	if kept, ok := d[key]; !ok || keep.wins(row, kept) {
+		d[key] = row
+	}
+}
+
+// sorted returns the kept rows in source order
+func (d dedupeRows) sorted() []dedupeRow {
+	rows := make([]dedupeRow, 0, len(d))
+	for _, row := range d {
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].line < rows[j].line
+	})
+	return rows
+}
+
+// Dedupe writes to the sink a single row for each key, chosen by keep, in source order. Each
+// worker keeps the rows it has chosen in its own table, tables are merged at the end. When
+// Config.SpillDir is set rows are partitioned by key in temporary files instead and deduplicated
+// one partition at a time, so that large keyspaces do not need to fit in memory
+func (p processor) Dedupe(keyColumns []string, keep Keep, sink Sink) error {
+	keyIndexes, err := p.keyIndexes(keyColumns)
+	if err != nil {
+		return err
+	}
+	valueIndex := -1
+	if keep.strategy == keepMax {
+		if valueIndex = p.ColumnIndex(keep.column); valueIndex == -1 {
+			return fmt.Errorf("%w: %s", ColumnNotFoundError, keep.column)
+		}
+	}
+
+	// row builds the dedupeRow of a source row, copying it
+	row := func(row string, line int) (string, dedupeRow) {
+		fields := p.split(row)
+		kept := dedupeRow{row: cloneString(row), line: line}
+		if valueIndex != -1 {
+			kept.value = strings.TrimSpace(fieldAt(fields, valueIndex))
+		}
+		return keyOf(fields, keyIndexes), kept
+	}
+
+	if err := sink.Open(p.header); err != nil {
+		return closeSink(sink, err)
+	}
+	if p.config.SpillDir != "" {
+		err = p.dedupeOnDisk(keep, row, sink)
+	} else {
+		err = p.dedupeInMemory(keep, row, sink)
+	}
+	return closeSink(sink, err)
+}
+
+func (p processor) dedupeInMemory(keep Keep, row func(string, int) (string, dedupeRow), sink Sink) error {
+	partials := make([]dedupeRows, p.config.NumberOfWorkers)
+	for i := range partials {
+		partials[i] = dedupeRows{}
+	}
+
+	err := p.RunChunks(func(chunk Chunk) error {
+		for i, r := range chunk.Rows {
+			if chunk.Cancelled() {
+				return errCancelled
+			}
+			key, kept := row(r, chunk.Line(i))
+			partials[chunk.Worker].add(keep, key, kept)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, partial := range partials[1:] {
+		for key, kept := range partial {
+			partials[0].add(keep, key, kept)
+		}
+	}
+
+	batch := make([][]string, 0, dedupeBatch)
+	for _, kept := range partials[0].sorted() {
+		if batch = append(batch, p.split(kept.row)); len(batch) == dedupeBatch {
+			if err := sink.Write(batch); err != nil {
+				return err
+			}
+			batch = make([][]string, 0, dedupeBatch)
+		}
+	}
+	if len(batch) > 0 {
+		return sink.Write(batch)
+	}
+	return nil
+}
+
+// dedupeOnDisk spills the rows partitioned by key. The rows kept in each partition are saved as
+// a run sorted by line, runs are then merged to restore the source order
+func (p processor) dedupeOnDisk(keep Keep, row func(string, int) (string, dedupeRow), sink Sink) error {
+	spilled, err := newSpill(p.config.SpillDir)
+	if err != nil {
+		return err
+	}
+	defer spilled.close()
+
+	err = p.RunChunks(func(chunk Chunk) error {
+		for i, r := range chunk.Rows {
+			if chunk.Cancelled() {
+				return errCancelled
+			}
+			key, kept := row(r, chunk.Line(i))
+			if err := spilled.write(key, strconv.Itoa(kept.line), kept.value, kept.row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp(p.config.SpillDir, "parallel-csv-dedupe-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	var runs []string
+	for i := 0; i < spillPartitions; i++ {
+		partition := dedupeRows{}
+		err := spilled.each(i, func(record []string) error {
+			line, err := strconv.Atoi(record[1])
+			if err != nil {
+				return err
+			}
+			partition.add(keep, record[0], dedupeRow{row: record[3], line: line, value: record[2]})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		kept := partition.sorted()
+		rows := make([]sortRow, len(kept))
+		for j, r := range kept {
+			rows[j] = sortRow{row: r.row, line: r.line}
+		}
+		run, err := writeRun(dir, rows)
+		if err != nil {
+			return err
+		}
+		runs = append(runs, run)
+	}
+
+	// without keys runs are merged by line
+	batch := make([][]string, 0, dedupeBatch)
+	err = mergeRuns(rowSorter{p: p}, runs, func(row sortRow) error {
+		if batch = append(batch, p.split(row.row)); len(batch) == dedupeBatch {
+			if err := sink.Write(batch); err != nil {
+				return err
+			}
+			batch = make([][]string, 0, dedupeBatch)
+		}
+		return nil
+	})
+	if err == nil && len(batch) > 0 {
+		err = sink.Write(batch)
+	}
+	return err
+}
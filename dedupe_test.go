@@ -0,0 +1,63 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const versions = "id,version,name\na,1,first\nb,3,bob\na,3,third\nc,1,carla\na,2,second\nb,10,bobby\n"
+
+func TestDedupe(t *testing.T) {
+	tests := []struct {
+		keep     Keep
+		expected [][]string
+	}{
+		{KeepFirst(), [][]string{{"a", "1", "first"}, {"b", "3", "bob"}, {"c", "1", "carla"}}},
+		{KeepLast(), [][]string{{"c", "1", "carla"}, {"a", "2", "second"}, {"b", "10", "bobby"}}},
+		{KeepMax("version"), [][]string{{"a", "3", "third"}, {"c", "1", "carla"}, {"b", "10", "bobby"}}},
+	}
+
+	for _, spill := range []bool{false, true} {
+		for _, test := range tests {
+			config := GetDefaultConfig()
+			config.BytesPerWorker = 16
+			if spill {
+				config.SpillDir = t.TempDir()
+			}
+			p := NewProcessor(strings.NewReader(versions), &config)
+
+			sink := &memorySink{}
+			assert.Nil(t, p.Dedupe([]string{"id"}, test.keep, sink))
+			assert.Equal(t, []string{"id", "version", "name"}, sink.header)
+			assert.Equal(t, test.expected, sink.rows)
+			assert.True(t, sink.closed)
+		}
+	}
+}
+
+func TestDedupeLarge(t *testing.T) {
+	b := strings.Builder{}
+	b.WriteString("key,value\n")
+	for i := 0; i < 5000; i++ {
+		b.WriteString(strconv.Itoa(i%1500) + "," + strconv.Itoa(i) + "\n")
+	}
+
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 512
+	config.SpillDir = t.TempDir()
+	p := NewProcessor(strings.NewReader(b.String()), &config)
+
+	sink := &memorySink{}
+	assert.Nil(t, p.Dedupe([]string{"key"}, KeepLast(), sink))
+	assert.Len(t, sink.rows, 1500)
+	for i, row := range sink.rows {
+		assert.Equal(t, strconv.Itoa(3500+i), row[1])
+	}
+}
+
+func TestDedupeUnknownColumn(t *testing.T) {
+	p := NewProcessor(strings.NewReader(versions), nil)
+	assert.ErrorIs(t, p.Dedupe([]string{"id"}, KeepMax("updated"), &memorySink{}), ColumnNotFoundError)
+}
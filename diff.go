@@ -0,0 +1,592 @@
+package parallel_csv
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+const ColumnMismatchError = Error("files do not have the same columns")
+const UnsortedKeyError = Error("keys are not sorted")
+
+// MaxDiffSamples is the number of differences kept in a DiffReport
+const MaxDiffSamples = 10
+
+// DiffStrategy decides how the rows of the two files are matched
+type DiffStrategy int
+
+const (
+	// HashedDiff indexes the old file by key, in memory or, when Config.SpillDir is set, in
+	// temporary files partitioned by key. The files can be in any order
+	HashedDiff DiffStrategy = iota
+	// SortedDiff merges files sorted by key, compared as strings, in a single pass keeping a
+	// few chunks in memory
+	SortedDiff
+)
+
+// ChangeType is the kind of a row difference
+type ChangeType int
+
+const (
+	RowAdded ChangeType = iota
+	RowRemoved
+	RowChanged
+)
+
+func (c ChangeType) String() string {
+	switch c {
+	case RowAdded:
+		return "added"
+	case RowRemoved:
+		return "removed"
+	default:
+		return "changed"
+	}
+}
+
+// RowDiff is a row which differs between the old and the new file. Old is nil for added rows,
+// New is nil for removed ones. Lines are 0 when the row is missing from the file
+type RowDiff struct {
+	Type    ChangeType
+	Key     []string
+	Old     []string
+	New     []string
+	OldLine int
+	NewLine int
+	// Changes lists the columns of a changed row, in the order of the old file
+	Changes []FieldChange
+}
+
+// FieldChange is a column whose value differs
+type FieldChange struct {
+	Column string
+	Old    string
+	New    string
+}
+
+// DiffConfig tells Diff how to read and match the files
+type DiffConfig struct {
+	// Config is used to read both files, the default one when nil
+	Config   *Config
+	Strategy DiffStrategy
+	// Handler receives every difference, one call at a time. Sorted diffs report them in key
+	// order, hashed diffs in no particular order. An error stops the diff and is returned
+	Handler func(diff RowDiff) error
+}
+
+// DiffReport counts the differences between two files and keeps the first MaxDiffSamples
+type DiffReport struct {
+	Added     int
+	Removed   int
+	Changed   int
+	Unchanged int
+	Samples   []RowDiff
+}
+
+// Equal tells whether the files have the same rows
+func (r *DiffReport) Equal() bool {
+	return r.Added == 0 && r.Removed == 0 && r.Changed == 0
+}
+
+// Diff compares the old file a with the new file b, matching their rows by the key columns,
+// which are expected to be unique. Columns are matched by name, both files must have the same
+// ones; files without header are compared field by field
+func Diff(a, b io.Reader, keyColumns []string, config *DiffConfig) (*DiffReport, error) {
+	if config == nil {
+		config = &DiffConfig{}
+	}
+	processorConfig := GetDefaultConfig()
+	if config.Config != nil {
+		processorConfig = *config.Config
+	}
+	// each file would overwrite the checkpoint of the other
+	processorConfig.CheckpointPath = ""
+	aConfig, bConfig := processorConfig, processorConfig
+
+	d := &differ{handler: config.Handler, report: &DiffReport{}}
+	var err error
+	if d.a, err = newProcessor(a, &aConfig); err != nil {
+		return nil, fmt.Errorf("old file: %w", err)
+	}
+	if d.b, err = newProcessor(b, &bConfig); err != nil {
+		return nil, fmt.Errorf("new file: %w", err)
+	}
+	if d.aKeys, err = headerIndexes(d.a.header, keyColumns); err != nil {
+		return nil, fmt.Errorf("old file: %w", err)
+	}
+	if d.bKeys, err = headerIndexes(d.b.header, keyColumns); err != nil {
+		return nil, fmt.Errorf("new file: %w", err)
+	}
+	if err = d.matchColumns(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case config.Strategy == SortedDiff:
+		err = d.sorted()
+	case processorConfig.SpillDir != "":
+		err = d.hashedOnDisk()
+	default:
+		err = d.hashedInMemory()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d.report, nil
+}
+
+// differ holds the state shared by the strategies
+type differ struct {
+	a, b         *processor
+	aKeys, bKeys []int
+	// columns are the non-key columns, by position in each file. Empty for files without header
+	columns []diffColumn
+	handler func(diff RowDiff) error
+	mu      sync.Mutex
+	report  *DiffReport
+}
+
+type diffColumn struct {
+	name string
+	a, b int
+}
+
+// matchColumns pairs the columns of the files by name
+func (d *differ) matchColumns() error {
+	if len(d.a.header) != len(d.b.header) {
+		return ColumnMismatchError
+	}
+
+	isKey := map[int]bool{}
+	for _, index := range d.aKeys {
+		isKey[index] = true
+	}
+	for i, name := range d.a.header {
+		if isKey[i] {
+			continue
+		}
+		index := headerIndex(d.b.header, name)
+		if index == -1 {
+			return fmt.Errorf("%w: %s is missing from the new file", ColumnMismatchError, name)
+		}
+		d.columns = append(d.columns, diffColumn{name: name, a: i, b: index})
+	}
+	return nil
+}
+
+// changes returns the columns whose value differs
+func (d *differ) changes(old, updated []string) []FieldChange {
+	var changes []FieldChange
+	if len(d.a.header) > 0 {
+		for _, column := range d.columns {
+			oldValue, newValue := fieldAt(old, column.a), fieldAt(updated, column.b)
+			if oldValue != newValue {
+				changes = append(changes, FieldChange{Column: column.name, Old: oldValue, New: newValue})
+			}
+		}
+		return changes
+	}
+
+	width := len(old)
+	if len(updated) > width {
+		width = len(updated)
+	}
+	for i := 0; i < width; i++ {
+		if oldValue, newValue := fieldAt(old, i), fieldAt(updated, i); oldValue != newValue {
+			changes = append(changes, FieldChange{Column: columnName(i), Old: oldValue, New: newValue})
+		}
+	}
+	return changes
+}
+
+func fieldAt(fields []string, i int) string {
+	if i < len(fields) {
+		return fields[i]
+	}
+	return ""
+}
+
+// compare records the difference between two rows sharing a key, if any
+func (d *differ) compare(old *diffEntry, updated []string, line int) error {
+	changes := d.changes(old.fields, updated)
+	if len(changes) == 0 {
+		d.mu.Lock()
+		d.report.Unchanged++
+		d.mu.Unlock()
+		return nil
+	}
+
+	return d.record(RowDiff{
+		Type:    RowChanged,
+		Key:     keyFields(old.fields, d.aKeys),
+		Old:     old.fields,
+		New:     updated,
+		OldLine: old.line,
+		NewLine: line,
+		Changes: changes,
+	})
+}
+
+// record counts a difference and passes it to the handler
+func (d *differ) record(diff RowDiff) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch diff.Type {
+	case RowAdded:
+		d.report.Added++
+	case RowRemoved:
+		d.report.Removed++
+	default:
+		d.report.Changed++
+	}
+	if len(d.report.Samples) < MaxDiffSamples {
+		d.report.Samples = append(d.report.Samples, diff)
+	}
+
+	if d.handler != nil {
+		return d.handler(diff)
+	}
+	return nil
+}
+
+func keyFields(fields []string, indexes []int) []string {
+	key := make([]string, len(indexes))
+	for i, index := range indexes {
+		key[i] = fieldAt(fields, index)
+	}
+	return key
+}
+
+func cloneFields(fields []string) []string {
+	cloned := make([]string, len(fields))
+	for i, field := range fields {
+		cloned[i] = cloneString(field)
+	}
+	return cloned
+}
+
+// diffEntry is a row of the old file, matched is set once a row of the new file has its key
+type diffEntry struct {
+	fields  []string
+	line    int
+	matched int32
+}
+
+// diffTable indexes rows of the old file by key
+type diffTable map[string]*diffEntry
+
+// add keeps the first row of each key
+func (t diffTable) add(key string, fields []string, line int) {
+	if _, ok := t[key]; !ok {
+		t[key] = &diffEntry{fields: fields, line: line}
+	}
+}
+
+// match compares a row of the new file with the row of the old file having its key. It is safe
+// for concurrent use once the table is complete
+func (d *differ) match(table diffTable, fields []string, line int) error {
+	old, ok := table[keyOf(fields, d.bKeys)]
+	if !ok {
+		return d.record(RowDiff{Type: RowAdded, Key: keyFields(fields, d.bKeys), New: fields, NewLine: line})
+	}
+	atomic.StoreInt32(&old.matched, 1)
+	return d.compare(old, fields, line)
+}
+
+// unmatched records the rows of the old file missing from the new one, in source order
+func (d *differ) unmatched(table diffTable) error {
+	var removed []*diffEntry
+	for _, entry := range table {
+		if entry.matched == 0 {
+			removed = append(removed, entry)
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool {
+		return removed[i].line < removed[j].line
+	})
+
+	for _, entry := range removed {
+		err := d.record(RowDiff{Type: RowRemoved, Key: keyFields(entry.fields, d.aKeys), Old: entry.fields, OldLine: entry.line})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *differ) hashedInMemory() error {
+	table := diffTable{}
+	mu := sync.Mutex{}
+	err := d.a.RunChunks(func(chunk Chunk) error {
+		for i, row := range chunk.Rows {
+			if chunk.Cancelled() {
+				return errCancelled
+			}
+			fields := cloneFields(d.a.split(row))
+			key := keyOf(fields, d.aKeys)
+			mu.Lock()
+			table.add(key, fields, chunk.Line(i))
+			mu.Unlock()
+		}
+		return nil
+	})
+	if err != nil && err != EmptyFileError {
+		return err
+	}
+
+	err = d.b.RunChunks(func(chunk Chunk) error {
+		for i, row := range chunk.Rows {
+			if chunk.Cancelled() {
+				return errCancelled
+			}
+			if err := d.match(table, cloneFields(d.b.split(row)), chunk.Line(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil && err != EmptyFileError {
+		return err
+	}
+
+	return d.unmatched(table)
+}
+
+// the side of a spilled row
+const (
+	oldSide = "a"
+	newSide = "b"
+)
+
+// hashedOnDisk spills the rows of both files partitioned by key, then diffs one partition at
+// a time. Spilled records are the key, the side, the line and the fields of the row
+func (d *differ) hashedOnDisk() error {
+	rows, err := newSpill(d.a.config.SpillDir)
+	if err != nil {
+		return err
+	}
+	defer rows.close()
+
+	spillFile := func(p *processor, keys []int, side string) error {
+		err := p.RunChunks(func(chunk Chunk) error {
+			for i, row := range chunk.Rows {
+				if chunk.Cancelled() {
+					return errCancelled
+				}
+				fields := p.split(row)
+				record := append([]string{keyOf(fields, keys), side, strconv.Itoa(chunk.Line(i))}, fields...)
+				if err := rows.write(record...); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err == EmptyFileError {
+			return nil
+		}
+		return err
+	}
+	if err := spillFile(d.a, d.aKeys, oldSide); err != nil {
+		return err
+	}
+	if err := spillFile(d.b, d.bKeys, newSide); err != nil {
+		return err
+	}
+
+	for i := 0; i < spillPartitions; i++ {
+		table := diffTable{}
+		err := rows.each(i, func(record []string) error {
+			if record[1] != oldSide {
+				return nil
+			}
+			line, err := strconv.Atoi(record[2])
+			if err != nil {
+				return err
+			}
+			table.add(record[0], record[3:], line)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		err = rows.each(i, func(record []string) error {
+			if record[1] != newSide {
+				return nil
+			}
+			line, err := strconv.Atoi(record[2])
+			if err != nil {
+				return err
+			}
+			return d.match(table, record[3:], line)
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := d.unmatched(table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sorted walks both files in key order at the same time
+func (d *differ) sorted() error {
+	left := newSortedRows(d.a, d.aKeys)
+	defer left.stop()
+	right := newSortedRows(d.b, d.bKeys)
+	defer right.stop()
+
+	o, oldOk, err := left.next()
+	if err != nil {
+		return err
+	}
+	n, newOk, err := right.next()
+	if err != nil {
+		return err
+	}
+
+	for oldOk || newOk {
+		switch {
+		case !newOk || (oldOk && o.key < n.key):
+			err = d.record(RowDiff{Type: RowRemoved, Key: keyFields(o.fields, d.aKeys), Old: o.fields, OldLine: o.line})
+			if err == nil {
+				o, oldOk, err = left.next()
+			}
+		case !oldOk || n.key < o.key:
+			err = d.record(RowDiff{Type: RowAdded, Key: keyFields(n.fields, d.bKeys), New: n.fields, NewLine: n.line})
+			if err == nil {
+				n, newOk, err = right.next()
+			}
+		default:
+			err = d.compare(&diffEntry{fields: o.fields, line: o.line}, n.fields, n.line)
+			if err == nil {
+				o, oldOk, err = left.next()
+			}
+			if err == nil {
+				n, newOk, err = right.next()
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errDiffStopped stops the reading of a file once the diff is over
+var errDiffStopped = errors.New("diff stopped")
+
+// sortedRow is a row of a sorted file
+type sortedRow struct {
+	key    string
+	fields []string
+	line   int
+}
+
+// sortedRows reads a file in parallel and hands its rows over in source order, checking that
+// their keys are sorted
+type sortedRows struct {
+	keys    []int
+	batches chan []sortedRow
+	done    chan struct{}
+	result  chan error
+	batch   []sortedRow
+	last    *sortedRow
+	once    sync.Once
+}
+
+func newSortedRows(p *processor, keys []int) *sortedRows {
+	s := &sortedRows{
+		keys:    keys,
+		batches: make(chan []sortedRow, p.config.NumberOfWorkers),
+		done:    make(chan struct{}),
+		result:  make(chan error, 1),
+	}
+
+	go func() {
+		// rows travel through the sink prefixed with their line number
+		err := p.runSink(sortedRowsSink{s}, nil, func(chunk Chunk) ([][]string, error) {
+			rows := make([][]string, len(chunk.Rows))
+			for i, row := range chunk.Rows {
+				if chunk.Cancelled() {
+					return nil, errCancelled
+				}
+				rows[i] = append([]string{strconv.Itoa(chunk.Line(i))}, cloneFields(p.split(row))...)
+			}
+			return rows, nil
+		})
+		if err == EmptyFileError {
+			err = nil
+		}
+		close(s.batches)
+		s.result <- err
+	}()
+
+	return s
+}
+
+// next returns the following row, false once the file is over
+func (s *sortedRows) next() (sortedRow, bool, error) {
+	for len(s.batch) == 0 {
+		batch, ok := <-s.batches
+		if !ok {
+			err := <-s.result
+			s.result <- err
+			return sortedRow{}, false, err
+		}
+		s.batch = batch
+	}
+
+	row := s.batch[0]
+	s.batch = s.batch[1:]
+	if s.last != nil && row.key < s.last.key {
+		return sortedRow{}, false, &ParseError{Line: row.line, Err: UnsortedKeyError}
+	}
+	s.last = &row
+	return row, true, nil
+}
+
+// stop ends the reading of the file and waits for it
+func (s *sortedRows) stop() {
+	s.once.Do(func() {
+		close(s.done)
+		for range s.batches {
+		}
+		<-s.result
+	})
+}
+
+// sortedRowsSink turns the rows written by runSink into sortedRow batches
+type sortedRowsSink struct {
+	rows *sortedRows
+}
+
+func (s sortedRowsSink) Open(header []string) error {
+	return nil
+}
+
+func (s sortedRowsSink) Write(rows [][]string) error {
+	batch := make([]sortedRow, len(rows))
+	for i, row := range rows {
+		line, _ := strconv.Atoi(row[0])
+		batch[i] = sortedRow{key: keyOf(row[1:], s.rows.keys), fields: row[1:], line: line}
+	}
+
+	select {
+	case s.rows.batches <- batch:
+		return nil
+	case <-s.rows.done:
+		return errDiffStopped
+	}
+}
+
+func (s sortedRowsSink) Close() error {
+	return nil
+}
@@ -0,0 +1,109 @@
+package parallel_csv
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+const oldCustomers = "id,name,country\nc1,anna,IT\nc2,bob,FR\nc3,carla,DE\nc4,dan,US\n"
+const newCustomers = "id,country,name\nc1,IT,anna\nc2,ES,bob\nc4,US,dan\nc5,UK,eve\n"
+
+func diffConfigs(t *testing.T) map[string]*DiffConfig {
+	small := GetDefaultConfig()
+	small.BytesPerWorker = 16
+	spilled := small
+	spilled.SpillDir = t.TempDir()
+
+	return map[string]*DiffConfig{
+		"hashed":  {Config: &small},
+		"spilled": {Config: &spilled},
+		"sorted":  {Config: &small, Strategy: SortedDiff},
+	}
+}
+
+func TestDiff(t *testing.T) {
+	for name, config := range diffConfigs(t) {
+		var diffs []RowDiff
+		config.Handler = func(diff RowDiff) error {
+			diffs = append(diffs, diff)
+			return nil
+		}
+
+		report, err := Diff(strings.NewReader(oldCustomers), strings.NewReader(newCustomers), []string{"id"}, config)
+		assert.Nil(t, err, name)
+		assert.Equal(t, 1, report.Added, name)
+		assert.Equal(t, 1, report.Removed, name)
+		assert.Equal(t, 1, report.Changed, name)
+		assert.Equal(t, 2, report.Unchanged, name)
+		assert.False(t, report.Equal(), name)
+		assert.ElementsMatch(t, diffs, report.Samples, name)
+
+		byKey := map[string]RowDiff{}
+		for _, diff := range diffs {
+			byKey[diff.Key[0]] = diff
+		}
+		assert.Equal(t, RowDiff{Type: RowAdded, Key: []string{"c5"}, New: []string{"c5", "UK", "eve"}, NewLine: 5}, byKey["c5"], name)
+		assert.Equal(t, RowDiff{Type: RowRemoved, Key: []string{"c3"}, Old: []string{"c3", "carla", "DE"}, OldLine: 4}, byKey["c3"], name)
+		assert.Equal(t, []FieldChange{{Column: "country", Old: "FR", New: "ES"}}, byKey["c2"].Changes, name)
+		assert.Equal(t, 3, byKey["c2"].OldLine, name)
+		assert.Equal(t, 3, byKey["c2"].NewLine, name)
+	}
+}
+
+func TestDiffSortedOrder(t *testing.T) {
+	var types []string
+	_, err := Diff(strings.NewReader(oldCustomers), strings.NewReader(newCustomers), []string{"id"}, &DiffConfig{
+		Strategy: SortedDiff,
+		Handler: func(diff RowDiff) error {
+			types = append(types, fmt.Sprintf("%s %s", diff.Key[0], diff.Type))
+			return nil
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"c2 changed", "c3 removed", "c5 added"}, types)
+}
+
+func TestDiffUnsorted(t *testing.T) {
+	unsorted := "id,name,country\nc2,bob,FR\nc1,anna,IT\n"
+	_, err := Diff(strings.NewReader(unsorted), strings.NewReader(newCustomers), []string{"id"}, &DiffConfig{Strategy: SortedDiff})
+	assert.ErrorIs(t, err, UnsortedKeyError)
+}
+
+func TestDiffHandlerError(t *testing.T) {
+	stop := errors.New("stop")
+	for name, config := range diffConfigs(t) {
+		config.Handler = func(diff RowDiff) error {
+			return stop
+		}
+		_, err := Diff(strings.NewReader(oldCustomers), strings.NewReader(newCustomers), []string{"id"}, config)
+		assert.ErrorIs(t, err, stop, name)
+	}
+}
+
+func TestDiffEqual(t *testing.T) {
+	report, err := Diff(strings.NewReader(oldCustomers), strings.NewReader(oldCustomers), []string{"id"}, nil)
+	assert.Nil(t, err)
+	assert.True(t, report.Equal())
+	assert.Equal(t, 4, report.Unchanged)
+}
+
+func TestDiffColumnMismatch(t *testing.T) {
+	_, err := Diff(strings.NewReader(oldCustomers), strings.NewReader("id,name,city\nc1,anna,Rome\n"), []string{"id"}, nil)
+	assert.ErrorIs(t, err, ColumnMismatchError)
+
+	_, err = Diff(strings.NewReader(oldCustomers), strings.NewReader(newCustomers), []string{"email"}, nil)
+	assert.ErrorIs(t, err, ColumnNotFoundError)
+}
+
+func TestDiffWithoutHeader(t *testing.T) {
+	config := GetDefaultConfig()
+	config.HeaderConfig.HasHeader = false
+
+	report, err := Diff(strings.NewReader("1,a\n2,b\n"), strings.NewReader("1,a\n2,c,x\n"), []string{"col_1"}, &DiffConfig{Config: &config})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.Changed)
+	assert.Equal(t, []FieldChange{{Column: "col_2", Old: "b", New: "c"}, {Column: "col_3", Old: "", New: "x"}}, report.Samples[0].Changes)
+}
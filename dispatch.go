@@ -0,0 +1,164 @@
+package parallel_csv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ChunkMessage is a chunk serialized as JSON, to be processed by another process
+type ChunkMessage struct {
+	Header []string `json:"header,omitempty"`
+	Rows   []string `json:"rows"`
+	// Lines are the line numbers of the rows when some have been filtered out, the rows being
+	// consecutive from StartLine otherwise
+	Lines     []int `json:"lines,omitempty"`
+	StartLine int   `json:"start_line"`
+	Offset    int64 `json:"offset"`
+	Index     int   `json:"index"`
+}
+
+// ChunkQueue publishes the chunks of DispatchChunks to a queue, such as SQS or NATS. With the AWS
+// SDK for Go, Send calls SendMessage with the body as message body
+type ChunkQueue interface {
+	Send(ctx context.Context, body []byte) error
+}
+
+// ChunkSource receives the chunks published by DispatchChunks. With SQS, Receive calls
+// ReceiveMessage for a single message, and ack deletes it on success or resets its visibility
+// timeout on failure, so that it is delivered again. With NATS JetStream, ack calls Ack or Nak
+type ChunkSource interface {
+	// Receive waits for the next chunk. It returns io.EOF once no chunk will ever come, and the
+	// error of ctx once it is done. ack is called once the chunk has been processed, with the
+	// error of the job if it should be delivered again
+	Receive(ctx context.Context) (body []byte, ack func(err error) error, err error)
+}
+
+// DispatchChunks reads the input like RunChunks, but publishes each chunk to the queue instead of
+// processing it, so that ConsumeChunks processes it in other processes. Filters run before the
+// chunks are published. The workers publish the chunks at once, and not in source order
+func (p processor) DispatchChunks(ctx context.Context, queue ChunkQueue) error {
+	return p.RunChunks(func(chunk Chunk) error {
+		message := ChunkMessage{
+			Header:    chunk.Header,
+			Rows:      chunk.Rows,
+			StartLine: chunk.StartLine,
+			Offset:    chunk.Offset,
+			Index:     chunk.Index,
+		}
+		if chunk.lines != nil {
+			message.Lines = chunk.lines
+		}
+		body, err := json.Marshal(message)
+		if err != nil {
+			return err
+		}
+		if err := queue.Send(ctx, body); err != nil {
+			return fmt.Errorf("send chunk %d: %w", chunk.Index, err)
+		}
+		return nil
+	})
+}
+
+// ConsumeChunks runs job on the chunks received from source by config.NumberOfWorkers workers,
+// until the source returns io.EOF or ctx is done. The rows arrive as split in records by the parser
+// of the dispatching processor, a record holding line breaks staying whole: the separator and the
+// parser of config are not used. A job failing is retried
+// following config.Retry, then handled by config.ErrorPolicy: AbortOnError leaves the chunk to be
+// delivered again and stops consuming, returning the error, SkipOnError passes the error to
+// config.ErrorHandler and acknowledges the chunk. A panicking job fails with a PanicError. An
+// error of the source stops consuming whatever the policy
+func ConsumeChunks(ctx context.Context, source ChunkSource, config *Config, job ChunkJob) error {
+	if config == nil {
+		defaultConfig := GetDefaultConfig()
+		config = &defaultConfig
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	state := newRunState(config)
+	// the workers stop receiving once the run is aborted
+	go func() {
+		select {
+		case <-state.abort:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	wg := sync.WaitGroup{}
+	wg.Add(config.NumberOfWorkers)
+	for worker := 0; worker < config.NumberOfWorkers; worker++ {
+		go func(worker int) {
+			defer wg.Done()
+			for !state.aborted() {
+				body, ack, err := source.Receive(ctx)
+				if err == io.EOF || ctx.Err() != nil {
+					return
+				}
+				// the chunks received meanwhile are left to be delivered again
+				if err == nil && state.aborted() {
+					ack(errCancelled)
+					return
+				}
+				// a failing source has no chunk to skip, whatever the error policy
+				if err != nil {
+					state.once.Do(func() {
+						state.err = fmt.Errorf("receive chunk: %w", err)
+						close(state.abort)
+					})
+					return
+				}
+				consumeChunk(state, worker, body, ack, job)
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	if state.err != nil {
+		return state.err
+	}
+	return ctx.Err()
+}
+
+// consumeChunk runs the job on a chunk received, then acknowledges it
+func consumeChunk(state *runState, worker int, body []byte, ack func(err error) error, job ChunkJob) {
+	message := ChunkMessage{}
+	err := json.Unmarshal(body, &message)
+	if err == nil {
+		chunk := Chunk{
+			Header:    message.Header,
+			Rows:      message.Rows,
+			StartLine: message.StartLine,
+			Offset:    message.Offset,
+			Index:     message.Index,
+			Worker:    worker,
+			lines:     message.Lines,
+			done:      state.abort,
+		}
+		err = state.config.Retry.do(state.abort, func(int, error) {}, func() error {
+			return runJob(job, chunk)
+		})
+		if err != nil {
+			err = fmt.Errorf("chunk %d: %w", message.Index, err)
+		}
+	} else {
+		err = fmt.Errorf("decode chunk: %w", err)
+	}
+
+	if err != nil {
+		state.fail(err)
+		// skipped chunks are done with, the others are left to be delivered again
+		if state.config.ErrorPolicy == SkipOnError {
+			err = nil
+		}
+	}
+	if ackErr := ack(err); ackErr != nil {
+		state.fail(fmt.Errorf("acknowledge chunk %d: %w", message.Index, ackErr))
+	}
+}
@@ -0,0 +1,156 @@
+package parallel_csv
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memoryQueue is a queue holding the messages in memory. Receive returns io.EOF once it is empty
+// and closed, the messages failing are delivered again
+type memoryQueue struct {
+	mu       sync.Mutex
+	messages [][]byte
+	closed   bool
+	acked    int
+	nacked   int
+}
+
+func (q *memoryQueue) Send(ctx context.Context, body []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.messages = append(q.messages, body)
+	return nil
+}
+
+func (q *memoryQueue) Receive(ctx context.Context) ([]byte, func(err error) error, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.messages) == 0 {
+		if q.closed {
+			return nil, nil, io.EOF
+		}
+		return nil, nil, errors.New("queue is not closed")
+	}
+	body := q.messages[0]
+	q.messages = q.messages[1:]
+	return body, func(err error) error {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if err != nil {
+			q.nacked++
+			q.messages = append(q.messages, body)
+			return nil
+		}
+		q.acked++
+		return nil
+	}, nil
+}
+
+func TestDispatchChunks(t *testing.T) {
+	queue := &memoryQueue{}
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 64
+	config.Where, _ = ParseWhere("n > 5")
+	assert.Nil(t, NewProcessor(strings.NewReader(numbers(100)), &config).DispatchChunks(context.Background(), queue))
+	queue.closed = true
+	chunks := len(queue.messages)
+	assert.Greater(t, chunks, 1)
+
+	mu := sync.Mutex{}
+	var rows []string
+	lines := map[string]int{}
+	consumer := GetDefaultConfig()
+	consumer.NumberOfWorkers = 3
+	err := ConsumeChunks(context.Background(), queue, &consumer, func(chunk Chunk) error {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, []string{"n"}, chunk.Header)
+		for i, row := range chunk.Rows {
+			rows = append(rows, row)
+			lines[row] = chunk.Line(i)
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Len(t, rows, 95)
+	assert.Equal(t, 7, lines["6"])
+	assert.Equal(t, chunks, queue.acked)
+	sort.Strings(rows)
+	assert.Equal(t, "10", rows[0])
+}
+
+func TestConsumeChunksRecords(t *testing.T) {
+	// the rows are split by the parser of the dispatching processor
+	queue := &memoryQueue{}
+	config := GetDefaultConfig()
+	config.HeaderConfig.HasHeader = false
+	config.Parser = asciiParser{}
+	input := "a\x1f1\x1eb\x1fmulti\nline\x1ec\x1f3\x1e"
+	assert.Nil(t, NewProcessor(strings.NewReader(input), &config).DispatchChunks(context.Background(), queue))
+	queue.closed = true
+
+	var rows []string
+	consumer := GetDefaultConfig()
+	consumer.NumberOfWorkers = 1
+	err := ConsumeChunks(context.Background(), queue, &consumer, func(chunk Chunk) error {
+		rows = append(rows, chunk.Rows...)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a\x1f1", "b\x1fmulti\nline", "c\x1f3"}, rows)
+}
+
+func TestConsumeChunksErrors(t *testing.T) {
+	queue := &memoryQueue{}
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 64
+	assert.Nil(t, NewProcessor(strings.NewReader(numbers(100)), &config).DispatchChunks(context.Background(), queue))
+	queue.closed = true
+	chunks := len(queue.messages)
+
+	// the failing chunk is retried, then skipped
+	failing := func(chunk Chunk) error {
+		for _, row := range chunk.Rows {
+			if row == "50" {
+				return errors.New("bad row")
+			}
+		}
+		return nil
+	}
+	var skipped []error
+	consumer := GetDefaultConfig()
+	consumer.NumberOfWorkers = 1
+	consumer.ErrorPolicy = SkipOnError
+	consumer.ErrorHandler = func(err error) { skipped = append(skipped, err) }
+	consumer.Retry = RetryPolicy{Attempts: 2}
+	assert.Nil(t, ConsumeChunks(context.Background(), queue, &consumer, failing))
+	assert.Len(t, skipped, 1)
+	assert.Contains(t, skipped[0].Error(), "bad row")
+	assert.Equal(t, chunks, queue.acked)
+
+	// aborting leaves the chunk in the queue
+	queue = &memoryQueue{}
+	assert.Nil(t, NewProcessor(strings.NewReader(numbers(100)), &config).DispatchChunks(context.Background(), queue))
+	queue.closed = true
+	consumer = GetDefaultConfig()
+	err := ConsumeChunks(context.Background(), queue, &consumer, func(chunk Chunk) error {
+		if chunk.Index == 0 {
+			panic("boom")
+		}
+		return nil
+	})
+	var panicErr *PanicError
+	assert.True(t, errors.As(err, &panicErr))
+	assert.GreaterOrEqual(t, queue.nacked, 1)
+	requeued := false
+	for _, message := range queue.messages {
+		requeued = requeued || strings.Contains(string(message), `"index":0}`)
+	}
+	assert.True(t, requeued)
+}
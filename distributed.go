@@ -0,0 +1,321 @@
+package parallel_csv
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+const NoRangeWorkersError = Error("coordinator has no workers")
+
+const (
+	// DefaultRangeBytes is the size of the ranges of a Coordinator when not set
+	DefaultRangeBytes = 256 * MB
+	// MaxRangeErrors is the number of skipped errors kept by a RangeResult
+	MaxRangeErrors = 100
+)
+
+// RangeTask is a byte range of a file handed to a worker, encoded as JSON to travel to remote
+// workers. The range starts at the beginning of a row, and holds the rows starting before End
+type RangeTask struct {
+	Index int    `json:"index"`
+	Path  string `json:"path"`
+	Start int64  `json:"start"`
+	End   int64  `json:"end"`
+	// HeaderEnd is the size of the header line, read along with the range, 0 without header
+	HeaderEnd int64 `json:"header_end"`
+}
+
+// RangeResult is the outcome of a range processed by a worker
+type RangeResult struct {
+	Index int   `json:"index"`
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	// Stats are the statistics of the run of the range
+	Stats Stats `json:"stats"`
+	// Errors holds the first MaxRangeErrors errors skipped by Config.ErrorPolicy
+	Errors []string `json:"errors,omitempty"`
+}
+
+// RangeWorker is the transport handing ranges to a worker. RangeProcessor implements it on the
+// local machine. For a remote one, a gRPC client implements it by sending the task, as JSON or in
+// a message with the same fields, to a service of the worker machine whose handler calls
+// RangeProcessor.ProcessRange and replies with the result. A failed call fails the attempt, which
+// the Coordinator retries on any worker
+type RangeWorker interface {
+	ProcessRange(ctx context.Context, task RangeTask) (*RangeResult, error)
+}
+
+// RangeProcessor runs a job on the ranges of files on the local machine, with a processor of its
+// own for each range
+type RangeProcessor struct {
+	// Open opens the file of a task, such as a local file or an object read through RemoteReader
+	// and io.NewSectionReader
+	Open func(ctx context.Context, path string) (io.ReaderAt, error)
+	// Config is the configuration of the processors, the default one if nil. The header of the
+	// file is read along with each range, following its HeaderConfig
+	Config *Config
+	Job    ChunkJob
+}
+
+// ProcessRange runs the job on the rows of the range. The offsets of the chunks are the ones in
+// the file, but their line numbers count from the start of the range, header included
+func (r *RangeProcessor) ProcessRange(ctx context.Context, task RangeTask) (*RangeResult, error) {
+	source, err := r.Open(ctx, task.Path)
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := source.(io.Closer); ok {
+		defer closer.Close()
+	}
+	var input io.Reader = io.NewSectionReader(source, task.Start, task.End-task.Start)
+	if task.HeaderEnd > 0 {
+		input = io.MultiReader(io.NewSectionReader(source, 0, task.HeaderEnd), input)
+	}
+
+	config := GetDefaultConfig()
+	if r.Config != nil {
+		config = *r.Config
+	}
+	result := &RangeResult{Index: task.Index, Start: task.Start, End: task.End}
+	mu := sync.Mutex{}
+	handler := config.ErrorHandler
+	config.ErrorHandler = func(err error) {
+		mu.Lock()
+		if len(result.Errors) < MaxRangeErrors {
+			result.Errors = append(result.Errors, err.Error())
+		}
+		mu.Unlock()
+		if handler != nil {
+			handler(err)
+		}
+	}
+
+	p, err := newProcessor(input, &config)
+	if err == EmptyFileError {
+		// a range of empty lines
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-ctx.Done():
+			p.Stop()
+		case <-stopped:
+		}
+	}()
+
+	shift := task.Start - task.HeaderEnd
+	err = p.RunChunks(func(chunk Chunk) error {
+		chunk.Offset += shift
+		return r.Job(chunk)
+	})
+	if err != nil && err != EmptyFileError {
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	result.Stats = p.Stats()
+	return result, nil
+}
+
+// DistributedResult is the outcome of a run of a Coordinator
+type DistributedResult struct {
+	// Stats are the statistics of the ranges added up, except the ones of the queue and of the
+	// workers
+	Stats  Stats
+	Ranges []RangeResult
+	// Errors holds the first MaxRangeErrors errors skipped by each range
+	Errors []string
+}
+
+// Coordinator splits a file in byte ranges and has its workers process them, each one processing
+// a range at a time: a worker listed several times processes as many at once. The ranges are cut
+// at the first line break after every RangeBytes bytes, so quoted fields must not hold line
+// breaks. A range failing is handed again to the workers following Retry, then fails the run
+type Coordinator struct {
+	// RangeBytes is the size of the ranges, DefaultRangeBytes if 0
+	RangeBytes int
+	// HasHeader tells that the first line of the file is its header, read by every range
+	HasHeader bool
+	Retry     RetryPolicy
+	workers   []RangeWorker
+}
+
+// NewCoordinator creates a coordinator handing ranges to workers, for files with a header
+func NewCoordinator(workers ...RangeWorker) *Coordinator {
+	return &Coordinator{HasHeader: true, workers: workers}
+}
+
+// Ranges cuts the size bytes of source in the tasks of a run on path
+func (c *Coordinator) Ranges(path string, source io.ReaderAt, size int64) ([]RangeTask, error) {
+	rangeBytes := int64(c.RangeBytes)
+	if rangeBytes <= 0 {
+		rangeBytes = int64(DefaultRangeBytes)
+	}
+
+	headerEnd := int64(0)
+	if c.HasHeader {
+		end, err := nextLine(source, 0, size)
+		if err != nil {
+			return nil, err
+		}
+		if end == 0 || end > size {
+			return nil, HeaderNotFoundError
+		}
+		headerEnd = end
+	}
+
+	var tasks []RangeTask
+	for start := headerEnd; start < size; {
+		end := size
+		if start+rangeBytes < size {
+			var err error
+			if end, err = nextLine(source, start+rangeBytes, size); err != nil {
+				return nil, err
+			}
+		}
+		tasks = append(tasks, RangeTask{Index: len(tasks), Path: path, Start: start, End: end, HeaderEnd: headerEnd})
+		start = end
+	}
+	return tasks, nil
+}
+
+// nextLine returns the offset following the first line break at or after offset, size if there
+// is none
+func nextLine(source io.ReaderAt, offset int64, size int64) (int64, error) {
+	buffer := make([]byte, 64*KB)
+	for offset < size {
+		n, err := source.ReadAt(buffer, offset)
+		if i := bytes.IndexByte(buffer[:n], LineBreak[0]); i != -1 {
+			return offset + int64(i) + 1, nil
+		}
+		if err == io.EOF || n == 0 {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		offset += int64(n)
+	}
+	return size, nil
+}
+
+// Run processes the size bytes of source, the file at path, with the workers. It returns once
+// every range has been processed, or once one has failed
+func (c *Coordinator) Run(ctx context.Context, path string, source io.ReaderAt, size int64) (*DistributedResult, error) {
+	if len(c.workers) == 0 {
+		return nil, NoRangeWorkersError
+	}
+	tasks, err := c.Ranges(path, source, size)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	queue := make(chan RangeTask, len(tasks))
+	for _, task := range tasks {
+		queue <- task
+	}
+
+	mu := sync.Mutex{}
+	var results []RangeResult
+	var failure error
+	attempts := map[int]int{}
+	left := sync.WaitGroup{}
+	left.Add(len(tasks))
+
+	workers := sync.WaitGroup{}
+	workers.Add(len(c.workers))
+	for _, worker := range c.workers {
+		go func(worker RangeWorker) {
+			defer workers.Done()
+			for task := range queue {
+				if ctx.Err() != nil {
+					left.Done()
+					continue
+				}
+				result, err := worker.ProcessRange(ctx, task)
+
+				mu.Lock()
+				attempts[task.Index]++
+				attempt := attempts[task.Index]
+				switch {
+				case err == nil:
+					results = append(results, *result)
+				case ctx.Err() == nil && attempt < c.Retry.Attempts && c.Retry.retryable(err):
+					mu.Unlock()
+					// the range goes back to any worker once the backoff is over
+					c.requeue(ctx, queue, task, c.Retry.delay(attempt), &left)
+					continue
+				case failure == nil:
+					failure = fmt.Errorf("range %d (bytes %d to %d): %w", task.Index, task.Start, task.End, err)
+					cancel()
+				}
+				mu.Unlock()
+				left.Done()
+			}
+		}(worker)
+	}
+	left.Wait()
+	close(queue)
+	workers.Wait()
+
+	if failure != nil {
+		return nil, failure
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Index < results[j].Index })
+	result := &DistributedResult{Ranges: results}
+	for _, r := range results {
+		result.Stats.add(r.Stats)
+		result.Errors = append(result.Errors, r.Errors...)
+	}
+	return result, nil
+}
+
+// requeue hands the task back to the workers after delay, or gives up on it once ctx is done
+func (c *Coordinator) requeue(ctx context.Context, queue chan<- RangeTask, task RangeTask, delay time.Duration, left *sync.WaitGroup) {
+	go func() {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			// the queue has room for every task, and stays open until they are all done
+			queue <- task
+		case <-ctx.Done():
+			left.Done()
+		}
+	}()
+}
+
+// add adds up the counters of the run of a range
+func (s *Stats) add(other Stats) {
+	s.BytesRead += other.BytesRead
+	s.BytesDispatched += other.BytesDispatched
+	s.RowsRead += other.RowsRead
+	s.RowsDelivered += other.RowsDelivered
+	s.RowsSkipped += other.RowsSkipped
+	s.RowsFiltered += other.RowsFiltered
+	s.RowsMatched += other.RowsMatched
+	s.Chunks += other.Chunks
+	s.Retries += other.Retries
+	s.Redispatched += other.Redispatched
+	s.RowsIgnored += other.RowsIgnored
+	s.BytesIgnored += other.BytesIgnored
+	s.Exhausted = s.Exhausted || other.Exhausted
+	s.Stopped = s.Stopped || other.Stopped
+}
@@ -0,0 +1,163 @@
+package parallel_csv
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// rangeSum is a range processor summing the numbers of its ranges
+type rangeSum struct {
+	RangeProcessor
+	sum int64
+}
+
+func newRangeSum(file string, config *Config) *rangeSum {
+	r := &rangeSum{}
+	r.RangeProcessor = RangeProcessor{
+		Open: func(ctx context.Context, path string) (io.ReaderAt, error) {
+			return strings.NewReader(file), nil
+		},
+		Config: config,
+		Job: func(chunk Chunk) error {
+			for _, row := range chunk.Rows {
+				n, err := strconv.Atoi(strings.TrimSpace(string(row)))
+				if err != nil {
+					return err
+				}
+				atomic.AddInt64(&r.sum, int64(n))
+			}
+			return nil
+		},
+	}
+	return r
+}
+
+// flakyWorker fails the first attempts of every range
+type flakyWorker struct {
+	RangeWorker
+	mu       sync.Mutex
+	failures int
+	failed   map[int]int
+}
+
+func (w *flakyWorker) ProcessRange(ctx context.Context, task RangeTask) (*RangeResult, error) {
+	w.mu.Lock()
+	if w.failed[task.Index] < w.failures {
+		w.failed[task.Index]++
+		w.mu.Unlock()
+		return nil, errors.New("worker unavailable")
+	}
+	w.mu.Unlock()
+	return w.RangeWorker.ProcessRange(ctx, task)
+}
+
+func TestCoordinatorRanges(t *testing.T) {
+	file := numbers(1000)
+	c := NewCoordinator(newRangeSum(file, nil))
+	c.RangeBytes = 100
+	tasks, err := c.Ranges("numbers.csv", strings.NewReader(file), int64(len(file)))
+	assert.Nil(t, err)
+	assert.Greater(t, len(tasks), 10)
+
+	offset := int64(len("n\n"))
+	for i, task := range tasks {
+		assert.Equal(t, i, task.Index)
+		assert.Equal(t, offset, task.Start)
+		assert.Equal(t, int64(2), task.HeaderEnd)
+		assert.Equal(t, byte('\n'), file[task.End-1])
+		offset = task.End
+	}
+	assert.Equal(t, int64(len(file)), offset)
+
+	_, err = c.Ranges("empty.csv", strings.NewReader(""), 0)
+	assert.ErrorIs(t, err, HeaderNotFoundError)
+}
+
+func TestCoordinatorRun(t *testing.T) {
+	file := numbers(10000)
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 256
+	first, second := newRangeSum(file, &config), newRangeSum(file, &config)
+
+	c := NewCoordinator(first, second, first)
+	c.RangeBytes = 4 * KB
+	result, err := c.Run(context.Background(), "numbers.csv", strings.NewReader(file), int64(len(file)))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10000*10001/2), first.sum+second.sum)
+	assert.Equal(t, int64(10000), result.Stats.RowsDelivered)
+	assert.Greater(t, len(result.Ranges), 1)
+	for i, r := range result.Ranges {
+		assert.Equal(t, i, r.Index)
+	}
+}
+
+func TestCoordinatorChunkOffsets(t *testing.T) {
+	file := numbers(1000)
+	mu := sync.Mutex{}
+	r := &RangeProcessor{
+		Open: func(ctx context.Context, path string) (io.ReaderAt, error) {
+			return strings.NewReader(file), nil
+		},
+		Job: func(chunk Chunk) error {
+			mu.Lock()
+			defer mu.Unlock()
+			first := strings.TrimSpace(string(chunk.Rows[0]))
+			assert.True(t, strings.HasPrefix(file[chunk.Offset:], first+"\n"))
+			return nil
+		},
+	}
+	c := NewCoordinator(r)
+	c.RangeBytes = 500
+	_, err := c.Run(context.Background(), "numbers.csv", strings.NewReader(file), int64(len(file)))
+	assert.Nil(t, err)
+}
+
+func TestCoordinatorRetry(t *testing.T) {
+	file := numbers(1000)
+	sum := newRangeSum(file, nil)
+	flaky := &flakyWorker{RangeWorker: sum, failures: 2, failed: map[int]int{}}
+
+	c := NewCoordinator(flaky)
+	c.RangeBytes = 1 * KB
+	c.Retry = RetryPolicy{Attempts: 3}
+	result, err := c.Run(context.Background(), "numbers.csv", strings.NewReader(file), int64(len(file)))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1000*1001/2), sum.sum)
+	assert.Equal(t, int64(1000), result.Stats.RowsDelivered)
+
+	flaky = &flakyWorker{RangeWorker: newRangeSum(file, nil), failures: 3, failed: map[int]int{}}
+	c = NewCoordinator(flaky)
+	c.RangeBytes = 1 * KB
+	c.Retry = RetryPolicy{Attempts: 3}
+	_, err = c.Run(context.Background(), "numbers.csv", strings.NewReader(file), int64(len(file)))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "worker unavailable")
+}
+
+func TestCoordinatorSkippedErrors(t *testing.T) {
+	file := "n\n1\n2\nx\n4\ny\n6\n"
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 2
+	config.ErrorPolicy = SkipOnError
+	sum := newRangeSum(file, &config)
+
+	c := NewCoordinator(sum)
+	c.RangeBytes = 4
+	result, err := c.Run(context.Background(), "mixed.csv", strings.NewReader(file), int64(len(file)))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(13), sum.sum)
+	assert.Len(t, result.Errors, 2)
+	assert.Contains(t, result.Errors[0], "invalid syntax")
+}
+
+func TestCoordinatorNoWorkers(t *testing.T) {
+	_, err := NewCoordinator().Run(context.Background(), "numbers.csv", strings.NewReader(numbers(1)), 4)
+	assert.ErrorIs(t, err, NoRangeWorkersError)
+}
@@ -0,0 +1,99 @@
+package parallel_csv
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// DuckDBAppender appends rows to a DuckDB table. The Appender of marcboeker/go-duckdb implements
+// it, as created by duckdb.NewAppenderFromConn on a connection of the database
+type DuckDBAppender interface {
+	AppendRow(values ...driver.Value) error
+	// Flush writes the rows appended so far to the table
+	Flush() error
+	Close() error
+}
+
+// DuckDBSink appends the rows to a DuckDB table through its appender API, without going through
+// SQL or CSV. The appender expects the values in the types of the table columns: the schema
+// converts the fields to int64, float64, bool or time.Time, the other columns are appended as
+// strings. Rows are appended in source order, and flushed every BatchRows rows and by Close
+type DuckDBSink struct {
+	// Schema converts the fields to the types of its columns. Empty values are NULL, except in the
+	// string columns of the schema
+	Schema *Schema
+	// BatchRows is the number of rows appended between two flushes, DefaultBatchRows if 0
+	BatchRows int
+	appender  DuckDBAppender
+	header    []string
+	types     typedRow
+	pending   int
+	rows      int64
+}
+
+// NewDuckDBSink creates a sink appending the rows with appender, which is closed by Close
+func NewDuckDBSink(appender DuckDBAppender) *DuckDBSink {
+	return &DuckDBSink{appender: appender}
+}
+
+func (s *DuckDBSink) Open(header []string) error {
+	var err error
+	if s.types, err = bindTypes(header, s.Schema); err != nil {
+		return err
+	}
+	if s.BatchRows <= 0 {
+		s.BatchRows = DefaultBatchRows
+	}
+	s.header = header
+	return nil
+}
+
+func (s *DuckDBSink) Write(rows [][]string) error {
+	for _, row := range rows {
+		if len(s.header) > 0 && len(row) != len(s.header) {
+			return fmt.Errorf("%w: %d fields for %d columns", FieldCountError, len(row), len(s.header))
+		}
+		values, err := s.types(row)
+		if err != nil {
+			return err
+		}
+		args := make([]driver.Value, len(values))
+		for i, value := range values {
+			args[i] = value
+		}
+		if err := s.appender.AppendRow(args...); err != nil {
+			return fmt.Errorf("append to duckdb: %w", err)
+		}
+
+		s.pending++
+		if s.pending == s.BatchRows {
+			if err := s.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *DuckDBSink) flush() error {
+	if err := s.appender.Flush(); err != nil {
+		return fmt.Errorf("flush to duckdb: %w", err)
+	}
+	s.rows += int64(s.pending)
+	s.pending = 0
+	return nil
+}
+
+// Close flushes the last rows and closes the appender
+func (s *DuckDBSink) Close() error {
+	err := s.flush()
+	if closeErr := s.appender.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Rows returns the number of rows flushed so far
+func (s *DuckDBSink) Rows() int64 {
+	return s.rows
+}
@@ -0,0 +1,62 @@
+package parallel_csv
+
+import (
+	"database/sql/driver"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeAppender keeps the rows flushed, failing on the rows holding fail
+type fakeAppender struct {
+	pending [][]driver.Value
+	rows    [][]driver.Value
+	closed  bool
+	fail    driver.Value
+}
+
+func (a *fakeAppender) AppendRow(values ...driver.Value) error {
+	for _, value := range values {
+		if a.fail != nil && value == a.fail {
+			return errors.New("could not convert value")
+		}
+	}
+	a.pending = append(a.pending, values)
+	return nil
+}
+
+func (a *fakeAppender) Flush() error {
+	a.rows, a.pending = append(a.rows, a.pending...), nil
+	return nil
+}
+
+func (a *fakeAppender) Close() error {
+	a.closed = true
+	return a.Flush()
+}
+
+func TestDuckDBSink(t *testing.T) {
+	appender := &fakeAppender{}
+	sink := NewDuckDBSink(appender)
+	sink.Schema = &Schema{Columns: []ColumnSchema{
+		{Name: "id", Type: IntegerType},
+		{Name: "at", Type: TimeType, Layout: "2006-01-02"},
+	}}
+	sink.BatchRows = 2
+
+	input := "id,name,at\n1,Mario,2021-01-02\n2,,\n3,Peach,2021-01-04\n"
+	assert.Nil(t, NewProcessor(strings.NewReader(input), nil).Copy(sink))
+	assert.True(t, appender.closed)
+	assert.Equal(t, int64(3), sink.Rows())
+	assert.Equal(t, []driver.Value{int64(1), "Mario", time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)}, appender.rows[0])
+	assert.Equal(t, []driver.Value{int64(2), nil, nil}, appender.rows[1])
+	assert.Len(t, appender.rows, 3)
+
+	appender = &fakeAppender{fail: "7"}
+	err := NewProcessor(strings.NewReader(numbers(10)), nil).Copy(NewDuckDBSink(appender))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "could not convert value")
+	assert.True(t, appender.closed)
+}
@@ -0,0 +1,202 @@
+package parallel_csv
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// keySeparator joins the fields of a composite key, it is not expected to appear in the data
+const keySeparator = "\x1f"
+
+// Duplicate is a key found on more than one row
+type Duplicate struct {
+	Key       []string
+	Count     int
+	FirstLine int
+	LastLine  int
+}
+
+// DuplicateReport lists the duplicated keys, sorted by the line where they first appear
+type DuplicateReport struct {
+	Rows       int
+	Duplicates []Duplicate
+}
+
+// keyOccurrences tracks how many times a key has been seen and where
+type keyOccurrences struct {
+	count     int
+	firstLine int
+	lastLine  int
+}
+
+func (o *keyOccurrences) add(other keyOccurrences) {
+	if o.count == 0 || other.firstLine < o.firstLine {
+		o.firstLine = other.firstLine
+	}
+	if other.lastLine > o.lastLine {
+		o.lastLine = other.lastLine
+	}
+	o.count += other.count
+}
+
+// keyIndexes resolves the positions of the key columns in the header
+func (p processor) keyIndexes(columns []string) ([]int, error) {
+	indexes := make([]int, len(columns))
+	for i, column := range columns {
+		indexes[i] = p.ColumnIndex(column)
+		if indexes[i] == -1 {
+			return nil, fmt.Errorf("%w: %s", ColumnNotFoundError, column)
+		}
+	}
+	return indexes, nil
+}
+
+// keyOf builds the composite key of a row. The key is a copy, it does not point into the row
+func keyOf(fields []string, indexes []int) string {
+	b := strings.Builder{}
+	for i, index := range indexes {
+		if i > 0 {
+			b.WriteString(keySeparator)
+		}
+		if index < len(fields) {
+			b.WriteString(fields[index])
+		}
+	}
+	return b.String()
+}
+
+// DetectDuplicates reports the keys appearing on more than one row. Each worker keeps its own
+// set of keys, merged at the end, unless Config.SpillDir is set: keys are then partitioned in
+// temporary files and counted one partition at a time
+func (p processor) DetectDuplicates(keyColumns []string) (*DuplicateReport, error) {
+	indexes, err := p.keyIndexes(keyColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DuplicateReport{}
+	if p.config.SpillDir != "" {
+		err = p.detectDuplicatesOnDisk(indexes, report)
+	} else {
+		err = p.detectDuplicatesInMemory(indexes, report)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(report.Duplicates, func(i, j int) bool {
+		return report.Duplicates[i].FirstLine < report.Duplicates[j].FirstLine
+	})
+	return report, nil
+}
+
+func (p processor) detectDuplicatesInMemory(indexes []int, report *DuplicateReport) error {
+	sets := make([]map[string]*keyOccurrences, p.config.NumberOfWorkers)
+	for i := range sets {
+		sets[i] = map[string]*keyOccurrences{}
+	}
+
+	err := p.RunChunks(func(chunk Chunk) error {
+		set := sets[chunk.Worker]
+		for i, row := range chunk.Rows {
+			if chunk.Cancelled() {
+				return errCancelled
+			}
+			key := keyOf(p.split(row), indexes)
+			line := chunk.Line(i)
+
+			occurrences, ok := set[key]
+			if !ok {
+				occurrences = &keyOccurrences{}
+				set[key] = occurrences
+			}
+			occurrences.add(keyOccurrences{count: 1, firstLine: line, lastLine: line})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	merged := sets[0]
+	for _, set := range sets[1:] {
+		for key, occurrences := range set {
+			if existing, ok := merged[key]; ok {
+				existing.add(*occurrences)
+			} else {
+				merged[key] = occurrences
+			}
+		}
+	}
+
+	report.Rows = int(p.Stats().RowsDelivered)
+	report.Duplicates = collectDuplicates(merged)
+	return nil
+}
+
+func (p processor) detectDuplicatesOnDisk(indexes []int, report *DuplicateReport) error {
+	keys, err := newSpill(p.config.SpillDir)
+	if err != nil {
+		return err
+	}
+	defer keys.close()
+
+	err = p.RunChunks(func(chunk Chunk) error {
+		for i, row := range chunk.Rows {
+			if chunk.Cancelled() {
+				return errCancelled
+			}
+			err := keys.write(keyOf(p.split(row), indexes), strconv.Itoa(chunk.Line(i)))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < spillPartitions; i++ {
+		set := map[string]*keyOccurrences{}
+		err := keys.each(i, func(record []string) error {
+			line, err := strconv.Atoi(record[1])
+			if err != nil {
+				return err
+			}
+
+			occurrences, ok := set[record[0]]
+			if !ok {
+				occurrences = &keyOccurrences{}
+				set[record[0]] = occurrences
+			}
+			occurrences.add(keyOccurrences{count: 1, firstLine: line, lastLine: line})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		report.Duplicates = append(report.Duplicates, collectDuplicates(set)...)
+	}
+
+	report.Rows = int(p.Stats().RowsDelivered)
+	return nil
+}
+
+func collectDuplicates(set map[string]*keyOccurrences) []Duplicate {
+	var duplicates []Duplicate
+	for key, occurrences := range set {
+		if occurrences.count < 2 {
+			continue
+		}
+		duplicates = append(duplicates, Duplicate{
+			Key:       strings.Split(key, keySeparator),
+			Count:     occurrences.count,
+			FirstLine: occurrences.firstLine,
+			LastLine:  occurrences.lastLine,
+		})
+	}
+	return duplicates
+}
@@ -0,0 +1,33 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+const withDuplicates = "id,country,name\n1,it,anna\n2,fr,bob\n1,it,carla\n3,it,dario\n1,fr,elena\n1,it,fabio\n2,fr,gino\n"
+
+func TestDetectDuplicates(t *testing.T) {
+	for _, spillDir := range []string{"", t.TempDir()} {
+		config := GetDefaultConfig()
+		config.BytesPerWorker = 16
+		config.SpillDir = spillDir
+		p := NewProcessor(strings.NewReader(withDuplicates), &config)
+
+		report, err := p.DetectDuplicates([]string{"id", "country"})
+		assert.Nil(t, err)
+		assert.Equal(t, 7, report.Rows)
+		assert.Equal(t, []Duplicate{
+			{Key: []string{"1", "it"}, Count: 3, FirstLine: 2, LastLine: 7},
+			{Key: []string{"2", "fr"}, Count: 2, FirstLine: 3, LastLine: 8},
+		}, report.Duplicates)
+	}
+}
+
+func TestDetectDuplicatesUnknownColumn(t *testing.T) {
+	p := NewProcessor(strings.NewReader(withDuplicates), nil)
+
+	_, err := p.DetectDuplicates([]string{"email"})
+	assert.ErrorIs(t, err, ColumnNotFoundError)
+}
@@ -0,0 +1,193 @@
+package parallel_csv
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+const DynamoDBUnprocessedError = Error("dynamodb left items unprocessed")
+
+// dynamoDBBatchItems is the maximum number of items of a BatchWriteItem request
+const dynamoDBBatchItems = 25
+
+// DefaultDynamoDBRetry is the retry policy of DynamoDBSink when not set
+var DefaultDynamoDBRetry = RetryPolicy{Attempts: 8, Backoff: 50 * time.Millisecond, MaxBackoff: 5 * time.Second}
+
+// DynamoDBItem maps the attributes of an item to their values: strings, int64, float64 or bool
+type DynamoDBItem map[string]interface{}
+
+// DynamoDBClient writes items with BatchWriteItem. With the AWS SDK for Go v2, BatchWriteItem
+// converts each item with attributevalue.MarshalMap into a PutRequest, and converts back the
+// UnprocessedItems of the table with attributevalue.UnmarshalMap
+type DynamoDBClient interface {
+	// BatchWriteItem puts at most 25 items in table and returns the ones left unprocessed
+	BatchWriteItem(ctx context.Context, table string, items []DynamoDBItem) (unprocessed []DynamoDBItem, err error)
+}
+
+// DynamoDBKey maps a column to a key attribute of the table
+type DynamoDBKey struct {
+	// Attribute is the name of the key attribute
+	Attribute string
+	// Column holds the values of the key, Attribute if empty
+	Column string
+}
+
+// DynamoDBSink puts each row as an item of a DynamoDB table, the other columns becoming attributes
+// named like them. Items are written in batches of 25 by several goroutines at once, and not in
+// source order. The items left unprocessed and the failing batches are written again following
+// Retry, WritesPerSecond keeping the sink within the write capacity of the table. Empty values
+// are left out of the items, except in the string columns of the schema
+type DynamoDBSink struct {
+	Table string
+	// PartitionKey is the partition key of the table, whose values must not be empty
+	PartitionKey DynamoDBKey
+	// SortKey is the sort key of the table, if it has one
+	SortKey DynamoDBKey
+	// Schema converts the fields to the types of its columns, which are then stored as such
+	Schema *Schema
+	// Concurrency is the number of batches written at once, 1 if 0
+	Concurrency int
+	// WritesPerSecond caps the items written per second, retries included, 0 for no limit
+	WritesPerSecond int
+	// Retry writes again the items left unprocessed, DefaultDynamoDBRetry if it has no attempts
+	Retry   RetryPolicy
+	ctx     context.Context
+	client  DynamoDBClient
+	header  []string
+	keys    []int
+	types   typedRow
+	limiter *rateLimiter
+	loader  *parallelLoader
+	rows    int64
+}
+
+// NewDynamoDBSink creates a sink putting the rows in table with client, partitionKey naming both
+// the key attribute and the column holding it
+func NewDynamoDBSink(ctx context.Context, client DynamoDBClient, table string, partitionKey string) *DynamoDBSink {
+	return &DynamoDBSink{Table: table, PartitionKey: DynamoDBKey{Attribute: partitionKey}, ctx: ctx, client: client}
+}
+
+func (s *DynamoDBSink) Open(header []string) error {
+	if s.PartitionKey.Attribute == "" {
+		return fmt.Errorf("dynamodb sink for %s needs a partition key", s.Table)
+	}
+	columns := []string{s.PartitionKey.column()}
+	if s.SortKey.Attribute != "" {
+		columns = append(columns, s.SortKey.column())
+	}
+	var err error
+	if s.keys, err = headerIndexes(header, columns); err != nil {
+		return err
+	}
+	if s.types, err = bindTypes(header, s.Schema); err != nil {
+		return err
+	}
+	if s.Retry.Attempts == 0 {
+		s.Retry = DefaultDynamoDBRetry
+	}
+	if s.WritesPerSecond > 0 {
+		s.limiter = newRateLimiter(s.WritesPerSecond)
+	}
+	concurrency := s.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	s.header = header
+	s.loader = newParallelLoader(concurrency, dynamoDBBatchItems, s.batchWrite)
+	return nil
+}
+
+// column returns the column of the key
+func (k DynamoDBKey) column() string {
+	if k.Column == "" {
+		return k.Attribute
+	}
+	return k.Column
+}
+
+func (s *DynamoDBSink) Write(rows [][]string) error {
+	for _, row := range rows {
+		for _, key := range s.keys {
+			if key >= len(row) || row[key] == "" {
+				return fmt.Errorf("%w: empty key in %s", RequiredValueError, s.Table)
+			}
+		}
+		values, err := s.types(row)
+		if err != nil {
+			return err
+		}
+		if err := s.loader.add(values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close writes the last batch and waits for every batch to end, the client is left open
+func (s *DynamoDBSink) Close() error {
+	if s.loader == nil {
+		return nil
+	}
+	return s.loader.close()
+}
+
+// Rows returns the number of items written so far
+func (s *DynamoDBSink) Rows() int64 {
+	return atomic.LoadInt64(&s.rows)
+}
+
+// item turns the values of a row in an item, renaming the key columns to the key attributes
+func (s *DynamoDBSink) item(values []interface{}) DynamoDBItem {
+	item := DynamoDBItem{}
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		name := columnName(i)
+		if i < len(s.header) {
+			name = s.header[i]
+		}
+		if t, ok := value.(time.Time); ok {
+			value = formatValue(t, time.RFC3339Nano)
+		}
+		item[name] = value
+	}
+
+	for _, key := range []DynamoDBKey{s.PartitionKey, s.SortKey} {
+		if value, ok := item[key.column()]; ok && key.Column != "" && key.Column != key.Attribute {
+			delete(item, key.Column)
+			item[key.Attribute] = value
+		}
+	}
+	return item
+}
+
+// batchWrite writes a batch, writing again the items left unprocessed until none is left
+func (s *DynamoDBSink) batchWrite(worker int, batch [][]interface{}) error {
+	items := make([]DynamoDBItem, len(batch))
+	for i, values := range batch {
+		items[i] = s.item(values)
+	}
+
+	err := s.Retry.do(nil, func(int, error) {}, func() error {
+		if s.limiter != nil {
+			s.limiter.wait(len(items), nil)
+		}
+		unprocessed, err := s.client.BatchWriteItem(s.ctx, s.Table, items)
+		if err != nil {
+			return err
+		}
+		atomic.AddInt64(&s.rows, int64(len(items)-len(unprocessed)))
+		if items = unprocessed; len(items) > 0 {
+			return fmt.Errorf("%w: %d items", DynamoDBUnprocessedError, len(items))
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("write to %s: %w", s.Table, err)
+	}
+	return nil
+}
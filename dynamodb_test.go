@@ -0,0 +1,81 @@
+package parallel_csv
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDynamoDB keeps the items written by partition key, leaving the last one of each of the
+// first throttled batches unprocessed
+type fakeDynamoDB struct {
+	mu        sync.Mutex
+	items     map[interface{}]DynamoDBItem
+	batches   []int
+	throttled int
+}
+
+func (d *fakeDynamoDB) BatchWriteItem(ctx context.Context, table string, items []DynamoDBItem) ([]DynamoDBItem, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.items == nil {
+		d.items = map[interface{}]DynamoDBItem{}
+	}
+	d.batches = append(d.batches, len(items))
+	var unprocessed []DynamoDBItem
+	if d.throttled > 0 {
+		d.throttled--
+		items, unprocessed = items[:len(items)-1], items[len(items)-1:]
+	}
+	for _, item := range items {
+		d.items[item["pk"]] = item
+	}
+	return unprocessed, nil
+}
+
+func TestDynamoDBSink(t *testing.T) {
+	client := &fakeDynamoDB{throttled: 2}
+	sink := NewDynamoDBSink(context.Background(), client, "numbers", "pk")
+	sink.PartitionKey.Column = "n"
+	sink.Schema = &Schema{Columns: []ColumnSchema{{Name: "n", Type: IntegerType}}}
+	sink.Concurrency = 2
+	sink.Retry = RetryPolicy{Attempts: 3, Backoff: time.Millisecond}
+
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 64
+	assert.Nil(t, NewProcessor(strings.NewReader(numbers(60)), &config).Copy(sink))
+	assert.Equal(t, int64(60), sink.Rows())
+	assert.Len(t, client.items, 60)
+	assert.Equal(t, DynamoDBItem{"pk": int64(42)}, client.items[int64(42)])
+	// 3 batches of at most 25 items, plus one retry for each throttled one
+	assert.Len(t, client.batches, 5)
+	for _, items := range client.batches {
+		assert.LessOrEqual(t, items, 25)
+	}
+}
+
+func TestDynamoDBSinkItems(t *testing.T) {
+	client := &fakeDynamoDB{}
+	sink := NewDynamoDBSink(context.Background(), client, "people", "pk")
+	sink.PartitionKey.Column = "id"
+	sink.SortKey = DynamoDBKey{Attribute: "country"}
+	sink.WritesPerSecond = 1000
+
+	input := "id,country,name\n1,IT,Mario\n2,FR,\n"
+	assert.Nil(t, NewProcessor(strings.NewReader(input), nil).Copy(sink))
+	assert.Equal(t, DynamoDBItem{"pk": "1", "country": "IT", "name": "Mario"}, client.items["1"])
+	assert.Equal(t, DynamoDBItem{"pk": "2", "country": "FR"}, client.items["2"])
+
+	err := NewProcessor(strings.NewReader("id,country\n3,\n"), nil).Copy(sink)
+	assert.ErrorIs(t, err, RequiredValueError)
+
+	// every attempt leaves an item unprocessed
+	client = &fakeDynamoDB{throttled: 10}
+	sink = NewDynamoDBSink(context.Background(), client, "numbers", "n")
+	sink.Retry = RetryPolicy{Attempts: 2}
+	err = NewProcessor(strings.NewReader(numbers(10)), nil).Copy(sink)
+	assert.ErrorIs(t, err, DynamoDBUnprocessedError)
+}
@@ -0,0 +1,202 @@
+package parallel_csv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const ElasticsearchRejectedError = Error("elasticsearch rejected the documents")
+
+// DefaultElasticsearchBatch is the number of documents of a bulk request when not set
+const DefaultElasticsearchBatch = 1000
+
+// DefaultElasticsearchRetry is the retry policy of ElasticsearchSink when not set
+var DefaultElasticsearchRetry = RetryPolicy{Attempts: 5, Backoff: time.Second, MaxBackoff: 30 * time.Second}
+
+// ElasticsearchClient sends bulk requests. With go-elasticsearch, Bulk calls Client.Bulk with a
+// reader of the body and decodes the status of each item of the response:
+//
+//	var result struct {
+//		Items []map[string]struct {
+//			Status int
+//			Error  struct{ Reason string }
+//		}
+//	}
+//
+// Items are left empty when the whole request fails
+type ElasticsearchClient interface {
+	Bulk(ctx context.Context, body []byte) (ElasticsearchBulkResponse, error)
+}
+
+// ElasticsearchBulkResponse is the outcome of a bulk request
+type ElasticsearchBulkResponse struct {
+	// Status is the HTTP status of the response
+	Status int
+	// Items are the outcomes of the actions, in request order
+	Items []ElasticsearchItem
+}
+
+// ElasticsearchItem is the outcome of an action of a bulk request
+type ElasticsearchItem struct {
+	Status int
+	// Error is the reason of the failure, empty on success
+	Error string
+}
+
+// ElasticsearchSink indexes each row as a JSON document keyed by column name, through bulk
+// requests of BatchRows documents. Several requests run at once and not in source order. The
+// requests answered with 429 or a server error, and the documents rejected with 429, are sent
+// again following Retry. Any other rejection fails the sink
+type ElasticsearchSink struct {
+	Index string
+	// IDColumn is the column whose values are the ids of the documents, generated by Elasticsearch
+	// if empty
+	IDColumn string
+	// Schema converts the fields to the types of its columns, which are then encoded as such
+	Schema *Schema
+	// BatchRows is the number of documents of a request, DefaultElasticsearchBatch if 0
+	BatchRows int
+	// Concurrency is the number of requests running at once, 1 if 0
+	Concurrency int
+	// Retry runs again the requests and documents rejected with 429, DefaultElasticsearchRetry if
+	// it has no attempts
+	Retry  RetryPolicy
+	ctx    context.Context
+	client ElasticsearchClient
+	header []string
+	id     int
+	types  typedRow
+	loader *parallelLoader
+	rows   int64
+}
+
+// NewElasticsearchSink creates a sink indexing the rows into index with client
+func NewElasticsearchSink(ctx context.Context, client ElasticsearchClient, index string) *ElasticsearchSink {
+	return &ElasticsearchSink{Index: index, ctx: ctx, client: client}
+}
+
+func (s *ElasticsearchSink) Open(header []string) error {
+	s.id = -1
+	if s.IDColumn != "" {
+		indexes, err := headerIndexes(header, []string{s.IDColumn})
+		if err != nil {
+			return err
+		}
+		s.id = indexes[0]
+	}
+	var err error
+	if s.types, err = bindTypes(header, s.Schema); err != nil {
+		return err
+	}
+	if s.BatchRows <= 0 {
+		s.BatchRows = DefaultElasticsearchBatch
+	}
+	if s.Retry.Attempts == 0 {
+		s.Retry = DefaultElasticsearchRetry
+	}
+	concurrency := s.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	s.header = header
+	s.loader = newParallelLoader(concurrency, s.BatchRows, s.bulk)
+	return nil
+}
+
+func (s *ElasticsearchSink) Write(rows [][]string) error {
+	for _, row := range rows {
+		if len(s.header) > 0 && len(row) != len(s.header) {
+			return fmt.Errorf("%w: %d fields for %d columns", FieldCountError, len(row), len(s.header))
+		}
+		values, err := s.types(row)
+		if err != nil {
+			return err
+		}
+		if err := s.loader.add(values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close sends the last request and waits for every request to end, the client is left open
+func (s *ElasticsearchSink) Close() error {
+	if s.loader == nil {
+		return nil
+	}
+	return s.loader.close()
+}
+
+// Rows returns the number of documents indexed so far
+func (s *ElasticsearchSink) Rows() int64 {
+	return atomic.LoadInt64(&s.rows)
+}
+
+// bulk indexes a batch, sending again the documents rejected with 429 until none is left
+func (s *ElasticsearchSink) bulk(worker int, batch [][]interface{}) error {
+	documents := make([][]byte, len(batch))
+	for i, values := range batch {
+		var err error
+		if documents[i], err = s.document(values); err != nil {
+			return err
+		}
+	}
+
+	err := s.Retry.do(nil, func(int, error) {}, func() error {
+		body := bytes.Join(documents, nil)
+		response, err := s.client.Bulk(s.ctx, body)
+		switch {
+		case err != nil:
+			return err
+		case response.Status == http.StatusTooManyRequests || response.Status >= 500:
+			return fmt.Errorf("%w: status %d", ElasticsearchRejectedError, response.Status)
+		case response.Status >= 300:
+			return permanentError{fmt.Errorf("%w: status %d", ElasticsearchRejectedError, response.Status)}
+		case len(response.Items) != len(documents):
+			return permanentError{fmt.Errorf("%d items in the response of %d documents", len(response.Items), len(documents))}
+		}
+
+		var rejected [][]byte
+		for i, item := range response.Items {
+			switch {
+			case item.Status == http.StatusTooManyRequests:
+				rejected = append(rejected, documents[i])
+			case item.Status >= 300:
+				return permanentError{fmt.Errorf("%w: status %d: %s", ElasticsearchRejectedError, item.Status, item.Error)}
+			}
+		}
+		atomic.AddInt64(&s.rows, int64(len(documents)-len(rejected)))
+		if documents = rejected; len(documents) > 0 {
+			return fmt.Errorf("%w: %d documents with status 429", ElasticsearchRejectedError, len(documents))
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("index into %s: %w", s.Index, err)
+	}
+	return nil
+}
+
+// document encodes the action and the source of a row, each one followed by a line break
+func (s *ElasticsearchSink) document(values []interface{}) ([]byte, error) {
+	action := map[string]string{"_index": s.Index}
+	if s.id >= 0 && s.id < len(values) {
+		action["_id"] = formatValue(values[s.id], time.RFC3339Nano)
+	}
+	b, err := json.Marshal(map[string]interface{}{"index": action})
+	if err != nil {
+		return nil, err
+	}
+	source, err := json.Marshal(typedObject(s.header, values))
+	if err != nil {
+		return nil, err
+	}
+	b = append(append(append(b, '\n'), source...), '\n')
+	return b, nil
+}
@@ -0,0 +1,87 @@
+package parallel_csv
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeElasticsearch keeps the documents indexed by id. The first throttled requests are answered
+// with 429, then the documents whose id is in reject are rejected with their status once
+type fakeElasticsearch struct {
+	mu        sync.Mutex
+	documents map[string]map[string]interface{}
+	requests  int
+	throttled int
+	reject    map[string]int
+}
+
+func (f *fakeElasticsearch) Bulk(ctx context.Context, body []byte) (ElasticsearchBulkResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+	if f.throttled > 0 {
+		f.throttled--
+		return ElasticsearchBulkResponse{Status: 429}, nil
+	}
+	if f.documents == nil {
+		f.documents = map[string]map[string]interface{}{}
+	}
+
+	response := ElasticsearchBulkResponse{Status: 200}
+	lines := bytes.Split(bytes.TrimSuffix(body, []byte("\n")), []byte("\n"))
+	for i := 0; i+1 < len(lines); i += 2 {
+		action := map[string]map[string]string{}
+		document := map[string]interface{}{}
+		json.Unmarshal(lines[i], &action)
+		json.Unmarshal(lines[i+1], &document)
+
+		id := action["index"]["_id"]
+		if status, ok := f.reject[id]; ok {
+			delete(f.reject, id)
+			response.Items = append(response.Items, ElasticsearchItem{Status: status, Error: "mapper_parsing_exception"})
+			continue
+		}
+		f.documents[id] = document
+		response.Items = append(response.Items, ElasticsearchItem{Status: 201})
+	}
+	return response, nil
+}
+
+func TestElasticsearchSink(t *testing.T) {
+	client := &fakeElasticsearch{throttled: 1, reject: map[string]int{"7": 429, "42": 429}}
+	sink := NewElasticsearchSink(context.Background(), client, "numbers")
+	sink.IDColumn = "n"
+	sink.Schema = &Schema{Columns: []ColumnSchema{{Name: "n", Type: IntegerType}}}
+	sink.BatchRows = 10
+	sink.Concurrency = 4
+	sink.Retry = RetryPolicy{Attempts: 3, Backoff: time.Millisecond}
+
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 64
+	assert.Nil(t, NewProcessor(strings.NewReader(numbers(95)), &config).Copy(sink))
+	assert.Equal(t, int64(95), sink.Rows())
+	assert.Len(t, client.documents, 95)
+	assert.Equal(t, map[string]interface{}{"n": 42.0}, client.documents["42"])
+	// 10 batches, one throttled and two with a document rejected
+	assert.Equal(t, 13, client.requests)
+}
+
+func TestElasticsearchSinkRejected(t *testing.T) {
+	client := &fakeElasticsearch{reject: map[string]int{"3": 400}}
+	sink := NewElasticsearchSink(context.Background(), client, "people")
+	sink.IDColumn = "n"
+	err := NewProcessor(strings.NewReader(numbers(10)), nil).Copy(sink)
+	assert.ErrorIs(t, err, ElasticsearchRejectedError)
+	assert.Contains(t, err.Error(), "mapper_parsing_exception")
+	assert.Equal(t, 1, client.requests)
+
+	sink = NewElasticsearchSink(context.Background(), client, "people")
+	sink.IDColumn = "id"
+	assert.ErrorIs(t, NewProcessor(strings.NewReader(numbers(10)), nil).Copy(sink), ColumnNotFoundError)
+}
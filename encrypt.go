@@ -0,0 +1,286 @@
+package parallel_csv
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"runtime"
+	"sync"
+)
+
+const DecryptionError = Error("encrypted data is corrupted or the key is wrong")
+const TruncatedError = Error("encrypted data is truncated")
+
+// encryptedMagic starts every encrypted stream
+const encryptedMagic = "PCSVAES1"
+
+// encryptedSegment is the size of the plaintext sealed at once, the last segment may be shorter
+const encryptedSegment = 64 * KB
+
+// saltSize is the size of the random salt deriving the key of each stream
+const saltSize = 16
+
+// streamAEAD derives the AES-GCM cipher of a stream from the key and the salt of the stream, so
+// that nonces can be plain segment counters
+func streamAEAD(key []byte, salt []byte) (cipher.AEAD, error) {
+	// the key size is checked before derivation, which always produces 32 bytes
+	if _, err := aes.NewCipher(key); err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(encryptedMagic))
+	mac.Write(salt)
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// segmentNonce is the counter of the segment followed by a byte flagging the last one, which
+// makes truncated streams detectable
+func segmentNonce(counter uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// EncryptWriter encrypts what is written to it with AES-GCM, in a format of this package read by
+// NewDecryptReader. The stream starts with the magic "PCSVAES1" and a random 16 bytes salt, the
+// cipher key being the HMAC-SHA256 of both by the key given. The data follows, split in 64KB
+// segments each sealed on its own. As in the STREAM construction, the nonce of a segment is its
+// position followed by a byte flagging the last one, so that segments cannot be reordered, dropped
+// or cut off unnoticed. Other tools, age included, cannot read it.
+//
+// Segments are sealed by several goroutines and written in order. Close must be called to write
+// the last segment. Once the underlying writer fails, Write and Close return its error
+type EncryptWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	buffer  []byte
+	counter uint64
+	jobs    chan segmentJob
+	// sealed holds the result channels of the segments, in order
+	sealed chan chan []byte
+	done   chan struct{}
+	// mu guards err, the first error of the underlying writer
+	mu   sync.Mutex
+	err  error
+	once sync.Once
+}
+
+type segmentJob struct {
+	plaintext []byte
+	counter   uint64
+	result    chan []byte
+}
+
+// NewEncryptWriter writes the header of the stream and returns the writer. The key must be 16,
+// 24 or 32 bytes long
+func NewEncryptWriter(w io.Writer, key []byte) (*EncryptWriter, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := streamAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append([]byte(encryptedMagic), salt...)); err != nil {
+		return nil, err
+	}
+
+	workers := runtime.NumCPU()
+	e := &EncryptWriter{
+		w:      w,
+		aead:   aead,
+		buffer: make([]byte, 0, encryptedSegment),
+		jobs:   make(chan segmentJob, workers),
+		sealed: make(chan chan []byte, 2*workers),
+		done:   make(chan struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range e.jobs {
+				job.result <- e.aead.Seal(nil, segmentNonce(job.counter, false), job.plaintext, nil)
+			}
+		}()
+	}
+	go e.writeSealed()
+	return e, nil
+}
+
+// writeSealed writes the sealed segments in order, keeping the first error
+func (e *EncryptWriter) writeSealed() {
+	defer close(e.done)
+	for result := range e.sealed {
+		segment := <-result
+		if e.failure() == nil {
+			if _, err := e.w.Write(segment); err != nil {
+				e.fail(err)
+			}
+		}
+	}
+}
+
+func (e *EncryptWriter) failure() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.err
+}
+
+func (e *EncryptWriter) fail(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+func (e *EncryptWriter) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		// the segments sealed after a failure would never be written
+		if err := e.failure(); err != nil {
+			return written - len(p), err
+		}
+		// a full segment is sealed only once more data comes, since the last one is flagged
+		if len(e.buffer) == encryptedSegment {
+			result := make(chan []byte, 1)
+			e.sealed <- result
+			e.jobs <- segmentJob{plaintext: e.buffer, counter: e.counter, result: result}
+			e.counter++
+			e.buffer = make([]byte, 0, encryptedSegment)
+		}
+
+		n := copy(e.buffer[len(e.buffer):cap(e.buffer)], p)
+		e.buffer = e.buffer[:len(e.buffer)+n]
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Close seals the last segment and waits for every segment to be written. The underlying writer
+// is left open
+func (e *EncryptWriter) Close() error {
+	e.once.Do(func() {
+		close(e.jobs)
+		close(e.sealed)
+		<-e.done
+		if e.failure() == nil {
+			if _, err := e.w.Write(e.aead.Seal(nil, segmentNonce(e.counter, true), e.buffer, nil)); err != nil {
+				e.fail(err)
+			}
+		}
+	})
+	return e.failure()
+}
+
+// decryptReader opens the segments of an encrypted stream one at a time
+type decryptReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	counter uint64
+	buffer  []byte
+	last    bool
+}
+
+// NewDecryptReader reads the header of a stream written by EncryptWriter and returns the reader of
+// its plaintext, which can be handed to NewProcessor. Reads fail with DecryptionError when the data
+// has been tampered with, and with TruncatedError when the stream ends before its last segment
+func NewDecryptReader(r io.Reader, key []byte) (io.Reader, error) {
+	header := make([]byte, len(encryptedMagic)+saltSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, TruncatedError
+	}
+	if !bytes.Equal(header[:len(encryptedMagic)], []byte(encryptedMagic)) {
+		return nil, DecryptionError
+	}
+	aead, err := streamAEAD(key, header[len(encryptedMagic):])
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{r: bufio.NewReader(r), aead: aead}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buffer) == 0 {
+		if d.last {
+			return 0, io.EOF
+		}
+		if err := d.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.buffer)
+	d.buffer = d.buffer[n:]
+	return n, nil
+}
+
+// open decrypts the next segment, it is the last one when nothing follows it
+func (d *decryptReader) open() error {
+	sealed := make([]byte, encryptedSegment+d.aead.Overhead())
+	n, err := io.ReadFull(d.r, sealed)
+	if err == io.EOF {
+		return TruncatedError
+	}
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	if err == nil {
+		if _, err := d.r.Peek(1); err == io.EOF {
+			d.last = true
+		}
+	} else {
+		d.last = true
+	}
+
+	d.buffer, err = d.aead.Open(nil, segmentNonce(d.counter, d.last), sealed[:n], nil)
+	if err != nil {
+		if d.last {
+			// a full segment flagged as not being the last one means that the stream was cut
+			if _, notLast := d.aead.Open(nil, segmentNonce(d.counter, false), sealed[:n], nil); notLast == nil {
+				return TruncatedError
+			}
+		}
+		return DecryptionError
+	}
+	d.counter++
+	return nil
+}
+
+// EncryptedSink writes the rows as CSV encrypted by an EncryptWriter
+type EncryptedSink struct {
+	*CSVSink
+	encrypter *EncryptWriter
+}
+
+// NewEncryptedSink writes the header of the encrypted stream to w and returns the sink. The key must
+// be 16, 24 or 32 bytes long
+func NewEncryptedSink(w io.Writer, key []byte, separator string) (*EncryptedSink, error) {
+	encrypter, err := NewEncryptWriter(w, key)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptedSink{CSVSink: NewCSVSink(encrypter, separator), encrypter: encrypter}, nil
+}
+
+// Close flushes the rows and writes the last encrypted segment, the underlying writer is left open
+func (s *EncryptedSink) Close() error {
+	err := s.CSVSink.Close()
+	if closeErr := s.encrypter.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
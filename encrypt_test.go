@@ -0,0 +1,134 @@
+package parallel_csv
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func encrypt(t *testing.T, plaintext []byte) []byte {
+	out := &bytes.Buffer{}
+	w, err := NewEncryptWriter(out, testKey)
+	assert.Nil(t, err)
+	// odd sized writes cross the segment boundaries
+	for len(plaintext) > 0 {
+		n := 7777
+		if n > len(plaintext) {
+			n = len(plaintext)
+		}
+		_, err := w.Write(plaintext[:n])
+		assert.Nil(t, err)
+		plaintext = plaintext[n:]
+	}
+	assert.Nil(t, w.Close())
+	return out.Bytes()
+}
+
+func decrypt(ciphertext []byte, key []byte) ([]byte, error) {
+	r, err := NewDecryptReader(bytes.NewReader(ciphertext), key)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 10, encryptedSegment, 3*encryptedSegment + 123} {
+		plaintext := bytes.Repeat([]byte("abcdefghij"), size/10+1)[:size]
+		ciphertext := encrypt(t, plaintext)
+		assert.False(t, bytes.Contains(ciphertext, []byte("abcdefghij")), size)
+
+		decrypted, err := decrypt(ciphertext, testKey)
+		assert.Nil(t, err, size)
+		assert.Equal(t, len(plaintext), len(decrypted), size)
+		assert.True(t, bytes.Equal(plaintext, decrypted), size)
+	}
+}
+
+func TestDecryptTampered(t *testing.T) {
+	ciphertext := encrypt(t, bytes.Repeat([]byte("x"), 2*encryptedSegment+10))
+
+	_, err := decrypt(ciphertext, []byte("another key of 32 bytes, really!"))
+	assert.ErrorIs(t, err, DecryptionError)
+
+	tampered := append([]byte{}, ciphertext...)
+	tampered[100] ^= 1
+	_, err = decrypt(tampered, testKey)
+	assert.ErrorIs(t, err, DecryptionError)
+
+	// cut after the second segment
+	header := len(encryptedMagic) + saltSize
+	_, err = decrypt(ciphertext[:header+2*(encryptedSegment+16)], testKey)
+	assert.ErrorIs(t, err, TruncatedError)
+
+	_, err = decrypt(ciphertext[:header], testKey)
+	assert.ErrorIs(t, err, TruncatedError)
+}
+
+// failingWriter accepts the first writes, then fails every other one
+type failingWriter struct {
+	mu      sync.Mutex
+	left    int
+	written int
+	failed  int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.left == 0 {
+		w.failed++
+		return 0, errors.New("disk full")
+	}
+	w.left--
+	w.written++
+	return len(p), nil
+}
+
+func TestEncryptWriterError(t *testing.T) {
+	out := &failingWriter{left: 2}
+	w, err := NewEncryptWriter(out, testKey)
+	assert.Nil(t, err)
+
+	segment := make([]byte, encryptedSegment)
+	for i := 0; i < 1000 && err == nil; i++ {
+		_, err = w.Write(segment)
+	}
+	assert.NotNil(t, err)
+	assert.Equal(t, "disk full", err.Error())
+	assert.Equal(t, err, w.Close())
+
+	// nothing is written once the writer has failed
+	assert.Equal(t, 2, out.written)
+	assert.Equal(t, 1, out.failed)
+}
+
+func TestEncryptInvalidKey(t *testing.T) {
+	_, err := NewEncryptWriter(&bytes.Buffer{}, []byte("short"))
+	assert.NotNil(t, err)
+}
+
+func TestEncryptedSink(t *testing.T) {
+	input := numbers(20000)
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 4 * KB
+	p := NewProcessor(strings.NewReader(input), &config)
+
+	out := &bytes.Buffer{}
+	sink, err := NewEncryptedSink(out, testKey, ",")
+	assert.Nil(t, err)
+	assert.Nil(t, p.Copy(sink))
+
+	// the encrypted export can be processed again once decrypted
+	r, err := NewDecryptReader(out, testKey)
+	assert.Nil(t, err)
+	rows, err := NewProcessor(r, nil).Tail(1)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"20000"}, rows)
+}
@@ -0,0 +1,131 @@
+package parallel_csv
+
+import (
+	"io"
+	"runtime"
+)
+
+// EstimateSampleBytes is the size of the sample read by Estimate from seekable inputs
+const EstimateSampleBytes = MB
+
+// EstimateReport is what a run is expected to look like, from a sample of the input
+type EstimateReport struct {
+	// Bytes is the size of the input after the header, -1 when it cannot be known without
+	// reading the whole input
+	Bytes int64 `json:"bytes"`
+	// SampleBytes and SampleRows are the size and number of complete rows of the sample
+	SampleBytes int `json:"sample_bytes"`
+	SampleRows  int `json:"sample_rows"`
+	// AverageRowBytes is the size of the rows of the sample, line breaks included
+	AverageRowBytes float64 `json:"average_row_bytes"`
+	// Rows and Chunks are estimated from the size of the input, -1 when it is unknown
+	Rows   int64 `json:"rows"`
+	Chunks int64 `json:"chunks"`
+	// Memory is the bytes held by the chunks in flight with the current config: the ones queued
+	// for the workers, the ones being processed and the one being read
+	Memory int64 `json:"memory"`
+	// RecommendedWorkers and RecommendedChunkBytes give every CPU several chunks to process
+	RecommendedWorkers    int `json:"recommended_workers"`
+	RecommendedChunkBytes int `json:"recommended_chunk_bytes"`
+}
+
+// Estimate samples the input and reports the expected size of a run without processing
+// anything, so that the config can be checked first. Inputs implementing io.Seeker, such as
+// files, are measured and sampled from the start of the rows, then moved back, the others are
+// sampled from what is already buffered
+func (p processor) Estimate() (*EstimateReport, error) {
+	report := &EstimateReport{Bytes: -1, Rows: -1, Chunks: -1}
+
+	var sample []byte
+	if seeker, ok := p.source.(io.ReadSeeker); ok {
+		var err error
+		if sample, report.Bytes, err = p.sampleSeeker(seeker); err != nil {
+			return nil, err
+		}
+	} else {
+		// Peek fails when the input is shorter than the buffer, returning what there is
+		sample, _ = p.reader.Peek(p.reader.Size())
+	}
+
+	complete := sample[:p.parser.RecordsEnd(sample)]
+	if report.Bytes >= 0 && int64(len(sample)) == report.Bytes {
+		// the whole input has been sampled, its last row may have no line break
+		complete = sample
+	}
+	if len(complete) > 0 {
+		report.SampleBytes = len(complete)
+		report.SampleRows = p.parser.Count(complete)
+		report.AverageRowBytes = float64(report.SampleBytes) / float64(report.SampleRows)
+	}
+
+	chunkBytes := int64(p.config.BytesPerWorker)
+	if report.Bytes >= 0 {
+		report.Chunks = (report.Bytes + chunkBytes - 1) / chunkBytes
+		if report.AverageRowBytes > 0 {
+			report.Rows = int64(float64(report.Bytes)/report.AverageRowBytes + 0.5)
+		}
+		if report.Bytes < chunkBytes {
+			chunkBytes = report.Bytes
+		}
+	}
+
+	// a chunk holds its buffer, the text of its rows unless buffers are reused, and the rows
+	perChunk := chunkBytes
+	if !p.config.ReuseBuffers {
+		perChunk += chunkBytes
+	}
+	if report.AverageRowBytes > 0 {
+		perChunk += int64(float64(chunkBytes)/report.AverageRowBytes) * 16
+	}
+	report.Memory = int64(2*p.config.NumberOfWorkers+1) * perChunk
+
+	report.RecommendedWorkers, report.RecommendedChunkBytes = recommendConfig(report.Bytes, p.config.BytesPerWorker)
+	return report, nil
+}
+
+// sampleSeeker reads the first rows of a seekable input and its size, then moves it back
+func (p processor) sampleSeeker(seeker io.ReadSeeker) ([]byte, int64, error) {
+	position, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, 0, err
+	}
+	end, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	size := end - p.start.Offset
+	sample := make([]byte, EstimateSampleBytes)
+	if size < int64(len(sample)) {
+		sample = sample[:size]
+	}
+	if _, err := seeker.Seek(p.start.Offset, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	n, err := io.ReadFull(seeker, sample)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, 0, err
+	}
+
+	// the buffered reader goes on from where it was
+	if _, err := seeker.Seek(position, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	return sample[:n], size, nil
+}
+
+// recommendConfig uses every CPU and cuts the input in about four chunks per worker, between
+// 64KB and 64MB each, rounded to the KB
+func recommendConfig(bytes int64, chunkBytes int) (int, int) {
+	workers := runtime.NumCPU()
+	if bytes >= 0 {
+		chunkBytes = int(bytes / int64(4*workers))
+	}
+	if chunkBytes < 64*KB {
+		chunkBytes = 64 * KB
+	}
+	if chunkBytes > 64*MB {
+		chunkBytes = 64 * MB
+	}
+	return workers, chunkBytes / KB * KB
+}
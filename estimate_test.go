@@ -0,0 +1,63 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestEstimate(t *testing.T) {
+	input := numbers(1000)
+	config := GetDefaultConfig()
+	config.NumberOfWorkers = 2
+	config.BytesPerWorker = KB
+	p := NewProcessor(strings.NewReader(input), &config)
+
+	report, err := p.Estimate()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(input)-2), report.Bytes)
+	assert.Equal(t, 1000, report.SampleRows)
+	assert.Equal(t, int64(1000), report.Rows)
+	assert.Equal(t, int64(4), report.Chunks)
+	// 5 chunks in flight, each with its buffer, its text and 263 rows
+	assert.Equal(t, int64(5*(2*KB+263*16)), report.Memory)
+	assert.Equal(t, runtime.NumCPU(), report.RecommendedWorkers)
+	assert.Equal(t, 64*KB, report.RecommendedChunkBytes)
+
+	// the input is left untouched
+	var rows int64
+	assert.Nil(t, p.Run(func(header []string, chunk []string) {
+		atomic.AddInt64(&rows, int64(len(chunk)))
+	}))
+	assert.Equal(t, int64(1000), rows)
+}
+
+func TestEstimateNotSeekable(t *testing.T) {
+	input := struct{ io.Reader }{strings.NewReader(numbers(10000))}
+	p := NewProcessor(input, nil)
+
+	report, err := p.Estimate()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(-1), report.Bytes)
+	assert.Equal(t, int64(-1), report.Rows)
+	assert.Greater(t, report.SampleRows, 100)
+	assert.InDelta(t, 4, report.AverageRowBytes, 0.5)
+
+	var rows int64
+	assert.Nil(t, p.Run(func(header []string, chunk []string) {
+		atomic.AddInt64(&rows, int64(len(chunk)))
+	}))
+	assert.Equal(t, int64(10000), rows)
+}
+
+func TestRecommendConfig(t *testing.T) {
+	workers, chunk := recommendConfig(int64(runtime.NumCPU()*4*10*MB+123), MB)
+	assert.Equal(t, runtime.NumCPU(), workers)
+	assert.Equal(t, 10*MB, chunk)
+
+	_, chunk = recommendConfig(-1, GB)
+	assert.Equal(t, 64*MB, chunk)
+}
@@ -0,0 +1,290 @@
+package parallel_csv
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exporter writes the rows of SQL queries as CSV. The rows are formatted by several goroutines
+// and written in order
+type Exporter struct {
+	// Separator is the field separator, "," if empty
+	Separator string
+	// NoHeader skips the line holding the names of the columns
+	NoHeader bool
+	// Null is written for NULL values, which are empty fields by default
+	Null string
+	// TimeLayout formats the time values, time.RFC3339Nano if empty
+	TimeLayout string
+	// Workers is the number of batches formatted or partitions queried at once, the number of
+	// CPUs if 0
+	Workers int
+	// BatchRows is the number of rows formatted together, DefaultBatchRows if 0
+	BatchRows int
+}
+
+// WriteRows writes the rows to w and closes them. A single goroutine scans the rows, the batches
+// are formatted in parallel
+func (e Exporter) WriteRows(w io.Writer, rows *sql.Rows) error {
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	out := newExportWriter(w, e.workers())
+	if !e.NoHeader {
+		if part := out.part(); part != nil {
+			part <- e.formatHeader(columns)
+			close(part)
+		}
+	}
+	for {
+		batch, err := scanBatch(rows, len(columns), e.batchRows())
+		if err != nil {
+			out.close()
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		part := out.part()
+		if part == nil {
+			break
+		}
+		go func() {
+			part <- e.formatBatch(nil, batch)
+			close(part)
+		}()
+	}
+	return out.close()
+}
+
+// ExportQuery writes the rows of query to w, splitting them into partitions queried in parallel.
+// The partitions are ranges of the integer key column, found through its minimum and maximum,
+// and the rows are written ordered by key. The query and key are used verbatim in the SQL
+// statements, the args are the ones of the query
+func (e Exporter) ExportQuery(ctx context.Context, w io.Writer, db *sql.DB, query string, key string, partitions int, args ...interface{}) error {
+	var min, max sql.NullInt64
+	bounds := fmt.Sprintf("SELECT MIN(%s), MAX(%s) FROM (%s) pcsv_export", key, key, query)
+	if err := db.QueryRowContext(ctx, bounds, args...).Scan(&min, &max); err != nil {
+		return err
+	}
+
+	queries := []string{query}
+	if min.Valid && max.Valid {
+		queries = partitionQueries(query, key, min.Int64, max.Int64, partitions)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	out := newExportWriter(w, e.workers())
+	mu := sync.Mutex{}
+	var firstErr error
+	for i, query := range queries {
+		part := out.part()
+		if part == nil {
+			break
+		}
+		go func(header bool, query string) {
+			defer close(part)
+			if err := e.exportPart(ctx, part, out.failed, db, header, query, args); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				cancel()
+			}
+		}(i == 0 && !e.NoHeader, query)
+	}
+
+	err := out.close()
+	if firstErr != nil {
+		return firstErr
+	}
+	return err
+}
+
+// partitionQueries splits the keys between min and max, both included, into ranges of the same
+// size
+func partitionQueries(query string, key string, min int64, max int64, partitions int) []string {
+	span := uint64(max) - uint64(min)
+	if partitions < 1 {
+		partitions = 1
+	}
+	if span < uint64(partitions) {
+		partitions = int(span) + 1
+	}
+	step := span/uint64(partitions) + 1
+
+	queries := make([]string, partitions)
+	for i := range queries {
+		low := min + int64(uint64(i)*step)
+		high := max
+		if i < partitions-1 {
+			high = low + int64(step-1)
+		}
+		queries[i] = fmt.Sprintf("SELECT * FROM (%s) pcsv_export WHERE %s >= %d AND %s <= %d ORDER BY %s",
+			query, key, low, key, high, key)
+	}
+	return queries
+}
+
+// exportPart runs a partition, sending its rows to part in batches until failed is closed
+func (e Exporter) exportPart(ctx context.Context, part chan<- []byte, failed <-chan struct{}, db *sql.DB, header bool, query string, args []interface{}) error {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	if header {
+		part <- e.formatHeader(columns)
+	}
+	for {
+		batch, err := scanBatch(rows, len(columns), e.batchRows())
+		if err != nil || len(batch) == 0 {
+			return err
+		}
+		select {
+		case part <- e.formatBatch(nil, batch):
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-failed:
+			return nil
+		}
+	}
+}
+
+// scanBatch scans up to size rows, it returns an empty batch after the last one
+func scanBatch(rows *sql.Rows, columns int, size int) ([][]interface{}, error) {
+	var batch [][]interface{}
+	for len(batch) < size && rows.Next() {
+		values := make([]interface{}, columns)
+		pointers := make([]interface{}, columns)
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		batch = append(batch, values)
+	}
+	return batch, rows.Err()
+}
+
+func (e Exporter) formatHeader(columns []string) []byte {
+	fields := make([]string, len(columns))
+	for i, column := range columns {
+		fields[i] = quoteField(column, e.separator())
+	}
+	return []byte(strings.Join(fields, e.separator()) + LineBreak)
+}
+
+// formatBatch appends the rows of the batch as CSV to b
+func (e Exporter) formatBatch(b []byte, batch [][]interface{}) []byte {
+	separator := e.separator()
+	layout := e.TimeLayout
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+
+	for _, values := range batch {
+		for i, value := range values {
+			if i > 0 {
+				b = append(b, separator...)
+			}
+			if value == nil && e.Null != "" {
+				b = append(b, e.Null...)
+				continue
+			}
+			b = append(b, quoteField(formatValue(value, layout), separator)...)
+		}
+		b = append(b, LineBreak...)
+	}
+	return b
+}
+
+func (e Exporter) separator() string {
+	if e.Separator == "" {
+		return ","
+	}
+	return e.Separator
+}
+
+func (e Exporter) workers() int {
+	if e.Workers <= 0 {
+		return runtime.NumCPU()
+	}
+	return e.Workers
+}
+
+func (e Exporter) batchRows() int {
+	if e.BatchRows <= 0 {
+		return DefaultBatchRows
+	}
+	return e.BatchRows
+}
+
+// exportWriter writes the output of the parts in the order they were queued, each part sending
+// its chunks on its own channel and closing it. Once a write fails the rest is dropped
+type exportWriter struct {
+	pending chan chan []byte
+	failed  chan struct{}
+	done    chan struct{}
+	err     error
+}
+
+func newExportWriter(w io.Writer, workers int) *exportWriter {
+	out := &exportWriter{
+		pending: make(chan chan []byte, workers),
+		failed:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		defer close(out.done)
+		for part := range out.pending {
+			// the part is drained even after a failure, so that its sender is not blocked
+			for chunk := range part {
+				if out.err != nil {
+					continue
+				}
+				if _, err := w.Write(chunk); err != nil {
+					out.err = err
+					close(out.failed)
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// part queues a new part and returns its channel, or nil once a write has failed
+func (o *exportWriter) part() chan []byte {
+	part := make(chan []byte, 1)
+	select {
+	case o.pending <- part:
+		return part
+	case <-o.failed:
+		return nil
+	}
+}
+
+// close waits for the queued parts to be written and returns the first write error
+func (o *exportWriter) close() error {
+	close(o.pending)
+	<-o.done
+	return o.err
+}
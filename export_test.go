@@ -0,0 +1,162 @@
+package parallel_csv
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTable is a driver serving the rows of a table keyed by its first column. It answers the
+// bounds and partition queries of ExportQuery, any other query returns every row
+type fakeTable struct {
+	columns []string
+	rows    [][]driver.Value
+	mu      sync.Mutex
+	queries []string
+	fail    bool
+}
+
+func (d *fakeTable) Open(name string) (driver.Conn, error) { return fakeTableConn{d}, nil }
+
+type fakeTableConn struct{ table *fakeTable }
+
+func (c fakeTableConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("unsupported")
+}
+func (c fakeTableConn) Close() error              { return nil }
+func (c fakeTableConn) Begin() (driver.Tx, error) { return nil, errors.New("unsupported") }
+
+func (c fakeTableConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	t := c.table
+	t.mu.Lock()
+	t.queries = append(t.queries, query)
+	t.mu.Unlock()
+
+	if strings.HasPrefix(query, "SELECT MIN(") {
+		if len(t.rows) == 0 {
+			return &fakeRows{columns: []string{"min", "max"}, rows: [][]driver.Value{{nil, nil}}}, nil
+		}
+		return &fakeRows{columns: []string{"min", "max"}, rows: [][]driver.Value{{t.rows[0][0], t.rows[len(t.rows)-1][0]}}}, nil
+	}
+
+	low, high := int64(-1<<63), int64(1<<63-1)
+	if i := strings.Index(query, " WHERE "); i >= 0 {
+		var key string
+		fmt.Sscanf(query[i:], " WHERE %s >= %d AND %s <= %d", &key, &low, &key, &high)
+		if t.fail && low > t.rows[0][0].(int64) {
+			return nil, errors.New("connection lost")
+		}
+	}
+	rows := &fakeRows{columns: t.columns}
+	for _, row := range t.rows {
+		if key := row[0].(int64); key >= low && key <= high {
+			rows.rows = append(rows.rows, row)
+		}
+	}
+	return rows, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+var tableDrivers = 0
+
+// openFakeTable registers a new fake driver serving n rows and opens it
+func openFakeTable(n int) (*fakeTable, *sql.DB) {
+	fake := &fakeTable{columns: []string{"id", "name", "born", "score"}}
+	born := time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := 1; i <= n; i++ {
+		var score driver.Value
+		if i%2 == 0 {
+			score = float64(i) / 2
+		}
+		fake.rows = append(fake.rows, []driver.Value{int64(i), "name " + strconv.Itoa(i) + ", jr", born, score})
+	}
+	tableDrivers++
+	name := "faketable" + strconv.Itoa(tableDrivers)
+	sql.Register(name, fake)
+	db, _ := sql.Open(name, "")
+	return fake, db
+}
+
+// expectedExport is the CSV of the first n rows of a fake table
+func expectedExport(n int) string {
+	b := strings.Builder{}
+	b.WriteString("id;name;born;score\n")
+	for i := 1; i <= n; i++ {
+		score := "NULL"
+		if i%2 == 0 {
+			score = strconv.FormatFloat(float64(i)/2, 'g', -1, 64)
+		}
+		b.WriteString(fmt.Sprintf("%d;name %d, jr;2000-01-02T03:04:05Z;%s\n", i, i, score))
+	}
+	return b.String()
+}
+
+func TestExporterWriteRows(t *testing.T) {
+	_, db := openFakeTable(95)
+	rows, err := db.Query("SELECT * FROM people")
+	assert.Nil(t, err)
+
+	out := &bytes.Buffer{}
+	exporter := Exporter{Separator: ";", Null: "NULL", Workers: 3, BatchRows: 10}
+	assert.Nil(t, exporter.WriteRows(out, rows))
+	assert.Equal(t, expectedExport(95), out.String())
+}
+
+func TestExporterExportQuery(t *testing.T) {
+	fake, db := openFakeTable(95)
+
+	out := &bytes.Buffer{}
+	exporter := Exporter{Separator: ";", Null: "NULL", Workers: 2, BatchRows: 7}
+	assert.Nil(t, exporter.ExportQuery(context.Background(), out, db, "SELECT * FROM people", "id", 4))
+	assert.Equal(t, expectedExport(95), out.String())
+	assert.Len(t, fake.queries, 5)
+	assert.Contains(t, fake.queries, "SELECT * FROM (SELECT * FROM people) pcsv_export WHERE id >= 73 AND id <= 95 ORDER BY id")
+
+	_, db = openFakeTable(0)
+	out.Reset()
+	assert.Nil(t, exporter.ExportQuery(context.Background(), out, db, "SELECT * FROM people", "id", 4))
+	assert.Equal(t, "id;name;born;score\n", out.String())
+}
+
+func TestExporterExportQueryError(t *testing.T) {
+	fake, db := openFakeTable(95)
+	fake.fail = true
+
+	err := Exporter{}.ExportQuery(context.Background(), io.Discard, db, "SELECT * FROM people", "id", 4)
+	assert.EqualError(t, err, "connection lost")
+}
+
+func TestPartitionQueries(t *testing.T) {
+	assert.Len(t, partitionQueries("q", "k", 1, 2, 8), 2)
+	queries := partitionQueries("q", "k", -1<<63, 1<<63-1, 2)
+	assert.Equal(t, []string{
+		"SELECT * FROM (q) pcsv_export WHERE k >= -9223372036854775808 AND k <= -1 ORDER BY k",
+		"SELECT * FROM (q) pcsv_export WHERE k >= 0 AND k <= 9223372036854775807 ORDER BY k",
+	}, queries)
+}
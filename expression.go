@@ -0,0 +1,707 @@
+package parallel_csv
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const ExpressionSyntaxError = Error("invalid expression")
+const ExpressionError = Error("cannot evaluate expression")
+
+// Expression computes a value from the fields of a row, such as `price * qty` or
+// `substr(date, 0, 4)`. It is compiled once to a small bytecode, run by a stack machine for each
+// row.
+//
+// Columns are referred to by name, quoted with double quotes or backticks when they are not plain
+// identifiers. Strings are enclosed in single quotes. Operators are, from the lowest precedence:
+// comparisons (=, !=, <>, <, <=, >, >=) giving true or false, + and - along with || joining
+// strings, then *, / and %, then unary -. Arithmetic is exact on integers and done on floats
+// otherwise, / always giving a float. An empty operand makes the result of arithmetic and
+// comparisons empty, like NULL in SQL, while || joins it as an empty string.
+//
+// The functions are substr(s, start[, length]) counting characters from 0, upper(s), lower(s),
+// trim(s), len(s), concat(s, ...), coalesce(s, ...) returning the first non-empty value, abs(x),
+// round(x[, digits]) and if(condition, then, else), which only evaluates the branch taken. A
+// condition is false when it is empty, 0 or false
+type Expression struct {
+	expr string
+	code []instruction
+	// consts are the literals pushed by opConst, funcs the functions called by opCall
+	consts []string
+	funcs  []expressionFunc
+	// columns are the columns read by opColumn
+	columns []string
+}
+
+type opcode uint8
+
+const (
+	// opConst pushes consts[arg]
+	opConst opcode = iota
+	// opColumn pushes the field of columns[arg]
+	opColumn
+	// opCall replaces the n values on top of the stack with the result of funcs[arg]
+	opCall
+	// opJump continues at arg, opJumpUnless does it when the condition popped is false
+	opJump
+	opJumpUnless
+	opNegate
+	opAdd
+	opSubtract
+	opMultiply
+	opDivide
+	opModulo
+	opConcat
+	opEqual
+	opNotEqual
+	opLess
+	opLessOrEqual
+	opGreater
+	opGreaterOrEqual
+)
+
+type instruction struct {
+	op  opcode
+	arg int
+	n   int
+}
+
+var binaryOpcodes = map[string]opcode{
+	"+": opAdd, "-": opSubtract, "*": opMultiply, "/": opDivide, "%": opModulo, "||": opConcat,
+	"=": opEqual, "!=": opNotEqual, "<>": opNotEqual, "<": opLess, "<=": opLessOrEqual, ">": opGreater,
+	">=": opGreaterOrEqual,
+}
+
+// expressionFunc is a function callable from an expression with min to max arguments, any number
+// from min when max is -1
+type expressionFunc struct {
+	min, max int
+	fn       func(args []string) (string, error)
+}
+
+var expressionFuncs = map[string]expressionFunc{
+	"substr": {2, 3, substrFunc},
+	"upper":  {1, 1, func(args []string) (string, error) { return strings.ToUpper(args[0]), nil }},
+	"lower":  {1, 1, func(args []string) (string, error) { return strings.ToLower(args[0]), nil }},
+	"trim":   {1, 1, func(args []string) (string, error) { return strings.TrimSpace(args[0]), nil }},
+	"len": {1, 1, func(args []string) (string, error) {
+		return strconv.Itoa(utf8.RuneCountInString(args[0])), nil
+	}},
+	"concat": {1, -1, func(args []string) (string, error) { return strings.Join(args, ""), nil }},
+	"coalesce": {1, -1, func(args []string) (string, error) {
+		for _, arg := range args {
+			if strings.TrimSpace(arg) != "" {
+				return arg, nil
+			}
+		}
+		return "", nil
+	}},
+	"abs":   {1, 1, absFunc},
+	"round": {1, 2, roundFunc},
+}
+
+// ParseExpression compiles an expression
+func ParseExpression(expr string) (*Expression, error) {
+	tokens, err := lexExpression([]rune(expr))
+	if err != nil {
+		return nil, err
+	}
+	return compileExpression(expr, tokens)
+}
+
+// MustParseExpression is like ParseExpression but panics if the expression is invalid
+func MustParseExpression(expr string) *Expression {
+	e, err := ParseExpression(expr)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+func (e *Expression) String() string {
+	return e.expr
+}
+
+func compileExpression(expr string, tokens []token) (*Expression, error) {
+	c := &expressionCompiler{tokens: tokens, e: &Expression{expr: expr}, slots: map[string]int{}}
+	if err := c.comparison(); err != nil {
+		return nil, err
+	}
+	if c.pos < len(c.tokens) {
+		return nil, c.errorf("unexpected %q", c.tokens[c.pos].text)
+	}
+	return c.e, nil
+}
+
+// ComputedColumn is a Transform setting the column Name to the value of Expr, appended unless the
+// header already has it. The expression sees the columns as they are before the transform
+type ComputedColumn struct {
+	Name string
+	Expr *Expression
+}
+
+// ParseComputedColumns parses definitions of computed columns separated by semicolons, such as
+// `total = price * qty; year = substr(date, 0, 4)`
+func ParseComputedColumns(definitions string) ([]ComputedColumn, error) {
+	runes := []rune(definitions)
+	tokens, err := lexExpression(runes)
+	if err != nil {
+		return nil, err
+	}
+
+	var columns []ComputedColumn
+	for len(tokens) > 0 {
+		end := 0
+		for end < len(tokens) && !(tokens[end].kind == punctToken && tokens[end].text == ";") {
+			end++
+		}
+		definition := tokens[:end]
+		if len(definition) < 3 || definition[0].kind != identToken ||
+			definition[1].kind != operatorToken || definition[1].text != "=" {
+			return nil, fmt.Errorf("%w: expected a definition such as total = price * qty", ExpressionSyntaxError)
+		}
+
+		to := len(runes)
+		if end < len(tokens) {
+			to = tokens[end].pos
+		}
+		text := strings.TrimSpace(string(runes[definition[2].pos:to]))
+		expr, err := compileExpression(text, definition[2:])
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, ComputedColumn{Name: definition[0].text, Expr: expr})
+
+		if end < len(tokens) {
+			end++
+		}
+		tokens = tokens[end:]
+	}
+	return columns, nil
+}
+
+func (c ComputedColumn) Bind(header []string) ([]string, RowFunc, error) {
+	indexes, err := headerIndexes(header, c.Expr.columns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	target := headerIndex(header, c.Name)
+	output := header
+	if target == -1 && len(header) > 0 {
+		output = append(append(make([]string, 0, len(header)+1), header...), c.Name)
+	}
+
+	return output, func(fields []string) ([]string, error) {
+		value, err := c.Expr.eval(indexes, fields)
+		if err != nil {
+			return nil, err
+		}
+		if target == -1 {
+			return append(fields, value), nil
+		}
+		for len(fields) <= target {
+			fields = append(fields, "")
+		}
+		fields[target] = value
+		return fields, nil
+	}, nil
+}
+
+// eval runs the expression on a row, indexes being the positions of its columns
+func (e *Expression) eval(indexes []int, fields []string) (string, error) {
+	stack := make([]string, 0, 8)
+	for pc := 0; pc < len(e.code); pc++ {
+		in := e.code[pc]
+		switch in.op {
+		case opConst:
+			stack = append(stack, e.consts[in.arg])
+		case opColumn:
+			value := ""
+			if index := indexes[in.arg]; index < len(fields) {
+				value = fields[index]
+			}
+			stack = append(stack, value)
+		case opCall:
+			args := stack[len(stack)-in.n:]
+			value, err := e.funcs[in.arg].fn(args)
+			if err != nil {
+				return "", fmt.Errorf("%w: %s: %v", ExpressionError, e.expr, err)
+			}
+			stack = append(stack[:len(stack)-in.n], value)
+		case opJump:
+			pc = in.arg - 1
+		case opJumpUnless:
+			condition := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if !truthy(condition) {
+				pc = in.arg - 1
+			}
+		case opNegate:
+			value, err := arithmetic(opSubtract, "0", stack[len(stack)-1])
+			if err != nil {
+				return "", fmt.Errorf("%w: %s: %v", ExpressionError, e.expr, err)
+			}
+			stack[len(stack)-1] = value
+		default:
+			a, b := stack[len(stack)-2], stack[len(stack)-1]
+			value, err := binaryOp(in.op, a, b)
+			if err != nil {
+				return "", fmt.Errorf("%w: %s: %v", ExpressionError, e.expr, err)
+			}
+			stack = append(stack[:len(stack)-2], value)
+		}
+	}
+	return stack[0], nil
+}
+
+// truthy tells whether a value is a true condition
+func truthy(value string) bool {
+	value = strings.TrimSpace(value)
+	if x, err := strconv.ParseFloat(value, 64); err == nil {
+		return x != 0
+	}
+	return value != "" && !strings.EqualFold(value, "false")
+}
+
+func binaryOp(op opcode, a, b string) (string, error) {
+	switch op {
+	case opConcat:
+		return a + b, nil
+	case opEqual, opNotEqual, opLess, opLessOrEqual, opGreater, opGreaterOrEqual:
+		a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+		if a == "" || b == "" {
+			return "", nil
+		}
+		c := compareValues(a, b)
+		switch op {
+		case opEqual:
+			return strconv.FormatBool(c == 0), nil
+		case opNotEqual:
+			return strconv.FormatBool(c != 0), nil
+		case opLess:
+			return strconv.FormatBool(c < 0), nil
+		case opLessOrEqual:
+			return strconv.FormatBool(c <= 0), nil
+		case opGreater:
+			return strconv.FormatBool(c > 0), nil
+		default:
+			return strconv.FormatBool(c >= 0), nil
+		}
+	default:
+		return arithmetic(op, a, b)
+	}
+}
+
+// arithmetic applies an arithmetic operator, exactly when both operands are integers and the
+// result fits in an int64
+func arithmetic(op opcode, a, b string) (string, error) {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	if a == "" || b == "" {
+		return "", nil
+	}
+
+	x, errX := strconv.ParseInt(a, 10, 64)
+	y, errY := strconv.ParseInt(b, 10, 64)
+	if errX == nil && errY == nil {
+		if r, ok := intArithmetic(op, x, y); ok {
+			return strconv.FormatInt(r, 10), nil
+		}
+	}
+
+	f, err := parseNumber(a)
+	if err != nil {
+		return "", err
+	}
+	g, err := parseNumber(b)
+	if err != nil {
+		return "", err
+	}
+	var r float64
+	switch op {
+	case opAdd:
+		r = f + g
+	case opSubtract:
+		r = f - g
+	case opMultiply:
+		r = f * g
+	case opDivide, opModulo:
+		if g == 0 {
+			return "", fmt.Errorf("division by zero")
+		}
+		if r = f / g; op == opModulo {
+			r = math.Mod(f, g)
+		}
+	}
+	return formatNumber(r), nil
+}
+
+// intArithmetic computes the operations on integers, it returns false on overflow and for the
+// operations which are not exact on integers
+func intArithmetic(op opcode, x, y int64) (int64, bool) {
+	switch op {
+	case opAdd:
+		r := x + y
+		return r, (r > x) == (y > 0)
+	case opSubtract:
+		r := x - y
+		return r, (r < x) == (y > 0)
+	case opMultiply:
+		if x == 0 || y == 0 {
+			return 0, true
+		}
+		r := x * y
+		return r, r/y == x && !(x == -1 && y == math.MinInt64) && !(y == -1 && x == math.MinInt64)
+	case opModulo:
+		switch y {
+		case 0:
+			// the float path reports the division by zero
+			return 0, false
+		case -1:
+			// x % -1 is 0, math.MinInt64 included
+			return 0, true
+		}
+		return x % y, true
+	default:
+		return 0, false
+	}
+}
+
+func parseNumber(value string) (float64, error) {
+	x, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", value)
+	}
+	return x, nil
+}
+
+func formatNumber(x float64) string {
+	return strconv.FormatFloat(x, 'f', -1, 64)
+}
+
+// substrFunc returns length characters of s from start, counted from 0, the rest of s without length
+func substrFunc(args []string) (string, error) {
+	runes := []rune(args[0])
+	start, err := strconv.Atoi(strings.TrimSpace(args[1]))
+	if err != nil {
+		return "", fmt.Errorf("substr start %q is not an integer", args[1])
+	}
+	end := len(runes)
+	if len(args) == 3 {
+		length, err := strconv.Atoi(strings.TrimSpace(args[2]))
+		if err != nil {
+			return "", fmt.Errorf("substr length %q is not an integer", args[2])
+		}
+		if length >= 0 && start+length < end {
+			end = start + length
+		}
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start >= end {
+		return "", nil
+	}
+	return string(runes[start:end]), nil
+}
+
+func absFunc(args []string) (string, error) {
+	value := strings.TrimSpace(args[0])
+	if strings.HasPrefix(value, "-") {
+		return arithmetic(opSubtract, "0", value)
+	}
+	if value == "" {
+		return "", nil
+	}
+	_, err := parseNumber(value)
+	return value, err
+}
+
+// roundFunc rounds x to digits decimal places, to tens, hundreds and so on when digits is negative
+func roundFunc(args []string) (string, error) {
+	value := strings.TrimSpace(args[0])
+	if value == "" {
+		return "", nil
+	}
+	x, err := parseNumber(value)
+	if err != nil {
+		return "", err
+	}
+	digits := 0
+	if len(args) == 2 {
+		if digits, err = strconv.Atoi(strings.TrimSpace(args[1])); err != nil {
+			return "", fmt.Errorf("round digits %q is not an integer", args[1])
+		}
+	}
+
+	scale := math.Pow(10, float64(digits))
+	x = math.Round(x*scale) / scale
+	if digits < 0 {
+		digits = 0
+	}
+	return strconv.FormatFloat(x, 'f', digits, 64), nil
+}
+
+// lexExpression splits an expression in tokens, their positions counted in runes
+func lexExpression(runes []rune) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case strings.ContainsRune("(),;", r):
+			tokens = append(tokens, token{kind: punctToken, text: string(r), pos: i})
+			i++
+		case strings.ContainsRune("+-*/%=!<>|", r):
+			j := i + 1
+			if j < len(runes) && (runes[j] == '=' && strings.ContainsRune("!<>", r) ||
+				r == '<' && runes[j] == '>' || r == '|' && runes[j] == '|') {
+				j++
+			}
+			op := string(runes[i:j])
+			if _, ok := binaryOpcodes[op]; !ok {
+				return nil, fmt.Errorf("%w: unexpected %s at %d", ExpressionSyntaxError, op, i+1)
+			}
+			tokens = append(tokens, token{kind: operatorToken, text: op, pos: i})
+			i = j
+		case r == '\'' || r == '"' || r == '`':
+			text, end, ok := lexQuoted(runes, i)
+			if !ok {
+				return nil, fmt.Errorf("%w: unterminated quote at %d", ExpressionSyntaxError, i+1)
+			}
+			kind := identToken
+			if r == '\'' {
+				kind = stringToken
+			}
+			// quoted names are always columns, never functions
+			tokens = append(tokens, token{kind: kind, text: text, pos: i, quoted: true})
+			i = end
+		case unicode.IsDigit(r) || r == '.':
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.' ||
+				(runes[j] == 'e' || runes[j] == 'E') ||
+				(runes[j] == '+' || runes[j] == '-') && (runes[j-1] == 'e' || runes[j-1] == 'E')) {
+				j++
+			}
+			text := string(runes[i:j])
+			if _, err := strconv.ParseFloat(text, 64); err != nil {
+				return nil, fmt.Errorf("%w: invalid number %s at %d", ExpressionSyntaxError, text, i+1)
+			}
+			tokens = append(tokens, token{kind: numberToken, text: text, pos: i})
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, token{kind: identToken, text: string(runes[i:j]), pos: i})
+			i = j
+		default:
+			return nil, fmt.Errorf("%w: unexpected %q at %d", ExpressionSyntaxError, r, i+1)
+		}
+	}
+	return tokens, nil
+}
+
+// expressionCompiler parses the tokens by recursive descent, emitting the code of each node
+// after the code of its operands
+type expressionCompiler struct {
+	tokens []token
+	pos    int
+	e      *Expression
+	// slots are the positions of the columns in e.columns
+	slots map[string]int
+}
+
+func (c *expressionCompiler) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s: %s", ExpressionSyntaxError, c.e.expr, fmt.Sprintf(format, args...))
+}
+
+func (c *expressionCompiler) peek() (token, bool) {
+	if c.pos == len(c.tokens) {
+		return token{}, false
+	}
+	return c.tokens[c.pos], true
+}
+
+// acceptOperator consumes the next token if it is one of the operators
+func (c *expressionCompiler) acceptOperator(operators ...string) (string, bool) {
+	t, ok := c.peek()
+	if !ok || t.kind != operatorToken {
+		return "", false
+	}
+	for _, operator := range operators {
+		if t.text == operator {
+			c.pos++
+			return operator, true
+		}
+	}
+	return "", false
+}
+
+func (c *expressionCompiler) expect(punct string) error {
+	t, ok := c.peek()
+	if !ok || t.kind != punctToken || t.text != punct {
+		if !ok {
+			return c.errorf("expected %s at the end", punct)
+		}
+		return c.errorf("expected %s, found %q", punct, t.text)
+	}
+	c.pos++
+	return nil
+}
+
+func (c *expressionCompiler) emit(op opcode, arg int, n int) int {
+	c.e.code = append(c.e.code, instruction{op: op, arg: arg, n: n})
+	return len(c.e.code) - 1
+}
+
+// binaryLevel parses operands joined by the operators, left to right
+func (c *expressionCompiler) binaryLevel(operand func() error, operators ...string) error {
+	if err := operand(); err != nil {
+		return err
+	}
+	for {
+		operator, ok := c.acceptOperator(operators...)
+		if !ok {
+			return nil
+		}
+		if err := operand(); err != nil {
+			return err
+		}
+		c.emit(binaryOpcodes[operator], 0, 0)
+	}
+}
+
+func (c *expressionCompiler) comparison() error {
+	return c.binaryLevel(c.additive, "=", "!=", "<>", "<", "<=", ">", ">=")
+}
+
+func (c *expressionCompiler) additive() error {
+	return c.binaryLevel(c.multiplicative, "+", "-", "||")
+}
+
+func (c *expressionCompiler) multiplicative() error {
+	return c.binaryLevel(c.unary, "*", "/", "%")
+}
+
+func (c *expressionCompiler) unary() error {
+	if _, ok := c.acceptOperator("-"); ok {
+		if err := c.unary(); err != nil {
+			return err
+		}
+		c.emit(opNegate, 0, 0)
+		return nil
+	}
+	return c.primary()
+}
+
+func (c *expressionCompiler) primary() error {
+	t, ok := c.peek()
+	if !ok {
+		return c.errorf("unexpected end of expression")
+	}
+	c.pos++
+
+	switch {
+	case t.kind == numberToken || t.kind == stringToken:
+		c.e.consts = append(c.e.consts, t.text)
+		c.emit(opConst, len(c.e.consts)-1, 0)
+		return nil
+	case t.kind == punctToken && t.text == "(":
+		if err := c.comparison(); err != nil {
+			return err
+		}
+		return c.expect(")")
+	case t.kind == identToken:
+		if next, ok := c.peek(); ok && !t.quoted && next.kind == punctToken && next.text == "(" {
+			c.pos++
+			return c.call(strings.ToLower(t.text))
+		}
+		slot, ok := c.slots[t.text]
+		if !ok {
+			slot = len(c.e.columns)
+			c.slots[t.text] = slot
+			c.e.columns = append(c.e.columns, t.text)
+		}
+		c.emit(opColumn, slot, 0)
+		return nil
+	default:
+		return c.errorf("unexpected %q", t.text)
+	}
+}
+
+// call compiles a call to the function name, whose opening parenthesis has been consumed
+func (c *expressionCompiler) call(name string) error {
+	if name == "if" {
+		return c.conditional()
+	}
+	fn, ok := expressionFuncs[name]
+	if !ok {
+		return c.errorf("unknown function %s", name)
+	}
+
+	n := 0
+	if t, ok := c.peek(); !ok || t.kind != punctToken || t.text != ")" {
+		for {
+			if err := c.comparison(); err != nil {
+				return err
+			}
+			n++
+			if t, ok := c.peek(); !ok || t.kind != punctToken || t.text != "," {
+				break
+			}
+			c.pos++
+		}
+	}
+	if err := c.expect(")"); err != nil {
+		return err
+	}
+	if n < fn.min || fn.max >= 0 && n > fn.max {
+		return c.errorf("%s takes %s arguments, got %d", name, arity(fn), n)
+	}
+
+	c.e.funcs = append(c.e.funcs, fn)
+	c.emit(opCall, len(c.e.funcs)-1, n)
+	return nil
+}
+
+// conditional compiles if(condition, then, else) to jumps around the branch not taken
+func (c *expressionCompiler) conditional() error {
+	if err := c.comparison(); err != nil {
+		return err
+	}
+	if err := c.expect(","); err != nil {
+		return err
+	}
+	jumpUnless := c.emit(opJumpUnless, 0, 0)
+	if err := c.comparison(); err != nil {
+		return err
+	}
+	if err := c.expect(","); err != nil {
+		return err
+	}
+	jump := c.emit(opJump, 0, 0)
+	c.e.code[jumpUnless].arg = len(c.e.code)
+	if err := c.comparison(); err != nil {
+		return err
+	}
+	c.e.code[jump].arg = len(c.e.code)
+	return c.expect(")")
+}
+
+func arity(fn expressionFunc) string {
+	switch {
+	case fn.max == -1:
+		return fmt.Sprintf("at least %d", fn.min)
+	case fn.min == fn.max:
+		return strconv.Itoa(fn.min)
+	default:
+		return fmt.Sprintf("%d to %d", fn.min, fn.max)
+	}
+}
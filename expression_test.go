@@ -0,0 +1,104 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestExpression(t *testing.T) {
+	header := []string{"price", "qty", "date", "name", "note"}
+	fields := []string{"2.5", "4", "2021-03-15", " Anna ", ""}
+	tests := []struct {
+		expr     string
+		expected string
+	}{
+		{"price * qty", "10"},
+		{"qty * 3 - 2 * 2", "8"},
+		{"-(qty + 1) * 2", "-10"},
+		{"qty / 8", "0.5"},
+		{"qty % 3", "1"},
+		{"qty % -1", "0"},
+		{"9223372036854775807 + 1", "9223372036854776000"},
+		{"substr(date, 0, 4)", "2021"},
+		{"substr(date, 5)", "03-15"},
+		{"substr(name, 10, 2)", ""},
+		{"upper(trim(name)) || '!'", "ANNA!"},
+		{"len(trim(name))", "4"},
+		{"concat(date, ' ', qty)", "2021-03-15 4"},
+		{"coalesce(note, 'n/a')", "n/a"},
+		{"note * 2", ""},
+		{"qty >= 4", "true"},
+		{"price < 10", "true"},
+		{"name = 'Anna'", "true"},
+		{"note > 1", ""},
+		{"note != 'x'", ""},
+		{"note = ''", ""},
+		{"if(note < 1, 'less', 'unknown')", "unknown"},
+		{"note || 'x'", "x"},
+		{"if(qty > 3, 'many', 'few')", "many"},
+		{"if(note, note / 0, 'empty')", "empty"},
+		{"round(price * 1.234, 2)", "3.09"},
+		{"round(1234, -2)", "1200"},
+		{"abs(-3.5)", "3.5"},
+		{`"price" + 1`, "3.5"},
+		{"1.5e2 + 0", "150"},
+	}
+
+	for _, test := range tests {
+		expr, err := ParseExpression(test.expr)
+		if !assert.Nil(t, err, test.expr) {
+			continue
+		}
+		indexes, err := headerIndexes(header, expr.columns)
+		assert.Nil(t, err, test.expr)
+		value, err := expr.eval(indexes, fields)
+		assert.Nil(t, err, test.expr)
+		assert.Equal(t, test.expected, value, test.expr)
+	}
+}
+
+func TestExpressionErrors(t *testing.T) {
+	for _, expr := range []string{"", "price *", "(price", "price qty", "upper()", "substr(a)", "nope(a)",
+		"a ! b", "'open", "if(a, b)", "1.2.3"} {
+		_, err := ParseExpression(expr)
+		assert.ErrorIs(t, err, ExpressionSyntaxError, expr)
+	}
+
+	expr := MustParseExpression("name * 2")
+	_, err := expr.eval([]int{0}, []string{"anna"})
+	assert.ErrorIs(t, err, ExpressionError)
+	_, err = MustParseExpression("1 / 0").eval(nil, nil)
+	assert.ErrorIs(t, err, ExpressionError)
+	r, ok := intArithmetic(opModulo, math.MinInt64, -1)
+	assert.True(t, ok)
+	assert.Equal(t, int64(0), r)
+	for _, expr := range []string{"7 % 0", "7 % zero", "7.5 % zero", "7 / zero"} {
+		_, err = MustParseExpression(expr).eval([]int{0}, []string{"0"})
+		assert.ErrorIs(t, err, ExpressionError, expr)
+		assert.Contains(t, err.Error(), "division by zero", expr)
+	}
+}
+
+func TestComputedColumns(t *testing.T) {
+	columns, err := ParseComputedColumns("total = amount * 2; customer = upper(customer); 'x' = 1")
+	assert.NotNil(t, err)
+
+	columns, err = ParseComputedColumns("total = amount * 2; customer = upper(customer) || ';'")
+	assert.Nil(t, err)
+	assert.Len(t, columns, 2)
+	assert.Equal(t, "amount * 2", columns[0].Expr.String())
+
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 8
+	config.Transforms = []Transform{columns[0], columns[1]}
+	sink := &memorySink{}
+	assert.Nil(t, NewProcessor(strings.NewReader(orders), &config).Copy(sink))
+	assert.Equal(t, []string{"order", "customer", "amount", "total"}, sink.header)
+	assert.Equal(t, []string{"1", "C1;", "10", "20"}, sink.rows[0])
+	assert.Len(t, sink.rows, 4)
+
+	config.Transforms = []Transform{ComputedColumn{Name: "x", Expr: MustParseExpression("missing + 1")}}
+	assert.ErrorIs(t, NewProcessor(strings.NewReader(orders), &config).Copy(&memorySink{}), ColumnNotFoundError)
+}
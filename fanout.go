@@ -0,0 +1,166 @@
+package parallel_csv
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FanOutPolicy decides what a FanOutSink does once one of its sinks fails
+type FanOutPolicy int
+
+const (
+	// FailAll fails the run as soon as a sink fails, the other sinks being aborted with it
+	FailAll FanOutPolicy = iota
+	// ContinueOthers stops writing to a failed sink and keeps writing to the others. The run fails
+	// right away only when every sink has failed, otherwise Close reports the failed ones
+	ContinueOthers
+)
+
+// FanOutBranch is one of the sinks of a FanOutSink
+type FanOutBranch struct {
+	Sink Sink
+	// BatchRows gathers the rows until there are that many before writing them, 0 writing them as
+	// received. The rows gathered are copied, since they outlive the chunk they come from
+	BatchRows int
+}
+
+// FanOutError holds the errors of the sinks which failed, by their position among the branches
+type FanOutError struct {
+	Errors map[int]error
+}
+
+func (e *FanOutError) Error() string {
+	indexes := make([]int, 0, len(e.Errors))
+	for index := range e.Errors {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	messages := make([]string, len(indexes))
+	for i, index := range indexes {
+		messages[i] = fmt.Sprintf("sink %d: %v", index, e.Errors[index])
+	}
+	return strings.Join(messages, "; ")
+}
+
+// FanOutSink writes the rows to several sinks in a single pass over the input, such as a file, a
+// message queue and a collector of statistics. The sinks are written concurrently, each with its
+// own batching, and must not modify the rows. Failed writes are not retried, since the other sinks
+// already have the rows
+type FanOutSink struct {
+	Policy   FanOutPolicy
+	branches []*fanOutBranch
+}
+
+// fanOutBranch is a branch with its state, err being set once it failed
+type fanOutBranch struct {
+	FanOutBranch
+	pending [][]string
+	err     error
+}
+
+// NewFanOutSink creates a sink writing to every branch
+func NewFanOutSink(policy FanOutPolicy, branches ...FanOutBranch) *FanOutSink {
+	s := &FanOutSink{Policy: policy}
+	for _, branch := range branches {
+		s.branches = append(s.branches, &fanOutBranch{FanOutBranch: branch})
+	}
+	return s
+}
+
+func (s *FanOutSink) Open(header []string) error {
+	return s.each(func(b *fanOutBranch) error {
+		return b.Sink.Open(header)
+	})
+}
+
+func (s *FanOutSink) Write(rows [][]string) error {
+	err := s.each(func(b *fanOutBranch) error {
+		if b.BatchRows <= 0 {
+			return b.Sink.Write(rows)
+		}
+		for _, row := range rows {
+			b.pending = append(b.pending, cloneFields(row))
+		}
+		if len(b.pending) < b.BatchRows {
+			return nil
+		}
+		err := b.Sink.Write(b.pending)
+		b.pending = nil
+		return err
+	})
+	// the sinks which succeeded would get the rows twice
+	if err != nil {
+		return permanentError{err}
+	}
+	return nil
+}
+
+// Close writes the rows still gathered and closes the sinks, the failed ones being aborted. It
+// returns a FanOutError if any sink failed
+func (s *FanOutSink) Close() error {
+	err := s.each(func(b *fanOutBranch) error {
+		if len(b.pending) == 0 {
+			return nil
+		}
+		err := b.Sink.Write(b.pending)
+		b.pending = nil
+		return err
+	})
+	if err != nil && s.Policy == FailAll {
+		s.Abort(err)
+		return err
+	}
+
+	for _, b := range s.branches {
+		if b.err != nil {
+			abortSink(b.Sink, b.err)
+		}
+	}
+	s.each(func(b *fanOutBranch) error {
+		return b.Sink.Close()
+	})
+	return s.failure(true)
+}
+
+// Abort aborts every sink
+func (s *FanOutSink) Abort(err error) {
+	for _, b := range s.branches {
+		abortSink(b.Sink, err)
+	}
+}
+
+// each runs fn concurrently on the branches which have not failed, and returns the error the
+// policy requires
+func (s *FanOutSink) each(fn func(b *fanOutBranch) error) error {
+	wg := sync.WaitGroup{}
+	for _, b := range s.branches {
+		if b.err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(b *fanOutBranch) {
+			defer wg.Done()
+			b.err = fn(b)
+		}(b)
+	}
+	wg.Wait()
+	return s.failure(false)
+}
+
+// failure returns the errors of the failed branches if the run must fail, that is when one has
+// failed under FailAll, when all have or, if done, when any has
+func (s *FanOutSink) failure(done bool) error {
+	errs := map[int]error{}
+	for i, b := range s.branches {
+		if b.err != nil {
+			errs[i] = b.err
+		}
+	}
+	if len(errs) == 0 || s.Policy == ContinueOthers && !done && len(errs) < len(s.branches) {
+		return nil
+	}
+	return &FanOutError{Errors: errs}
+}
@@ -0,0 +1,72 @@
+package parallel_csv
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFanOutSink(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 16
+	config.ReuseBuffers = true
+	p := NewProcessor(strings.NewReader(numbers(100)), &config)
+
+	direct, batched := &memorySink{}, &memorySink{}
+	sink := NewFanOutSink(FailAll, FanOutBranch{Sink: direct}, FanOutBranch{Sink: batched, BatchRows: 30})
+	assert.Nil(t, p.Copy(sink))
+
+	assert.Equal(t, []string{"n"}, batched.header)
+	assert.Len(t, direct.rows, 100)
+	assert.Len(t, batched.rows, 100)
+	// the rows gathered outlive the recycled buffers
+	for i, row := range batched.rows {
+		assert.Equal(t, []string{strconv.Itoa(i + 1)}, row)
+	}
+	assert.True(t, direct.closed && batched.closed)
+}
+
+func TestFanOutSinkFailAll(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 16
+	config.Retry = RetryPolicy{Attempts: 3}
+	path := filepath.Join(t.TempDir(), "out.csv")
+	p := NewProcessor(strings.NewReader(numbers(100)), &config)
+
+	file := NewFileSink(path, ",")
+	err := p.Copy(NewFanOutSink(FailAll, FanOutBranch{Sink: file}, FanOutBranch{Sink: &flakySink{failures: 1}}))
+
+	var fanOut *FanOutError
+	assert.True(t, errors.As(err, &fanOut))
+	assert.ErrorIs(t, fanOut.Errors[1], errTransient)
+	assert.Len(t, fanOut.Errors, 1)
+	// the failure is not retried, and the file sink has been aborted
+	assert.Equal(t, int64(0), p.Stats().Retries)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFanOutSinkContinueOthers(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 16
+	p := NewProcessor(strings.NewReader(numbers(100)), &config)
+
+	healthy, flaky := &memorySink{}, &flakySink{failures: 1}
+	err := p.Copy(NewFanOutSink(ContinueOthers, FanOutBranch{Sink: healthy}, FanOutBranch{Sink: flaky}))
+
+	var fanOut *FanOutError
+	assert.True(t, errors.As(err, &fanOut))
+	assert.Equal(t, "sink 1: "+errTransient.Error(), fanOut.Error())
+	assert.Len(t, healthy.rows, 100)
+	assert.True(t, healthy.closed && flaky.closed)
+
+	// a run fails right away once every sink has
+	p = NewProcessor(strings.NewReader(numbers(100)), &config)
+	err = p.Copy(NewFanOutSink(ContinueOthers, FanOutBranch{Sink: &flakySink{failures: 1}}))
+	assert.True(t, errors.As(err, &fanOut))
+	assert.Less(t, p.Stats().RowsDelivered, int64(100))
+}
@@ -0,0 +1,162 @@
+package parallel_csv
+
+import (
+	"strings"
+)
+
+const Quote = `"`
+const BareQuoteError = Error(`bare " in non-quoted field`)
+const QuoteError = Error(`extraneous " after quoted field`)
+const UnterminatedQuoteError = Error(`quoted field is not terminated`)
+
+// splitRecord splits a row in its fields, unquoting the quoted ones as described by RFC 4180.
+// In strict mode a misplaced quote is an error, otherwise it is kept as part of the field.
+// Along with the error it returns the 1-based position of the offending character
+func splitRecord(row string, separator string, strict bool) ([]string, int, error) {
+	if separator == "" || !strings.Contains(row, Quote) {
+		return strings.Split(row, separator), 0, nil
+	}
+
+	fields := make([]string, 0, strings.Count(row, separator)+1)
+	pos := 0
+	for {
+		if !strings.HasPrefix(row[pos:], Quote) {
+			end := strings.Index(row[pos:], separator)
+			if end == -1 {
+				end = len(row)
+			} else {
+				end += pos
+			}
+
+			field := row[pos:end]
+			if i := strings.Index(field, Quote); strict && i != -1 {
+				return nil, pos + i + 1, BareQuoteError
+			}
+			fields = append(fields, field)
+
+			if end == len(row) {
+				return fields, 0, nil
+			}
+			pos = end + len(separator)
+			continue
+		}
+
+		field := strings.Builder{}
+		i := pos + len(Quote)
+		for {
+			end := strings.Index(row[i:], Quote)
+			if end == -1 {
+				if strict {
+					return nil, pos + 1, UnterminatedQuoteError
+				}
+				field.WriteString(row[i:])
+				i = len(row)
+				break
+			}
+
+			field.WriteString(row[i : i+end])
+			i += end + len(Quote)
+			// a doubled quote is an escaped quote
+			if !strings.HasPrefix(row[i:], Quote) {
+				break
+			}
+			field.WriteString(Quote)
+			i += len(Quote)
+		}
+
+		if i < len(row) && !strings.HasPrefix(row[i:], separator) {
+			if strict {
+				return nil, i + 1, QuoteError
+			}
+
+			// whatever follows the closing quote is part of the field
+			end := strings.Index(row[i:], separator)
+			if end == -1 {
+				end = len(row) - i
+			}
+			field.WriteString(row[i : i+end])
+			i += end
+		}
+
+		fields = append(fields, field.String())
+		if i == len(row) {
+			return fields, 0, nil
+		}
+		pos = i + len(separator)
+	}
+}
+
+// quoteField quotes a field if it contains a separator, a quote or a line break
+func quoteField(field string, separator string) string {
+	return quoteFieldWith(field, separator, Quote, false)
+}
+
+// quoteFieldWith quotes a field with quote, doubling the quotes it contains, if it contains a
+// separator, a quote or a line break or if always is set
+func quoteFieldWith(field string, separator string, quote string, always bool) string {
+	if !always && !strings.Contains(field, quote) && !strings.Contains(field, LineBreak) &&
+		(separator == "" || !strings.Contains(field, separator)) {
+		return field
+	}
+	return quote + strings.ReplaceAll(field, quote, quote+quote) + quote
+}
+
+// checkRow parses a row and checks its number of fields, 0 expected fields means any number
+func (p processor) checkRow(row string, expected int) (int, error) {
+	var fields []string
+	var column int
+	var err error
+	if csv, ok := p.parser.(CSVParser); ok {
+		fields, column, err = splitRecord(row, csv.Separator, p.config.Strict)
+	} else {
+		fields, err = p.parser.Fields(row)
+	}
+	if err != nil {
+		return column, err
+	}
+	if expected > 0 && len(fields) != expected {
+		return 0, FieldCountError
+	}
+	return 0, nil
+}
+
+// validateRows drops the rows which are malformed or whose number of fields differs from the
+// expected one. It returns false if the run has been aborted
+func (p processor) validateRows(state *runState, chunk Chunk) (Chunk, bool) {
+	expected := len(chunk.Header)
+	if expected == 0 && p.config.Strict {
+		expected = state.fieldCount
+	}
+	if !p.config.ValidateFieldCount && !p.config.Strict {
+		expected = 0
+	}
+
+	valid := chunk
+	valid.Rows = chunk.Rows[:0:0]
+	valid.lines = []int{}
+
+	for i, row := range chunk.Rows {
+		if chunk.Cancelled() {
+			return chunk, false
+		}
+		column, err := p.checkRow(row, expected)
+		if err == nil {
+			valid.Rows = append(valid.Rows, row)
+			valid.lines = append(valid.lines, chunk.Line(i))
+			continue
+		}
+
+		parseErr := &ParseError{Line: chunk.Line(i), Column: column, Err: err}
+		p.reject(state, row, parseErr)
+		state.fail(parseErr)
+		if state.aborted() {
+			return chunk, false
+		}
+	}
+
+	// every row is valid, the line numbers are still consecutive
+	if len(valid.Rows) == len(chunk.Rows) {
+		return chunk, true
+	}
+	return valid, true
+}
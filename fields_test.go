@@ -0,0 +1,86 @@
+package parallel_csv
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestSplitRecord(t *testing.T) {
+	tests := []struct {
+		row    string
+		fields []string
+	}{
+		{`a,b,c`, []string{"a", "b", "c"}},
+		{`a,,`, []string{"a", "", ""}},
+		{`"a,b",c`, []string{"a,b", "c"}},
+		{`"say ""hi""",x`, []string{`say "hi"`, "x"}},
+		{`"",x`, []string{"", "x"}},
+		{`x,"y"`, []string{"x", "y"}},
+	}
+
+	for _, test := range tests {
+		fields, _, err := splitRecord(test.row, ",", true)
+		assert.Nil(t, err, test.row)
+		assert.Equal(t, test.fields, fields, test.row)
+	}
+}
+
+func TestSplitRecordMalformed(t *testing.T) {
+	tests := []struct {
+		row    string
+		column int
+		err    error
+		fields []string
+	}{
+		{`a,b"c`, 4, BareQuoteError, []string{"a", `b"c`}},
+		{`a,"bc`, 3, UnterminatedQuoteError, []string{"a", "bc"}},
+		{`"a"b,c`, 4, QuoteError, []string{"ab", "c"}},
+	}
+
+	for _, test := range tests {
+		_, column, err := splitRecord(test.row, ",", true)
+		assert.ErrorIs(t, err, test.err, test.row)
+		assert.Equal(t, test.column, column, test.row)
+
+		// lenient mode keeps the quotes it cannot make sense of
+		fields, _, err := splitRecord(test.row, ",", false)
+		assert.Nil(t, err, test.row)
+		assert.Equal(t, test.fields, fields, test.row)
+	}
+}
+
+func TestStrictMode(t *testing.T) {
+	input := "1,\"a,b\"\n2,c\"d\n3,\"e\n4,f,g\n5,h\n"
+	var errs []error
+	p := NewProcessor(strings.NewReader(input), &Config{
+		NumberOfWorkers: 1,
+		HeaderConfig: HeaderConfig{
+			Separator: ",",
+		},
+		BytesPerWorker: KB,
+		ErrorPolicy:    SkipOnError,
+		ErrorHandler: func(err error) {
+			errs = append(errs, err)
+		},
+		Strict: true,
+	})
+
+	var rows []string
+	err := p.Run(func(header []string, chunk []string) {
+		rows = append(rows, chunk...)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1,\"a,b\"", "5,h"}, rows)
+
+	assert.Len(t, errs, 3)
+	var parseErr *ParseError
+	assert.True(t, errors.As(errs[0], &parseErr))
+	assert.Equal(t, 2, parseErr.Line)
+	assert.Equal(t, 4, parseErr.Column)
+	assert.ErrorIs(t, errs[0], BareQuoteError)
+	assert.ErrorIs(t, errs[1], UnterminatedQuoteError)
+	assert.ErrorIs(t, errs[2], FieldCountError)
+	assert.Equal(t, "line 4: wrong number of fields", errs[2].Error())
+}
@@ -0,0 +1,82 @@
+package parallel_csv
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// FileSink writes rows as CSV to the file at Path atomically: they go to a temporary file in the
+// same directory, renamed to Path once the run succeeds and removed if it fails. Consumers
+// watching the directory never see a partial output, and an existing file is only replaced by a
+// complete one
+type FileSink struct {
+	*CSVSink
+	Path string
+	file *os.File
+}
+
+// NewFileSink creates a sink writing to the file at path, using separator between fields
+func NewFileSink(path string, separator string) *FileSink {
+	return &FileSink{CSVSink: NewCSVSink(nil, separator), Path: path}
+}
+
+func (s *FileSink) Open(header []string) error {
+	file, err := createTemp(s.Path)
+	if err != nil {
+		return err
+	}
+	s.file = file
+	s.CSVSink.w.Reset(file)
+	return s.CSVSink.Open(header)
+}
+
+// Close flushes the rows and renames the temporary file to Path
+func (s *FileSink) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	file := s.file
+	s.file = nil
+
+	err := s.CSVSink.Close()
+	return commitTemp(file, s.Path, err)
+}
+
+// Abort removes the temporary file, leaving Path untouched
+func (s *FileSink) Abort(error) {
+	if s.file != nil {
+		discardTemp(s.file)
+		s.file = nil
+	}
+}
+
+// createTemp creates the temporary file replaced by commitTemp with path, in its directory so
+// that the rename is atomic. Its name does not end like path, not to match its patterns
+func createTemp(path string) (*os.File, error) {
+	return os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-")
+}
+
+// commitTemp closes the temporary file and renames it to path, unless err, the error of
+// writing it, or closing it fails. The file is removed when not renamed
+func commitTemp(file *os.File, path string, err error) error {
+	// CreateTemp makes the file readable by its owner only
+	if err == nil {
+		err = file.Chmod(0o644)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
+
+// discardTemp closes and removes the temporary file
+func discardTemp(file *os.File) {
+	file.Close()
+	os.Remove(file.Name())
+}
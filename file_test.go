@@ -0,0 +1,70 @@
+package parallel_csv
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileSink(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.csv")
+	assert.Nil(t, os.WriteFile(path, []byte("old\n"), 0o644))
+
+	sink := NewFileSink(path, ";")
+	assert.Nil(t, NewProcessor(strings.NewReader(customers), nil).Copy(sink))
+	content, _ := os.ReadFile(path)
+	assert.Equal(t, "id;name;country\nc1;anna;IT\nc2;bob, jr;FR\nc3;carla;DE\n", string(content))
+
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 1)
+}
+
+func TestFileSinkFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.csv")
+	assert.Nil(t, os.WriteFile(path, []byte("old\n"), 0o644))
+
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 8
+	config.Transforms = []Transform{failingTransform{after: "c2"}}
+	err := NewProcessor(strings.NewReader(customers), &config).Copy(NewFileSink(path, ","))
+	assert.NotNil(t, err)
+
+	// the previous output is left as it was, without temporary files
+	content, _ := os.ReadFile(path)
+	assert.Equal(t, "old\n", string(content))
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 1)
+}
+
+func TestShardedSinkFailure(t *testing.T) {
+	dir := t.TempDir()
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 8
+	config.Transforms = []Transform{failingTransform{after: "c3"}}
+	sink := NewShardedSink(filepath.Join(dir, "out-"), ",", 1, 0)
+	assert.NotNil(t, NewProcessor(strings.NewReader(customers), &config).Copy(sink))
+
+	// the completed file is kept, the one holding c2 is removed
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, []string{filepath.Join(dir, "out-000001.csv")}, sink.Files)
+}
+
+// failingTransform fails on the row whose first field is after
+type failingTransform struct {
+	after string
+}
+
+func (f failingTransform) Bind(header []string) ([]string, RowFunc, error) {
+	return header, func(fields []string) ([]string, error) {
+		if fields[0] == f.after {
+			return nil, errors.New("failed")
+		}
+		return fields, nil
+	}, nil
+}
@@ -0,0 +1,181 @@
+package parallel_csv
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const FlightNotFoundError = Error("flight not found")
+
+// ArrowField is a column of the Arrow schema of the batches written by ArrowSink
+type ArrowField struct {
+	Name string
+	// Type is the type of the column in the schema, StringType for the columns it does not
+	// list. Its values are int64, float64, bool, time.Time or string, or the values of its
+	// decoder
+	Type     ColumnType
+	Nullable bool
+}
+
+// ArrowBatch is a record batch in columnar layout: Columns[i] holds the values of the field i for
+// each of the Rows rows, nil being null
+type ArrowBatch struct {
+	Rows    int
+	Columns [][]interface{}
+}
+
+// ArrowWriter writes the Arrow record batches of a stream, such as a Flight stream. With
+// apache/arrow-go, it creates the arrow.Schema of the fields and a flight.Writer on the
+// stream of a DoGet call, then builds each batch in an arrow.Record with array.NewRecordBuilder
+// and writes it
+type ArrowWriter interface {
+	// WriteSchema is called once, before the first batch
+	WriteSchema(fields []ArrowField) error
+	WriteBatch(batch ArrowBatch) error
+}
+
+// ArrowSink writes the rows as Arrow record batches of BatchRows rows, the last one possibly
+// shorter. Empty values are null, except in the string columns of the schema
+type ArrowSink struct {
+	// Schema gives the types of the columns, the others being strings
+	Schema *Schema
+	// BatchRows is the number of rows of a batch, DefaultBatchRows if 0
+	BatchRows int
+	writer    ArrowWriter
+	types     typedRow
+	batch     ArrowBatch
+}
+
+// NewArrowSink creates a sink writing the record batches with writer
+func NewArrowSink(writer ArrowWriter) *ArrowSink {
+	return &ArrowSink{writer: writer}
+}
+
+func (s *ArrowSink) Open(header []string) error {
+	var err error
+	if s.types, err = bindTypes(header, s.Schema); err != nil {
+		return err
+	}
+	if s.BatchRows <= 0 {
+		s.BatchRows = DefaultBatchRows
+	}
+
+	columns := map[string]ColumnSchema{}
+	if s.Schema != nil {
+		for _, column := range s.Schema.Columns {
+			columns[column.Name] = column
+		}
+	}
+	fields := make([]ArrowField, len(header))
+	for i, name := range header {
+		field := ArrowField{Name: name, Type: StringType, Nullable: true}
+		if column, ok := columns[name]; ok && column.Type != "" {
+			field.Type, field.Nullable = column.Type, !column.Required
+		}
+		fields[i] = field
+	}
+	s.batch.Columns = make([][]interface{}, len(fields))
+	return s.writer.WriteSchema(fields)
+}
+
+func (s *ArrowSink) Write(rows [][]string) error {
+	for _, row := range rows {
+		if len(row) != len(s.batch.Columns) {
+			return fmt.Errorf("%w: %d fields for %d columns", FieldCountError, len(row), len(s.batch.Columns))
+		}
+		values, err := s.types(row)
+		if err != nil {
+			return err
+		}
+		for i, value := range values {
+			s.batch.Columns[i] = append(s.batch.Columns[i], value)
+		}
+		if s.batch.Rows++; s.batch.Rows == s.BatchRows {
+			if err := s.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// flush writes the pending rows as a batch
+func (s *ArrowSink) flush() error {
+	if s.batch.Rows == 0 {
+		return nil
+	}
+	err := s.writer.WriteBatch(s.batch)
+	// the writer may keep the batch, the next one gets new columns
+	s.batch = ArrowBatch{Columns: make([][]interface{}, len(s.batch.Columns))}
+	return err
+}
+
+// Close writes the last batch
+func (s *ArrowSink) Close() error {
+	return s.flush()
+}
+
+// FlightDataset is a processed CSV served by a FlightServer
+type FlightDataset struct {
+	// Open returns the processor of a new read of the dataset, such as one over a new reader of
+	// its file with Config.Transforms, since a processor runs only once
+	Open func() (Processor, error)
+	// Schema gives the types of the columns, the others being strings
+	Schema *Schema
+}
+
+// FlightServer serves processed CSVs as Arrow Flight streams, so that Python or Spark consumers
+// pull them without intermediate files. It holds the datasets and runs them; the Flight service of
+// apache/arrow-go wraps it: ListFlights sends a FlightInfo for each of Flights, whose ticket is the
+// name of the dataset, and DoGet calls FlightServer.DoGet with the ticket and an ArrowWriter on
+// the stream of the call
+type FlightServer struct {
+	// BatchRows is the number of rows of the batches, DefaultBatchRows if 0
+	BatchRows int
+	mu        sync.Mutex
+	datasets  map[string]FlightDataset
+}
+
+// NewFlightServer creates a server with no dataset
+func NewFlightServer() *FlightServer {
+	return &FlightServer{datasets: map[string]FlightDataset{}}
+}
+
+// Register serves a dataset under name, replacing the one with the same name
+func (s *FlightServer) Register(name string, dataset FlightDataset) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.datasets[name] = dataset
+}
+
+// Flights returns the names of the datasets, sorted
+func (s *FlightServer) Flights() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.datasets))
+	for name := range s.datasets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DoGet processes the dataset of the ticket and writes its rows to w as record batches. It fails
+// with FlightNotFoundError when no dataset has that name
+func (s *FlightServer) DoGet(ticket string, w ArrowWriter) error {
+	s.mu.Lock()
+	dataset, ok := s.datasets[ticket]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", FlightNotFoundError, ticket)
+	}
+
+	p, err := dataset.Open()
+	if err != nil {
+		return err
+	}
+	sink := NewArrowSink(w)
+	sink.Schema, sink.BatchRows = dataset.Schema, s.BatchRows
+	return p.Copy(sink)
+}
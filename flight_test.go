@@ -0,0 +1,69 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// memoryArrowWriter keeps the schema and the batches written
+type memoryArrowWriter struct {
+	fields  []ArrowField
+	batches []ArrowBatch
+}
+
+func (w *memoryArrowWriter) WriteSchema(fields []ArrowField) error {
+	w.fields = fields
+	return nil
+}
+
+func (w *memoryArrowWriter) WriteBatch(batch ArrowBatch) error {
+	w.batches = append(w.batches, batch)
+	return nil
+}
+
+func TestArrowSink(t *testing.T) {
+	w := &memoryArrowWriter{}
+	sink := NewArrowSink(w)
+	sink.Schema = &Schema{Columns: []ColumnSchema{{Name: "age", Type: IntegerType, Required: true}}}
+	sink.BatchRows = 2
+
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 8
+	input := "name,age\nanna,34\n,28\ncarla,41\n"
+	assert.Nil(t, NewProcessor(strings.NewReader(input), &config).Copy(sink))
+
+	assert.Equal(t, []ArrowField{{Name: "name", Type: StringType, Nullable: true}, {Name: "age", Type: IntegerType}}, w.fields)
+	assert.Equal(t, []ArrowBatch{
+		{Rows: 2, Columns: [][]interface{}{{"anna", nil}, {int64(34), int64(28)}}},
+		{Rows: 1, Columns: [][]interface{}{{"carla"}, {int64(41)}}},
+	}, w.batches)
+}
+
+func TestFlightServer(t *testing.T) {
+	s := NewFlightServer()
+	opened := 0
+	s.Register("people", FlightDataset{
+		Open: func() (Processor, error) {
+			opened++
+			config := GetDefaultConfig()
+			config.Where = MustParseWhere("age > 30")
+			return NewProcessor(strings.NewReader("name,age\nanna,34\nbob,28\ncarla,41\n"), &config), nil
+		},
+		Schema: &Schema{Columns: []ColumnSchema{{Name: "age", Type: FloatType}}},
+	})
+	s.Register("empty", FlightDataset{})
+	assert.Equal(t, []string{"empty", "people"}, s.Flights())
+
+	// every read runs the dataset again
+	for i := 1; i <= 2; i++ {
+		w := &memoryArrowWriter{}
+		assert.Nil(t, s.DoGet("people", w))
+		assert.Equal(t, i, opened)
+		assert.Len(t, w.batches, 1)
+		assert.Equal(t, [][]interface{}{{"anna", "carla"}, {34.0, 41.0}}, w.batches[0].Columns)
+	}
+
+	err := s.DoGet("missing", &memoryArrowWriter{})
+	assert.ErrorIs(t, err, FlightNotFoundError)
+}
@@ -0,0 +1,154 @@
+package parallel_csv
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultGCSChunkBytes is the size of the chunks uploaded by GCSSink when not set
+	DefaultGCSChunkBytes = 16 * MB
+	// gcsChunkUnit divides the size of every chunk of a resumable upload but the last
+	gcsChunkUnit = 256 * KB
+)
+
+// DefaultGCSRetry is the retry policy of GCSSink when not set
+var DefaultGCSRetry = RetryPolicy{Attempts: 5, Backoff: 500 * time.Millisecond, MaxBackoff: 10 * time.Second}
+
+// GCSClient runs resumable uploads to Cloud Storage. With the JSON API, CreateSession posts to
+// the upload URL with uploadType=resumable, UploadChunk puts the bytes to the session URI with
+// their Content-Range and Status puts an empty body with the range "bytes */*"
+type GCSClient interface {
+	// CreateSession starts a resumable upload of the object, returning its session URI
+	CreateSession(ctx context.Context, bucket string, object string) (session string, err error)
+	// UploadChunk uploads the bytes starting at offset, final telling that they end the object.
+	// It returns the size of the object persisted by the server, which may not hold the whole
+	// chunk
+	UploadChunk(ctx context.Context, session string, offset int64, chunk []byte, final bool) (persisted int64, err error)
+	// Status returns the size of the object persisted by the server
+	Status(ctx context.Context, session string) (persisted int64, err error)
+	// Cancel ends the session, discarding what has been uploaded
+	Cancel(ctx context.Context, session string) error
+}
+
+// GCSSink writes rows as CSV straight to a Cloud Storage object, through a resumable upload. The
+// output is uploaded in chunks of ChunkBytes as the rows come: a chunk failing is retried from
+// where the server stopped, as told by GCSClient.Status. The object is created once the run
+// succeeds, the upload is cancelled if it fails
+type GCSSink struct {
+	*CSVSink
+	Bucket string
+	Object string
+	// ChunkBytes is the size of the chunks, DefaultGCSChunkBytes if 0. It must be a multiple of
+	// 256KB
+	ChunkBytes int
+	// Retry runs again the chunks failing, DefaultGCSRetry if it has no attempts
+	Retry   RetryPolicy
+	ctx     context.Context
+	client  GCSClient
+	session string
+	// buffer holds the bytes not persisted yet, starting at offset
+	buffer []byte
+	offset int64
+}
+
+// NewGCSSink creates a sink uploading the rows to object in bucket through client, using
+// separator between fields
+func NewGCSSink(ctx context.Context, client GCSClient, bucket string, object string, separator string) *GCSSink {
+	return &GCSSink{CSVSink: NewCSVSink(nil, separator), Bucket: bucket, Object: object, ctx: ctx, client: client}
+}
+
+func (s *GCSSink) Open(header []string) error {
+	if s.ChunkBytes <= 0 {
+		s.ChunkBytes = DefaultGCSChunkBytes
+	}
+	if s.ChunkBytes%gcsChunkUnit != 0 {
+		return fmt.Errorf("GCSSink.ChunkBytes must be a multiple of 256KB, got %d", s.ChunkBytes)
+	}
+	if s.Retry.Attempts == 0 {
+		s.Retry = DefaultGCSRetry
+	}
+
+	session, err := s.client.CreateSession(s.ctx, s.Bucket, s.Object)
+	if err != nil {
+		return fmt.Errorf("upload to gs://%s/%s: %w", s.Bucket, s.Object, err)
+	}
+	s.session = session
+	s.CSVSink.w.Reset(gcsWriter{s})
+	return s.CSVSink.Open(header)
+}
+
+// gcsWriter uploads the output of the CSVSink every ChunkBytes
+type gcsWriter struct {
+	sink *GCSSink
+}
+
+func (w gcsWriter) Write(b []byte) (int, error) {
+	s := w.sink
+	s.buffer = append(s.buffer, b...)
+	for len(s.buffer) >= s.ChunkBytes {
+		if err := s.upload(s.offset+int64(s.ChunkBytes), false); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// upload sends the buffered bytes before end. Only the final chunk must be persisted whole, the
+// server keeping the others in multiples of 256KB
+func (s *GCSSink) upload(end int64, final bool) error {
+	err := s.Retry.do(nil, func(int, error) {}, func() error {
+		start := s.offset
+		persisted, err := s.client.UploadChunk(s.ctx, s.session, start, s.buffer[:end-start], final)
+		if err != nil {
+			// the next attempt resumes from the last byte received
+			if persisted, statusErr := s.client.Status(s.ctx, s.session); statusErr == nil {
+				s.persisted(persisted)
+			}
+			return err
+		}
+		s.persisted(persisted)
+		if s.offset < end && (final || s.offset == start) {
+			return fmt.Errorf("%d bytes out of %d persisted", s.offset, end)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("upload to gs://%s/%s at byte %d: %w", s.Bucket, s.Object, s.offset, err)
+	}
+	return nil
+}
+
+// persisted drops the bytes the server has persisted from the buffer
+func (s *GCSSink) persisted(offset int64) {
+	if offset > s.offset {
+		s.buffer = s.buffer[offset-s.offset:]
+		s.offset = offset
+	}
+}
+
+// Close uploads the last chunk, which creates the object, or cancels the upload if it fails
+func (s *GCSSink) Close() error {
+	if s.session == "" {
+		return nil
+	}
+
+	err := s.CSVSink.Close()
+	if err == nil {
+		err = s.upload(s.offset+int64(len(s.buffer)), true)
+	}
+	if err != nil {
+		s.client.Cancel(s.ctx, s.session)
+	}
+	s.session = ""
+	return err
+}
+
+// Abort cancels the upload, no object is created
+func (s *GCSSink) Abort(error) {
+	if s.session != "" {
+		s.client.Cancel(s.ctx, s.session)
+		s.session = ""
+	}
+}
@@ -0,0 +1,78 @@
+package parallel_csv
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeGCS keeps the object of a single session. The calls numbered in fail break after receiving
+// half of the chunk
+type fakeGCS struct {
+	object    []byte
+	calls     int
+	fail      map[int]bool
+	final     bool
+	cancelled bool
+}
+
+func (f *fakeGCS) CreateSession(ctx context.Context, bucket string, object string) (string, error) {
+	return "session-1", nil
+}
+
+func (f *fakeGCS) UploadChunk(ctx context.Context, session string, offset int64, chunk []byte, final bool) (int64, error) {
+	f.calls++
+	if offset != int64(len(f.object)) {
+		return 0, errors.New("invalid offset")
+	}
+	if f.fail[f.calls] {
+		f.object = append(f.object, chunk[:len(chunk)/2]...)
+		return 0, errors.New("connection reset")
+	}
+	f.object = append(f.object, chunk...)
+	f.final = final
+	return int64(len(f.object)), nil
+}
+
+func (f *fakeGCS) Status(ctx context.Context, session string) (int64, error) {
+	return int64(len(f.object)), nil
+}
+
+func (f *fakeGCS) Cancel(ctx context.Context, session string) error {
+	f.cancelled = true
+	return nil
+}
+
+func TestGCSSink(t *testing.T) {
+	client := &fakeGCS{fail: map[int]bool{2: true}}
+	sink := NewGCSSink(context.Background(), client, "bucket", "out.csv", ",")
+	sink.ChunkBytes = 256 * KB
+	sink.Retry = RetryPolicy{Attempts: 2, Backoff: time.Millisecond}
+	input := numbers(100000)
+	assert.Nil(t, NewProcessor(strings.NewReader(input), nil).Copy(sink))
+
+	// the second chunk is resumed where it broke
+	assert.Equal(t, input, string(client.object))
+	assert.Equal(t, 4, client.calls)
+	assert.True(t, client.final)
+	assert.False(t, client.cancelled)
+}
+
+func TestGCSSinkFailure(t *testing.T) {
+	client := &fakeGCS{fail: map[int]bool{2: true, 3: true}}
+	sink := NewGCSSink(context.Background(), client, "bucket", "out.csv", ",")
+	sink.ChunkBytes = 256 * KB
+	sink.Retry = RetryPolicy{Attempts: 2}
+	err := NewProcessor(strings.NewReader(numbers(100000)), nil).Copy(sink)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "connection reset")
+	assert.True(t, client.cancelled)
+	assert.False(t, client.final)
+
+	sink = NewGCSSink(context.Background(), &fakeGCS{}, "bucket", "out.csv", ",")
+	sink.ChunkBytes = 100 * KB
+	assert.NotNil(t, sink.Open(nil))
+}
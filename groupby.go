@@ -0,0 +1,308 @@
+package parallel_csv
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AggFunc is an aggregation function of GroupBy
+type AggFunc string
+
+const (
+	// Count counts the rows of the group, or the non-empty values of Agg.Column when set
+	Count AggFunc = "count"
+	Sum   AggFunc = "sum"
+	Avg   AggFunc = "avg"
+	// Min and Max compare values as numbers when both are numbers, as strings otherwise
+	Min AggFunc = "min"
+	Max AggFunc = "max"
+	// Distinct counts the distinct non-empty values
+	Distinct AggFunc = "distinct"
+)
+
+// Agg is an aggregation computed for every group
+type Agg struct {
+	Func   AggFunc
+	Column string
+	// Name is the name of the result column, func(column) if empty
+	Name string
+}
+
+func (a Agg) name() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return fmt.Sprintf("%s(%s)", a.Func, a.Column)
+}
+
+// GroupByResult holds one row per group: the key columns followed by the aggregations.
+// Rows are sorted by key
+type GroupByResult struct {
+	Header []string
+	Rows   [][]string
+}
+
+// aggState is the partial result of an aggregation over part of a group
+type aggState struct {
+	count    int
+	sum      float64
+	min, max string
+	distinct map[string]struct{}
+}
+
+func (s *aggState) add(fn AggFunc, value string) {
+	if value == "" {
+		return
+	}
+
+	if s.count == 0 || compareValues(value, s.min) < 0 {
+		s.min = value
+	}
+	if s.count == 0 || compareValues(value, s.max) > 0 {
+		s.max = value
+	}
+	s.count++
+
+	switch fn {
+	case Sum, Avg:
+		// values have been checked to be numbers by the caller
+		x, _ := strconv.ParseFloat(value, 64)
+		s.sum += x
+	case Distinct:
+		if s.distinct == nil {
+			s.distinct = map[string]struct{}{}
+		}
+		s.distinct[value] = struct{}{}
+	}
+}
+
+func (s *aggState) merge(other *aggState) {
+	if other.count > 0 {
+		if s.count == 0 || compareValues(other.min, s.min) < 0 {
+			s.min = other.min
+		}
+		if s.count == 0 || compareValues(other.max, s.max) > 0 {
+			s.max = other.max
+		}
+	}
+	s.count += other.count
+	s.sum += other.sum
+
+	for value := range other.distinct {
+		if s.distinct == nil {
+			s.distinct = map[string]struct{}{}
+		}
+		s.distinct[value] = struct{}{}
+	}
+}
+
+func (s *aggState) result(fn AggFunc) string {
+	switch fn {
+	case Count:
+		return strconv.Itoa(s.count)
+	case Sum:
+		return formatFloat(s.sum)
+	case Avg:
+		if s.count == 0 {
+			return ""
+		}
+		return formatFloat(s.sum / float64(s.count))
+	case Min:
+		return s.min
+	case Max:
+		return s.max
+	default:
+		return strconv.Itoa(len(s.distinct))
+	}
+}
+
+func formatFloat(x float64) string {
+	return strconv.FormatFloat(x, 'f', -1, 64)
+}
+
+// groups maps each key to the partial aggregations of its group
+type groups map[string][]*aggState
+
+// add adds the values of a row, one for each aggregation, to the group of key
+func (g groups) add(key string, values []string, aggs []Agg) {
+	states, ok := g[key]
+	if !ok {
+		states = make([]*aggState, len(aggs))
+		for i := range states {
+			states[i] = &aggState{}
+		}
+		g[key] = states
+	}
+
+	for i, agg := range aggs {
+		if agg.Func == Count && agg.Column == "" {
+			states[i].count++
+			continue
+		}
+		states[i].add(agg.Func, values[i])
+	}
+}
+
+func (g groups) merge(other groups) {
+	for key, states := range other {
+		existing, ok := g[key]
+		if !ok {
+			g[key] = states
+			continue
+		}
+		for i, state := range states {
+			existing[i].merge(state)
+		}
+	}
+}
+
+func (g groups) rows(aggs []Agg) [][]string {
+	rows := make([][]string, 0, len(g))
+	for key, states := range g {
+		row := strings.Split(key, keySeparator)
+		for i, agg := range aggs {
+			row = append(row, states[i].result(agg.Func))
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// aggValues extracts the input of each aggregation from a row. The values are copies, they do
+// not point into the row
+func (p processor) aggValues(fields []string, indexes []int, aggs []Agg, line int) ([]string, error) {
+	values := make([]string, len(aggs))
+	for i, index := range indexes {
+		if index == -1 || index >= len(fields) {
+			continue
+		}
+
+		values[i] = cloneString(strings.TrimSpace(fields[index]))
+		if aggs[i].Func != Sum && aggs[i].Func != Avg || values[i] == "" {
+			continue
+		}
+		if _, err := strconv.ParseFloat(values[i], 64); err != nil {
+			return nil, &ParseError{Line: line, Column: index + 1, Err: TypeMismatchError}
+		}
+	}
+	return values, nil
+}
+
+// GroupBy groups the rows by the key columns and computes the aggregations of each group. Each
+// worker aggregates the rows it processes in its own table, tables are merged at the end. When
+// Config.SpillDir is set rows are partitioned by key in temporary files instead, and aggregated one
+// partition at a time, so that high-cardinality keys do not need to fit in memory
+func (p processor) GroupBy(keys []string, aggs []Agg) (*GroupByResult, error) {
+	keyIndexes, err := p.keyIndexes(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	aggIndexes := make([]int, len(aggs))
+	result := &GroupByResult{Header: append([]string{}, keys...)}
+	for i, agg := range aggs {
+		aggIndexes[i] = -1
+		if agg.Column != "" {
+			if aggIndexes[i] = p.ColumnIndex(agg.Column); aggIndexes[i] == -1 {
+				return nil, fmt.Errorf("%w: %s", ColumnNotFoundError, agg.Column)
+			}
+		} else if agg.Func != Count {
+			return nil, fmt.Errorf("%s needs a column", agg.Func)
+		}
+		result.Header = append(result.Header, agg.name())
+	}
+
+	if p.config.SpillDir != "" {
+		err = p.groupByOnDisk(keyIndexes, aggIndexes, aggs, result)
+	} else {
+		err = p.groupByInMemory(keyIndexes, aggIndexes, aggs, result)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result.Rows, func(i, j int) bool {
+		a, b := result.Rows[i], result.Rows[j]
+		for k := range keys {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+	return result, nil
+}
+
+func (p processor) groupByInMemory(keyIndexes []int, aggIndexes []int, aggs []Agg, result *GroupByResult) error {
+	partials := make([]groups, p.config.NumberOfWorkers)
+	for i := range partials {
+		partials[i] = groups{}
+	}
+
+	err := p.RunChunks(func(chunk Chunk) error {
+		for i, row := range chunk.Rows {
+			if chunk.Cancelled() {
+				return errCancelled
+			}
+			fields := p.split(row)
+			values, err := p.aggValues(fields, aggIndexes, aggs, chunk.Line(i))
+			if err != nil {
+				return err
+			}
+			partials[chunk.Worker].add(keyOf(fields, keyIndexes), values, aggs)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, partial := range partials[1:] {
+		partials[0].merge(partial)
+	}
+	result.Rows = partials[0].rows(aggs)
+	return nil
+}
+
+func (p processor) groupByOnDisk(keyIndexes []int, aggIndexes []int, aggs []Agg, result *GroupByResult) error {
+	spilled, err := newSpill(p.config.SpillDir)
+	if err != nil {
+		return err
+	}
+	defer spilled.close()
+
+	err = p.RunChunks(func(chunk Chunk) error {
+		for i, row := range chunk.Rows {
+			if chunk.Cancelled() {
+				return errCancelled
+			}
+			fields := p.split(row)
+			values, err := p.aggValues(fields, aggIndexes, aggs, chunk.Line(i))
+			if err != nil {
+				return err
+			}
+			if err := spilled.write(append([]string{keyOf(fields, keyIndexes)}, values...)...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < spillPartitions; i++ {
+		partition := groups{}
+		err := spilled.each(i, func(record []string) error {
+			partition.add(record[0], record[1:], aggs)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		result.Rows = append(result.Rows, partition.rows(aggs)...)
+	}
+	return nil
+}
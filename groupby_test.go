@@ -0,0 +1,55 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+const sales = "country,city,amount,customer\n" +
+	"IT,rome,10,anna\n" +
+	"FR,paris,5,bob\n" +
+	"IT,milan,2.5,anna\n" +
+	"IT,rome,7.5,carla\n" +
+	"FR,lyon,,dario\n" +
+	"DE,berlin,1,elena\n"
+
+func TestGroupBy(t *testing.T) {
+	for _, spillDir := range []string{"", t.TempDir()} {
+		config := GetDefaultConfig()
+		config.BytesPerWorker = 16
+		config.SpillDir = spillDir
+		p := NewProcessor(strings.NewReader(sales), &config)
+
+		result, err := p.GroupBy([]string{"country"}, []Agg{
+			{Func: Count},
+			{Func: Sum, Column: "amount"},
+			{Func: Avg, Column: "amount", Name: "average"},
+			{Func: Min, Column: "city"},
+			{Func: Max, Column: "amount"},
+			{Func: Distinct, Column: "customer"},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"country", "count()", "sum(amount)", "average", "min(city)", "max(amount)", "distinct(customer)"}, result.Header)
+		assert.Equal(t, [][]string{
+			{"DE", "1", "1", "1", "berlin", "1", "1"},
+			{"FR", "2", "5", "5", "lyon", "5", "2"},
+			{"IT", "3", "20", "6.666666666666667", "milan", "10", "2"},
+		}, result.Rows)
+	}
+}
+
+func TestGroupByCompositeKey(t *testing.T) {
+	p := NewProcessor(strings.NewReader(sales), nil)
+
+	result, err := p.GroupBy([]string{"country", "city"}, []Agg{{Func: Count}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"IT", "rome", "2"}, result.Rows[len(result.Rows)-1])
+}
+
+func TestGroupByNotANumber(t *testing.T) {
+	p := NewProcessor(strings.NewReader(sales), nil)
+
+	_, err := p.GroupBy([]string{"country"}, []Agg{{Func: Sum, Column: "city"}})
+	assert.ErrorIs(t, err, TypeMismatchError)
+}
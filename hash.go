@@ -0,0 +1,196 @@
+package parallel_csv
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// HashAlgorithm is the hash function of RowHash and RowHasher
+type HashAlgorithm int
+
+const (
+	// HashXX64 is the 64 bits xxHash, fast and good enough to detect changes, written as 16
+	// hexadecimal digits
+	HashXX64 HashAlgorithm = iota
+	// HashSHA256 is SHA-256, written as 64 hexadecimal digits
+	HashSHA256
+)
+
+// DefaultHashColumn is the name of the column added by RowHash when Column is empty
+const DefaultHashColumn = "row_hash"
+
+// RowHasher computes a stable hash of rows, over all of their fields or the ones of some columns.
+// The fields are hashed with their length, so that moving a separator changes the hash. It can be
+// used by several workers at once
+type RowHasher struct {
+	algorithm HashAlgorithm
+	// indexes are the fields hashed, all of them if nil
+	indexes []int
+	parser  RecordParser
+}
+
+// newRowHasher resolves the columns against the header, an empty list meaning every field
+func newRowHasher(header []string, algorithm HashAlgorithm, columns []string) (*RowHasher, error) {
+	if algorithm != HashXX64 && algorithm != HashSHA256 {
+		return nil, fmt.Errorf("unknown hash algorithm %d", algorithm)
+	}
+	h := &RowHasher{algorithm: algorithm}
+	if len(columns) > 0 {
+		indexes, err := headerIndexes(header, columns)
+		if err != nil {
+			return nil, err
+		}
+		h.indexes = indexes
+	}
+	return h, nil
+}
+
+// RowHasher returns a hasher of the rows of p over the given columns, every field if none is
+// given, so that jobs can hash the rows they receive with HashRow
+func (p processor) RowHasher(algorithm HashAlgorithm, columns ...string) (*RowHasher, error) {
+	h, err := newRowHasher(p.header, algorithm, columns)
+	if err != nil {
+		return nil, err
+	}
+	h.parser = p.parser
+	return h, nil
+}
+
+// Hash returns the hash of the fields of a row, in hexadecimal. Missing fields hash as empty
+func (h *RowHasher) Hash(fields []string) string {
+	var buffer []byte
+	var length [binary.MaxVarintLen64]byte
+	add := func(field string) {
+		buffer = append(buffer, length[:binary.PutUvarint(length[:], uint64(len(field)))]...)
+		buffer = append(buffer, field...)
+	}
+	if h.indexes == nil {
+		for _, field := range fields {
+			add(field)
+		}
+	} else {
+		for _, index := range h.indexes {
+			if index < len(fields) {
+				add(fields[index])
+			} else {
+				add("")
+			}
+		}
+	}
+
+	if h.algorithm == HashSHA256 {
+		sum := sha256.Sum256(buffer)
+		return hex.EncodeToString(sum[:])
+	}
+	hash := strconv.FormatUint(xxHash64(buffer), 16)
+	return strings.Repeat("0", 16-len(hash)) + hash
+}
+
+// HashRow splits a row as received by a job and returns its hash. It is only available on the
+// hashers returned by Processor.RowHasher
+func (h *RowHasher) HashRow(row string) (string, error) {
+	fields, err := h.parser.Fields(row)
+	if err != nil {
+		return "", err
+	}
+	return h.Hash(fields), nil
+}
+
+// RowHash appends to the rows the hash of their fields, or of the fields of Columns, in a column
+// named Column, DefaultHashColumn if empty. It detects changed rows, and gives idempotent loads
+// a key when the rows have none
+type RowHash struct {
+	Algorithm HashAlgorithm
+	Columns   []string
+	Column    string
+}
+
+func (r RowHash) Bind(header []string) ([]string, RowFunc, error) {
+	h, err := newRowHasher(header, r.Algorithm, r.Columns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	column := r.Column
+	if column == "" {
+		column = DefaultHashColumn
+	}
+	if len(header) > 0 {
+		header = append(append([]string{}, header...), column)
+	}
+	return header, func(fields []string) ([]string, error) {
+		return append(fields, h.Hash(fields)), nil
+	}, nil
+}
+
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+// xxHash64 is XXH64 with seed 0
+func xxHash64(b []byte) uint64 {
+	n := len(b)
+	var h uint64
+	if n >= 32 {
+		// the sums wrap around, which constants cannot do
+		prime1, prime2 := xxPrime1, xxPrime2
+		v1 := prime1 + prime2
+		v2 := prime2
+		v3 := uint64(0)
+		v4 := -prime1
+		for ; len(b) >= 32; b = b[32:] {
+			v1 = xxRound(v1, binary.LittleEndian.Uint64(b[0:8]))
+			v2 = xxRound(v2, binary.LittleEndian.Uint64(b[8:16]))
+			v3 = xxRound(v3, binary.LittleEndian.Uint64(b[16:24]))
+			v4 = xxRound(v4, binary.LittleEndian.Uint64(b[24:32]))
+		}
+		h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) + bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		h = xxMerge(h, v1)
+		h = xxMerge(h, v2)
+		h = xxMerge(h, v3)
+		h = xxMerge(h, v4)
+	} else {
+		h = xxPrime5
+	}
+	h += uint64(n)
+
+	for ; len(b) >= 8; b = b[8:] {
+		h ^= xxRound(0, binary.LittleEndian.Uint64(b))
+		h = bits.RotateLeft64(h, 27)*xxPrime1 + xxPrime4
+	}
+	if len(b) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(b)) * xxPrime1
+		h = bits.RotateLeft64(h, 23)*xxPrime2 + xxPrime3
+		b = b[4:]
+	}
+	for _, c := range b {
+		h ^= uint64(c) * xxPrime5
+		h = bits.RotateLeft64(h, 11) * xxPrime1
+	}
+
+	h ^= h >> 33
+	h *= xxPrime2
+	h ^= h >> 29
+	h *= xxPrime3
+	h ^= h >> 32
+	return h
+}
+
+func xxRound(acc uint64, input uint64) uint64 {
+	acc += input * xxPrime2
+	return bits.RotateLeft64(acc, 31) * xxPrime1
+}
+
+func xxMerge(acc uint64, v uint64) uint64 {
+	acc ^= xxRound(0, v)
+	return acc*xxPrime1 + xxPrime4
+}
@@ -0,0 +1,62 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestXXHash64(t *testing.T) {
+	assert.Equal(t, uint64(0xef46db3751d8e999), xxHash64(nil))
+	assert.Equal(t, uint64(0x44bc2cf5ad770999), xxHash64([]byte("abc")))
+	assert.Equal(t, uint64(0xfbcea83c8a378bf1), xxHash64([]byte("Nobody inspects the spammish repetition")))
+}
+
+func TestRowHasher(t *testing.T) {
+	h, err := newRowHasher([]string{"id", "name"}, HashXX64, nil)
+	assert.Nil(t, err)
+	hash := h.Hash([]string{"1", "anna"})
+	assert.Len(t, hash, 16)
+	assert.Equal(t, hash, h.Hash([]string{"1", "anna"}))
+	// moving the boundary between fields changes the hash
+	assert.NotEqual(t, h.Hash([]string{"1a", "nna"}), hash)
+
+	key, err := newRowHasher([]string{"id", "name"}, HashSHA256, []string{"id"})
+	assert.Nil(t, err)
+	assert.Len(t, key.Hash([]string{"1", "anna"}), 64)
+	assert.Equal(t, key.Hash([]string{"1", "anna"}), key.Hash([]string{"1", "bob"}))
+
+	_, err = newRowHasher([]string{"id"}, HashSHA256, []string{"name"})
+	assert.ErrorIs(t, err, ColumnNotFoundError)
+	_, err = newRowHasher([]string{"id"}, HashAlgorithm(7), nil)
+	assert.NotNil(t, err)
+}
+
+func TestRowHash(t *testing.T) {
+	sink := copyRows(t, customers, RowHash{Columns: []string{"id"}, Column: "key"})
+	assert.Equal(t, []string{"id", "name", "country", "key"}, sink.header)
+
+	h, _ := newRowHasher(nil, HashXX64, nil)
+	assert.Equal(t, h.Hash([]string{"c2"}), sink.rows[1][3])
+}
+
+func TestProcessorRowHasher(t *testing.T) {
+	p := NewProcessor(strings.NewReader(customers), nil)
+	h, err := p.RowHasher(HashSHA256, "name")
+	assert.Nil(t, err)
+
+	mu := sync.Mutex{}
+	hashes := map[string]string{}
+	err = p.Run(func(header []string, rows []string) {
+		for _, row := range rows {
+			hash, err := h.HashRow(row)
+			assert.Nil(t, err)
+			mu.Lock()
+			hashes[row[:2]] = hash
+			mu.Unlock()
+		}
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, h.Hash([]string{"", "bob, jr"}), hashes["c2"])
+}
@@ -0,0 +1,95 @@
+package parallel_csv
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// DetectHeaderRows is the maximum number of rows examined by DetectHeader after the first one
+const DetectHeaderRows = 20
+
+// DetectHeader tells whether the first line of a sample of comma separated values looks like a
+// header. Every column votes: for a header when its first value does not match the type of the
+// values below it, or differs in length from string values all of the same length, against it
+// otherwise. A first row with empty or duplicate values is never a header, and at least two rows
+// are needed to tell
+func DetectHeader(sample []byte) bool {
+	return detectHeader(sample, CSVParser{Separator: ","}, true)
+}
+
+// detectHeader runs DetectHeader on a sample parsed by parser, complete tells whether the sample
+// is the whole input, its last row then being complete even without a line break
+func detectHeader(sample []byte, parser RecordParser, complete bool) bool {
+	end := parser.RecordsEnd(sample)
+	if complete {
+		end = len(sample)
+	}
+	if end == 0 {
+		return false
+	}
+
+	var rows [][]string
+	for _, record := range parser.Records(string(sample[:end])) {
+		if len(rows) > DetectHeaderRows {
+			break
+		}
+		if fields, err := parser.Fields(record); err == nil {
+			rows = append(rows, fields)
+		}
+	}
+	return looksLikeHeader(rows)
+}
+
+func looksLikeHeader(rows [][]string) bool {
+	if len(rows) < 2 {
+		return false
+	}
+
+	first := rows[0]
+	seen := make(map[string]bool, len(first))
+	for _, name := range first {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			return false
+		}
+		seen[name] = true
+	}
+
+	votes := 0
+	for i, name := range first {
+		var typ inferredType
+		// the length of the values, -1 once they differ
+		length := 0
+		for _, row := range rows[1:] {
+			if i >= len(row) || strings.TrimSpace(row[i]) == "" {
+				continue
+			}
+			value := strings.TrimSpace(row[i])
+			typ = typ.join(infer(value))
+			if n := utf8.RuneCountInString(value); length == 0 {
+				length = n
+			} else if n != length {
+				length = -1
+			}
+		}
+
+		name = strings.TrimSpace(name)
+		switch {
+		case typ.typ == "":
+			// no values to compare with
+		case typ.typ != StringType:
+			if typ.join(infer(name)) == typ {
+				votes--
+			} else {
+				votes++
+			}
+		case length > 0:
+			if utf8.RuneCountInString(name) == length {
+				votes--
+			} else {
+				votes++
+			}
+		}
+	}
+	return votes > 0
+}
@@ -0,0 +1,51 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestDetectHeader(t *testing.T) {
+	tests := []struct {
+		sample string
+		header bool
+	}{
+		{"name,age\nanna,34\nbob,28\n", true},
+		{"id,code\n1,AB\n2,CD\n", true},
+		{"anna,34\nbob,28\n", false},
+		{"AB,x\nCD,y\nEF,z", false},
+		{"a,a\n1,2\n", false},
+		{"name,,age\nanna,IT,34\n", false},
+		{"name,age\n", false},
+		{"", false},
+		{"amount,when\n10.5,2021-03-01\n7,2021-03-02\n", true},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.header, DetectHeader([]byte(test.sample)), test.sample)
+	}
+}
+
+func TestAutoHeader(t *testing.T) {
+	config := GetDefaultConfig()
+	config.HeaderConfig.HasHeader = false
+	config.AutoHeader = true
+	config.BytesPerWorker = 8
+
+	p := NewProcessor(strings.NewReader("name,age\nanna,34\nbob,28\n"), &config)
+	assert.Equal(t, []string{"name", "age"}, p.GetHeader())
+	assert.True(t, p.GetConfig().HeaderConfig.HasHeader)
+	assert.False(t, config.HeaderConfig.HasHeader)
+	sink := &memorySink{}
+	assert.Nil(t, p.Copy(sink))
+	assert.Equal(t, [][]string{{"anna", "34"}, {"bob", "28"}}, sink.rows)
+
+	config.HeaderConfig.HasHeader = true
+	p = NewProcessor(strings.NewReader("anna,34\nbob,28\n"), &config)
+	assert.Empty(t, p.GetHeader())
+	assert.False(t, p.GetConfig().HeaderConfig.HasHeader)
+	sink = &memorySink{}
+	assert.Nil(t, p.Copy(sink))
+	assert.Equal(t, [][]string{{"anna", "34"}, {"bob", "28"}}, sink.rows)
+}
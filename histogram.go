@@ -0,0 +1,101 @@
+package parallel_csv
+
+import "math"
+
+// DefaultHistogramBins is the number of bins of the histograms when Config.HistogramBins is 0
+const DefaultHistogramBins = 10
+
+// HistogramKind is the way Profile divides the values of numeric and time columns in bins
+type HistogramKind int
+
+const (
+	// NoHistogram leaves the histograms out of the profile
+	NoHistogram HistogramKind = iota
+	// EquiWidthHistogram divides the range between the minimum and the maximum in bins of the
+	// same width
+	EquiWidthHistogram
+	// EquiDepthHistogram divides the values in bins holding as many of them
+	EquiDepthHistogram
+)
+
+func (k HistogramKind) String() string {
+	switch k {
+	case EquiWidthHistogram:
+		return "equi-width"
+	case EquiDepthHistogram:
+		return "equi-depth"
+	default:
+		return "none"
+	}
+}
+
+// HistogramBin counts the values between Lower and Upper. Every bin but the last excludes its
+// upper bound
+type HistogramBin struct {
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+	Count int     `json:"count"`
+}
+
+// Histogram is the distribution of the values of a column. It is computed from the t-digest of
+// the column in the same pass as the rest of the profile, so counts and bounds are estimates
+type Histogram struct {
+	Kind string `json:"kind"`
+	// Time tells that the bounds are Unix times in seconds
+	Time bool           `json:"time,omitempty"`
+	Bins []HistogramBin `json:"bins"`
+}
+
+// histogram divides the values summarized by digest in bins
+func (t *tDigest) histogram(kind HistogramKind, bins int) *Histogram {
+	t.compress()
+	if kind == NoHistogram || len(t.centroids) == 0 {
+		return nil
+	}
+	if bins <= 0 {
+		bins = DefaultHistogramBins
+	}
+
+	bounds := make([]float64, bins+1)
+	for i := range bounds {
+		if kind == EquiDepthHistogram {
+			bounds[i] = t.quantile(float64(i) / float64(bins))
+		} else {
+			bounds[i] = t.min + (t.max-t.min)*float64(i)/float64(bins)
+		}
+	}
+	bounds[bins] = t.max
+
+	histogram := &Histogram{Kind: kind.String(), Bins: make([]HistogramBin, bins)}
+	// the counts are differences of rounded cumulative counts, so that they add up to the total
+	below := 0
+	for i := range histogram.Bins {
+		upTo := int(math.Round(t.total * t.cdf(bounds[i+1])))
+		histogram.Bins[i] = HistogramBin{Lower: bounds[i], Upper: bounds[i+1], Count: upTo - below}
+		below = upTo
+	}
+	return histogram
+}
+
+// cdf estimates the fraction of the values lower than or equal to x
+func (t *tDigest) cdf(x float64) float64 {
+	t.compress()
+	switch {
+	case len(t.centroids) == 0 || x < t.min:
+		return 0
+	case x >= t.max:
+		return 1
+	}
+
+	// the inverse of quantile, interpolating between the centers of the centroids
+	position, previous, previousMean := 0.0, 0.0, t.min
+	for _, c := range t.centroids {
+		center := position + c.weight/2
+		if x < c.mean {
+			return interpolate(previous, center, (x-previousMean)/(c.mean-previousMean)) / t.total
+		}
+		position += c.weight
+		previous, previousMean = center, c.mean
+	}
+	return interpolate(previous, t.total, (x-previousMean)/(t.max-previousMean)) / t.total
+}
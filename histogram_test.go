@@ -0,0 +1,68 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProfileHistogram(t *testing.T) {
+	input := strings.Builder{}
+	input.WriteString("n,day,name\n")
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 1000; i++ {
+		day := start.AddDate(0, 0, i%10)
+		input.WriteString(strconv.Itoa(i) + "," + day.Format("2006-01-02") + ",x\n")
+	}
+
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 1 * KB
+	config.Histogram = EquiWidthHistogram
+	config.HistogramBins = 4
+	p := NewProcessor(strings.NewReader(input.String()), &config)
+	report, err := p.Profile()
+	assert.Nil(t, err)
+
+	n := report.Columns[0].Histogram
+	assert.Equal(t, "equi-width", n.Kind)
+	assert.False(t, n.Time)
+	assert.Len(t, n.Bins, 4)
+	total := 0
+	for i, bin := range n.Bins {
+		assert.InDelta(t, 999.0/4*float64(i), bin.Lower, 0.001)
+		assert.InDelta(t, 250, bin.Count, 10)
+		total += bin.Count
+	}
+	assert.Equal(t, 1000, total)
+	assert.Equal(t, 999.0, n.Bins[3].Upper)
+
+	day := report.Columns[1].Histogram
+	assert.True(t, day.Time)
+	assert.Equal(t, float64(start.Unix()), day.Bins[0].Lower)
+	assert.Equal(t, float64(start.AddDate(0, 0, 9).Unix()), day.Bins[3].Upper)
+	assert.Nil(t, report.Columns[2].Histogram)
+}
+
+func TestEquiDepthHistogram(t *testing.T) {
+	digest := &tDigest{}
+	for i := 0; i < 10000; i++ {
+		// most values are small
+		digest.add(float64(i * i))
+	}
+
+	histogram := digest.histogram(EquiDepthHistogram, 5)
+	assert.Equal(t, "equi-depth", histogram.Kind)
+	assert.Equal(t, 0.0, histogram.Bins[0].Lower)
+	assert.Equal(t, float64(9999*9999), histogram.Bins[4].Upper)
+	for i, bin := range histogram.Bins {
+		assert.InDelta(t, 2000, bin.Count, 40)
+		expected := float64(2000*(i+1)) * float64(2000*(i+1))
+		assert.InEpsilon(t, expected, bin.Upper, 0.02)
+	}
+
+	assert.Nil(t, digest.histogram(NoHistogram, 5))
+	assert.Nil(t, (&tDigest{}).histogram(EquiWidthHistogram, 5))
+	assert.Len(t, digest.histogram(EquiWidthHistogram, 0).Bins, DefaultHistogramBins)
+}
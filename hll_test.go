@@ -0,0 +1,28 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func TestHyperLogLog(t *testing.T) {
+	for _, n := range []int{0, 1, 100, 10000, 200000} {
+		h := newHyperLogLog()
+		for i := 0; i < n; i++ {
+			h.add(strconv.Itoa(i))
+			h.add(strconv.Itoa(i))
+		}
+		assert.InDelta(t, n, h.count(), 0.03*float64(n)+1, "n = %d", n)
+	}
+}
+
+func TestHyperLogLogMerge(t *testing.T) {
+	a, b := newHyperLogLog(), newHyperLogLog()
+	for i := 0; i < 60000; i++ {
+		a.add(strconv.Itoa(i))
+		b.add(strconv.Itoa(i + 40000))
+	}
+	a.merge(b)
+	assert.InDelta(t, 100000, a.count(), 3000)
+}
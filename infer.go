@@ -0,0 +1,233 @@
+package parallel_csv
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxInferredSamples is the number of sample values kept for each inferred column
+const MaxInferredSamples = 5
+
+// timeLayouts are the layouts recognized by InferSchema, from the most to the least specific
+var timeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"}
+
+// InferredColumn describes a column as observed in the data
+type InferredColumn struct {
+	Name string     `json:"name"`
+	Type ColumnType `json:"type"`
+	// Layout is the time layout of TimeType columns
+	Layout    string   `json:"layout,omitempty"`
+	Nullable  bool     `json:"nullable"`
+	NullRate  float64  `json:"null_rate"`
+	MinLength int      `json:"min_length"`
+	MaxLength int      `json:"max_length"`
+	Samples   []string `json:"samples"`
+}
+
+// InferredSchema is the schema observed by InferSchema, it can be encoded as JSON
+type InferredSchema struct {
+	Rows    int              `json:"rows"`
+	Columns []InferredColumn `json:"columns"`
+}
+
+// Schema turns the inferred schema in a Schema usable by Validate
+func (s *InferredSchema) Schema() Schema {
+	schema := Schema{}
+	for _, column := range s.Columns {
+		schema.Columns = append(schema.Columns, ColumnSchema{
+			Name:      column.Name,
+			Type:      column.Type,
+			Required:  !column.Nullable,
+			MaxLength: column.MaxLength,
+			Layout:    column.Layout,
+		})
+	}
+	return schema
+}
+
+// inferredType is the most specific type accepting every value seen so far
+type inferredType struct {
+	typ    ColumnType
+	layout string
+}
+
+// infer returns the most specific type of a non-empty value
+func infer(value string) inferredType {
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return inferredType{typ: IntegerType}
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return inferredType{typ: FloatType}
+	}
+	if lower := strings.ToLower(value); lower == "true" || lower == "false" {
+		return inferredType{typ: BooleanType}
+	}
+	for _, layout := range timeLayouts {
+		if _, err := time.Parse(layout, value); err == nil {
+			return inferredType{typ: TimeType, layout: layout}
+		}
+	}
+	return inferredType{typ: StringType}
+}
+
+// join returns the most specific type accepting the values of both types
+func (t inferredType) join(other inferredType) inferredType {
+	switch {
+	case t.typ == "":
+		return other
+	case other.typ == "" || t == other:
+		return t
+	case t.typ == IntegerType && other.typ == FloatType, t.typ == FloatType && other.typ == IntegerType:
+		return inferredType{typ: FloatType}
+	default:
+		return inferredType{typ: StringType}
+	}
+}
+
+type sample struct {
+	line  int
+	value string
+}
+
+// columnObservations accumulates what a worker has seen of a column
+type columnObservations struct {
+	typ       inferredType
+	values    int
+	nulls     int
+	minLength int
+	maxLength int
+	samples   []sample
+}
+
+func (o *columnObservations) observe(value string, line int) {
+	o.values++
+	value = strings.TrimSpace(value)
+	if value == "" {
+		o.nulls++
+		return
+	}
+
+	length := utf8.RuneCountInString(value)
+	if o.values-o.nulls == 1 || length < o.minLength {
+		o.minLength = length
+	}
+	if length > o.maxLength {
+		o.maxLength = length
+	}
+
+	o.typ = o.typ.join(infer(value))
+	if len(o.samples) < MaxInferredSamples && !o.sampled(value) {
+		o.samples = append(o.samples, sample{line: line, value: cloneString(value)})
+	}
+}
+
+func (o *columnObservations) sampled(value string) bool {
+	for _, s := range o.samples {
+		if s.value == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *columnObservations) merge(other *columnObservations) {
+	if other.values-other.nulls > 0 {
+		if o.values-o.nulls == 0 || other.minLength < o.minLength {
+			o.minLength = other.minLength
+		}
+		if other.maxLength > o.maxLength {
+			o.maxLength = other.maxLength
+		}
+	}
+	o.values += other.values
+	o.nulls += other.nulls
+	o.typ = o.typ.join(other.typ)
+
+	// keep the samples appearing first in the file
+	samples := append(o.samples, other.samples...)
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].line < samples[j].line
+	})
+	o.samples = o.samples[:0:0]
+	for _, s := range samples {
+		if len(o.samples) < MaxInferredSamples && !o.sampled(s.value) {
+			o.samples = append(o.samples, s)
+		}
+	}
+}
+
+// columnName is the name of a column of a file without header
+func columnName(i int) string {
+	return fmt.Sprintf("col_%d", i+1)
+}
+
+// InferSchema scans the whole file and reports the type, null rate, lengths and some sample
+// values of every column. Columns of files without header are named col_1, col_2 and so on
+func (p processor) InferSchema() (*InferredSchema, error) {
+	var columns []*columnObservations
+	mu := sync.Mutex{}
+
+	err := p.RunChunks(func(chunk Chunk) error {
+		var partial []*columnObservations
+		for i, row := range chunk.Rows {
+			if chunk.Cancelled() {
+				return errCancelled
+			}
+			for j, value := range p.split(row) {
+				if j == len(partial) {
+					partial = append(partial, &columnObservations{})
+				}
+				partial[j].observe(value, chunk.Line(i))
+			}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		for j, observations := range partial {
+			if j == len(columns) {
+				columns = append(columns, &columnObservations{})
+			}
+			columns[j].merge(observations)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	schema := &InferredSchema{Rows: int(p.Stats().RowsDelivered)}
+	for i := 0; i < len(columns) || i < len(p.header); i++ {
+		column := InferredColumn{Name: columnName(i), Type: StringType}
+		if i < len(p.header) {
+			column.Name = p.header[i]
+		}
+
+		if i < len(columns) {
+			observations := columns[i]
+			// rows missing the column count as nulls
+			nulls := observations.nulls + schema.Rows - observations.values
+			if observations.typ.typ != "" {
+				column.Type = observations.typ.typ
+				column.Layout = observations.typ.layout
+			}
+			column.Nullable = nulls > 0
+			column.MinLength = observations.minLength
+			column.MaxLength = observations.maxLength
+			if schema.Rows > 0 {
+				column.NullRate = float64(nulls) / float64(schema.Rows)
+			}
+			for _, s := range observations.samples {
+				column.Samples = append(column.Samples, s.value)
+			}
+		}
+
+		schema.Columns = append(schema.Columns, column)
+	}
+
+	return schema, nil
+}
@@ -0,0 +1,75 @@
+package parallel_csv
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestInferSchema(t *testing.T) {
+	input := "id,price,active,created,note\n" +
+		"1,10,true,2021-03-01,first\n" +
+		"2,10.5,false,2021-03-02,\n" +
+		"3,7,TRUE,2021-03-03,third one\n" +
+		"4,,false,not a date,x\n"
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 32
+	p := NewProcessor(strings.NewReader(input), &config)
+
+	schema, err := p.InferSchema()
+	assert.Nil(t, err)
+	assert.Equal(t, 4, schema.Rows)
+	assert.Len(t, schema.Columns, 5)
+
+	id := schema.Columns[0]
+	assert.Equal(t, "id", id.Name)
+	assert.Equal(t, IntegerType, id.Type)
+	assert.False(t, id.Nullable)
+	assert.Equal(t, []string{"1", "2", "3", "4"}, id.Samples)
+
+	price := schema.Columns[1]
+	assert.Equal(t, FloatType, price.Type)
+	assert.True(t, price.Nullable)
+	assert.Equal(t, 0.25, price.NullRate)
+
+	assert.Equal(t, BooleanType, schema.Columns[2].Type)
+	assert.Equal(t, StringType, schema.Columns[3].Type)
+
+	note := schema.Columns[4]
+	assert.Equal(t, StringType, note.Type)
+	assert.Equal(t, 1, note.MinLength)
+	assert.Equal(t, 9, note.MaxLength)
+
+	_, err = json.Marshal(schema)
+	assert.Nil(t, err)
+}
+
+func TestInferSchemaFile(t *testing.T) {
+	file := openFile("testdata/without_header.csv")
+	config := GetDefaultConfig()
+	config.HeaderConfig.HasHeader = false
+	p := NewProcessor(file, &config)
+
+	schema, err := p.InferSchema()
+	assert.Nil(t, err)
+	assert.Equal(t, 200, schema.Rows)
+	assert.Equal(t, "col_1", schema.Columns[0].Name)
+	assert.Equal(t, IntegerType, schema.Columns[0].Type)
+	assert.Equal(t, FloatType, schema.Columns[1].Type)
+
+	// the inferred schema validates the data it comes from
+	file = openFile("testdata/without_header.csv")
+	report, err := NewProcessor(file, &config).Validate(schema.Schema())
+	assert.Nil(t, err)
+	assert.True(t, report.Valid())
+}
+
+func TestInferTimeColumn(t *testing.T) {
+	p := NewProcessor(strings.NewReader("at\n2021-03-01T10:00:00Z\n2021-03-02T11:30:00Z\n"), nil)
+
+	schema, err := p.InferSchema()
+	assert.Nil(t, err)
+	assert.Equal(t, TimeType, schema.Columns[0].Type)
+	assert.Equal(t, "2006-01-02T15:04:05Z07:00", schema.Columns[0].Layout)
+}
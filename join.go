@@ -0,0 +1,142 @@
+package parallel_csv
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+const KeyCountError = Error("join keys do not match lookup keys")
+
+// JoinType decides what happens to the rows without a match in the lookup file
+type JoinType int
+
+const (
+	// InnerJoin drops the rows without a match
+	InnerJoin JoinType = iota
+	// LeftJoin keeps the rows without a match, with empty lookup columns
+	LeftJoin
+)
+
+// JoinConfig describes how rows are matched with the lookup file
+type JoinConfig struct {
+	Type JoinType
+	// Keys are the key columns of the processed file
+	Keys []string
+	// LookupKeys are the key columns of the lookup file, the same as Keys if empty
+	LookupKeys []string
+}
+
+// lookupTable maps each key of the lookup file to the non-key fields of its rows
+type lookupTable struct {
+	header []string
+	rows   map[string][][]string
+	width  int
+}
+
+// loadLookup reads the lookup file in parallel and indexes its rows by key. It uses the same
+// header configuration as p
+func (p processor) loadLookup(lookup io.Reader, keys []string) (*lookupTable, error) {
+	return loadLookup(lookup, &Config{
+		NumberOfWorkers: p.config.NumberOfWorkers,
+		HeaderConfig:    p.config.HeaderConfig,
+		BytesPerWorker:  p.config.BytesPerWorker,
+	}, keys)
+}
+
+// loadLookup reads a lookup file with config and indexes its rows by key
+func loadLookup(lookup io.Reader, config *Config, keys []string) (*lookupTable, error) {
+	other, err := newProcessor(lookup, config)
+	if err != nil {
+		return nil, fmt.Errorf("lookup: %w", err)
+	}
+
+	keyIndexes, err := other.keyIndexes(keys)
+	if err != nil {
+		return nil, fmt.Errorf("lookup: %w", err)
+	}
+
+	isKey := map[int]bool{}
+	for _, index := range keyIndexes {
+		isKey[index] = true
+	}
+	var valueIndexes []int
+	table := &lookupTable{rows: map[string][][]string{}}
+	for i, column := range other.header {
+		if !isKey[i] {
+			valueIndexes = append(valueIndexes, i)
+			table.header = append(table.header, column)
+		}
+	}
+	table.width = len(valueIndexes)
+
+	mu := sync.Mutex{}
+	err = other.RunChunks(func(chunk Chunk) error {
+		for _, row := range chunk.Rows {
+			if chunk.Cancelled() {
+				return errCancelled
+			}
+			fields := other.split(row)
+			values := make([]string, len(valueIndexes))
+			for i, index := range valueIndexes {
+				if index < len(fields) {
+					values[i] = cloneString(fields[index])
+				}
+			}
+
+			key := keyOf(fields, keyIndexes)
+			mu.Lock()
+			table.rows[key] = append(table.rows[key], values)
+			mu.Unlock()
+		}
+		return nil
+	})
+	if err != nil && err != EmptyFileError {
+		return nil, err
+	}
+
+	return table, nil
+}
+
+// Join matches every row with the rows of the lookup file having the same key, and writes the
+// joined rows to the sink in source order: the columns of the row followed by the non-key columns
+// of the lookup file. The lookup file is loaded in memory and should be the smaller one
+func (p processor) Join(lookup io.Reader, join JoinConfig, sink Sink) error {
+	lookupKeys := join.LookupKeys
+	if len(lookupKeys) == 0 {
+		lookupKeys = join.Keys
+	}
+	if len(lookupKeys) != len(join.Keys) {
+		return KeyCountError
+	}
+
+	keyIndexes, err := p.keyIndexes(join.Keys)
+	if err != nil {
+		return err
+	}
+	table, err := p.loadLookup(lookup, lookupKeys)
+	if err != nil {
+		return err
+	}
+
+	header := append(append([]string{}, p.header...), table.header...)
+	missing := make([]string, table.width)
+	return p.runSink(sink, header, func(chunk Chunk) ([][]string, error) {
+		var joined [][]string
+		for _, row := range chunk.Rows {
+			if chunk.Cancelled() {
+				return nil, errCancelled
+			}
+			fields := p.split(row)
+			matches := table.rows[keyOf(fields, keyIndexes)]
+			if len(matches) == 0 && join.Type == LeftJoin {
+				matches = [][]string{missing}
+			}
+
+			for _, match := range matches {
+				joined = append(joined, append(append(make([]string, 0, len(fields)+len(match)), fields...), match...))
+			}
+		}
+		return joined, nil
+	})
+}
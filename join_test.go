@@ -0,0 +1,52 @@
+package parallel_csv
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+const orders = "order,customer,amount\n1,c1,10\n2,c2,20\n3,c9,30\n4,c1,40\n"
+const customers = "id,name,country\nc1,anna,IT\nc2,\"bob, jr\",FR\nc3,carla,DE\n"
+
+func TestJoin(t *testing.T) {
+	tests := []struct {
+		typ      JoinType
+		expected string
+	}{
+		{InnerJoin, "order,customer,amount,name,country\n1,c1,10,anna,IT\n2,c2,20,\"bob, jr\",FR\n4,c1,40,anna,IT\n"},
+		{LeftJoin, "order,customer,amount,name,country\n1,c1,10,anna,IT\n2,c2,20,\"bob, jr\",FR\n3,c9,30,,\n4,c1,40,anna,IT\n"},
+	}
+
+	for _, test := range tests {
+		config := GetDefaultConfig()
+		config.BytesPerWorker = 8
+		p := NewProcessor(strings.NewReader(orders), &config)
+
+		out := &bytes.Buffer{}
+		err := p.Join(strings.NewReader(customers), JoinConfig{
+			Type:       test.typ,
+			Keys:       []string{"customer"},
+			LookupKeys: []string{"id"},
+		}, NewCSVSink(out, ","))
+		assert.Nil(t, err)
+		assert.Equal(t, test.expected, out.String())
+	}
+}
+
+func TestJoinMultipleMatches(t *testing.T) {
+	p := NewProcessor(strings.NewReader("k,v\na,1\nb,2\n"), nil)
+
+	out := &bytes.Buffer{}
+	err := p.Join(strings.NewReader("k,w\na,x\na,y\n"), JoinConfig{Keys: []string{"k"}}, NewCSVSink(out, ","))
+	assert.Nil(t, err)
+	assert.Equal(t, "k,v,w\na,1,x\na,1,y\n", out.String())
+}
+
+func TestJoinUnknownLookupColumn(t *testing.T) {
+	p := NewProcessor(strings.NewReader(orders), nil)
+
+	err := p.Join(strings.NewReader(customers), JoinConfig{Keys: []string{"customer"}}, NewCSVSink(&bytes.Buffer{}, ","))
+	assert.ErrorIs(t, err, ColumnNotFoundError)
+}
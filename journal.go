@@ -0,0 +1,159 @@
+package parallel_csv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Journal is an append-only file recording the byte ranges of the chunks committed, one JSON
+// line each, so that a chunk is processed once across restarts. A job writing to a
+// transactional store skips the chunks already committed, and commits each of the others to
+// the journal once its transaction succeeds; the next run starts with ResumeFromJournal.
+// A crash between the transaction and Commit processes the chunk again: stores keeping the
+// offsets of the chunk in the same transaction can tell and make processing exactly-once.
+// Chunks are matched by their offsets, so the runs must use the same BytesPerWorker
+type Journal struct {
+	mu        sync.Mutex
+	file      *os.File
+	committed map[int64]JournalEntry
+}
+
+// JournalEntry is a chunk committed to the journal
+type JournalEntry struct {
+	// Start and End are the offsets of the first byte of the chunk and of the one following it
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	// Line is the source line of the first row and Rows the number of rows of the chunk, those
+	// filtered out included
+	Line        int       `json:"line"`
+	Rows        int       `json:"rows"`
+	CommittedAt time.Time `json:"committed_at"`
+}
+
+// OpenJournal opens the journal at path, creating it if missing. A last line cut short by a
+// crash while committing is discarded
+func OpenJournal(path string) (*Journal, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	journal := &Journal{file: file, committed: map[int64]JournalEntry{}}
+	valid, err := journal.load()
+	if err == nil {
+		err = file.Truncate(valid)
+	}
+	if err == nil {
+		_, err = file.Seek(valid, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return journal, nil
+}
+
+// load reads the entries of the journal, returning the size of the complete lines
+func (j *Journal) load() (int64, error) {
+	reader := bufio.NewReader(j.file)
+	var valid int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return valid, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		entry := JournalEntry{}
+		if err := json.Unmarshal(bytes.TrimSpace(line), &entry); err != nil {
+			return 0, err
+		}
+		j.committed[entry.Start] = entry
+		valid += int64(len(line))
+	}
+}
+
+// Committed tells whether the chunk has been committed by a previous run
+func (j *Journal) Committed(chunk Chunk) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry, ok := j.committed[chunk.Offset]
+	return ok && entry.End == chunk.Offset+chunk.size
+}
+
+// Commit records the chunk as processed. The entry is synced to disk before Commit returns
+func (j *Journal) Commit(chunk Chunk) error {
+	entry := JournalEntry{
+		Start:       chunk.Offset,
+		End:         chunk.Offset + chunk.size,
+		Line:        chunk.StartLine,
+		Rows:        chunk.rowsRead,
+		CommittedAt: time.Now(),
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := j.file.Sync(); err != nil {
+		return err
+	}
+	j.committed[entry.Start] = entry
+	return nil
+}
+
+// Entries returns the chunks committed, in no particular order
+func (j *Journal) Entries() []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries := make([]JournalEntry, 0, len(j.committed))
+	for _, entry := range j.committed {
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// Close closes the journal file
+func (j *Journal) Close() error {
+	return j.file.Close()
+}
+
+// resume returns where the chunks committed in sequence from start end
+func (j *Journal) resume(start Checkpoint) Checkpoint {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for {
+		entry, ok := j.committed[start.Offset]
+		if !ok {
+			return start
+		}
+		start.Offset, start.Line = entry.End, entry.Line+entry.Rows
+		start.Rows += int64(entry.Rows)
+	}
+}
+
+// ResumeFromJournal makes the next run start after the chunks committed in sequence from where
+// it would start. The chunks committed past the first missing one are still read, and the job
+// skips them checking Journal.Committed
+func (p *processor) ResumeFromJournal(journal *Journal) error {
+	resume := journal.resume(p.start)
+	if resume.Offset == p.start.Offset {
+		return nil
+	}
+	return p.startFrom(resume.Offset, resume.Line, resume.Rows)
+}
@@ -0,0 +1,84 @@
+package parallel_csv
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// commitRows runs a job committing each chunk to the journal, collecting its rows, and failing
+// on the row equal to fail
+func commitRows(t *testing.T, journal *Journal, fail string, rows map[string]int) error {
+	config := GetDefaultConfig()
+	config.NumberOfWorkers = 4
+	config.BytesPerWorker = 32
+	p := NewProcessor(strings.NewReader(numbers(100)), &config)
+	assert.Nil(t, p.ResumeFromJournal(journal))
+
+	mu := sync.Mutex{}
+	return p.RunChunks(func(chunk Chunk) error {
+		if journal.Committed(chunk) {
+			return nil
+		}
+		for _, row := range chunk.Rows {
+			if row == fail {
+				return errors.New("store unavailable")
+			}
+		}
+
+		mu.Lock()
+		for _, row := range chunk.Rows {
+			rows[row]++
+		}
+		mu.Unlock()
+		return journal.Commit(chunk)
+	})
+}
+
+func TestJournalResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "offsets.jsonl")
+	rows := map[string]int{}
+
+	journal, err := OpenJournal(path)
+	assert.Nil(t, err)
+	assert.NotNil(t, commitRows(t, journal, "50", rows))
+	assert.Nil(t, journal.Close())
+	assert.NotEmpty(t, rows)
+
+	journal, err = OpenJournal(path)
+	assert.Nil(t, err)
+	defer journal.Close()
+	assert.Nil(t, commitRows(t, journal, "", rows))
+
+	// every row has been processed once across both runs
+	assert.Len(t, rows, 100)
+	for row, n := range rows {
+		assert.Equal(t, 1, n, row)
+	}
+}
+
+func TestJournalTruncatedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "offsets.jsonl")
+	content := `{"start":2,"end":10,"line":2,"rows":4}` + "\n" + `{"start":10,"en`
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0o644))
+
+	journal, err := OpenJournal(path)
+	assert.Nil(t, err)
+	assert.Equal(t, []JournalEntry{{Start: 2, End: 10, Line: 2, Rows: 4}}, journal.Entries())
+	assert.Nil(t, journal.Commit(Chunk{Offset: 10, size: 6, StartLine: 6, rowsRead: 3}))
+	assert.Nil(t, journal.Close())
+
+	journal, err = OpenJournal(path)
+	assert.Nil(t, err)
+	defer journal.Close()
+	assert.Len(t, journal.Entries(), 2)
+	assert.True(t, journal.Committed(Chunk{Offset: 10, size: 6}))
+	assert.False(t, journal.Committed(Chunk{Offset: 10, size: 8}))
+
+	_, err = OpenJournal(filepath.Join(t.TempDir(), "missing", "offsets.jsonl"))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,243 @@
+package parallel_csv
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// KafkaEncoding is the encoding of the values of the messages published by KafkaSink
+type KafkaEncoding int
+
+const (
+	// KafkaJSON encodes each row as a JSON object keyed by column name
+	KafkaJSON KafkaEncoding = iota
+	// KafkaAvro encodes each row as an Avro record, see KafkaSink.AvroSchema
+	KafkaAvro
+)
+
+// KafkaDelivery is the delivery guarantee of KafkaSink
+type KafkaDelivery int
+
+const (
+	// KafkaAtLeastOnce publishes each batch before accepting more rows: a failing batch fails the
+	// sink, and may have been partially published
+	KafkaAtLeastOnce KafkaDelivery = iota
+	// KafkaAtMostOnce publishes the batches in the background: failing batches are passed to
+	// KafkaSink.ErrorHandler and dropped
+	KafkaAtMostOnce
+)
+
+// DefaultKafkaBatch is the number of messages published at once when not set
+const DefaultKafkaBatch = 1000
+
+// KafkaMessage is a message published by KafkaSink
+type KafkaMessage struct {
+	Key   []byte
+	Value []byte
+}
+
+// KafkaProducer publishes messages to a topic, returning once they are acknowledged. With
+// segmentio/kafka-go, Produce converts the messages and calls Writer.WriteMessages
+type KafkaProducer interface {
+	Produce(ctx context.Context, topic string, messages []KafkaMessage) error
+}
+
+// KafkaSink publishes each row as a message to a Kafka topic, in source order. It is an AckSink:
+// the chunks count as processed once the batch holding their last row has been published, with
+// KafkaAtMostOnce once it has been attempted
+type KafkaSink struct {
+	Topic    string
+	Encoding KafkaEncoding
+	// KeyColumns are the columns whose values, joined by commas, are the key of the messages.
+	// Messages have no key when empty
+	KeyColumns []string
+	// Schema converts the fields to the types of its columns, which are then encoded as such
+	Schema *Schema
+	// SchemaID, when positive, prefixes Avro values with the schema registry header: a zero byte
+	// followed by the id on 4 bytes
+	SchemaID int
+	// BatchMessages is the number of messages published at once, DefaultKafkaBatch if 0
+	BatchMessages int
+	Delivery      KafkaDelivery
+	// ErrorHandler receives the errors of the batches dropped by KafkaAtMostOnce
+	ErrorHandler func(err error)
+	ctx          context.Context
+	producer     KafkaProducer
+	header       []string
+	keys         []int
+	types        typedRow
+	avro         *avroEncoder
+	batch        []KafkaMessage
+	// acks are called once the current batch has been published
+	acks []func(err error)
+	// background publishes the batches of KafkaAtMostOnce
+	background chan kafkaBatch
+	wg         sync.WaitGroup
+}
+
+// kafkaBatch is a batch of messages published in the background, acks being called after it
+type kafkaBatch struct {
+	messages []KafkaMessage
+	acks     []func(err error)
+}
+
+// NewKafkaSink creates a sink publishing the rows to topic with producer
+func NewKafkaSink(ctx context.Context, producer KafkaProducer, topic string) *KafkaSink {
+	return &KafkaSink{Topic: topic, ctx: ctx, producer: producer}
+}
+
+func (s *KafkaSink) Open(header []string) error {
+	var err error
+	if s.keys, err = headerIndexes(header, s.KeyColumns); err != nil {
+		return err
+	}
+	if s.types, err = bindTypes(header, s.Schema); err != nil {
+		return err
+	}
+	if s.Encoding == KafkaAvro {
+		if s.avro, err = newAvroEncoder(header, s.Schema, len(header)); err != nil {
+			return err
+		}
+	}
+	if s.BatchMessages <= 0 {
+		s.BatchMessages = DefaultKafkaBatch
+	}
+
+	s.header = header
+	if s.Delivery == KafkaAtMostOnce {
+		s.background = make(chan kafkaBatch, 1)
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			for batch := range s.background {
+				if len(batch.messages) > 0 {
+					err := s.producer.Produce(s.ctx, s.Topic, batch.messages)
+					if err != nil && s.ErrorHandler != nil {
+						s.ErrorHandler(err)
+					}
+				}
+				// the failing batches are dropped, so they are done with as well
+				for _, ack := range batch.acks {
+					ack(nil)
+				}
+			}
+		}()
+	}
+	return nil
+}
+
+// AvroSchema returns the schema of the Avro values once the sink is open, or after the first row
+// for files without header
+func (s *KafkaSink) AvroSchema() string {
+	if s.avro == nil {
+		return ""
+	}
+	return s.avro.Schema()
+}
+
+func (s *KafkaSink) Write(rows [][]string) error {
+	for _, row := range rows {
+		message, err := s.message(row)
+		if err != nil {
+			return err
+		}
+
+		s.batch = append(s.batch, message)
+		if len(s.batch) == s.BatchMessages {
+			if err := s.publish(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteAck is Write calling ack once the batch holding the last of the rows has been published
+func (s *KafkaSink) WriteAck(rows [][]string, ack func(err error)) error {
+	if err := s.Write(rows); err != nil {
+		return err
+	}
+	s.acks = append(s.acks, ack)
+	// the last row has just been published with its batch
+	if len(s.batch) == 0 {
+		return s.publish()
+	}
+	return nil
+}
+
+// message encodes a row
+func (s *KafkaSink) message(row []string) (KafkaMessage, error) {
+	message := KafkaMessage{}
+	if len(s.keys) > 0 {
+		key := make([]string, len(s.keys))
+		for i, index := range s.keys {
+			if index < len(row) {
+				key[i] = row[index]
+			}
+		}
+		message.Key = []byte(strings.Join(key, ","))
+	}
+
+	values, err := s.types(row)
+	if err != nil {
+		return message, err
+	}
+	if s.avro == nil {
+		message.Value, err = json.Marshal(typedObject(s.header, values))
+		return message, err
+	}
+
+	if s.SchemaID > 0 {
+		message.Value = make([]byte, 5)
+		binary.BigEndian.PutUint32(message.Value[1:], uint32(s.SchemaID))
+	}
+	if len(s.header) > 0 && len(values) != len(s.header) {
+		return message, fmt.Errorf("%w: %d fields for %d columns", FieldCountError, len(values), len(s.header))
+	}
+	if len(s.avro.fields) == 0 && len(values) > 0 {
+		// files without header get their columns from the first row
+		if s.avro, err = newAvroEncoder(nil, s.Schema, len(values)); err != nil {
+			return message, err
+		}
+	}
+	message.Value = s.avro.encode(message.Value, values)
+	return message, nil
+}
+
+// publish sends the current batch, then acknowledges the rows written up to now
+func (s *KafkaSink) publish() error {
+	batch, acks := s.batch, s.acks
+	s.batch, s.acks = make([]KafkaMessage, 0, s.BatchMessages), nil
+	if s.background != nil {
+		if len(batch) > 0 || len(acks) > 0 {
+			s.background <- kafkaBatch{messages: batch, acks: acks}
+		}
+		return nil
+	}
+
+	var err error
+	if len(batch) > 0 {
+		if err = s.producer.Produce(s.ctx, s.Topic, batch); err != nil {
+			err = fmt.Errorf("publish to %s: %w", s.Topic, err)
+		}
+	}
+	for _, ack := range acks {
+		ack(err)
+	}
+	return err
+}
+
+// Close publishes the last batch and waits for the background ones, the producer is left open
+func (s *KafkaSink) Close() error {
+	err := s.publish()
+	if s.background != nil {
+		close(s.background)
+		s.wg.Wait()
+		s.background = nil
+	}
+	return err
+}
@@ -0,0 +1,112 @@
+package parallel_csv
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeProducer keeps the batches published, failing the ones holding the fail key
+type fakeProducer struct {
+	mu      sync.Mutex
+	batches [][]KafkaMessage
+	fail    string
+}
+
+func (p *fakeProducer) Produce(ctx context.Context, topic string, messages []KafkaMessage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, message := range messages {
+		if p.fail != "" && string(message.Key) == p.fail {
+			return errors.New("broker unavailable")
+		}
+	}
+	p.batches = append(p.batches, messages)
+	return nil
+}
+
+func TestKafkaSinkJSON(t *testing.T) {
+	producer := &fakeProducer{}
+	sink := NewKafkaSink(context.Background(), producer, "people")
+	sink.KeyColumns = []string{"country", "name"}
+	sink.BatchMessages = 2
+	sink.Schema = &Schema{Columns: []ColumnSchema{{Name: "age", Type: IntegerType}}}
+
+	input := "name,country,age\nMario,IT,42\nLuigi,IT,\nPeach,FR,30\n"
+	assert.Nil(t, NewProcessor(strings.NewReader(input), nil).Copy(sink))
+
+	assert.Len(t, producer.batches, 2)
+	assert.Equal(t, KafkaMessage{Key: []byte("IT,Mario"), Value: []byte(`{"name":"Mario","country":"IT","age":42}`)},
+		producer.batches[0][0])
+	assert.Equal(t, `{"name":"Luigi","country":"IT","age":null}`, string(producer.batches[0][1].Value))
+	assert.Equal(t, "FR,Peach", string(producer.batches[1][0].Key))
+}
+
+func TestKafkaSinkAvro(t *testing.T) {
+	producer := &fakeProducer{}
+	sink := NewKafkaSink(context.Background(), producer, "people")
+	sink.Encoding = KafkaAvro
+	sink.SchemaID = 7
+	sink.Schema = &Schema{Columns: []ColumnSchema{
+		{Name: "age", Type: IntegerType, Required: true},
+		{Name: "active", Type: BooleanType},
+	}}
+
+	input := "first name,age,active\nMario,42,true\nLuigi,-1,\n"
+	assert.Nil(t, NewProcessor(strings.NewReader(input), nil).Copy(sink))
+
+	assert.Equal(t, `{"fields":[{"default":null,"name":"first_name","type":["null","string"]},`+
+		`{"name":"age","type":"long"},{"default":null,"name":"active","type":["null","boolean"]}],"name":"Row","type":"record"}`,
+		sink.AvroSchema())
+
+	messages := producer.batches[0]
+	// schema id, string branch, "Mario", 42, boolean branch, true
+	assert.Equal(t, []byte{0, 0, 0, 0, 7, 2, 10, 'M', 'a', 'r', 'i', 'o', 84, 2, 1}, messages[0].Value)
+	// null active
+	assert.Equal(t, []byte{0, 0, 0, 0, 7, 2, 10, 'L', 'u', 'i', 'g', 'i', 1, 0}, messages[1].Value)
+}
+
+func TestKafkaSinkDelivery(t *testing.T) {
+	producer := &fakeProducer{fail: "3"}
+	sink := NewKafkaSink(context.Background(), producer, "numbers")
+	sink.KeyColumns = []string{"n"}
+	sink.BatchMessages = 2
+	err := NewProcessor(strings.NewReader(numbers(6)), nil).Copy(sink)
+	assert.NotNil(t, err)
+
+	producer = &fakeProducer{fail: "3"}
+	sink = NewKafkaSink(context.Background(), producer, "numbers")
+	sink.KeyColumns = []string{"n"}
+	sink.BatchMessages = 2
+	sink.Delivery = KafkaAtMostOnce
+	dropped := 0
+	sink.ErrorHandler = func(err error) { dropped++ }
+
+	assert.Nil(t, NewProcessor(strings.NewReader(numbers(6)), nil).Copy(sink))
+	assert.Equal(t, 1, dropped)
+	assert.Len(t, producer.batches, 2)
+}
+
+func TestKafkaSinkAcks(t *testing.T) {
+	producer := &fakeProducer{fail: "5"}
+	sink := NewKafkaSink(context.Background(), producer, "numbers")
+	sink.KeyColumns = []string{"n"}
+	sink.BatchMessages = 3
+	assert.Nil(t, sink.Open([]string{"n"}))
+
+	var acks []error
+	ack := func(err error) { acks = append(acks, err) }
+	assert.Nil(t, sink.WriteAck([][]string{{"1"}, {"2"}}, ack))
+	assert.Empty(t, acks)
+	// the batch holding the last row of both writes is published
+	assert.Nil(t, sink.WriteAck([][]string{{"3"}}, ack))
+	assert.Equal(t, []error{nil, nil}, acks)
+
+	assert.Nil(t, sink.WriteAck([][]string{{"4"}, {"5"}}, ack))
+	assert.NotNil(t, sink.Close())
+	assert.Len(t, acks, 3)
+	assert.NotNil(t, acks[2])
+}
@@ -0,0 +1,215 @@
+package parallel_csv
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// tailBlockSize is the size of the blocks read backwards by Tail
+const tailBlockSize = 64 * KB
+
+// limitSink writes to the sink the rows between skip and skip+left, then stops the run. A
+// negative left does not limit the rows
+type limitSink struct {
+	Sink
+	skip int
+	left int
+}
+
+func (s *limitSink) Write(rows [][]string) error {
+	if s.skip >= len(rows) {
+		s.skip -= len(rows)
+		return nil
+	}
+	rows, s.skip = rows[s.skip:], 0
+
+	if s.left >= 0 && len(rows) > s.left {
+		rows = rows[:s.left]
+	}
+	if len(rows) > 0 {
+		if err := s.Sink.Write(rows); err != nil {
+			return err
+		}
+	}
+	if s.left >= 0 {
+		if s.left -= len(rows); s.left == 0 {
+			return errStopRun
+		}
+	}
+	return nil
+}
+
+func (s *limitSink) Abort(err error) {
+	abortSink(s.Sink, err)
+}
+
+// rowsSink keeps the rows written as a single field
+type rowsSink struct {
+	rows []string
+}
+
+func (s *rowsSink) Open(header []string) error { return nil }
+func (s *rowsSink) Close() error               { return nil }
+func (s *rowsSink) Write(rows [][]string) error {
+	for _, row := range rows {
+		s.rows = append(s.rows, row[0])
+	}
+	return nil
+}
+
+// tailSink keeps the last n rows written
+type tailSink struct {
+	*rowsSink
+	n int
+}
+
+func (s *tailSink) Write(rows [][]string) error {
+	s.rowsSink.Write(rows)
+	if len(s.rows) > 2*s.n {
+		s.rows = append([]string{}, s.rows[len(s.rows)-s.n:]...)
+	}
+	return nil
+}
+
+func (s *tailSink) Close() error {
+	if len(s.rows) > s.n {
+		s.rows = s.rows[len(s.rows)-s.n:]
+	}
+	return nil
+}
+
+// Limit writes to the sink, in source order, the n rows following the first offset ones. A
+// negative n writes every row after offset. The run stops as soon as the rows have been written,
+// without reading the rest of the file
+func (p processor) Limit(offset int, n int, sink Sink) error {
+	err := p.runSink(&limitSink{Sink: sink, skip: offset, left: n}, p.header, func(chunk Chunk) ([][]string, error) {
+		rows := make([][]string, len(chunk.Rows))
+		for i, row := range chunk.Rows {
+			if chunk.Cancelled() {
+				return nil, errCancelled
+			}
+			rows[i] = p.split(row)
+		}
+		return rows, nil
+	})
+	if err == errStopRun {
+		return nil
+	}
+	return err
+}
+
+// Head returns the first n rows, without reading the rest of the file
+func (p processor) Head(n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	rows := &rowsSink{}
+	err := p.runSink(&limitSink{Sink: rows, left: n}, nil, func(chunk Chunk) ([][]string, error) {
+		out := make([][]string, len(chunk.Rows))
+		for i, row := range chunk.Rows {
+			if chunk.Cancelled() {
+				return nil, errCancelled
+			}
+			out[i] = []string{cloneString(row)}
+		}
+		return out, nil
+	})
+	if err != nil && err != errStopRun {
+		return nil, err
+	}
+	return rows.rows, nil
+}
+
+// Tail returns the last n rows. When the input is an io.ReadSeeker, no row can be dropped by
+// Config.Filter, Config.Regexps, Config.Where, Config.SkipDataRows or validation and no budget is
+// set, the end of the input is read backwards, block by block, until it holds n rows. Otherwise
+// the whole file is read, keeping the last n rows
+func (p processor) Tail(n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	seeker, ok := p.source.(io.ReadSeeker)
+	if ok && p.config.Parser == nil && p.config.Filter == nil && len(p.config.Regexps) == 0 && p.config.Where == nil &&
+		p.config.SkipDataRows == 0 && !p.config.Strict && !p.config.ValidateFieldCount &&
+		p.config.MaxRows == 0 && p.config.MaxBytes == 0 {
+		return p.tailBackwards(seeker, n)
+	}
+
+	rows := &rowsSink{}
+	err := p.runSink(&tailSink{rowsSink: rows, n: n}, nil, func(chunk Chunk) ([][]string, error) {
+		// only the last n rows of a chunk can be among the last n of the file
+		rows := chunk.Rows
+		if len(rows) > n {
+			rows = rows[len(rows)-n:]
+		}
+		out := make([][]string, len(rows))
+		for i, row := range rows {
+			out[i] = []string{cloneString(row)}
+		}
+		return out, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows.rows, nil
+}
+
+// tailBackwards reads the input backwards from its end up to the first row
+func (p processor) tailBackwards(seeker io.ReadSeeker, n int) ([]string, error) {
+	end, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	// like the forward read, an input without rows after the header is empty
+	if end <= p.start.Offset {
+		return nil, EmptyFileError
+	}
+
+	// the blocks are kept from the last one, line breaks are counted in each block only once
+	var blocks [][]byte
+	breaks, size := 0, int64(0)
+	position := end
+	for position > p.start.Offset {
+		length := int64(tailBlockSize)
+		if position-p.start.Offset < length {
+			length = position - p.start.Offset
+		}
+		position -= length
+
+		block := make([]byte, length)
+		if _, err := seeker.Seek(position, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(seeker, block); err != nil {
+			return nil, err
+		}
+		if len(blocks) == 0 {
+			// the line break ending the last row does not start another one
+			block = bytes.TrimSuffix(block, []byte(LineBreak))
+		}
+		blocks = append(blocks, block)
+		size += int64(len(block))
+
+		// n line breaks before the last row mean that n complete rows have been read
+		breaks += bytes.Count(block, []byte(LineBreak))
+		if breaks >= n {
+			break
+		}
+	}
+
+	data := make([]byte, 0, size)
+	for i := len(blocks) - 1; i >= 0; i-- {
+		data = append(data, blocks[i]...)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	rows := strings.Split(string(data), LineBreak)
+	if len(rows) > n {
+		rows = rows[len(rows)-n:]
+	}
+	return rows, nil
+}
@@ -0,0 +1,166 @@
+package parallel_csv
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// numbers is a file with a header and the rows 1 to n
+func numbers(n int) string {
+	b := strings.Builder{}
+	b.WriteString("n\n")
+	for i := 1; i <= n; i++ {
+		b.WriteString(strconv.Itoa(i) + "\n")
+	}
+	return b.String()
+}
+
+// countingReader counts the bytes read, it hides the Seek method of the reader
+type countingReader struct {
+	reader io.Reader
+	read   int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.read += n
+	return n, err
+}
+
+func TestHead(t *testing.T) {
+	input := &countingReader{reader: strings.NewReader(numbers(100000))}
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 64
+	p := NewProcessor(input, &config)
+
+	rows, err := p.Head(5)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "2", "3", "4", "5"}, rows)
+	assert.Less(t, input.read, 100000)
+}
+
+func TestHeadMoreThanRows(t *testing.T) {
+	p := NewProcessor(strings.NewReader(numbers(3)), nil)
+	rows, err := p.Head(10)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "2", "3"}, rows)
+}
+
+func TestTail(t *testing.T) {
+	for _, n := range []int{0, 1, 3, 10} {
+		config := GetDefaultConfig()
+		config.BytesPerWorker = 8
+		p := NewProcessor(strings.NewReader(numbers(5)), &config)
+
+		rows, err := p.Tail(n)
+		assert.Nil(t, err)
+		expected := []string{"1", "2", "3", "4", "5"}
+		if n < len(expected) {
+			expected = expected[len(expected)-n:]
+		}
+		if n == 0 {
+			expected = nil
+		}
+		assert.Equal(t, expected, rows, n)
+	}
+}
+
+func TestTailBackwards(t *testing.T) {
+	// more rows than a single block, read backwards
+	input := numbers(50000)
+	p := NewProcessor(strings.NewReader(input), nil)
+	rows, err := p.Tail(3)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"49998", "49999", "50000"}, rows)
+
+	p = NewProcessor(strings.NewReader(input), nil)
+	rows, err = p.Tail(20000)
+	assert.Nil(t, err)
+	assert.Len(t, rows, 20000)
+	assert.Equal(t, "30001", rows[0])
+}
+
+func TestTailHeaderOnly(t *testing.T) {
+	// reading backwards or forwards, a file without rows is empty like for Head
+	p := NewProcessor(strings.NewReader(numbers(0)), nil)
+	rows, err := p.Tail(3)
+	assert.Equal(t, EmptyFileError, err)
+	assert.Nil(t, rows)
+
+	p = NewProcessor(&countingReader{reader: strings.NewReader(numbers(0))}, nil)
+	rows, err = p.Tail(3)
+	assert.Equal(t, EmptyFileError, err)
+	assert.Nil(t, rows)
+
+	p = NewProcessor(strings.NewReader(numbers(0)), nil)
+	rows, err = p.Head(3)
+	assert.Equal(t, EmptyFileError, err)
+	assert.Nil(t, rows)
+}
+
+func TestTailFullPass(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 16
+	config.Where = MustParseWhere("n < 40")
+	p := NewProcessor(&countingReader{reader: strings.NewReader(numbers(100))}, &config)
+
+	rows, err := p.Tail(3)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"37", "38", "39"}, rows)
+}
+
+func TestTailFilter(t *testing.T) {
+	// rows dropped by the filter cannot be found reading backwards
+	input := "n\n1\n2\nx1\nx2\n"
+	config := GetDefaultConfig()
+	config.Filter = func(row []byte) bool {
+		return !bytes.HasPrefix(row, []byte("x"))
+	}
+	p := NewProcessor(strings.NewReader(input), &config)
+
+	rows, err := p.Tail(2)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "2"}, rows)
+}
+
+func TestTailRegexps(t *testing.T) {
+	config := GetDefaultConfig()
+	config.Regexps = []RegexFilter{{Pattern: regexp.MustCompile("^[0-9]$")}}
+	p := NewProcessor(strings.NewReader(numbers(20)), &config)
+
+	rows, err := p.Tail(2)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"8", "9"}, rows)
+}
+
+func TestTailSkipDataRows(t *testing.T) {
+	// the skipped rows are not returned even when fewer than n rows are left
+	config := GetDefaultConfig()
+	config.SkipDataRows = 3
+	p := NewProcessor(strings.NewReader(numbers(5)), &config)
+
+	rows, err := p.Tail(5)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"4", "5"}, rows)
+}
+
+func TestLimit(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 16
+	p := NewProcessor(strings.NewReader(numbers(100)), &config)
+
+	out := &bytes.Buffer{}
+	assert.Nil(t, p.Limit(10, 3, NewCSVSink(out, ",")))
+	assert.Equal(t, "n\n11\n12\n13\n", out.String())
+
+	p = NewProcessor(strings.NewReader(numbers(5)), &config)
+	sink := &memorySink{}
+	assert.Nil(t, p.Limit(3, -1, sink))
+	assert.Equal(t, [][]string{{"4"}, {"5"}}, sink.rows)
+	assert.True(t, sink.closed)
+}
@@ -0,0 +1,180 @@
+package parallel_csv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBatchRows is the number of rows loaded at once by the database sinks when not set
+const DefaultBatchRows = 10000
+
+// typedRow converts the fields of a row to Go values
+type typedRow func(fields []string) ([]interface{}, error)
+
+// bindTypes returns the function converting the fields of the rows to the types of the schema
+// columns: int64, float64, bool, time.Time or string. Empty values become nil, except in the
+// string columns of the schema. Without a schema every non-empty value is a string. Values are
+// copied, so that they outlive the chunk buffer
+func bindTypes(header []string, schema *Schema) (typedRow, error) {
+	columns := map[int]compiledColumn{}
+	if schema != nil {
+		compiled, err := compileColumns(header, *schema)
+		if err != nil {
+			return nil, err
+		}
+		for _, column := range compiled {
+			columns[column.index] = column
+		}
+	}
+
+	return func(fields []string) ([]interface{}, error) {
+		values := make([]interface{}, len(fields))
+		for i, field := range fields {
+			column, ok := columns[i]
+			if !ok {
+				column = compiledColumn{ColumnSchema: ColumnSchema{Name: columnName(i)}}
+			}
+
+			value, err := column.convert(field)
+			if err != nil {
+				return nil, fmt.Errorf("%w: column %s: %q", TypeMismatchError, column.Name, field)
+			}
+			values[i] = value
+		}
+		return values, nil
+	}, nil
+}
+
+// convert parses value with the decoder of the column or as a value of its type, surrounding
+// spaces are ignored except in string columns without decoder
+func (c compiledColumn) convert(value string) (interface{}, error) {
+	if c.decoder != nil {
+		if trimmed := strings.TrimSpace(value); trimmed != "" {
+			return c.decoder([]byte(trimmed))
+		}
+		return nil, nil
+	}
+	if c.Type == StringType {
+		return cloneString(value), nil
+	}
+
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		return nil, nil
+	}
+	switch c.Type {
+	case IntegerType:
+		return strconv.ParseInt(trimmed, 10, 64)
+	case FloatType:
+		return strconv.ParseFloat(trimmed, 64)
+	case BooleanType:
+		return strconv.ParseBool(trimmed)
+	case TimeType:
+		layout := c.Layout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		return time.Parse(layout, trimmed)
+	default:
+		return cloneString(value), nil
+	}
+}
+
+// formatValue writes a value converted by bindTypes as text, times with layout. Nil is empty
+func formatValue(value interface{}, layout string) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(layout)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// parallelLoader groups rows in batches and loads them with several goroutines, each passing its
+// own index to load. Once a load fails the following batches are dropped
+type parallelLoader struct {
+	batches chan [][]interface{}
+	size    int
+	batch   [][]interface{}
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	err     error
+}
+
+func newParallelLoader(workers int, size int, load func(worker int, batch [][]interface{}) error) *parallelLoader {
+	if size <= 0 {
+		size = DefaultBatchRows
+	}
+
+	l := &parallelLoader{
+		batches: make(chan [][]interface{}, workers),
+		size:    size,
+	}
+	l.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func(worker int) {
+			defer l.wg.Done()
+			for batch := range l.batches {
+				if l.failed() != nil {
+					continue
+				}
+				if err := load(worker, batch); err != nil {
+					l.mu.Lock()
+					if l.err == nil {
+						l.err = err
+					}
+					l.mu.Unlock()
+				}
+			}
+		}(i)
+	}
+	return l
+}
+
+// failed returns the first error of the loads
+func (l *parallelLoader) failed() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// add appends a row to the current batch, sending it once full. It returns the first error of
+// the loads
+func (l *parallelLoader) add(row []interface{}) error {
+	if err := l.failed(); err != nil {
+		return err
+	}
+
+	l.batch = append(l.batch, row)
+	if len(l.batch) == l.size {
+		l.batches <- l.batch
+		l.batch = make([][]interface{}, 0, l.size)
+	}
+	return nil
+}
+
+// close sends the last batch, waits for every load and returns the first error
+func (l *parallelLoader) close() error {
+	if len(l.batch) > 0 {
+		l.batches <- l.batch
+		l.batch = nil
+	}
+	close(l.batches)
+	l.wg.Wait()
+	return l.failed()
+}
@@ -0,0 +1,59 @@
+package parallel_csv
+
+// Logger receives the structured logs of the processor, each message followed by alternating
+// keys and values. A *slog.Logger can be used as is
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// LogLevel is the level at which an event is logged
+type LogLevel int
+
+const (
+	// LogDefault logs the event at its default level
+	LogDefault LogLevel = iota
+	LogDebug
+	LogInfo
+	LogWarn
+	LogError
+	// LogOff does not log the event
+	LogOff
+)
+
+// LogLevels are the levels of the events logged by the processor
+type LogLevels struct {
+	// Run is the start and the end of the runs, LogInfo by default
+	Run LogLevel
+	// Chunk is the processing of each chunk along with its duration, LogDebug by default
+	Chunk LogLevel
+	// Retry is each job or sink write run again, LogWarn by default
+	Retry LogLevel
+	// Skip is each error skipped by SkipOnError, LogWarn by default
+	Skip LogLevel
+	// Error is the error failing a run, LogError by default
+	Error LogLevel
+}
+
+// log sends an event to the logger at level, or at fallback for LogDefault
+func (c *Config) log(level LogLevel, fallback LogLevel, msg string, args ...interface{}) {
+	if c.Logger == nil {
+		return
+	}
+	if level == LogDefault {
+		level = fallback
+	}
+
+	switch level {
+	case LogDebug:
+		c.Logger.Debug(msg, args...)
+	case LogInfo:
+		c.Logger.Info(msg, args...)
+	case LogWarn:
+		c.Logger.Warn(msg, args...)
+	case LogError:
+		c.Logger.Error(msg, args...)
+	}
+}
@@ -0,0 +1,22 @@
+//go:build go1.21
+
+package parallel_csv
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	out := &bytes.Buffer{}
+	config := GetDefaultConfig()
+	config.Logger = slog.New(slog.NewTextHandler(out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	assert.Nil(t, NewProcessor(strings.NewReader(numbers(10)), &config).Run(func(header []string, rows []string) {}))
+	assert.Contains(t, out.String(), "level=INFO msg=\"run started\" workers=")
+	assert.Contains(t, out.String(), "level=DEBUG msg=\"chunk processed\" index=0")
+	assert.Contains(t, out.String(), "msg=\"run finished\"")
+}
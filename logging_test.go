@@ -0,0 +1,83 @@
+package parallel_csv
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingLogger keeps the messages logged, prefixed by their level
+type recordingLogger struct {
+	mu       sync.Mutex
+	messages []string
+	args     [][]interface{}
+}
+
+func (l *recordingLogger) record(level string, msg string, args []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, level+" "+msg)
+	l.args = append(l.args, args)
+}
+
+func (l *recordingLogger) Debug(msg string, args ...interface{}) { l.record("DEBUG", msg, args) }
+func (l *recordingLogger) Info(msg string, args ...interface{})  { l.record("INFO", msg, args) }
+func (l *recordingLogger) Warn(msg string, args ...interface{})  { l.record("WARN", msg, args) }
+func (l *recordingLogger) Error(msg string, args ...interface{}) { l.record("ERROR", msg, args) }
+
+func (l *recordingLogger) count(message string) int {
+	n := 0
+	for _, m := range l.messages {
+		if m == message {
+			n++
+		}
+	}
+	return n
+}
+
+func TestLogger(t *testing.T) {
+	logger := &recordingLogger{}
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 8
+	config.Logger = logger
+	config.ErrorPolicy = SkipOnError
+	config.Retry = RetryPolicy{Attempts: 2}
+
+	failed := sync.Map{}
+	err := NewProcessor(strings.NewReader(numbers(10)), &config).RunChunks(func(chunk Chunk) error {
+		if chunk.Index == 1 {
+			// fails once, then succeeds
+			if _, retried := failed.LoadOrStore(chunk.Index, true); !retried {
+				return errors.New("transient")
+			}
+		}
+		if chunk.Index == 2 {
+			return errors.New("bad chunk")
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+
+	assert.Equal(t, "INFO run started", logger.messages[0])
+	assert.Equal(t, "INFO run finished", logger.messages[len(logger.messages)-1])
+	// both failing chunks are retried once
+	assert.Equal(t, 2, logger.count("WARN retrying"))
+	assert.Equal(t, 1, logger.count("WARN error skipped"))
+	assert.Greater(t, logger.count("DEBUG chunk processed"), 2)
+}
+
+func TestLoggerLevels(t *testing.T) {
+	logger := &recordingLogger{}
+	config := GetDefaultConfig()
+	config.Logger = logger
+	config.LogLevels = LogLevels{Run: LogDebug, Chunk: LogOff, Error: LogWarn}
+
+	err := NewProcessor(strings.NewReader(numbers(10)), &config).RunChunks(func(chunk Chunk) error {
+		return errors.New("broken")
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"DEBUG run started", "WARN run failed"}, logger.messages)
+	assert.Equal(t, err, logger.args[1][len(logger.args[1])-1])
+}
@@ -0,0 +1,125 @@
+package parallel_csv
+
+import (
+	"fmt"
+	"io"
+)
+
+const LookupMissError = Error("key not found in lookup table")
+const DuplicateLookupKeyError = Error("duplicate key in lookup table")
+
+// MissPolicy decides what a Lookup does with the rows whose key is not in its table
+type MissPolicy int
+
+const (
+	// MissEmpty keeps the rows, with empty looked-up columns
+	MissEmpty MissPolicy = iota
+	// MissDrop drops the rows
+	MissDrop
+	// MissError fails the rows with LookupMissError
+	MissError
+)
+
+// LookupConfig describes how a Lookup loads its reference file and matches the rows with it
+type LookupConfig struct {
+	// Keys are the key columns of the rows enriched
+	Keys []string
+	// ReferenceKeys are the key columns of the reference file, the same as Keys if empty
+	ReferenceKeys []string
+	// Columns are the columns of the reference file appended to the rows, all of the non-key
+	// ones if empty
+	Columns []string
+	Miss    MissPolicy
+	// Config reads the reference file, GetDefaultConfig if nil
+	Config *Config
+}
+
+// Lookup is a Transform appending to every row the columns of the row of a reference file having
+// the same key, a hash join with a file small enough to fit in memory. Unlike Join, which may
+// produce several rows for each one, every key of the reference file must be unique
+type Lookup struct {
+	keys    []string
+	miss    MissPolicy
+	header  []string
+	values  map[string][]string
+	missing []string
+}
+
+// NewLookup loads the reference file in memory, indexed by key
+func NewLookup(reference io.Reader, config LookupConfig) (*Lookup, error) {
+	referenceKeys := config.ReferenceKeys
+	if len(referenceKeys) == 0 {
+		referenceKeys = config.Keys
+	}
+	if len(referenceKeys) != len(config.Keys) || len(config.Keys) == 0 {
+		return nil, KeyCountError
+	}
+	readConfig := config.Config
+	if readConfig == nil {
+		defaultConfig := GetDefaultConfig()
+		readConfig = &defaultConfig
+	}
+
+	table, err := loadLookup(reference, readConfig, referenceKeys)
+	if err != nil {
+		return nil, err
+	}
+	columns := config.Columns
+	if len(columns) == 0 {
+		columns = table.header
+	}
+	indexes, err := headerIndexes(table.header, columns)
+	if err != nil {
+		return nil, fmt.Errorf("lookup: %w", err)
+	}
+
+	lookup := &Lookup{
+		keys:    config.Keys,
+		miss:    config.Miss,
+		header:  columns,
+		values:  make(map[string][]string, len(table.rows)),
+		missing: make([]string, len(columns)),
+	}
+	for key, rows := range table.rows {
+		if len(rows) > 1 {
+			return nil, fmt.Errorf("%w: %q", DuplicateLookupKeyError, key)
+		}
+		values := make([]string, len(indexes))
+		for i, index := range indexes {
+			values[i] = rows[0][index]
+		}
+		lookup.values[key] = values
+	}
+	return lookup, nil
+}
+
+// Len returns the number of keys of the reference file
+func (l *Lookup) Len() int {
+	return len(l.values)
+}
+
+func (l *Lookup) Bind(header []string) ([]string, RowFunc, error) {
+	indexes, err := headerIndexes(header, l.keys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var output []string
+	if len(header) > 0 {
+		output = append(append(make([]string, 0, len(header)+len(l.header)), header...), l.header...)
+	}
+	return output, func(fields []string) ([]string, error) {
+		key := keyOf(fields, indexes)
+		values, ok := l.values[key]
+		if !ok {
+			switch l.miss {
+			case MissDrop:
+				return nil, nil
+			case MissError:
+				return nil, fmt.Errorf("%w: %q", LookupMissError, key)
+			}
+			values = l.missing
+		}
+		return append(fields, values...), nil
+	}, nil
+}
@@ -0,0 +1,60 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		miss     MissPolicy
+		expected [][]string
+	}{
+		{MissEmpty, [][]string{{"1", "c1", "10", "IT"}, {"2", "c2", "20", "FR"}, {"3", "c9", "30", ""}, {"4", "c1", "40", "IT"}}},
+		{MissDrop, [][]string{{"1", "c1", "10", "IT"}, {"2", "c2", "20", "FR"}, {"4", "c1", "40", "IT"}}},
+	}
+
+	for _, test := range tests {
+		lookup, err := NewLookup(strings.NewReader(customers), LookupConfig{
+			Keys:          []string{"customer"},
+			ReferenceKeys: []string{"id"},
+			Columns:       []string{"country"},
+			Miss:          test.miss,
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 3, lookup.Len())
+
+		config := GetDefaultConfig()
+		config.BytesPerWorker = 8
+		config.Transforms = []Transform{lookup}
+		sink := &memorySink{}
+		assert.Nil(t, NewProcessor(strings.NewReader(orders), &config).Copy(sink))
+		assert.Equal(t, []string{"order", "customer", "amount", "country"}, sink.header)
+		assert.Equal(t, test.expected, sink.rows)
+	}
+}
+
+func TestLookupErrors(t *testing.T) {
+	lookup, err := NewLookup(strings.NewReader(customers), LookupConfig{
+		Keys:          []string{"customer"},
+		ReferenceKeys: []string{"id"},
+		Miss:          MissError,
+	})
+	assert.Nil(t, err)
+	config := GetDefaultConfig()
+	config.Transforms = []Transform{lookup}
+	err = NewProcessor(strings.NewReader(orders), &config).Copy(&memorySink{})
+	assert.ErrorIs(t, err, LookupMissError)
+
+	_, err = NewLookup(strings.NewReader("id,v\na,1\na,2\n"), LookupConfig{Keys: []string{"id"}})
+	assert.ErrorIs(t, err, DuplicateLookupKeyError)
+	_, err = NewLookup(strings.NewReader(customers), LookupConfig{Keys: []string{"id"}, Columns: []string{"nope"}})
+	assert.ErrorIs(t, err, ColumnNotFoundError)
+	_, err = NewLookup(strings.NewReader(customers), LookupConfig{Keys: []string{"a", "b"}, ReferenceKeys: []string{"id"}})
+	assert.ErrorIs(t, err, KeyCountError)
+
+	config.Transforms = []Transform{lookup}
+	err = NewProcessor(strings.NewReader("a,b\n1,2\n"), &config).Copy(&memorySink{})
+	assert.ErrorIs(t, err, ColumnNotFoundError)
+}
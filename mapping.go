@@ -0,0 +1,241 @@
+package parallel_csv
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const ColumnRangeError = Error("invalid column range")
+
+// ColumnMapping reshapes the rows: columns are renamed first, then the output is made of the
+// Columns listed, in that order. Columns not listed are dropped. Files without header need
+// Columns or Ranges, named col_1, col_2 and so on, and produce rows without header
+type ColumnMapping struct {
+	// Rename maps the input names to the output ones
+	Rename map[string]string
+	// Columns are the output columns, named after renaming. When empty every column is kept
+	// in its original order
+	Columns []string
+	// Drop removes columns, named after renaming, from the output
+	Drop []string
+	// Ranges selects the output columns by position instead of Columns, like cut does: a comma
+	// separated list of 1-based indexes and ranges such as "1-5, 8, -1". Negative indexes count
+	// from the last column and ranges such as "3-" run up to it. Files with a header resolve them
+	// against it, the others against each row, the positions past its end being left out
+	Ranges string
+}
+
+func (m ColumnMapping) Bind(header []string) ([]string, RowFunc, error) {
+	renamed := make([]string, len(header))
+	for i, column := range header {
+		renamed[i] = column
+		if name, ok := m.Rename[column]; ok {
+			renamed[i] = name
+		}
+	}
+
+	if m.Ranges != "" {
+		return m.bindRanges(header, renamed)
+	}
+
+	columns := m.Columns
+	if len(columns) == 0 {
+		if len(header) == 0 {
+			return nil, nil, errors.New("a column mapping without header must list its columns")
+		}
+		columns = renamed
+	}
+	if _, err := headerIndexes(renamed, m.Drop); err != nil {
+		return nil, nil, err
+	}
+	dropped := map[string]bool{}
+	for _, column := range m.Drop {
+		dropped[column] = true
+	}
+
+	output := []string{}
+	for _, column := range columns {
+		if !dropped[column] {
+			output = append(output, column)
+		}
+	}
+	indexes, err := headerIndexes(renamed, output)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(header) == 0 {
+		output = nil
+	}
+	return output, func(fields []string) ([]string, error) {
+		mapped := make([]string, len(indexes))
+		for i, index := range indexes {
+			if index < len(fields) {
+				mapped[i] = fields[index]
+			}
+		}
+		return mapped, nil
+	}, nil
+}
+
+// bindRanges binds a mapping selecting its columns with Ranges
+func (m ColumnMapping) bindRanges(header []string, renamed []string) ([]string, RowFunc, error) {
+	if len(m.Columns) > 0 {
+		return nil, nil, errors.New("a column mapping cannot have both Columns and Ranges")
+	}
+	ranges, err := parseColumnRanges(m.Ranges)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(header) == 0 {
+		if len(m.Drop) > 0 {
+			return nil, nil, errors.New("a column mapping without header cannot drop columns selected by Ranges")
+		}
+		return nil, func(fields []string) ([]string, error) {
+			var mapped []string
+			for _, r := range ranges {
+				first, last := r.resolve(len(fields))
+				for i := first; i <= last; i++ {
+					mapped = append(mapped, fields[i])
+				}
+			}
+			return mapped, nil
+		}, nil
+	}
+
+	if _, err := headerIndexes(renamed, m.Drop); err != nil {
+		return nil, nil, err
+	}
+	dropped := map[string]bool{}
+	for _, column := range m.Drop {
+		dropped[column] = true
+	}
+
+	var output []string
+	var indexes []int
+	for _, r := range ranges {
+		if !r.within(len(header)) {
+			return nil, nil, fmt.Errorf("%w: %s is out of the %d columns", ColumnNotFoundError, r, len(header))
+		}
+		first, last := r.resolve(len(header))
+		if first > last {
+			return nil, nil, fmt.Errorf("%w: %s ends before it starts", ColumnRangeError, r)
+		}
+		for i := first; i <= last; i++ {
+			if !dropped[renamed[i]] {
+				output = append(output, renamed[i])
+				indexes = append(indexes, i)
+			}
+		}
+	}
+
+	return output, func(fields []string) ([]string, error) {
+		mapped := make([]string, len(indexes))
+		for i, index := range indexes {
+			if index < len(fields) {
+				mapped[i] = fields[index]
+			}
+		}
+		return mapped, nil
+	}, nil
+}
+
+// columnRange is a range of 1-based column positions, negative ones counting from the last
+// column. A single position has the same first and last, an open range has no last
+type columnRange struct {
+	first int
+	last  int
+	open  bool
+}
+
+// parseColumnRanges parses a comma separated list of positions and ranges
+func parseColumnRanges(spec string) ([]columnRange, error) {
+	var ranges []columnRange
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		// the first dash after a digit divides the bounds, the others are signs
+		divider := -1
+		for i := 1; i < len(item); i++ {
+			if item[i] == '-' && item[i-1] >= '0' && item[i-1] <= '9' {
+				divider = i
+				break
+			}
+		}
+
+		var r columnRange
+		var err error
+		if divider == -1 {
+			r.first, err = parseColumnPosition(item)
+			r.last = r.first
+		} else {
+			r.first, err = parseColumnPosition(item[:divider])
+			if err == nil && divider == len(item)-1 {
+				r.open = true
+			} else if err == nil {
+				r.last, err = parseColumnPosition(item[divider+1:])
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ColumnRangeError, item)
+		}
+		// the order of bounds with different signs depends on the number of columns
+		if !r.open && (r.first > 0) == (r.last > 0) && r.first > r.last {
+			return nil, fmt.Errorf("%w: %q ends before it starts", ColumnRangeError, item)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+func parseColumnPosition(text string) (int, error) {
+	position, err := strconv.Atoi(text)
+	if err == nil && position == 0 {
+		err = errors.New("positions start from 1")
+	}
+	return position, err
+}
+
+// within tells whether the bounds of the range are among width columns
+func (r columnRange) within(width int) bool {
+	inside := func(position int) bool {
+		return position <= width && -position <= width
+	}
+	return inside(r.first) && (r.open || inside(r.last))
+}
+
+// resolve returns the 0-based positions of the range among width columns, clamped to them. The
+// range is empty if first is greater than last
+func (r columnRange) resolve(width int) (first int, last int) {
+	index := func(position int) int {
+		if position < 0 {
+			return width + position
+		}
+		return position - 1
+	}
+
+	first, last = index(r.first), width-1
+	if !r.open {
+		last = index(r.last)
+	}
+	if first < 0 {
+		first = 0
+	}
+	if last > width-1 {
+		last = width - 1
+	}
+	return first, last
+}
+
+func (r columnRange) String() string {
+	switch {
+	case r.open:
+		return fmt.Sprintf("%d-", r.first)
+	case r.first == r.last:
+		return strconv.Itoa(r.first)
+	default:
+		return fmt.Sprintf("%d-%d", r.first, r.last)
+	}
+}
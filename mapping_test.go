@@ -0,0 +1,105 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestColumnMapping(t *testing.T) {
+	sink := copyRows(t, customers, ColumnMapping{
+		Rename:  map[string]string{"id": "customer_id", "country": "country_code"},
+		Columns: []string{"country_code", "customer_id"},
+	})
+	assert.Equal(t, []string{"country_code", "customer_id"}, sink.header)
+	assert.Equal(t, [][]string{{"IT", "c1"}, {"FR", "c2"}, {"DE", "c3"}}, sink.rows)
+}
+
+func TestColumnMappingDrop(t *testing.T) {
+	sink := copyRows(t, customers, ColumnMapping{
+		Rename: map[string]string{"name": "full_name"},
+		Drop:   []string{"id"},
+	})
+	assert.Equal(t, []string{"full_name", "country"}, sink.header)
+	assert.Equal(t, [][]string{{"anna", "IT"}, {"bob, jr", "FR"}, {"carla", "DE"}}, sink.rows)
+}
+
+func TestColumnMappingChained(t *testing.T) {
+	// masking sees the names produced by the mapping
+	sink := copyRows(t, customers, ColumnMapping{Rename: map[string]string{"name": "n"}}, Mask(1, "n"))
+	assert.Equal(t, []string{"***a", "******r", "****a"}, column(sink.rows, 1))
+}
+
+func TestColumnMappingUnknownColumn(t *testing.T) {
+	_, _, err := ColumnMapping{Columns: []string{"name", "age"}}.Bind([]string{"name"})
+	assert.ErrorIs(t, err, ColumnNotFoundError)
+
+	// renamed columns are only known by their new name
+	_, _, err = ColumnMapping{Rename: map[string]string{"name": "n"}, Columns: []string{"name"}}.Bind([]string{"name"})
+	assert.ErrorIs(t, err, ColumnNotFoundError)
+}
+
+func TestColumnMappingWithoutHeader(t *testing.T) {
+	config := GetDefaultConfig()
+	config.HeaderConfig.HasHeader = false
+	config.Transforms = []Transform{ColumnMapping{Columns: []string{"col_3", "col_1"}}}
+	p := NewProcessor(strings.NewReader("a,b,c\nd,e,f\n"), &config)
+
+	sink := &memorySink{}
+	assert.Nil(t, p.Copy(sink))
+	assert.Empty(t, sink.header)
+	assert.Equal(t, [][]string{{"c", "a"}, {"f", "d"}}, sink.rows)
+
+	_, _, err := ColumnMapping{Drop: []string{"col_1"}}.Bind(nil)
+	assert.NotNil(t, err)
+}
+
+func TestColumnMappingRanges(t *testing.T) {
+	const wide = "a,b,c,d,e\n1,2,3,4,5\n"
+	tests := []struct {
+		ranges   string
+		expected []string
+	}{
+		{"1-3, 5", []string{"a", "b", "c", "e"}},
+		{"-1", []string{"e"}},
+		{"4-, 1", []string{"d", "e", "a"}},
+		{"-2--1,2-2", []string{"d", "e", "b"}},
+		{"2--2", []string{"b", "c", "d"}},
+	}
+	for _, test := range tests {
+		sink := copyRows(t, wide, ColumnMapping{Ranges: test.ranges})
+		assert.Equal(t, test.expected, sink.header, test.ranges)
+		assert.Len(t, sink.rows, 1)
+	}
+
+	sink := copyRows(t, wide, ColumnMapping{Ranges: "1-3", Rename: map[string]string{"a": "x"}, Drop: []string{"b"}})
+	assert.Equal(t, []string{"x", "c"}, sink.header)
+	assert.Equal(t, [][]string{{"1", "3"}}, sink.rows)
+}
+
+func TestColumnMappingRangesWithoutHeader(t *testing.T) {
+	config := GetDefaultConfig()
+	config.HeaderConfig.HasHeader = false
+	config.Transforms = []Transform{ColumnMapping{Ranges: "2-3,-1"}}
+	p := NewProcessor(strings.NewReader("a,b,c,d\ne,f\n"), &config)
+
+	sink := &memorySink{}
+	assert.Nil(t, p.Copy(sink))
+	assert.Empty(t, sink.header)
+	// like cut, the positions past the end of a row are left out
+	assert.Equal(t, [][]string{{"b", "c", "d"}, {"f", "f"}}, sink.rows)
+}
+
+func TestColumnMappingInvalidRanges(t *testing.T) {
+	for _, ranges := range []string{"0", "3-1", "a", "1,,2", "1-2-3", "-1--3"} {
+		_, _, err := ColumnMapping{Ranges: ranges}.Bind([]string{"a", "b", "c"})
+		assert.ErrorIs(t, err, ColumnRangeError, ranges)
+	}
+
+	_, _, err := ColumnMapping{Ranges: "2-4"}.Bind([]string{"a", "b", "c"})
+	assert.ErrorIs(t, err, ColumnNotFoundError)
+	_, _, err = ColumnMapping{Ranges: "-1-1"}.Bind([]string{"a", "b", "c"})
+	assert.ErrorIs(t, err, ColumnRangeError)
+	_, _, err = ColumnMapping{Ranges: "1", Columns: []string{"a"}}.Bind([]string{"a"})
+	assert.NotNil(t, err)
+}
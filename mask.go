@@ -0,0 +1,80 @@
+package parallel_csv
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"math/rand"
+	"unicode"
+)
+
+// valueTransform applies fn to each value of the columns. Empty values are left empty
+type valueTransform struct {
+	columns []string
+	fn      func(value string) string
+}
+
+func (t valueTransform) Bind(header []string) ([]string, RowFunc, error) {
+	indexes, err := headerIndexes(header, t.columns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return header, func(fields []string) ([]string, error) {
+		for _, index := range indexes {
+			if index < len(fields) && fields[index] != "" {
+				fields[index] = t.fn(fields[index])
+			}
+		}
+		return fields, nil
+	}, nil
+}
+
+// Mask replaces every character of the columns with '*' but the last keep ones, so that
+// "4111111111111111" becomes "************1111" when keeping 4
+func Mask(keep int, columns ...string) Transform {
+	return valueTransform{columns: columns, fn: func(value string) string {
+		runes := []rune(value)
+		for i := 0; i < len(runes)-keep; i++ {
+			runes[i] = '*'
+		}
+		return string(runes)
+	}}
+}
+
+// Hash replaces the values of the columns with their hex encoded HMAC-SHA256 keyed with the
+// salt. Equal values get equal hashes, so hashed columns can still be joined or grouped on
+func Hash(salt string, columns ...string) Transform {
+	return valueTransform{columns: columns, fn: func(value string) string {
+		return hex.EncodeToString(digest(salt, value))
+	}}
+}
+
+// Fake replaces digits with random digits and letters with random letters of the same case,
+// keeping every other character, so that the values keep their format: "AB-1234" may become
+// "QZ-8071". The substitution is derived from the salt and the value, equal values get
+// the same fake
+func Fake(salt string, columns ...string) Transform {
+	return valueTransform{columns: columns, fn: func(value string) string {
+		random := rand.New(rand.NewSource(int64(binary.BigEndian.Uint64(digest(salt, value)))))
+		runes := []rune(value)
+		for i, r := range runes {
+			switch {
+			case r >= '0' && r <= '9':
+				runes[i] = '0' + rune(random.Intn(10))
+			case unicode.IsUpper(r):
+				runes[i] = 'A' + rune(random.Intn(26))
+			case unicode.IsLower(r):
+				runes[i] = 'a' + rune(random.Intn(26))
+			}
+		}
+		return string(runes)
+	}}
+}
+
+func digest(salt string, value string) []byte {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
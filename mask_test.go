@@ -0,0 +1,78 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const accounts = "name,card,email\nanna,4111111111111111,anna@example.com\nbob,,bob@example.com\nanna,5500-0000-0000-0004,anna@example.com\n"
+
+func copyRows(t *testing.T, input string, transforms ...Transform) *memorySink {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 16
+	config.Transforms = transforms
+	p := NewProcessor(strings.NewReader(input), &config)
+
+	sink := &memorySink{}
+	assert.Nil(t, p.Copy(sink))
+	return sink
+}
+
+func TestMask(t *testing.T) {
+	sink := copyRows(t, accounts, Mask(4, "card"))
+	assert.Equal(t, []string{"************1111", "", "***************0004"}, column(sink.rows, 1))
+
+	sink = copyRows(t, "a\nàèì\n", Mask(5, "a"))
+	assert.Equal(t, []string{"àèì"}, column(sink.rows, 0))
+}
+
+func TestHash(t *testing.T) {
+	sink := copyRows(t, accounts, Hash("salt", "email"))
+	emails := column(sink.rows, 2)
+	assert.Regexp(t, "^[0-9a-f]{64}$", emails[0])
+	assert.Equal(t, emails[0], emails[2])
+	assert.NotEqual(t, emails[0], emails[1])
+
+	other := copyRows(t, accounts, Hash("pepper", "email"))
+	assert.NotEqual(t, emails[0], column(other.rows, 2)[0])
+}
+
+func TestFake(t *testing.T) {
+	sink := copyRows(t, accounts, Fake("salt", "name", "card"))
+	names, cards := column(sink.rows, 0), column(sink.rows, 1)
+	assert.Regexp(t, "^[a-z]{4}$", names[0])
+	assert.NotEqual(t, "anna", names[0])
+	assert.Equal(t, names[0], names[2])
+	assert.Regexp(t, "^[0-9]{16}$", cards[0])
+	assert.True(t, regexp.MustCompile(`^\d{4}-\d{4}-\d{4}-\d{4}$`).MatchString(cards[2]))
+	assert.Equal(t, "", cards[1])
+}
+
+func TestTransformUnknownColumn(t *testing.T) {
+	config := GetDefaultConfig()
+	config.Transforms = []Transform{Mask(2, "missing")}
+	p := NewProcessor(strings.NewReader(accounts), &config)
+
+	assert.ErrorIs(t, p.Copy(&memorySink{}), ColumnNotFoundError)
+}
+
+func TestTransformWithoutHeader(t *testing.T) {
+	config := GetDefaultConfig()
+	config.HeaderConfig.HasHeader = false
+	config.Transforms = []Transform{Mask(0, "col_2")}
+	p := NewProcessor(strings.NewReader("a,b\nc,d\n"), &config)
+
+	sink := &memorySink{}
+	assert.Nil(t, p.Copy(sink))
+	assert.Equal(t, [][]string{{"a", "*"}, {"c", "*"}}, sink.rows)
+}
+
+func column(rows [][]string, index int) []string {
+	values := make([]string, len(rows))
+	for i, row := range rows {
+		values[i] = row[index]
+	}
+	return values
+}
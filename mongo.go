@@ -0,0 +1,218 @@
+package parallel_csv
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// MongoField is a field of a MongoDocument
+type MongoField struct {
+	Key   string
+	Value interface{}
+}
+
+// MongoDocument is a document whose fields keep the order of the columns, it converts to a bson.D
+// field by field
+type MongoDocument []MongoField
+
+// MongoWriteError is the failure of a document of an insert
+type MongoWriteError struct {
+	// Index is the position of the document in the insert
+	Index   int
+	Code    int
+	Message string
+}
+
+// MongoCollection inserts documents in a collection. With the official driver, InsertMany calls
+// Collection.InsertMany with options.InsertMany().SetOrdered(ordered) and, on a
+// mongo.BulkWriteException, returns its WriteErrors as write errors and a nil error
+type MongoCollection interface {
+	// InsertMany inserts the documents and returns the failures of single documents. When ordered
+	// the insert stops at the first one, the following documents being left out. err is the
+	// failure of the whole insert
+	InsertMany(ctx context.Context, documents []MongoDocument, ordered bool) (writeErrors []MongoWriteError, err error)
+}
+
+// MongoBulkError reports the documents of an insert which failed
+type MongoBulkError struct {
+	Collection string
+	// Rows are the rows of the documents which failed, in the order of the insert
+	Rows [][]string
+	// Errors are the failures of the documents, in the same order as Rows
+	Errors []MongoWriteError
+	// Inserted is the number of documents inserted
+	Inserted int
+}
+
+func (e *MongoBulkError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = fmt.Sprintf("document %d: %s (code %d)", err.Index, err.Message, err.Code)
+	}
+	return fmt.Sprintf("insert into %s: %d documents failed: %s", e.Collection, len(e.Errors), strings.Join(messages, "; "))
+}
+
+// MongoSink inserts each row as a document keyed by column name, with an insert per chunk. Several
+// inserts run at once and not in source order. It is an AckSink: the documents failing are
+// reported by a *MongoBulkError, which goes through Config.ErrorPolicy like the errors of the
+// jobs. When not written through a processor, the failures are returned by the following Write or
+// by Close instead. Files without header have columns col_1, col_2 and so on
+type MongoSink struct {
+	Collection string
+	// Schema converts the fields to the types of its columns, which are then stored as such.
+	// InferredSchema.Schema gives the types found by InferSchema
+	Schema *Schema
+	// Ordered stops each insert at the first document failing, leaving out the following ones
+	Ordered bool
+	// Concurrency is the number of inserts running at once, 1 if 0
+	Concurrency int
+	ctx         context.Context
+	collection  MongoCollection
+	header      []string
+	types       typedRow
+	inserts     chan mongoInsert
+	wg          sync.WaitGroup
+	// mu guards err, the first failure of the inserts without ack
+	mu   sync.Mutex
+	err  error
+	rows int64
+}
+
+// mongoInsert is an insert waiting for a goroutine, ack is nil for plain writes
+type mongoInsert struct {
+	documents []MongoDocument
+	rows      [][]string
+	ack       func(err error)
+}
+
+// NewMongoSink creates a sink inserting the rows into collection, whose name is used in errors
+func NewMongoSink(ctx context.Context, collection MongoCollection, name string) *MongoSink {
+	return &MongoSink{Collection: name, ctx: ctx, collection: collection}
+}
+
+func (s *MongoSink) Open(header []string) error {
+	var err error
+	if s.types, err = bindTypes(header, s.Schema); err != nil {
+		return err
+	}
+	concurrency := s.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	s.header = header
+	s.inserts = make(chan mongoInsert)
+	s.wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer s.wg.Done()
+			for insert := range s.inserts {
+				s.insert(insert)
+			}
+		}()
+	}
+	return nil
+}
+
+func (s *MongoSink) Write(rows [][]string) error {
+	return s.WriteAck(rows, nil)
+}
+
+// WriteAck is Write calling ack once the rows have been inserted, with a *MongoBulkError if some
+// documents failed
+func (s *MongoSink) WriteAck(rows [][]string, ack func(err error)) error {
+	if err := s.failed(); err != nil {
+		return err
+	}
+
+	insert := mongoInsert{documents: make([]MongoDocument, len(rows)), rows: make([][]string, len(rows)), ack: ack}
+	for i, row := range rows {
+		values, err := s.types(row)
+		if err != nil {
+			return err
+		}
+		document := make(MongoDocument, len(values))
+		for j, value := range values {
+			document[j] = MongoField{Key: columnName(j), Value: value}
+			if j < len(s.header) {
+				document[j].Key = s.header[j]
+			}
+		}
+		insert.documents[i] = document
+		// the rows outlive the chunk buffer in the errors
+		insert.rows[i] = cloneFields(row)
+	}
+
+	if len(rows) == 0 {
+		if ack != nil {
+			ack(nil)
+		}
+		return nil
+	}
+	s.inserts <- insert
+	return nil
+}
+
+// insert runs an insert and reports its failures
+func (s *MongoSink) insert(insert mongoInsert) {
+	writeErrors, err := s.collection.InsertMany(s.ctx, insert.documents, s.Ordered)
+	if err != nil {
+		err = fmt.Errorf("insert into %s: %w", s.Collection, err)
+	} else {
+		inserted := len(insert.documents) - len(writeErrors)
+		if s.Ordered && len(writeErrors) > 0 {
+			// the documents after the failure were left out
+			inserted = writeErrors[0].Index
+		}
+		atomic.AddInt64(&s.rows, int64(inserted))
+		if len(writeErrors) > 0 {
+			bulkErr := &MongoBulkError{Collection: s.Collection, Errors: writeErrors, Inserted: inserted}
+			for _, writeErr := range writeErrors {
+				if writeErr.Index >= 0 && writeErr.Index < len(insert.rows) {
+					bulkErr.Rows = append(bulkErr.Rows, insert.rows[writeErr.Index])
+				} else {
+					bulkErr.Rows = append(bulkErr.Rows, nil)
+				}
+			}
+			err = bulkErr
+		}
+	}
+
+	if insert.ack != nil {
+		insert.ack(err)
+		return
+	}
+	if err != nil {
+		s.mu.Lock()
+		if s.err == nil {
+			s.err = err
+		}
+		s.mu.Unlock()
+	}
+}
+
+// failed returns the first failure of the inserts without ack
+func (s *MongoSink) failed() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close waits for every insert to end, the collection is left open
+func (s *MongoSink) Close() error {
+	if s.inserts == nil {
+		return nil
+	}
+	close(s.inserts)
+	s.wg.Wait()
+	s.inserts = nil
+	return s.failed()
+}
+
+// Rows returns the number of documents inserted so far
+func (s *MongoSink) Rows() int64 {
+	return atomic.LoadInt64(&s.rows)
+}
@@ -0,0 +1,93 @@
+package parallel_csv
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeMongo keeps the documents inserted, failing the ones whose first field is in duplicates
+type fakeMongo struct {
+	mu         sync.Mutex
+	documents  []MongoDocument
+	duplicates map[interface{}]bool
+	inserts    int
+}
+
+func (m *fakeMongo) InsertMany(ctx context.Context, documents []MongoDocument, ordered bool) ([]MongoWriteError, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inserts++
+	var writeErrors []MongoWriteError
+	for i, document := range documents {
+		if m.duplicates[document[0].Value] {
+			writeErrors = append(writeErrors, MongoWriteError{Index: i, Code: 11000, Message: "duplicate key"})
+			if ordered {
+				break
+			}
+			continue
+		}
+		m.documents = append(m.documents, document)
+	}
+	return writeErrors, nil
+}
+
+func TestMongoSink(t *testing.T) {
+	collection := &fakeMongo{}
+	sink := NewMongoSink(context.Background(), collection, "numbers")
+	sink.Schema = &Schema{Columns: []ColumnSchema{{Name: "n", Type: IntegerType}}}
+	sink.Concurrency = 4
+
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 64
+	assert.Nil(t, NewProcessor(strings.NewReader(numbers(95)), &config).Copy(sink))
+	assert.Equal(t, int64(95), sink.Rows())
+	assert.Len(t, collection.documents, 95)
+	assert.Greater(t, collection.inserts, 1)
+
+	input := "name,age\nMario,42\nLuigi,\n"
+	sink = NewMongoSink(context.Background(), collection, "people")
+	sink.Schema = &Schema{Columns: []ColumnSchema{{Name: "age", Type: IntegerType}}}
+	collection.documents = nil
+	assert.Nil(t, NewProcessor(strings.NewReader(input), nil).Copy(sink))
+	assert.Equal(t, MongoDocument{{"name", "Mario"}, {"age", int64(42)}}, collection.documents[0])
+	assert.Equal(t, MongoDocument{{"name", "Luigi"}, {"age", nil}}, collection.documents[1])
+}
+
+func TestMongoSinkErrors(t *testing.T) {
+	input := "id,name\n1,a\n2,b\n3,c\n4,d\n"
+	collection := &fakeMongo{duplicates: map[interface{}]bool{"2": true, "3": true}}
+
+	var skipped []error
+	config := GetDefaultConfig()
+	config.ErrorPolicy = SkipOnError
+	config.ErrorHandler = func(err error) { skipped = append(skipped, err) }
+	sink := NewMongoSink(context.Background(), collection, "people")
+	assert.Nil(t, NewProcessor(strings.NewReader(input), &config).Copy(sink))
+	assert.Equal(t, int64(2), sink.Rows())
+	assert.Len(t, skipped, 1)
+
+	var bulkErr *MongoBulkError
+	assert.True(t, errors.As(skipped[0], &bulkErr))
+	assert.Equal(t, [][]string{{"2", "b"}, {"3", "c"}}, bulkErr.Rows)
+	assert.Equal(t, 2, bulkErr.Inserted)
+	assert.Contains(t, bulkErr.Error(), "document 1: duplicate key (code 11000)")
+
+	// ordered inserts stop at the first failure, which aborts the run
+	collection.documents = nil
+	sink = NewMongoSink(context.Background(), collection, "people")
+	sink.Ordered = true
+	err := NewProcessor(strings.NewReader(input), nil).Copy(sink)
+	assert.True(t, errors.As(err, &bulkErr))
+	assert.Equal(t, 1, bulkErr.Inserted)
+	assert.Len(t, collection.documents, 1)
+
+	// without a processor the failure is returned by Close
+	sink = NewMongoSink(context.Background(), collection, "people")
+	assert.Nil(t, sink.Open([]string{"id"}))
+	assert.Nil(t, sink.Write([][]string{{"2"}}))
+	assert.True(t, errors.As(sink.Close(), &bulkErr))
+}
@@ -0,0 +1,194 @@
+package parallel_csv
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+)
+
+// MessagePackLayout is the shape of the values written by MessagePackSink
+type MessagePackLayout int
+
+const (
+	// MessagePackRows writes a map per row, keyed by column name
+	MessagePackRows MessagePackLayout = iota
+	// MessagePackColumns writes a map per batch of rows, holding an array of values per column
+	MessagePackColumns
+)
+
+// MessagePackSink writes the rows as a stream of MessagePack maps. Columns of files without header
+// are named col_1, col_2 and so on. Times are encoded with the timestamp extension type
+type MessagePackSink struct {
+	Layout MessagePackLayout
+	// Schema converts the fields to the types of its columns, which are then encoded as such.
+	// Without it every value is a string, or nil when empty
+	Schema *Schema
+	// BatchRows is the number of rows of the maps of MessagePackColumns, DefaultBatchRows if 0
+	BatchRows int
+	w         *bufio.Writer
+	header    []string
+	types     typedRow
+	batch     [][]interface{}
+	b         []byte
+}
+
+// NewMessagePackSink creates a sink writing the rows to w
+func NewMessagePackSink(w io.Writer, layout MessagePackLayout) *MessagePackSink {
+	return &MessagePackSink{Layout: layout, w: bufio.NewWriter(w)}
+}
+
+func (s *MessagePackSink) Open(header []string) error {
+	var err error
+	if s.types, err = bindTypes(header, s.Schema); err != nil {
+		return err
+	}
+	if s.BatchRows <= 0 {
+		s.BatchRows = DefaultBatchRows
+	}
+	s.header = header
+	return nil
+}
+
+func (s *MessagePackSink) Write(rows [][]string) error {
+	for _, row := range rows {
+		values, err := s.types(row)
+		if err != nil {
+			return err
+		}
+
+		if s.Layout == MessagePackColumns {
+			s.batch = append(s.batch, values)
+			if len(s.batch) == s.BatchRows {
+				if err := s.writeColumns(); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		s.b = appendMsgpackMapHeader(s.b[:0], len(values))
+		for i, value := range values {
+			s.b = appendMsgpackValue(appendMsgpackString(s.b, s.column(i)), value)
+		}
+		if _, err := s.w.Write(s.b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeColumns writes the pending rows as a map of arrays. Missing values of short rows are nil
+func (s *MessagePackSink) writeColumns() error {
+	columns := len(s.header)
+	for _, values := range s.batch {
+		if len(values) > columns {
+			columns = len(values)
+		}
+	}
+
+	s.b = appendMsgpackMapHeader(s.b[:0], columns)
+	for i := 0; i < columns; i++ {
+		s.b = appendMsgpackArrayHeader(appendMsgpackString(s.b, s.column(i)), len(s.batch))
+		for _, values := range s.batch {
+			var value interface{}
+			if i < len(values) {
+				value = values[i]
+			}
+			s.b = appendMsgpackValue(s.b, value)
+		}
+	}
+	s.batch = s.batch[:0]
+	_, err := s.w.Write(s.b)
+	return err
+}
+
+func (s *MessagePackSink) column(i int) string {
+	if i < len(s.header) {
+		return s.header[i]
+	}
+	return columnName(i)
+}
+
+// Close writes the last batch and flushes the buffered maps, the underlying writer is left open
+func (s *MessagePackSink) Close() error {
+	if len(s.batch) > 0 {
+		if err := s.writeColumns(); err != nil {
+			return err
+		}
+	}
+	return s.w.Flush()
+}
+
+// appendMsgpackValue encodes a value converted by bindTypes
+func appendMsgpackValue(b []byte, value interface{}) []byte {
+	switch v := value.(type) {
+	case nil:
+		return append(b, 0xc0)
+	case bool:
+		if v {
+			return append(b, 0xc3)
+		}
+		return append(b, 0xc2)
+	case int64:
+		if v >= -32 && v <= 127 {
+			return append(b, byte(v))
+		}
+		return appendUint(append(b, 0xd3), uint64(v), 8)
+	case float64:
+		return appendUint(append(b, 0xcb), math.Float64bits(v), 8)
+	case time.Time:
+		// timestamp 96: nanoseconds on 4 bytes then seconds on 8
+		b = append(b, 0xc7, 12, 0xff)
+		b = appendUint(b, uint64(v.Nanosecond()), 4)
+		return appendUint(b, uint64(v.Unix()), 8)
+	case string:
+		return appendMsgpackString(b, v)
+	default:
+		return appendMsgpackString(b, formatValue(v, time.RFC3339Nano))
+	}
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = appendUint(append(b, 0xda), uint64(n), 2)
+	default:
+		b = appendUint(append(b, 0xdb), uint64(n), 4)
+	}
+	return append(b, s...)
+}
+
+func appendMsgpackMapHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return appendUint(append(b, 0xde), uint64(n), 2)
+	default:
+		return appendUint(append(b, 0xdf), uint64(n), 4)
+	}
+}
+
+func appendMsgpackArrayHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return appendUint(append(b, 0xdc), uint64(n), 2)
+	default:
+		return appendUint(append(b, 0xdd), uint64(n), 4)
+	}
+}
+
+// appendUint appends the size lowest bytes of v, big endian
+func appendUint(b []byte, v uint64, size int) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[8-size:]...)
+}
@@ -0,0 +1,49 @@
+package parallel_csv
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMessagePackSinkRows(t *testing.T) {
+	out := &bytes.Buffer{}
+	sink := NewMessagePackSink(out, MessagePackRows)
+	sink.Schema = &Schema{Columns: []ColumnSchema{{Name: "age", Type: IntegerType}}}
+
+	input := "name,age\nMario,42\nLuigi,\n"
+	assert.Nil(t, NewProcessor(strings.NewReader(input), nil).Copy(sink))
+
+	expected := []byte{0x82, 0xa4, 'n', 'a', 'm', 'e', 0xa5, 'M', 'a', 'r', 'i', 'o', 0xa3, 'a', 'g', 'e', 42}
+	expected = append(expected, 0x82, 0xa4, 'n', 'a', 'm', 'e', 0xa5, 'L', 'u', 'i', 'g', 'i', 0xa3, 'a', 'g', 'e', 0xc0)
+	assert.Equal(t, expected, out.Bytes())
+}
+
+func TestMessagePackSinkColumns(t *testing.T) {
+	out := &bytes.Buffer{}
+	sink := NewMessagePackSink(out, MessagePackColumns)
+	sink.BatchRows = 2
+
+	config := GetDefaultConfig()
+	config.HeaderConfig.HasHeader = false
+	input := "Mario,42\nLuigi,\nPeach,7\n"
+	assert.Nil(t, NewProcessor(strings.NewReader(input), &config).Copy(sink))
+
+	expected := []byte{0x82, 0xa5, 'c', 'o', 'l', '_', '1', 0x92, 0xa5, 'M', 'a', 'r', 'i', 'o', 0xa5, 'L', 'u', 'i', 'g', 'i',
+		0xa5, 'c', 'o', 'l', '_', '2', 0x92, 0xa2, '4', '2', 0xc0}
+	expected = append(expected, 0x82, 0xa5, 'c', 'o', 'l', '_', '1', 0x91, 0xa5, 'P', 'e', 'a', 'c', 'h',
+		0xa5, 'c', 'o', 'l', '_', '2', 0x91, 0xa1, '7')
+	assert.Equal(t, expected, out.Bytes())
+}
+
+func TestAppendMsgpackValue(t *testing.T) {
+	assert.Equal(t, []byte{0xe0}, appendMsgpackValue(nil, int64(-32)))
+	assert.Equal(t, []byte{0xd3, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xdf}, appendMsgpackValue(nil, int64(-33)))
+	assert.Equal(t, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}, appendMsgpackValue(nil, 1.5))
+	assert.Equal(t, []byte{0xc3}, appendMsgpackValue(nil, true))
+	assert.Equal(t, []byte{0xc7, 12, 0xff, 0, 0, 0, 5, 0, 0, 0, 0, 0, 0, 0, 1},
+		appendMsgpackValue(nil, time.Unix(1, 5)))
+	assert.Equal(t, []byte{0xd9, 40}, appendMsgpackValue(nil, strings.Repeat("x", 40))[:2])
+}
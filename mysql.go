@@ -0,0 +1,184 @@
+package parallel_csv
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+)
+
+// mysqlTimeLayout is the format of the DATETIME values of MySQL
+const mysqlTimeLayout = "2006-01-02 15:04:05.999999"
+
+// readerID makes the names of the readers of LOAD DATA unique within the process
+var readerID int64
+
+// MySQLSink loads the rows into a MySQL table with LOAD DATA LOCAL INFILE, streaming each batch
+// through a reader registered with the driver. Several batches are loaded at once, each one by a
+// single statement, and not in source order. With go-sql-driver/mysql:
+//
+//	sink := NewMySQLSink(ctx, db, "people", mysql.RegisterReaderHandler, mysql.DeregisterReaderHandler)
+//
+// The server must allow local_infile
+type MySQLSink struct {
+	// Table is the name of the table, used verbatim in the statement
+	Table string
+	// ColumnMap renames the columns of the header to the ones of the table. Columns mapped to an
+	// empty name are not loaded, the others keep their name
+	ColumnMap map[string]string
+	// NullToken is a value loaded as NULL, such as "NULL" or "\N". Empty values are NULL already,
+	// except in the string columns of the schema
+	NullToken string
+	// Schema converts the fields to the types of its columns before they are loaded
+	Schema *Schema
+	// BatchRows is the number of rows loaded by a statement, DefaultBatchRows if 0
+	BatchRows int
+	// Connections is the number of statements run at once, 1 if 0
+	Connections int
+	ctx         context.Context
+	db          *sql.DB
+	register    func(name string, handler func() io.Reader)
+	deregister  func(name string)
+	statement   string
+	types       typedRow
+	loader      *parallelLoader
+	rows        int64
+}
+
+// NewMySQLSink creates a sink loading the rows into table. register and deregister hand the
+// readers of the batches over to the driver
+func NewMySQLSink(ctx context.Context, db *sql.DB, table string, register func(name string, handler func() io.Reader), deregister func(name string)) *MySQLSink {
+	return &MySQLSink{
+		Table:      table,
+		ctx:        ctx,
+		db:         db,
+		register:   register,
+		deregister: deregister,
+	}
+}
+
+// Open prepares the LOAD DATA statement. Fields are enclosed by double quotes and never escaped,
+// NULL is written unquoted
+func (s *MySQLSink) Open(header []string) error {
+	columns := make([]string, len(header))
+	for i, column := range header {
+		if mapped, ok := s.ColumnMap[column]; ok {
+			column = mapped
+		}
+		if column == "" {
+			// the field is read into a variable and dropped
+			columns[i] = "@skip"
+			continue
+		}
+		columns[i] = "`" + strings.ReplaceAll(column, "`", "``") + "`"
+	}
+
+	// the statement follows the name of the reader of each batch
+	s.statement = fmt.Sprintf("' INTO TABLE %s CHARACTER SET utf8mb4 "+
+		"FIELDS TERMINATED BY ',' OPTIONALLY ENCLOSED BY '\"' ESCAPED BY '' LINES TERMINATED BY '\\n'", s.Table)
+	if len(columns) > 0 {
+		s.statement += " (" + strings.Join(columns, ", ") + ")"
+	}
+
+	var err error
+	if s.types, err = bindTypes(header, s.Schema); err != nil {
+		return err
+	}
+	connections := s.Connections
+	if connections <= 0 {
+		connections = 1
+	}
+	s.loader = newParallelLoader(connections, s.BatchRows, s.load)
+	return nil
+}
+
+func (s *MySQLSink) Write(rows [][]string) error {
+	for _, row := range rows {
+		values, err := s.types(row)
+		if err != nil {
+			return err
+		}
+		if s.NullToken != "" {
+			for i, field := range row {
+				if field == s.NullToken {
+					values[i] = nil
+				}
+			}
+		}
+		if err := s.loader.add(values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close loads the last batch and waits for every statement to end, the database is left open
+func (s *MySQLSink) Close() error {
+	if s.loader == nil {
+		return nil
+	}
+	return s.loader.close()
+}
+
+// Rows returns the number of rows loaded so far
+func (s *MySQLSink) Rows() int64 {
+	return atomic.LoadInt64(&s.rows)
+}
+
+// load streams a batch to a LOAD DATA statement through a pipe
+func (s *MySQLSink) load(worker int, batch [][]interface{}) error {
+	name := fmt.Sprintf("pcsv-%d", atomic.AddInt64(&readerID, 1))
+	reader, writer := io.Pipe()
+	s.register(name, func() io.Reader { return reader })
+	defer s.deregister(name)
+
+	go func() {
+		writer.CloseWithError(writeMySQLRows(writer, batch))
+	}()
+
+	result, err := s.db.ExecContext(s.ctx, "LOAD DATA LOCAL INFILE 'Reader::"+name+s.statement)
+	// the writer is stuck if the statement ended before reading everything
+	reader.CloseWithError(io.ErrClosedPipe)
+	if err != nil {
+		return fmt.Errorf("load into %s: %w", s.Table, err)
+	}
+
+	if loaded, err := result.RowsAffected(); err == nil {
+		atomic.AddInt64(&s.rows, loaded)
+	}
+	return nil
+}
+
+// writeMySQLRows encodes a batch in the format expected by the LOAD DATA statement
+func writeMySQLRows(w io.Writer, batch [][]interface{}) error {
+	b := bufio.NewWriter(w)
+	for _, row := range batch {
+		for i, value := range row {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			switch v := value.(type) {
+			case nil:
+				b.WriteString("NULL")
+			case string:
+				b.WriteString(Quote + strings.ReplaceAll(v, Quote, Quote+Quote) + Quote)
+			case bool:
+				// booleans are TINYINT(1), which does not parse true and false
+				if v {
+					b.WriteByte('1')
+				} else {
+					b.WriteByte('0')
+				}
+			default:
+				b.WriteString(formatValue(v, mysqlTimeLayout))
+			}
+		}
+		if _, err := b.WriteString(LineBreak); err != nil {
+			return err
+		}
+	}
+	return b.Flush()
+}
@@ -0,0 +1,111 @@
+package parallel_csv
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeMySQL is a driver running LOAD DATA statements by reading the registered readers
+type fakeMySQL struct {
+	mu         sync.Mutex
+	readers    map[string]func() io.Reader
+	statements []string
+	loaded     []string
+}
+
+func (d *fakeMySQL) register(name string, handler func() io.Reader) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.readers[name] = handler
+}
+
+func (d *fakeMySQL) deregister(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.readers, name)
+}
+
+func (d *fakeMySQL) Open(name string) (driver.Conn, error) { return fakeMySQLConn{d}, nil }
+
+type fakeMySQLConn struct{ db *fakeMySQL }
+
+func (c fakeMySQLConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("unsupported")
+}
+func (c fakeMySQLConn) Close() error              { return nil }
+func (c fakeMySQLConn) Begin() (driver.Tx, error) { return nil, errors.New("unsupported") }
+
+func (c fakeMySQLConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	name := strings.SplitN(strings.SplitN(query, "'Reader::", 2)[1], "'", 2)[0]
+	c.db.mu.Lock()
+	handler, ok := c.db.readers[name]
+	c.db.mu.Unlock()
+	if !ok {
+		return nil, errors.New("reader not registered")
+	}
+
+	data, err := io.ReadAll(handler())
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), LineBreak), LineBreak)
+
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.statements = append(c.db.statements, query)
+	c.db.loaded = append(c.db.loaded, lines...)
+	return driver.RowsAffected(len(lines)), nil
+}
+
+var mysqlDrivers = 0
+
+// openFakeMySQL registers a new fake driver and opens it
+func openFakeMySQL() (*fakeMySQL, *sql.DB) {
+	fake := &fakeMySQL{readers: map[string]func() io.Reader{}}
+	mysqlDrivers++
+	name := "fakemysql" + strconv.Itoa(mysqlDrivers)
+	sql.Register(name, fake)
+	db, _ := sql.Open(name, "")
+	return fake, db
+}
+
+func TestMySQLSink(t *testing.T) {
+	fake, db := openFakeMySQL()
+	sink := NewMySQLSink(context.Background(), db, "numbers", fake.register, fake.deregister)
+	sink.BatchRows = 10
+	sink.Connections = 3
+
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 64
+	assert.Nil(t, NewProcessor(strings.NewReader(numbers(95)), &config).Copy(sink))
+	assert.Equal(t, int64(95), sink.Rows())
+	assert.Len(t, fake.loaded, 95)
+	assert.Len(t, fake.statements, 10)
+	assert.Empty(t, fake.readers)
+}
+
+func TestMySQLSinkMapping(t *testing.T) {
+	fake, db := openFakeMySQL()
+	sink := NewMySQLSink(context.Background(), db, "people", fake.register, fake.deregister)
+	sink.ColumnMap = map[string]string{"full name": "name", "note": ""}
+	sink.NullToken = `\N`
+	sink.Schema = &Schema{Columns: []ColumnSchema{
+		{Name: "active", Type: BooleanType},
+		{Name: "born", Type: TimeType, Layout: "2006-01-02"},
+	}}
+
+	input := "full name,note,active,born\n\"Mario \"\"Rossi\"\"\",x,true,1980-02-01\n\\N,,false,\n"
+	assert.Nil(t, NewProcessor(strings.NewReader(input), nil).Copy(sink))
+
+	assert.Equal(t, []string{`"Mario ""Rossi""","x",1,1980-02-01 00:00:00`, `NULL,NULL,0,NULL`}, fake.loaded)
+	assert.Contains(t, fake.statements[0], "INTO TABLE people")
+	assert.True(t, strings.HasSuffix(fake.statements[0], "(`name`, @skip, `active`, `born`)"))
+}
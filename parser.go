@@ -0,0 +1,87 @@
+package parallel_csv
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
+
+// RecordParser finds the records of a format in the blocks read from the input, and splits them in
+// fields. The reader cuts the input in blocks ending on a record boundary, which the workers then
+// split in records, so a parser is used by several goroutines at once. The header, when present,
+// is the first line of the input
+type RecordParser interface {
+	// RecordsEnd returns the length of the complete records at the start of block, the delimiter
+	// of the last one included, 0 if there is none. The rest of the block is read again with
+	// the following data
+	RecordsEnd(block []byte) int
+	// Count returns the number of records of a block returned by RecordsEnd, or of the last
+	// block of the input, which may not end with a delimiter
+	Count(block []byte) int
+	// Records splits such a block in its records, without delimiters
+	Records(block string) []string
+	// Fields splits a record in its fields
+	Fields(record string) ([]string, error)
+}
+
+// recordsPrefix returns the length of the first n records of block, the delimiter of the last
+// one included, or the length of block if it holds fewer
+func recordsPrefix(parser RecordParser, block []byte, n int64) int {
+	if n <= 0 || len(block) == 0 {
+		return 0
+	}
+	if int64(parser.Count(block)) <= n {
+		return len(block)
+	}
+	// the shortest prefix holding n records, the count growing with the prefix
+	i := sort.Search(len(block), func(i int) bool {
+		end := parser.RecordsEnd(block[:i+1])
+		return end > 0 && int64(parser.Count(block[:end])) >= n
+	})
+	return parser.RecordsEnd(block[:i+1])
+}
+
+// CSVParser parses lines of fields divided by Separator and quoted as described by RFC 4180.
+// Quoted fields cannot span several lines. It is the parser of a processor unless Config.Parser
+// is set
+type CSVParser struct {
+	Separator string
+}
+
+func (c CSVParser) RecordsEnd(block []byte) int {
+	return bytes.LastIndexByte(block, LineBreak[0]) + 1
+}
+
+func (c CSVParser) Count(block []byte) int {
+	return bytes.Count(bytes.TrimSuffix(block, []byte(LineBreak)), []byte(LineBreak)) + 1
+}
+
+func (c CSVParser) Records(block string) []string {
+	return strings.Split(strings.TrimSuffix(block, LineBreak), LineBreak)
+}
+
+// Fields splits a record, keeping misplaced quotes as part of the fields
+func (c CSVParser) Fields(record string) ([]string, error) {
+	fields, _, err := splitRecord(record, c.Separator, false)
+	return fields, err
+}
+
+// WhitespaceParser parses lines of fields divided by runs of spaces and tabs, like awk does:
+// leading and trailing blanks are ignored and fields cannot be quoted
+type WhitespaceParser struct{}
+
+func (WhitespaceParser) RecordsEnd(block []byte) int {
+	return CSVParser{}.RecordsEnd(block)
+}
+
+func (WhitespaceParser) Count(block []byte) int {
+	return CSVParser{}.Count(block)
+}
+
+func (WhitespaceParser) Records(block string) []string {
+	return CSVParser{}.Records(block)
+}
+
+func (WhitespaceParser) Fields(record string) ([]string, error) {
+	return strings.FieldsFunc(record, func(r rune) bool { return r == ' ' || r == '\t' }), nil
+}
@@ -0,0 +1,94 @@
+package parallel_csv
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// asciiParser parses records ending with the ASCII record separator, made of fields divided by
+// the unit separator
+type asciiParser struct{}
+
+func (asciiParser) RecordsEnd(block []byte) int {
+	return bytes.LastIndexByte(block, 0x1e) + 1
+}
+
+func (asciiParser) Count(block []byte) int {
+	return bytes.Count(bytes.TrimSuffix(block, []byte{0x1e}), []byte{0x1e}) + 1
+}
+
+func (asciiParser) Records(block string) []string {
+	return strings.Split(strings.TrimSuffix(block, "\x1e"), "\x1e")
+}
+
+func (asciiParser) Fields(record string) ([]string, error) {
+	return strings.Split(record, "\x1f"), nil
+}
+
+func TestCustomParser(t *testing.T) {
+	input := strings.Builder{}
+	for _, record := range []string{"a\x1f1", "b\x1fmulti\nline", "c\x1f3", "d\x1f4"} {
+		input.WriteString(record + "\x1e")
+	}
+
+	config := GetDefaultConfig()
+	config.HeaderConfig.HasHeader = false
+	config.BytesPerWorker = 8
+	config.Parser = asciiParser{}
+	p := NewProcessor(strings.NewReader(input.String()), &config)
+
+	sink := &memorySink{}
+	assert.Nil(t, p.Copy(sink))
+	assert.Equal(t, [][]string{{"a", "1"}, {"b", "multi\nline"}, {"c", "3"}, {"d", "4"}}, sink.rows)
+	assert.Equal(t, int64(4), p.Stats().RowsRead)
+}
+
+func TestCustomParserLines(t *testing.T) {
+	config := GetDefaultConfig()
+	config.HeaderConfig.HasHeader = false
+	config.BytesPerWorker = 16
+	config.Parser = asciiParser{}
+	p := NewProcessor(strings.NewReader("a\x1eb\x1ec\x1ed\x1ee\x1ef"), &config)
+
+	mu := sync.Mutex{}
+	lines := map[string]int{}
+	assert.Nil(t, p.RunChunks(func(chunk Chunk) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for i, row := range chunk.Rows {
+			lines[row] = chunk.Line(i)
+		}
+		return nil
+	}))
+	assert.Equal(t, map[string]int{"a": 1, "b": 2, "c": 3, "d": 4, "e": 5, "f": 6}, lines)
+}
+
+func TestCSVParser(t *testing.T) {
+	parser := CSVParser{Separator: ";"}
+	block := []byte("a;b\n\"c;d\";e\nf")
+	assert.Equal(t, 12, parser.RecordsEnd(block))
+	assert.Equal(t, 2, parser.Count(block[:12]))
+	assert.Equal(t, 3, parser.Count(block))
+	assert.Equal(t, []string{"a;b", `"c;d";e`}, parser.Records(string(block[:12])))
+
+	fields, err := parser.Fields(`"c;d";e`)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"c;d", "e"}, fields)
+}
+
+func TestWhitespaceParser(t *testing.T) {
+	input := "pid   user\tcommand\n  1 root   init \n42\tanna\t\tvim\n7 bob\n"
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 8
+	config.Parser = WhitespaceParser{}
+	config.Where = MustParseWhere("pid > 5")
+	p := NewProcessor(strings.NewReader(input), &config)
+
+	sink := &memorySink{}
+	assert.Nil(t, p.Copy(sink))
+	assert.Equal(t, []string{"pid", "user", "command"}, sink.header)
+	assert.Equal(t, [][]string{{"42", "anna", "vim"}, {"7", "bob"}}, sink.rows)
+}
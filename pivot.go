@@ -0,0 +1,249 @@
+package parallel_csv
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Unpivot turns each row into one row per value column, made of the id columns followed by the
+// name and the value of the value column, under the variable and value columns. Rows are written
+// to the sink in source order as the workers produce them, so memory does not grow with the file
+func (p processor) Unpivot(idColumns []string, valueColumns []string, sink Sink) error {
+	idIndexes, err := p.keyIndexes(idColumns)
+	if err != nil {
+		return err
+	}
+	valueIndexes, err := p.keyIndexes(valueColumns)
+	if err != nil {
+		return err
+	}
+
+	header := append(append([]string{}, idColumns...), "variable", "value")
+	return p.runSink(sink, header, func(chunk Chunk) ([][]string, error) {
+		rows := make([][]string, 0, len(chunk.Rows)*len(valueIndexes))
+		for _, row := range chunk.Rows {
+			if chunk.Cancelled() {
+				return nil, errCancelled
+			}
+			fields := p.split(row)
+			ids := keyFields(fields, idIndexes)
+			for i, index := range valueIndexes {
+				rows = append(rows, append(append(make([]string, 0, len(ids)+2), ids...), valueColumns[i], fieldAt(fields, index)))
+			}
+		}
+		return rows, nil
+	})
+}
+
+// pivotRow is an output row of Pivot being assembled
+type pivotRow struct {
+	ids  []string
+	line int
+	// values maps the pivoted columns to their value and the line it comes from
+	values map[string]pivotValue
+}
+
+type pivotValue struct {
+	value string
+	line  int
+}
+
+// pivotRows maps the id of each output row to the row
+type pivotRows map[string]*pivotRow
+
+// add sets the value of a pivoted column, the value of the latest line wins
+func (r pivotRows) add(id string, ids []string, column string, value pivotValue) {
+	row, ok := r[id]
+	if !ok {
+		row = &pivotRow{ids: ids, line: value.line, values: map[string]pivotValue{}}
+		r[id] = row
+	}
+	if value.line < row.line {
+		row.line = value.line
+	}
+	if existing, ok := row.values[column]; !ok || existing.line < value.line {
+		row.values[column] = value
+	}
+}
+
+func (r pivotRows) merge(other pivotRows) {
+	for id, row := range other {
+		for column, value := range row.values {
+			r.add(id, row.ids, column, value)
+		}
+	}
+}
+
+// sorted returns the rows in the order of their first line, with a field for each column
+func (r pivotRows) sorted(columns []string) [][]string {
+	rows := make([]*pivotRow, 0, len(r))
+	for _, row := range r {
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].line < rows[j].line
+	})
+
+	out := make([][]string, len(rows))
+	for i, row := range rows {
+		out[i] = append(make([]string, 0, len(row.ids)+len(columns)), row.ids...)
+		for _, column := range columns {
+			out[i] = append(out[i], row.values[column].value)
+		}
+	}
+	return out
+}
+
+// pivotColumns keeps the first line of each distinct value of the key column
+type pivotColumns map[string]int
+
+func (c pivotColumns) add(column string, line int) {
+	if first, ok := c[column]; !ok || line < first {
+		c[column] = line
+	}
+}
+
+// names returns the columns in the order they first appear
+func (c pivotColumns) names() []string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return c[names[i]] < c[names[j]]
+	})
+	return names
+}
+
+// Pivot turns rows sharing the same values in every column but the key and value columns into a
+// single row, with a column for each distinct value of the key column holding the value column.
+// When a key repeats, the value of the latest line wins. Pivoted columns and rows follow the order
+// of their first appearance. When Config.SpillDir is set rows are partitioned in temporary files and
+// pivoted one partition at a time, the order of the rows is then lost; only the distinct values of
+// the key column need to fit in memory
+func (p processor) Pivot(keyColumn string, valueColumn string, sink Sink) error {
+	keyIndex, valueIndex := p.ColumnIndex(keyColumn), p.ColumnIndex(valueColumn)
+	if keyIndex == -1 {
+		return fmt.Errorf("%w: %s", ColumnNotFoundError, keyColumn)
+	}
+	if valueIndex == -1 {
+		return fmt.Errorf("%w: %s", ColumnNotFoundError, valueColumn)
+	}
+
+	var idColumns []string
+	var idIndexes []int
+	for i, column := range p.header {
+		if i != keyIndex && i != valueIndex {
+			idColumns = append(idColumns, column)
+			idIndexes = append(idIndexes, i)
+		}
+	}
+
+	if p.config.SpillDir != "" {
+		return p.pivotOnDisk(idColumns, idIndexes, keyIndex, valueIndex, sink)
+	}
+	columns, rows, err := p.pivotInMemory(idIndexes, keyIndex, valueIndex)
+	if err != nil {
+		return err
+	}
+
+	if err := sink.Open(append(idColumns, columns.names()...)); err != nil {
+		return closeSink(sink, err)
+	}
+	err = sink.Write(rows)
+	return closeSink(sink, err)
+}
+
+func (p processor) pivotInMemory(idIndexes []int, keyIndex int, valueIndex int) (pivotColumns, [][]string, error) {
+	partials := make([]pivotRows, p.config.NumberOfWorkers)
+	columns := make([]pivotColumns, p.config.NumberOfWorkers)
+	for i := range partials {
+		partials[i], columns[i] = pivotRows{}, pivotColumns{}
+	}
+
+	err := p.RunChunks(func(chunk Chunk) error {
+		for i, row := range chunk.Rows {
+			if chunk.Cancelled() {
+				return errCancelled
+			}
+			fields := cloneFields(p.split(row))
+			line := chunk.Line(i)
+			column := fieldAt(fields, keyIndex)
+			columns[chunk.Worker].add(column, line)
+			partials[chunk.Worker].add(keyOf(fields, idIndexes), keyFields(fields, idIndexes), column, pivotValue{value: fieldAt(fields, valueIndex), line: line})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := 1; i < len(partials); i++ {
+		partials[0].merge(partials[i])
+		for column, line := range columns[i] {
+			columns[0].add(column, line)
+		}
+	}
+	return columns[0], partials[0].sorted(columns[0].names()), nil
+}
+
+// pivotOnDisk spills the rows partitioned by id: each record is the id, the line, the key, the
+// value and the id fields
+func (p processor) pivotOnDisk(idColumns []string, idIndexes []int, keyIndex int, valueIndex int, sink Sink) error {
+	spilled, err := newSpill(p.config.SpillDir)
+	if err != nil {
+		return err
+	}
+	defer spilled.close()
+
+	columns := pivotColumns{}
+	mu := sync.Mutex{}
+	err = p.RunChunks(func(chunk Chunk) error {
+		for i, row := range chunk.Rows {
+			if chunk.Cancelled() {
+				return errCancelled
+			}
+			fields := p.split(row)
+			line := chunk.Line(i)
+			column := fieldAt(fields, keyIndex)
+
+			// the column is copied only when kept, it may point into the chunk buffer
+			mu.Lock()
+			if _, ok := columns[column]; !ok || line < columns[column] {
+				columns.add(cloneString(column), line)
+			}
+			mu.Unlock()
+
+			record := append([]string{keyOf(fields, idIndexes), strconv.Itoa(line), column, fieldAt(fields, valueIndex)}, keyFields(fields, idIndexes)...)
+			if err := spilled.write(record...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	names := columns.names()
+	if err := sink.Open(append(idColumns, names...)); err != nil {
+		return closeSink(sink, err)
+	}
+	for i := 0; i < spillPartitions && err == nil; i++ {
+		partition := pivotRows{}
+		err = spilled.each(i, func(record []string) error {
+			line, err := strconv.Atoi(record[1])
+			if err != nil {
+				return err
+			}
+			partition.add(record[0], record[4:], record[2], pivotValue{value: record[3], line: line})
+			return nil
+		})
+		if err == nil && len(partition) > 0 {
+			err = sink.Write(partition.sorted(names))
+		}
+	}
+	return closeSink(sink, err)
+}
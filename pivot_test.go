@@ -0,0 +1,68 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const measures = "city,year,metric,value\nrome,2020,temp,20\nrome,2020,rain,5\nparis,2020,temp,15\nrome,2021,temp,21\nparis,2020,rain,9\nparis,2020,wind,3\nrome,2020,temp,22\n"
+
+func TestUnpivot(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 16
+	p := NewProcessor(strings.NewReader(customers), &config)
+
+	sink := &memorySink{}
+	assert.Nil(t, p.Unpivot([]string{"id"}, []string{"name", "country"}, sink))
+	assert.Equal(t, []string{"id", "variable", "value"}, sink.header)
+	assert.Equal(t, [][]string{
+		{"c1", "name", "anna"}, {"c1", "country", "IT"},
+		{"c2", "name", "bob, jr"}, {"c2", "country", "FR"},
+		{"c3", "name", "carla"}, {"c3", "country", "DE"},
+	}, sink.rows)
+	assert.True(t, sink.closed)
+}
+
+func TestPivot(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 16
+	p := NewProcessor(strings.NewReader(measures), &config)
+
+	sink := &memorySink{}
+	assert.Nil(t, p.Pivot("metric", "value", sink))
+	assert.Equal(t, []string{"city", "year", "temp", "rain", "wind"}, sink.header)
+	assert.Equal(t, [][]string{
+		{"rome", "2020", "22", "5", ""},
+		{"paris", "2020", "15", "9", "3"},
+		{"rome", "2021", "21", "", ""},
+	}, sink.rows)
+	assert.True(t, sink.closed)
+}
+
+func TestPivotOnDisk(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 16
+	config.SpillDir = t.TempDir()
+	p := NewProcessor(strings.NewReader(measures), &config)
+
+	sink := &memorySink{}
+	assert.Nil(t, p.Pivot("metric", "value", sink))
+	assert.Equal(t, []string{"city", "year", "temp", "rain", "wind"}, sink.header)
+
+	sort.Slice(sink.rows, func(i, j int) bool {
+		return strings.Join(sink.rows[i], ",") < strings.Join(sink.rows[j], ",")
+	})
+	assert.Equal(t, [][]string{
+		{"paris", "2020", "15", "9", "3"},
+		{"rome", "2020", "22", "5", ""},
+		{"rome", "2021", "21", "", ""},
+	}, sink.rows)
+}
+
+func TestPivotUnknownColumn(t *testing.T) {
+	p := NewProcessor(strings.NewReader(measures), nil)
+	assert.ErrorIs(t, p.Pivot("sensor", "value", &memorySink{}), ColumnNotFoundError)
+	assert.ErrorIs(t, p.Unpivot([]string{"city"}, []string{"humidity"}, &memorySink{}), ColumnNotFoundError)
+}
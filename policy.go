@@ -0,0 +1,134 @@
+package parallel_csv
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const FieldCountError = Error("wrong number of fields")
+
+// ErrorPolicy decides what happens when a row is invalid or a job returns an error
+type ErrorPolicy int
+
+const (
+	// AbortOnError stops the run at the first error, which is then returned by Run
+	AbortOnError ErrorPolicy = iota
+	// SkipOnError drops the offending rows and keeps going, errors are passed to Config.ErrorHandler
+	SkipOnError
+)
+
+// ParseError reports the source line of an invalid row and, when known, the 1-based position
+// of the offending character
+type ParseError struct {
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	if e.Column > 0 {
+		return fmt.Sprintf("line %d, column %d: %v", e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// PanicError is a panic recovered from a job, along with the position of the chunk it was processing
+type PanicError struct {
+	Value  interface{}
+	Stack  []byte
+	Line   int
+	Offset int64
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("job panicked on chunk starting at line %d (offset %d): %v", e.Line, e.Offset, e.Value)
+}
+
+// Unwrap returns the panic value when it is an error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// errStopRun is returned by internal jobs to end a run early, once they have all the rows they need
+var errStopRun = errors.New("run stopped")
+
+// errCancelled is returned by the built-in jobs leaving a chunk halfway because the run has
+// been aborted. The chunk is not completed
+var errCancelled = errors.New("chunk cancelled")
+
+// runState is shared by the reader and the workers for the duration of a run
+type runState struct {
+	config *Config
+	abort  chan struct{}
+	once   sync.Once
+	mu     sync.Mutex
+	err    error
+	// fieldCount is the number of fields of the first row, set by the reader in strict mode
+	fieldCount int
+	regexps    []boundRegex
+	where      *boundWhere
+	progress   *progress
+	limiter    *rateLimiter
+	// stopped is set when the run has been ended early by errStopRun
+	stopped bool
+	// exhausted is set by the reader when it stops at Config.MaxRows or Config.MaxBytes
+	exhausted bool
+	// redispatched holds the chunks to run again, nil unless Config.Redispatch is set
+	redispatched chan workerData
+}
+
+func newRunState(config *Config) *runState {
+	state := &runState{
+		config: config,
+		abort:  make(chan struct{}),
+	}
+	if config.MaxRowsPerSecond > 0 {
+		state.limiter = newRateLimiter(config.MaxRowsPerSecond)
+	}
+	if config.Idempotent && config.Redispatch > 0 {
+		state.redispatched = make(chan workerData, config.NumberOfWorkers)
+	}
+	return state
+}
+
+// fail applies the error policy to err
+func (s *runState) fail(err error) {
+	if s.config.ErrorPolicy == SkipOnError {
+		s.config.log(s.config.LogLevels.Skip, LogWarn, "error skipped", "error", err)
+		if s.config.ErrorHandler != nil {
+			s.mu.Lock()
+			s.config.ErrorHandler(err)
+			s.mu.Unlock()
+		}
+		return
+	}
+
+	s.once.Do(func() {
+		s.err = err
+		close(s.abort)
+	})
+}
+
+// stop ends the run without an error
+func (s *runState) stop() {
+	s.once.Do(func() {
+		s.stopped = true
+		close(s.abort)
+	})
+}
+
+// aborted tells whether the run has been stopped by an error
+func (s *runState) aborted() bool {
+	select {
+	case <-s.abort:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,190 @@
+package parallel_csv
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const malformed = "a,b\n1,2\n3\n4,5\n6,7,8\n"
+
+func malformedConfig(policy ErrorPolicy, handler func(err error)) *Config {
+	return &Config{
+		NumberOfWorkers: 1,
+		HeaderConfig: HeaderConfig{
+			HasHeader: true,
+			Separator: ",",
+		},
+		BytesPerWorker:     KB,
+		ValidateFieldCount: true,
+		ErrorPolicy:        policy,
+		ErrorHandler:       handler,
+	}
+}
+
+func TestFieldCountAbort(t *testing.T) {
+	p := NewProcessor(strings.NewReader(malformed), malformedConfig(AbortOnError, nil))
+
+	called := false
+	err := p.RunChunks(func(chunk Chunk) error {
+		called = true
+		return nil
+	})
+
+	var parseErr *ParseError
+	assert.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, 3, parseErr.Line)
+	assert.ErrorIs(t, err, FieldCountError)
+	assert.False(t, called)
+}
+
+func TestFieldCountSkip(t *testing.T) {
+	var lines []int
+	handler := func(err error) {
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			lines = append(lines, parseErr.Line)
+		}
+	}
+	p := NewProcessor(strings.NewReader(malformed), malformedConfig(SkipOnError, handler))
+
+	var rows []string
+	var rowLines []int
+	err := p.RunChunks(func(chunk Chunk) error {
+		for i, row := range chunk.Rows {
+			rows = append(rows, row)
+			rowLines = append(rowLines, chunk.Line(i))
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{3, 5}, lines)
+	assert.Equal(t, []string{"1,2", "4,5"}, rows)
+	assert.Equal(t, []int{2, 4}, rowLines)
+}
+
+func TestJobErrorAbort(t *testing.T) {
+	file := openFile("testdata/mid.csv")
+	config := GetDefaultConfig()
+	config.BytesPerWorker = KB
+	p := NewProcessor(file, &config)
+
+	jobErr := errors.New("job failed")
+	err := p.RunChunks(func(chunk Chunk) error {
+		return jobErr
+	})
+	assert.ErrorIs(t, err, jobErr)
+}
+
+func TestJobPanic(t *testing.T) {
+	p := NewProcessor(strings.NewReader("a,b\n1,2\n"), nil)
+
+	err := p.Run(func(header []string, rows []string) {
+		panic("boom")
+	})
+
+	var panicErr *PanicError
+	assert.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, "boom", panicErr.Value)
+	assert.Equal(t, 2, panicErr.Line)
+	assert.Equal(t, int64(4), panicErr.Offset)
+	assert.Contains(t, string(panicErr.Stack), "runJob")
+}
+
+func TestJobPanicSkip(t *testing.T) {
+	file := openFile("testdata/mid.csv")
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 64 * KB
+	config.ErrorPolicy = SkipOnError
+
+	panics := 0
+	config.ErrorHandler = func(err error) {
+		var panicErr *PanicError
+		if errors.As(err, &panicErr) {
+			panics++
+		}
+	}
+	p := NewProcessor(file, &config)
+
+	err := p.RunChunks(func(chunk Chunk) error {
+		if chunk.Offset == 36 {
+			panic(errors.New("first chunk"))
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, panics)
+}
+
+// cancelledInput is made of two chunks of 64KB: the first starts with a malformed row, the
+// second holds the rows of b
+func cancelledInput() (string, *Config) {
+	builder := strings.Builder{}
+	builder.WriteString("k,v\nmalformed\n")
+	for builder.Len() < 64*KB {
+		builder.WriteString("a,1\n")
+	}
+	for i := 0; builder.Len() < 120*KB; i++ {
+		builder.WriteString(fmt.Sprintf("b,%d\n", i))
+	}
+
+	config := GetDefaultConfig()
+	config.NumberOfWorkers = 2
+	config.BytesPerWorker = 64 * KB
+	config.ValidateFieldCount = true
+	return builder.String(), &config
+}
+
+// waitingTransform counts the rows of b, waiting on the first one for the other chunk to fail
+type waitingTransform struct {
+	once *sync.Once
+	rows *int64
+}
+
+func (w waitingTransform) Bind(header []string) ([]string, RowFunc, error) {
+	return header, func(fields []string) ([]string, error) {
+		if fields[0] == "b" {
+			w.once.Do(func() { time.Sleep(100 * time.Millisecond) })
+			atomic.AddInt64(w.rows, 1)
+		}
+		return fields, nil
+	}, nil
+}
+
+func TestAbortCancelsCopy(t *testing.T) {
+	input, config := cancelledInput()
+	var rows int64
+	config.Transforms = []Transform{waitingTransform{once: &sync.Once{}, rows: &rows}}
+
+	err := NewProcessor(strings.NewReader(input), config).Copy(&memorySink{})
+	assert.ErrorIs(t, err, FieldCountError)
+	// the rest of the chunk is left once the run is aborted
+	assert.Less(t, atomic.LoadInt64(&rows), int64(100))
+}
+
+func TestAbortCancelsFilter(t *testing.T) {
+	input, config := cancelledInput()
+	var rows int64
+	once := sync.Once{}
+	config.Filter = func(row []byte) bool {
+		if row[0] == 'b' {
+			once.Do(func() { time.Sleep(100 * time.Millisecond) })
+			atomic.AddInt64(&rows, 1)
+		}
+		return true
+	}
+
+	called := false
+	err := NewProcessor(strings.NewReader(input), config).RunChunks(func(chunk Chunk) error {
+		called = true
+		return nil
+	})
+	assert.ErrorIs(t, err, FieldCountError)
+	assert.Less(t, atomic.LoadInt64(&rows), int64(100))
+	assert.False(t, called)
+}
@@ -0,0 +1,160 @@
+package parallel_csv
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// PostgresConn is a Postgres connection able to run COPY FROM STDIN. A pgx connection is adapted
+// in a few lines:
+//
+//	type pgxConn struct{ *pgx.Conn }
+//
+//	func (c pgxConn) Exec(ctx context.Context, sql string) error {
+//		_, err := c.Conn.Exec(ctx, sql)
+//		return err
+//	}
+//
+//	func (c pgxConn) CopyFrom(ctx context.Context, sql string, r io.Reader) (int64, error) {
+//		tag, err := c.Conn.PgConn().CopyFrom(ctx, r, sql)
+//		return tag.RowsAffected(), err
+//	}
+type PostgresConn interface {
+	Exec(ctx context.Context, sql string) error
+	// CopyFrom runs the COPY statement with r as its standard input, returning the rows copied
+	CopyFrom(ctx context.Context, sql string, r io.Reader) (int64, error)
+}
+
+// PostgresSink loads the rows into a Postgres table with COPY, using its connections in parallel.
+// Rows are copied in batches, each one committed in its own transaction: a failing batch is
+// rolled back and fails the sink, the batches committed before it stay. Batches are not loaded
+// in source order
+type PostgresSink struct {
+	// Table is the name of the table, used verbatim in the statement
+	Table string
+	// Columns are the table columns receiving the fields of the rows, the header if empty
+	Columns []string
+	// Schema converts the fields to the types of its columns before they are copied, see Open
+	Schema *Schema
+	// BatchRows is the number of rows copied in a transaction, DefaultBatchRows if 0
+	BatchRows int
+	ctx       context.Context
+	conns     []PostgresConn
+	statement string
+	types     typedRow
+	loader    *parallelLoader
+	rows      int64
+}
+
+// NewPostgresSink creates a sink loading the rows into table with conns, which are used at the
+// same time and must not be shared with anything else during the load
+func NewPostgresSink(ctx context.Context, table string, conns ...PostgresConn) *PostgresSink {
+	return &PostgresSink{Table: table, ctx: ctx, conns: conns}
+}
+
+// Open prepares the COPY statement. Empty values are copied as NULL, except in the string columns
+// of the schema, and converted values are formatted the way Postgres parses them
+func (s *PostgresSink) Open(header []string) error {
+	if len(s.conns) == 0 {
+		return fmt.Errorf("postgres sink for %s has no connection", s.Table)
+	}
+
+	columns := s.Columns
+	if len(columns) == 0 {
+		columns = header
+	}
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quoteIdentifier(column)
+	}
+	s.statement = fmt.Sprintf("COPY %s (%s) FROM STDIN WITH (FORMAT csv)", s.Table, strings.Join(quoted, ", "))
+	if len(columns) == 0 {
+		s.statement = fmt.Sprintf("COPY %s FROM STDIN WITH (FORMAT csv)", s.Table)
+	}
+
+	var err error
+	if s.types, err = bindTypes(header, s.Schema); err != nil {
+		return err
+	}
+	s.loader = newParallelLoader(len(s.conns), s.BatchRows, s.copy)
+	return nil
+}
+
+func (s *PostgresSink) Write(rows [][]string) error {
+	for _, row := range rows {
+		values, err := s.types(row)
+		if err != nil {
+			return err
+		}
+		if err := s.loader.add(values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close copies the last batch and waits for every transaction to end, the connections are left
+// open
+func (s *PostgresSink) Close() error {
+	if s.loader == nil {
+		return nil
+	}
+	return s.loader.close()
+}
+
+// Rows returns the number of rows committed so far
+func (s *PostgresSink) Rows() int64 {
+	return atomic.LoadInt64(&s.rows)
+}
+
+// copy loads a batch in a transaction of the connection of the worker
+func (s *PostgresSink) copy(worker int, batch [][]interface{}) error {
+	conn := s.conns[worker]
+	if err := conn.Exec(s.ctx, "BEGIN"); err != nil {
+		return err
+	}
+
+	copied, err := conn.CopyFrom(s.ctx, s.statement, bytes.NewReader(postgresCSV(batch)))
+	if err == nil {
+		err = conn.Exec(s.ctx, "COMMIT")
+	}
+	if err != nil {
+		conn.Exec(s.ctx, "ROLLBACK")
+		return fmt.Errorf("copy into %s: %w", s.Table, err)
+	}
+
+	atomic.AddInt64(&s.rows, copied)
+	return nil
+}
+
+// postgresCSV encodes a batch in the CSV format of COPY, where an unquoted empty value is NULL
+// and a quoted one an empty string
+func postgresCSV(batch [][]interface{}) []byte {
+	b := bytes.Buffer{}
+	for _, row := range batch {
+		for i, value := range row {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			switch v := value.(type) {
+			case nil:
+			case string:
+				b.WriteString(Quote + strings.ReplaceAll(v, Quote, Quote+Quote) + Quote)
+			default:
+				b.WriteString(formatValue(v, time.RFC3339Nano))
+			}
+		}
+		b.WriteString(LineBreak)
+	}
+	return b.Bytes()
+}
+
+// quoteIdentifier quotes a column name for Postgres
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
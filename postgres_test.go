@@ -0,0 +1,98 @@
+package parallel_csv
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakePostgres records the statements run by its connections and the data copied
+type fakePostgres struct {
+	mu         sync.Mutex
+	statements []string
+	copied     []string
+	fail       string
+}
+
+type fakePostgresConn struct {
+	db *fakePostgres
+	// pending holds the data copied by the current transaction
+	pending []string
+}
+
+func (c *fakePostgresConn) Exec(ctx context.Context, sql string) error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.statements = append(c.db.statements, sql)
+	if sql == "COMMIT" {
+		c.db.copied = append(c.db.copied, c.pending...)
+	}
+	c.pending = nil
+	return nil
+}
+
+func (c *fakePostgresConn) CopyFrom(ctx context.Context, sql string, r io.Reader) (int64, error) {
+	data, _ := io.ReadAll(r)
+	lines := strings.Split(strings.TrimSuffix(string(data), LineBreak), LineBreak)
+	for _, line := range lines {
+		if c.db.fail != "" && strings.Contains(line, c.db.fail) {
+			return 0, errors.New("invalid input syntax")
+		}
+	}
+	c.pending = append(c.pending, lines...)
+	c.db.mu.Lock()
+	c.db.statements = append(c.db.statements, sql)
+	c.db.mu.Unlock()
+	return int64(len(lines)), nil
+}
+
+func TestPostgresSink(t *testing.T) {
+	db := &fakePostgres{}
+	sink := NewPostgresSink(context.Background(), "public.numbers",
+		&fakePostgresConn{db: db}, &fakePostgresConn{db: db}, &fakePostgresConn{db: db})
+	sink.BatchRows = 10
+
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 64
+	p := NewProcessor(strings.NewReader(numbers(95)), &config)
+	assert.Nil(t, p.Copy(sink))
+
+	assert.Equal(t, int64(95), sink.Rows())
+	assert.Len(t, db.copied, 95)
+	assert.Contains(t, db.statements, `COPY public.numbers ("n") FROM STDIN WITH (FORMAT csv)`)
+}
+
+func TestPostgresSinkSchema(t *testing.T) {
+	db := &fakePostgres{}
+	sink := NewPostgresSink(context.Background(), "people", &fakePostgresConn{db: db})
+	sink.Schema = &Schema{Columns: []ColumnSchema{
+		{Name: "name", Type: StringType},
+		{Name: "age", Type: IntegerType},
+		{Name: "born", Type: TimeType, Layout: "2006-01-02"},
+	}}
+
+	input := "name,age,born\n\"Rossi, \"\"Mario\"\"\", 42 ,1980-02-01\n,,\n"
+	assert.Nil(t, NewProcessor(strings.NewReader(input), nil).Copy(sink))
+	assert.Equal(t, []string{`"Rossi, ""Mario""",42,1980-02-01T00:00:00Z`, `"",,`}, db.copied)
+
+	sink = NewPostgresSink(context.Background(), "people", &fakePostgresConn{db: db})
+	sink.Schema = &Schema{Columns: []ColumnSchema{{Name: "age", Type: IntegerType}}}
+	err := NewProcessor(strings.NewReader("age\nold\n"), nil).Copy(sink)
+	assert.ErrorIs(t, err, TypeMismatchError)
+}
+
+func TestPostgresSinkRollback(t *testing.T) {
+	db := &fakePostgres{fail: "7"}
+	sink := NewPostgresSink(context.Background(), "numbers", &fakePostgresConn{db: db})
+	sink.BatchRows = 5
+
+	err := NewProcessor(strings.NewReader(numbers(10)), nil).Copy(sink)
+	assert.NotNil(t, err)
+	assert.Equal(t, int64(5), sink.Rows())
+	assert.Equal(t, []string{`"1"`, `"2"`, `"3"`, `"4"`, `"5"`}, db.copied)
+	assert.Contains(t, db.statements, "ROLLBACK")
+}
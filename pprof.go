@@ -0,0 +1,50 @@
+package parallel_csv
+
+import (
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// startProfiles starts the CPU profile of Config.CPUProfile, if any. The returned function stops
+// it and writes the heap profile of Config.HeapProfile, once the run is over
+func (p processor) startProfiles() (stop func() error, err error) {
+	var cpu *os.File
+	if p.config.CPUProfile != "" {
+		if cpu, err = os.Create(p.config.CPUProfile); err != nil {
+			return nil, err
+		}
+		if err = pprof.StartCPUProfile(cpu); err != nil {
+			cpu.Close()
+			return nil, err
+		}
+	}
+
+	return func() error {
+		var err error
+		if cpu != nil {
+			pprof.StopCPUProfile()
+			err = cpu.Close()
+		}
+		if p.config.HeapProfile != "" {
+			if heapErr := writeHeapProfile(p.config.HeapProfile); err == nil {
+				err = heapErr
+			}
+		}
+		return err
+	}, nil
+}
+
+// writeHeapProfile writes the memory in use after a garbage collection
+func writeHeapProfile(path string) error {
+	heap, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	runtime.GC()
+	err = pprof.WriteHeapProfile(heap)
+	if closeErr := heap.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,28 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	dir := t.TempDir()
+	config := GetDefaultConfig()
+	config.CPUProfile = filepath.Join(dir, "cpu.pprof")
+	config.HeapProfile = filepath.Join(dir, "heap.pprof")
+	p := NewProcessor(strings.NewReader(numbers(1000)), &config)
+
+	assert.Nil(t, p.Run(func(header []string, rows []string) {}))
+	for _, path := range []string{config.CPUProfile, config.HeapProfile} {
+		info, err := os.Stat(path)
+		assert.Nil(t, err)
+		assert.Greater(t, info.Size(), int64(0))
+	}
+
+	config.CPUProfile = filepath.Join(dir, "missing", "cpu.pprof")
+	p = NewProcessor(strings.NewReader(numbers(10)), &config)
+	assert.NotNil(t, p.Run(func(header []string, rows []string) {}))
+}
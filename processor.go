@@ -2,10 +2,15 @@ package parallel_csv
 
 import (
 	"bufio"
-	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
-	"strings"
+	"os"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Error string
@@ -29,10 +34,88 @@ const (
 //Job is an alias for the function called by users
 type Job func(header []string, rows []string)
 
+// ChunkJob is like Job but receives the whole chunk, including its position in the source.
+// A non-nil error is handled according to Config.ErrorPolicy. Rows can be kept after the job
+// returns, unless Config.ReuseBuffers is set
+type ChunkJob func(chunk Chunk) error
+
+// Chunk is a block of consecutive rows handed to a worker
+type Chunk struct {
+	Header []string
+	Rows   []string
+	// StartLine is the 1-based line number of Rows[0] in the source, header included
+	StartLine int
+	// Offset is the position in bytes of Rows[0] in the source, header included
+	Offset int64
+	// Index is the position of the chunk in the source, starting from 0
+	Index int
+	// Worker is the index of the worker processing the chunk, between 0 and NumberOfWorkers-1
+	Worker int
+	// lines holds the line number of each row once some rows have been dropped
+	lines  []int
+	buffer *sharedBuffer
+	// done is closed once the run is aborted
+	done <-chan struct{}
+	// redispatch tells that a failing job hands the chunk to the workers again
+	redispatch bool
+	// size is the number of bytes of the chunk in the source and rowsRead the number of rows
+	// they hold, before filtering
+	size     int64
+	rowsRead int
+	ack      *chunkAck
+}
+
+// Cancelled tells whether the run has been aborted, by an error or because it has ended early.
+// The rows of the chunk are then discarded: jobs going through large chunks can check it between
+// rows and return early
+func (c Chunk) Cancelled() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Line returns the source line number of Rows[i]
+func (c Chunk) Line(i int) int {
+	if c.lines != nil {
+		return c.lines[i]
+	}
+	return c.StartLine + i
+}
+
+// filter returns the chunk made of the rows for which keep is true. It stops halfway once the
+// chunk is cancelled
+func (c Chunk) filter(keep func(row string) bool) Chunk {
+	kept := c
+	kept.Rows = c.Rows[:0:0]
+	kept.lines = []int{}
+	for i, row := range c.Rows {
+		if c.Cancelled() {
+			return kept
+		}
+		if keep(row) {
+			kept.Rows = append(kept.Rows, row)
+			kept.lines = append(kept.lines, c.Line(i))
+		}
+	}
+
+	// every row is kept, the line numbers are unchanged
+	if len(kept.Rows) == len(c.Rows) {
+		return c
+	}
+	return kept
+}
+
 // HeaderConfig describe header configuration
 type HeaderConfig struct {
 	HasHeader bool
 	Separator string
+	// Generate names the columns of files without header col_1, col_2 and so on, as many as the
+	// fields of the first row, so that they can be used by name like a header. The first row
+	// must fit in the read buffer
+	Generate bool
 }
 
 //Config is the configuration needed to run the processor
@@ -40,28 +123,176 @@ type Config struct {
 	NumberOfWorkers int
 	HeaderConfig    HeaderConfig
 	BytesPerWorker  int
+	// AutoHeader ignores HeaderConfig.HasHeader, guessing instead whether the input has a header
+	// with DetectHeader on the beginning of the input. GetConfig reports the guess
+	AutoHeader bool
+	// HeaderAliases renames the columns of the header, from the names found in the input to the
+	// canonical ones. Every consumer of the header sees the canonical names, and ColumnIndex
+	// accepts both
+	HeaderAliases map[string]string
+	// ValidateFieldCount checks that every row has as many fields as the header
+	ValidateFieldCount bool
+	ErrorPolicy        ErrorPolicy
+	// ErrorHandler receives the errors skipped by SkipOnError, one call at a time
+	ErrorHandler func(err error)
+	// ReuseBuffers recycles the block buffers instead of allocating one per chunk. Rows then point
+	// into the recycled buffer and are only valid until the job returns: a job keeping them longer
+	// must copy them or call Chunk.Retain
+	ReuseBuffers bool
+	// Strict rejects the rows which are not RFC 4180 compliant: bare quotes in non-quoted fields,
+	// unterminated quoted fields and a number of fields differing from the header, or from the
+	// first row of files without header. Quoted fields cannot span multiple lines
+	Strict bool
+	// CheckpointPath is the file where the progress of a run is saved every CheckpointInterval
+	// and at the end of the run, so that it can be resumed with ResumeFrom
+	CheckpointPath     string
+	CheckpointInterval time.Duration
+	// StartOffset is the position in bytes, header included, where processing begins. It must be
+	// at the beginning of a row, such as the offset of a Checkpoint. The header is still read from
+	// the beginning of the input, and lines are counted as if the row at StartOffset were the
+	// first one
+	StartOffset int64
+	// SkipDataRows ignores the first rows of the run, header excluded, such as those ingested by
+	// a previous partial load. They are counted from where the run starts, StartOffset or a
+	// checkpoint, are never handed to the workers nor checked, and are counted by
+	// Stats.RowsIgnored
+	SkipDataRows int
+	// MaxRows and MaxBytes stop reading the input once that many rows or bytes, header excluded,
+	// have been handed to the workers, 0 meaning no limit. The rows are counted before being
+	// filtered or validated and the run ends without error, Stats.Exhausted telling that the
+	// budget cut it short. They suit previews of large files
+	MaxRows  int64
+	MaxBytes int64
+	// Filter drops the rows for which it returns false, before they are validated or split in
+	// fields, as cheaply as possible. It receives the raw record without line break, which must
+	// not be modified nor kept after it returns. It is called by several workers at once
+	Filter func(row []byte) bool
+	// Regexps drop the rows not matching all of them, after validation and before Where. The
+	// rows kept are counted by Stats.RowsMatched
+	Regexps []RegexFilter
+	// Where drops the rows not matching the expression before they reach the jobs
+	Where *Where
+	// SpillDir is the directory of the temporary files of disk-backed modes. When empty
+	// DetectDuplicates and GroupBy keep their state in memory
+	SpillDir string
+	// DeadLetter receives the rows rejected by validation, written verbatim with an extra column
+	// holding the reason, so that they can be fixed and processed again
+	DeadLetter io.Writer
+	// Transforms rewrite the rows written by Copy, in order
+	Transforms []Transform
+	// RowNumber prepends to the rows written by Copy their number or source line, in a column
+	// named RowNumberColumn, "row_number" or "line" if empty
+	RowNumber       RowNumbering
+	RowNumberColumn string
+	// Histogram adds to the columns reported by Profile the histogram of their values, in
+	// HistogramBins bins, DefaultHistogramBins if 0
+	Histogram     HistogramKind
+	HistogramBins int
+	// MaxRowsPerSecond limits the rate at which rows are handed to the jobs, all workers
+	// included, 0 means no limit. Chunks are delivered whole, so BytesPerWorker should hold
+	// well under a second of rows for a smooth rate
+	MaxRowsPerSecond int
+	// Parser finds the records of the input and their fields, a CSVParser using
+	// HeaderConfig.Separator if nil
+	Parser RecordParser
+	// Retry runs again the jobs and the sink writes failing with transient errors, before the
+	// error policy applies. Jobs are run again on the whole chunk, so they must be idempotent
+	Retry RetryPolicy
+	// Idempotent tells that a job can run again on a chunk whose previous run failed, whatever
+	// it did before failing. A chunk whose job still fails after Retry is then handed back to the
+	// workers, ahead of the blocks waiting, and run again up to Redispatch times before the
+	// error policy applies. Redispatched chunks are not filtered nor validated again, and
+	// Stats.Redispatched counts them. The methods writing to a Sink redispatch the chunks whose
+	// rows could not be produced, not those the sink failed to write
+	Idempotent bool
+	Redispatch int
+	// QueueSampleInterval is the interval at which the number of blocks waiting for a worker is
+	// recorded in Stats.QueueSamples, 0 meaning never
+	QueueSampleInterval time.Duration
+	// CPUProfile and HeapProfile are the files receiving the CPU profile of each run and the heap
+	// profile taken at its end, in the format of runtime/pprof, read by go tool pprof. Only one
+	// CPU profile can be taken at once in a program
+	CPUProfile  string
+	HeapProfile string
+	// Logger receives the start and end of the runs, the chunks processed, the retries and the
+	// errors, at the levels of LogLevels. Nothing is logged if nil
+	Logger    Logger
+	LogLevels LogLevels
+	// Trace records the byte range, lines, worker and duration of every chunk processed
+	Trace *Trace
 }
 
 //workerData is the struct needed for a routine in order to run
 type workerData struct {
-	job    Job
-	header []string
-	rows   []byte
+	job       ChunkJob
+	header    []string
+	rows      []byte
+	startLine int
+	offset    int64
+	index     int
+	// size is the number of bytes consumed from the source, line break included
+	size     int64
+	rowCount int
+	buffer   *sharedBuffer
+	// prepared is the chunk already filtered and validated by a failed attempt, attempts the
+	// number of times it has been redispatched
+	prepared *Chunk
+	attempts int
+	ack      *chunkAck
 }
 
 type Processor interface {
 	GetConfig() Config
 	GetHeader() []string
 	Run(job Job) error
+	RunChunks(job ChunkJob) error
+	ColumnIndex(name string) int
+	Validate(schema Schema) (*ValidationReport, error)
+	ValidateRules(rules Rules) (*ValidationReport, error)
+	Stats() Stats
+	DetectDuplicates(keyColumns []string) (*DuplicateReport, error)
+	Dedupe(keyColumns []string, keep Keep, sink Sink) error
+	InferSchema() (*InferredSchema, error)
+	Profile() (*ProfileReport, error)
+	TopValues(k int, columns ...string) (map[string][]FrequentValue, error)
+	Quality(config QualityConfig) (*QualityReport, error)
+	GroupBy(keys []string, aggs []Agg) (*GroupByResult, error)
+	Sort(keys []SortKey, out io.Writer) error
+	Join(lookup io.Reader, join JoinConfig, sink Sink) error
+	Pivot(keyColumn string, valueColumn string, sink Sink) error
+	Unpivot(idColumns []string, valueColumns []string, sink Sink) error
+	Sample(n int) ([]string, error)
+	Head(n int) ([]string, error)
+	Tail(n int) ([]string, error)
+	Limit(offset int, n int, sink Sink) error
+	ResumeFrom(checkpoint *Checkpoint) error
+	ResumeFromJournal(journal *Journal) error
+	ImportSQLite(dbPath string, table string) error
+	DispatchChunks(ctx context.Context, queue ChunkQueue) error
+	Copy(sink Sink) error
+	Transformed(fn RowFunc) io.ReadCloser
+	Estimate() (*EstimateReport, error)
+	RowHasher(algorithm HashAlgorithm, columns ...string) (*RowHasher, error)
+	Stop()
+	RunUntilSignal(job ChunkJob, signals ...os.Signal) (Stats, error)
 }
 
 //processor is the core struct
 type processor struct {
-	reader *bufio.Reader
-	header []string
-	config *Config
-	blocks chan workerData
-	wg     *sync.WaitGroup
+	source      io.Reader
+	reader      *bufio.Reader
+	header      []string
+	headerBytes int64
+	config      *Config
+	blocks      chan workerData
+	wg          *sync.WaitGroup
+	counters    *counters
+	pool        *sync.Pool
+	deadLetter  *deadLetter
+	parser      RecordParser
+	stop        *stopSignal
+	// start is where the next run begins, after the header unless resumed
+	start Checkpoint
 }
 
 func (p processor) GetConfig() Config {
@@ -72,6 +303,25 @@ func (p processor) GetHeader() []string {
 	return p.header
 }
 
+// ColumnIndex returns the position of the named column in the header, or -1 if it is not there
+func (p processor) ColumnIndex(name string) int {
+	if canonical, ok := p.config.HeaderAliases[name]; ok {
+		name = canonical
+	}
+	for i, column := range p.header {
+		if column == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// split splits a row in its fields, unquoting the quoted ones
+func (p processor) split(row string) []string {
+	fields, _ := p.parser.Fields(row)
+	return fields
+}
+
 func GetDefaultConfig() Config {
 	return Config{
 		NumberOfWorkers: 8,
@@ -83,35 +333,88 @@ func GetDefaultConfig() Config {
 	}
 }
 
-//NewProcessor creates a new processor. If config is not provided, a default config is set
+//NewProcessor creates a new processor. If config is not provided, a default config is set.
+//It panics if the config is not valid
 func NewProcessor(reader io.Reader, config *Config) Processor {
+	p, err := newProcessor(reader, config)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// newProcessor is like NewProcessor but returns an error instead of panicking
+func newProcessor(reader io.Reader, config *Config) (*processor, error) {
 	if reader == nil {
-		panic(InvalidReaderError)
+		return nil, InvalidReaderError
 	}
 
 	if config == nil {
 		defaultConfig := GetDefaultConfig()
 		config = &defaultConfig
 	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
 
 	blocks := make(chan workerData, config.NumberOfWorkers)
 	wg := &sync.WaitGroup{}
 
 	p := &processor{
-		reader: bufio.NewReader(reader),
-		config: config,
-		blocks: blocks,
-		wg:     wg,
+		source:   reader,
+		reader:   bufio.NewReader(reader),
+		config:   config,
+		blocks:   blocks,
+		wg:       wg,
+		counters: newCounters(config.NumberOfWorkers, blocks),
+		parser:   config.Parser,
+		stop:     newStopSignal(),
+	}
+	if p.parser == nil {
+		p.parser = CSVParser{Separator: config.HeaderConfig.Separator}
+	}
+
+	if config.ReuseBuffers {
+		p.pool = newBufferPool(config.BytesPerWorker)
+	}
+
+	if config.AutoHeader {
+		// Peek fails when the input is shorter than the buffer, returning what there is
+		sample, err := p.reader.Peek(p.reader.Size())
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		detected := *config
+		detected.HeaderConfig.HasHeader = detectHeader(sample, p.parser, err == io.EOF)
+		config, p.config = &detected, &detected
 	}
 
 	if config.HeaderConfig.HasHeader {
 		err := p.parseHeader()
 		if err != nil {
-			panic(HeaderNotFoundError)
+			return nil, HeaderNotFoundError
+		}
+	} else if config.HeaderConfig.Generate {
+		if err := p.generateHeader(); err != nil {
+			return nil, err
 		}
 	}
 
-	return p
+	if config.DeadLetter != nil {
+		p.deadLetter = newDeadLetter(config.DeadLetter, p.header, config.HeaderConfig.Separator)
+	}
+
+	p.start = Checkpoint{Offset: p.headerBytes, Line: 1}
+	if config.HeaderConfig.HasHeader {
+		p.start.Line++
+	}
+	if config.StartOffset > 0 {
+		if err := p.startFrom(config.StartOffset, p.start.Line, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
 }
 
 //parseHeader scan the first line and return the header if present
@@ -122,61 +425,510 @@ func (p *processor) parseHeader() error {
 		return HeaderNotFoundError
 	}
 
-	p.header = strings.Split(line[:len(line)-1], p.config.HeaderConfig.Separator)
+	p.headerBytes = int64(len(line))
+	atomic.AddInt64(&p.counters.bytesRead, p.headerBytes)
+	p.header = p.split(line[:len(line)-1])
+	p.aliasHeader()
+	return nil
+}
+
+// generateHeader names the columns after the fields of the first row, which is left unread
+func (p *processor) generateHeader() error {
+	// Peek fails when the input is shorter than the buffer, returning what there is
+	sample, err := p.reader.Peek(p.reader.Size())
+	if err != nil && err != io.EOF {
+		return err
+	}
+	end := p.parser.RecordsEnd(sample)
+	if end == 0 && err == nil {
+		return fmt.Errorf("%w: the first row is longer than %d bytes", HeaderNotFoundError, len(sample))
+	}
+	if end == 0 {
+		end = len(sample)
+	}
+	if end == 0 {
+		// empty input
+		return nil
+	}
+
+	fields, err := p.parser.Fields(p.parser.Records(string(sample[:end]))[0])
+	if err != nil {
+		return fmt.Errorf("%w: %v", HeaderNotFoundError, err)
+	}
+	p.header = make([]string, len(fields))
+	for i := range fields {
+		p.header[i] = columnName(i)
+	}
+	p.aliasHeader()
 	return nil
 }
 
+// aliasHeader replaces the names of the header having an alias with the canonical ones
+func (p *processor) aliasHeader() {
+	for i, column := range p.header {
+		if canonical, ok := p.config.HeaderAliases[column]; ok {
+			p.header[i] = canonical
+		}
+	}
+}
+
 //Run reads from the input reader and writes to the channel blocks of data
 func (p processor) Run(job Job) error {
+	return p.RunChunks(func(chunk Chunk) error {
+		job(chunk.Header, chunk.Rows)
+		return nil
+	})
+}
+
+// RunChunks is like Run but hands each chunk to the job together with its source line numbers
+func (p processor) RunChunks(job ChunkJob) error {
+	start := time.Now()
+	levels := p.config.LogLevels
+	p.config.log(levels.Run, LogInfo, "run started", "workers", p.config.NumberOfWorkers, "offset", p.start.Offset)
+
+	stopProfiles, err := p.startProfiles()
+	if err != nil {
+		return err
+	}
+	err = p.runChunks(job)
+	if profileErr := stopProfiles(); err == nil {
+		err = profileErr
+	}
+	stats := p.Stats()
+	args := []interface{}{"duration", time.Since(start), "chunks", stats.Chunks, "rows", stats.RowsDelivered,
+		"skipped", stats.RowsSkipped, "filtered", stats.RowsFiltered, "retries", stats.Retries}
+	if err != nil {
+		p.config.log(levels.Error, LogError, "run failed", append(args, "error", err)...)
+	} else {
+		p.config.log(levels.Run, LogInfo, "run finished", args...)
+	}
+	return err
+}
+
+func (p processor) runChunks(job ChunkJob) error {
+	state := newRunState(p.config)
+	if p.config.Where != nil {
+		where, err := p.config.Where.bind(p)
+		if err != nil {
+			return err
+		}
+		state.where = where
+	}
+	if len(p.config.Regexps) > 0 {
+		regexps, err := p.bindRegexps(p.config.Regexps)
+		if err != nil {
+			return err
+		}
+		state.regexps = regexps
+	}
+
+	state.progress = newProgress(p.start)
+	stop := make(chan struct{})
+	if p.config.CheckpointPath != "" {
+		go p.saveCheckpoints(state, stop)
+	}
+	if p.config.QueueSampleInterval > 0 {
+		go p.sampleQueue(stop)
+	}
+
 	p.wg.Add(p.config.NumberOfWorkers)
 	for i := 0; i < p.config.NumberOfWorkers; i++ {
-		go func(blocks chan workerData, wg *sync.WaitGroup) {
+		go func(worker int, blocks chan workerData, wg *sync.WaitGroup) {
 			defer wg.Done()
 
-			for data := range blocks {
-				j := data.job
-				text := string(data.rows)
-				lines := strings.Split(text, LineBreak)
-				j(data.header, lines)
+			waiting := time.Now()
+			for {
+				data, ok := state.next(blocks)
+				if !ok {
+					break
+				}
+				// after an abort the remaining blocks are only drained
+				if state.aborted() {
+					data.buffer.release()
+					continue
+				}
+
+				start := time.Now()
+				idle := start.Sub(waiting)
+				// an AckSink delays the completion until it delivers the rows
+				data.ack = newChunkAck(func(completed bool, err error) {
+					if err != nil {
+						state.fail(err)
+					}
+					if completed {
+						state.progress.complete(data.index, completedChunk{
+							end:  data.offset + data.size,
+							line: data.startLine + data.rowCount,
+							rows: int64(data.rowCount),
+						})
+					}
+				})
+				completed := p.process(state, worker, data)
+				data.ack.processed(completed)
+				if p.config.Trace != nil {
+					p.config.Trace.add(TraceChunk{
+						Index:     data.index,
+						Worker:    worker,
+						Start:     data.offset,
+						End:       data.offset + data.size,
+						FirstLine: data.startLine,
+						LastLine:  data.startLine + data.rowCount - 1,
+						Rows:      data.rowCount,
+						Duration:  time.Since(start),
+						Completed: completed,
+					})
+				}
+				data.buffer.release()
+				waiting = time.Now()
+				p.counters.processed(worker, data.rowCount, waiting.Sub(start), idle)
 			}
-		}(p.blocks, p.wg)
+			// the wait for the other workers to finish
+			p.counters.waited(worker, time.Since(waiting))
+		}(i, p.blocks, p.wg)
+	}
+
+	err := p.read(state, job)
+
+	close(p.blocks)
+	p.wg.Wait()
+	close(stop)
+
+	if p.config.CheckpointPath != "" {
+		if saveErr := state.progress.snapshot().save(p.config.CheckpointPath); err == nil {
+			err = saveErr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if state.err != nil {
+		return state.err
+	}
+	// the rows after the stop or beyond the budget have not been delivered
+	if state.stopped || state.exhausted || p.Stats().Stopped {
+		return nil
+	}
+	return p.Stats().reconcile(p.headerBytes)
+}
+
+// process turns a block of data into a chunk, validates it and runs the job on it, handing it
+// back to the workers if the job fails and Config.Redispatch allows it.
+// It returns true once the chunk has been fully processed, even if errors have been skipped
+func (p processor) process(state *runState, worker int, data workerData) bool {
+	var chunk Chunk
+	if data.prepared != nil {
+		// a redispatched chunk has already been filtered and validated
+		chunk = *data.prepared
+		chunk.Worker = worker
+	} else {
+		var ok bool
+		if chunk, ok = p.prepare(state, worker, data); !ok {
+			return false
+		}
+		atomic.AddInt64(&p.counters.rowsDelivered, int64(len(chunk.Rows)))
+	}
+	chunk.redispatch = p.config.Idempotent && data.attempts < p.config.Redispatch
+	chunk.ack = data.ack
+
+	start := time.Now()
+	err := p.config.Retry.do(state.abort, p.retried, func() error {
+		return runJob(data.job, chunk)
+	})
+	p.config.log(p.config.LogLevels.Chunk, LogDebug, "chunk processed", "index", chunk.Index, "worker", worker,
+		"line", chunk.StartLine, "rows", len(chunk.Rows), "duration", time.Since(start))
+	var permanent permanentError
+	if errors.As(err, &permanent) {
+		err = permanent.error
+	}
+	if errors.Is(err, errStopRun) {
+		state.stop()
+		return true
+	}
+	// the run has already been aborted by another chunk
+	if errors.Is(err, errCancelled) {
+		return false
+	}
+	if err != nil && chunk.redispatch && !state.aborted() {
+		p.redispatch(state, data, chunk, err)
+		return false
+	}
+	if err != nil {
+		state.fail(err)
+	}
+	return err == nil || p.config.ErrorPolicy == SkipOnError
+}
+
+// prepare turns a block of data into a chunk, dropping the rows filtered out or invalid. It
+// returns false if the run has been aborted meanwhile
+func (p processor) prepare(state *runState, worker int, data workerData) (Chunk, bool) {
+	var text string
+	if p.config.ReuseBuffers {
+		text = bytesToString(data.rows)
+	} else {
+		text = string(data.rows)
+	}
+
+	chunk := Chunk{
+		Header:    data.header,
+		Rows:      p.parser.Records(text),
+		StartLine: data.startLine,
+		Offset:    data.offset,
+		Index:     data.index,
+		Worker:    worker,
+		buffer:    data.buffer,
+		done:      state.abort,
+		size:      data.size,
+		rowsRead:  data.rowCount,
+	}
+
+	if p.config.Filter != nil {
+		rows := len(chunk.Rows)
+		chunk = chunk.filter(func(row string) bool {
+			return p.config.Filter(stringToBytes(row))
+		})
+		if chunk.Cancelled() {
+			return chunk, false
+		}
+		atomic.AddInt64(&p.counters.rowsFiltered, int64(rows-len(chunk.Rows)))
+	}
+
+	if p.config.Strict || p.config.ValidateFieldCount && len(chunk.Header) > 0 {
+		var ok bool
+		rows := len(chunk.Rows)
+		chunk, ok = p.validateRows(state, chunk)
+		if !ok {
+			return chunk, false
+		}
+		atomic.AddInt64(&p.counters.rowsSkipped, int64(rows-len(chunk.Rows)))
+	}
+
+	if len(state.regexps) > 0 {
+		rows := len(chunk.Rows)
+		chunk = chunk.filter(func(row string) bool {
+			return p.matchRegexps(state.regexps, row)
+		})
+		if chunk.Cancelled() {
+			return chunk, false
+		}
+		atomic.AddInt64(&p.counters.rowsFiltered, int64(rows-len(chunk.Rows)))
+		atomic.AddInt64(&p.counters.rowsMatched, int64(len(chunk.Rows)))
+	}
+
+	if state.where != nil {
+		rows := len(chunk.Rows)
+		chunk = chunk.filter(func(row string) bool {
+			return state.where.match(p.split(row))
+		})
+		if chunk.Cancelled() {
+			return chunk, false
+		}
+		atomic.AddInt64(&p.counters.rowsFiltered, int64(rows-len(chunk.Rows)))
 	}
 
+	if state.limiter != nil && !state.limiter.wait(len(chunk.Rows), state.abort) {
+		return chunk, false
+	}
+	return chunk, true
+}
+
+// retried counts a retry, attempt is the number of the one about to run and err the error of
+// the previous one
+func (p processor) retried(attempt int, err error) {
+	atomic.AddInt64(&p.counters.retries, 1)
+	p.config.log(p.config.LogLevels.Retry, LogWarn, "retrying", "attempt", attempt, "error", err)
+}
+
+// runJob runs the job on a chunk, turning a panic into a PanicError
+func runJob(job ChunkJob, chunk Chunk) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{
+				Value:  r,
+				Stack:  debug.Stack(),
+				Line:   chunk.StartLine,
+				Offset: chunk.Offset,
+			}
+		}
+	}()
+
+	return job(chunk)
+}
+
+// read splits the input in blocks ending on a line break and sends them to the workers.
+// The last block is flushed even if the input does not end with a line break
+func (p processor) read(state *runState, job ChunkJob) error {
 	tot := 0
-	buffer := make([]byte, 0, p.config.BytesPerWorker)
+	line := p.start.Line
+	offset := p.start.Offset
+	index := 0
+	budget := newBudget(p.config)
+	skip := int64(p.config.SkipDataRows)
+
+	buffer := p.newBuffer()
 	for {
-		n, err := io.ReadFull(p.reader, buffer[:cap(buffer)])
+		if p.stopRequested() {
+			buffer.release()
+			p.stopped()
+			return nil
+		}
+
+		// a single line does not fit in the buffer, make room for the rest of it
+		if len(buffer.data) == cap(buffer.data) {
+			grown := make([]byte, len(buffer.data), 2*cap(buffer.data))
+			copy(grown, buffer.data)
+			buffer.data = grown
+		}
+
+		n, err := io.ReadFull(p.reader, buffer.data[len(buffer.data):cap(buffer.data)])
 		tot += n
-		buffer = buffer[:n]
+		atomic.AddInt64(&p.counters.bytesRead, int64(n))
+		buffer.data = buffer.data[:len(buffer.data)+n]
 		if err != nil {
 			if err == io.EOF {
 				if tot == 0 {
+					buffer.release()
 					return EmptyFileError
 				}
 
 				break
 			}
 			if err != io.ErrUnexpectedEOF {
+				buffer.release()
 				return err
 			}
 		}
 
-		lastIndex := bytes.LastIndexByte(buffer, LineBreak[0])
-		if lastIndex != -1 {
-			p.blocks <- workerData{
-				job:    job,
-				header: p.header,
-				rows:   buffer[:lastIndex],
-			}
+		end := p.parser.RecordsEnd(buffer.data)
+		if end > 0 && skip > 0 {
+			ignored, rows := p.ignore(buffer, end, skip)
+			skip -= int64(rows)
+			line += rows
+			offset += int64(ignored)
+			end -= ignored
+		}
+		exhausted := false
+		if end > 0 && budget.limited() {
+			end, exhausted = budget.take(p.parser, buffer.data[:end])
+		}
+		if end > 0 {
+			// the remainder is moved to the next buffer before this one goes to a worker
+			next := p.newBuffer()
+			next.data = append(next.data, buffer.data[end:]...)
 
-			remain := buffer[lastIndex:]
-			buffer = make([]byte, 0, p.config.BytesPerWorker)
-			buffer = append(buffer, remain...)
+			rows := p.parser.Count(buffer.data[:end])
+			p.countFields(state, buffer.data[:end])
+			ok := p.dispatch(state, workerData{
+				job:       job,
+				header:    p.header,
+				rows:      buffer.data[:end],
+				startLine: line,
+				offset:    offset,
+				index:     index,
+				size:      int64(end),
+				rowCount:  rows,
+				buffer:    buffer,
+			})
+			if !ok {
+				next.release()
+				return nil
+			}
+			p.count(rows, end)
+			line += rows
+			offset += int64(end)
+			index++
+			buffer = next
+		}
+		if exhausted {
+			buffer.release()
+			p.exhaust(state)
+			return nil
 		}
 	}
 
-	close(p.blocks)
-	p.wg.Wait()
+	if len(buffer.data) > 0 && skip > 0 {
+		ignored, rows := p.ignore(buffer, len(buffer.data), skip)
+		line += rows
+		offset += int64(ignored)
+	}
+	exhausted := false
+	if len(buffer.data) > 0 && budget.limited() {
+		var end int
+		end, exhausted = budget.take(p.parser, buffer.data)
+		buffer.data = buffer.data[:end]
+	}
+	if exhausted {
+		p.exhaust(state)
+	}
+	if len(buffer.data) == 0 {
+		buffer.release()
+		return nil
+	}
+
+	rows := p.parser.Count(buffer.data)
+	p.countFields(state, buffer.data)
+	ok := p.dispatch(state, workerData{
+		job:       job,
+		header:    p.header,
+		rows:      buffer.data,
+		startLine: line,
+		offset:    offset,
+		index:     index,
+		size:      int64(len(buffer.data)),
+		rowCount:  rows,
+		buffer:    buffer,
+	})
+	if ok {
+		p.count(rows, len(buffer.data))
+	}
 
 	return nil
 }
+
+// ignore removes from the start of the buffer up to skip of the records ending before end, for
+// Config.SkipDataRows, and returns their length and number
+func (p processor) ignore(buffer *sharedBuffer, end int, skip int64) (int, int) {
+	ignored := recordsPrefix(p.parser, buffer.data[:end], skip)
+	if ignored == 0 {
+		return 0, 0
+	}
+	rows := p.parser.Count(buffer.data[:ignored])
+	buffer.data = buffer.data[:copy(buffer.data, buffer.data[ignored:])]
+	atomic.AddInt64(&p.counters.rowsIgnored, int64(rows))
+	atomic.AddInt64(&p.counters.bytesIgnored, int64(ignored))
+	return ignored, rows
+}
+
+// countFields sets the number of fields expected by strict mode in files without header,
+// taken from the first row of the first block
+func (p processor) countFields(state *runState, block []byte) {
+	if !p.config.Strict || len(p.header) > 0 || state.fieldCount > 0 {
+		return
+	}
+
+	first := p.parser.Records(string(block))[0]
+	fields, _ := p.parser.Fields(first)
+	state.fieldCount = len(fields)
+}
+
+// count records a dispatched block
+func (p processor) count(rows int, size int) {
+	atomic.AddInt64(&p.counters.chunks, 1)
+	atomic.AddInt64(&p.counters.rowsRead, int64(rows))
+	atomic.AddInt64(&p.counters.bytesDispatched, int64(size))
+}
+
+// dispatch sends a block to the workers. It returns false if the run has been aborted
+func (p processor) dispatch(state *runState, data workerData) bool {
+	select {
+	case p.blocks <- data:
+		p.counters.observeQueue(len(p.blocks))
+		return true
+	case <-state.abort:
+		data.buffer.release()
+		return false
+	case <-p.stop.ch:
+		data.buffer.release()
+		p.stopped()
+		return false
+	}
+}
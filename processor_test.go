@@ -1,9 +1,13 @@
 package parallel_csv
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -30,6 +34,7 @@ func TestEmptyFileWithoutHeader(t *testing.T) {
 		NumberOfWorkers: 2,
 		HeaderConfig: HeaderConfig{
 			HasHeader: false,
+			Separator: ",",
 		},
 		BytesPerWorker: 5 * KB,
 	}
@@ -116,3 +121,137 @@ func TestFileWithHeader(t *testing.T) {
 	assert.Len(t, ch, lines)
 	assert.Equal(t, []string{"Index", "Height(Inches)", "Weight(Pounds)"}, p.GetHeader())
 }
+
+func TestFileWithoutTrailingLineBreak(t *testing.T) {
+	file := openFile("testdata/very-small.csv")
+	p := NewProcessor(file, nil)
+
+	ch := make(chan string, 3)
+	err := p.Run(func(header []string, rows []string) {
+		for _, row := range rows {
+			ch <- row
+		}
+	})
+	assert.Nil(t, err)
+	assert.Len(t, ch, 3)
+}
+
+func TestLastLineWithoutLineBreak(t *testing.T) {
+	p := NewProcessor(strings.NewReader("a,b\n1,2\n3,4"), nil)
+
+	var rows []string
+	err := p.Run(func(header []string, chunk []string) {
+		rows = append(rows, chunk...)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1,2", "3,4"}, rows)
+}
+
+func TestSmallBlocks(t *testing.T) {
+	content, err := os.ReadFile("testdata/small.csv")
+	assert.Nil(t, err)
+	expected := strings.Split(strings.TrimSuffix(string(content), LineBreak), LineBreak)[1:]
+
+	// blocks smaller than a line force the buffer to grow and lines to span reads
+	p := NewProcessor(bytes.NewReader(content), &Config{
+		NumberOfWorkers: 1,
+		HeaderConfig: HeaderConfig{
+			HasHeader: true,
+			Separator: ",",
+		},
+		BytesPerWorker: 8,
+	})
+
+	var rows []string
+	err = p.Run(func(header []string, chunk []string) {
+		rows = append(rows, chunk...)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, expected, rows)
+}
+
+func TestChunkLineNumbers(t *testing.T) {
+	file := openFile("testdata/small.csv")
+	p := NewProcessor(file, &Config{
+		NumberOfWorkers: 4,
+		HeaderConfig: HeaderConfig{
+			HasHeader: true,
+			Separator: ",",
+		},
+		BytesPerWorker: 64,
+	})
+
+	// the first field of small.csv is the row index, one line below the header
+	var mu sync.Mutex
+	mismatches := 0
+	err := p.RunChunks(func(chunk Chunk) error {
+		for i, row := range chunk.Rows {
+			index, _ := strconv.Atoi(strings.Split(row, ",")[0])
+			if index+1 != chunk.Line(i) {
+				mu.Lock()
+				mismatches++
+				mu.Unlock()
+			}
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Zero(t, mismatches)
+}
+
+func TestHeaderAliases(t *testing.T) {
+	config := GetDefaultConfig()
+	config.HeaderAliases = map[string]string{"E-mail": "email", "email_address": "email", "Name": "name"}
+
+	for _, input := range []string{"Name,E-mail\nanna,a@x.it\n", "name,email_address\nanna,a@x.it\n"} {
+		p := NewProcessor(strings.NewReader(input), &config)
+		assert.Equal(t, []string{"name", "email"}, p.GetHeader())
+		assert.Equal(t, 1, p.ColumnIndex("email"))
+		assert.Equal(t, 1, p.ColumnIndex("E-mail"))
+		assert.Equal(t, -1, p.ColumnIndex("Email"))
+
+		sink := &memorySink{}
+		assert.Nil(t, p.Copy(sink))
+		assert.Equal(t, []string{"name", "email"}, sink.header)
+	}
+}
+
+func TestGenerateHeader(t *testing.T) {
+	config := GetDefaultConfig()
+	config.HeaderConfig = HeaderConfig{Separator: ",", Generate: true}
+	config.BytesPerWorker = 8
+	config.HeaderAliases = map[string]string{"col_2": "name"}
+	config.Where = MustParseWhere("col_1 > 1")
+
+	p := NewProcessor(strings.NewReader("1,anna,IT\n2,bob,FR\n3,carla"), &config)
+	assert.Equal(t, []string{"col_1", "name", "col_3"}, p.GetHeader())
+	assert.Equal(t, 1, p.ColumnIndex("name"))
+
+	sink := &memorySink{}
+	assert.Nil(t, p.Copy(sink))
+	assert.Equal(t, []string{"col_1", "name", "col_3"}, sink.header)
+	assert.Equal(t, [][]string{{"2", "bob", "FR"}, {"3", "carla"}}, sink.rows)
+
+	p = NewProcessor(strings.NewReader("a;b"), &config)
+	assert.Equal(t, []string{"col_1"}, p.GetHeader())
+
+	_, err := newProcessor(strings.NewReader(strings.Repeat("a", 5000)+"\n"), &config)
+	assert.ErrorIs(t, err, HeaderNotFoundError)
+}
+
+func TestFilter(t *testing.T) {
+	input := "type,value\nA,1\nB,2\nA,3\n,4\nA,\"5\"\n"
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 8
+	config.ValidateFieldCount = true
+	config.Filter = func(row []byte) bool {
+		return bytes.HasPrefix(row, []byte("A,"))
+	}
+	p := NewProcessor(strings.NewReader(input), &config)
+
+	sink := &memorySink{}
+	assert.Nil(t, p.Copy(sink))
+	assert.Equal(t, [][]string{{"A", "1"}, {"A", "3"}, {"A", "5"}}, sink.rows)
+	assert.Equal(t, int64(2), p.Stats().RowsFiltered)
+	assert.Equal(t, int64(0), p.Stats().RowsSkipped)
+}
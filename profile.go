@@ -0,0 +1,337 @@
+package parallel_csv
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxTopValues is the number of most frequent values reported for each column
+const MaxTopValues = 5
+
+// MaxProfiledValues is the number of distinct values counted exactly for each column,
+// beyond it new values are not counted anymore
+const MaxProfiledValues = 10000
+
+// ValueCount is a value and the number of rows it appears on
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// NumericProfile summarizes the values of a numeric column
+type NumericProfile struct {
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+	// P50, P95 and P99 are percentiles estimated with a t-digest, the more accurate the closer
+	// to the extremes
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+}
+
+// ColumnProfile summarizes the values of a column
+type ColumnProfile struct {
+	Name  string     `json:"name"`
+	Type  ColumnType `json:"type"`
+	Count int        `json:"count"`
+	Nulls int        `json:"nulls"`
+	// Distinct is exact when DistinctExact is set, otherwise it is a lower bound
+	Distinct      int  `json:"distinct"`
+	DistinctExact bool `json:"distinct_exact"`
+	// Cardinality is the number of distinct values, estimated with HyperLogLog within about 1%
+	// when Distinct is not exact
+	Cardinality int64 `json:"cardinality"`
+	// Min and Max are compared as numbers in numeric columns, as strings otherwise
+	Min       string          `json:"min"`
+	Max       string          `json:"max"`
+	Numeric   *NumericProfile `json:"numeric,omitempty"`
+	TopValues []ValueCount    `json:"top_values"`
+	// Histogram is the distribution of the values of numeric and time columns, when asked with
+	// Config.Histogram
+	Histogram *Histogram `json:"histogram,omitempty"`
+}
+
+// ProfileReport is the result of Profile, it can be encoded as JSON
+type ProfileReport struct {
+	Rows    int             `json:"rows"`
+	Columns []ColumnProfile `json:"columns"`
+}
+
+// KeyCandidates returns the columns whose values are all present and distinct, or nearly so
+// when their cardinality is estimated, which could identify the rows
+func (r *ProfileReport) KeyCandidates() []string {
+	var names []string
+	for _, column := range r.Columns {
+		if column.Nulls > 0 || r.Rows == 0 {
+			continue
+		}
+		if column.DistinctExact && column.Distinct == r.Rows ||
+			!column.DistinctExact && float64(column.Cardinality) >= 0.97*float64(r.Rows) {
+			names = append(names, column.Name)
+		}
+	}
+	return names
+}
+
+// moments holds the running count, mean and sum of squared deviations of a set of numbers
+type moments struct {
+	n    float64
+	mean float64
+	m2   float64
+}
+
+func (m *moments) add(x float64) {
+	m.n++
+	delta := x - m.mean
+	m.mean += delta / m.n
+	m.m2 += delta * (x - m.mean)
+}
+
+// merge combines the moments of two disjoint sets of numbers
+func (m *moments) merge(other moments) {
+	if other.n == 0 {
+		return
+	}
+	n := m.n + other.n
+	delta := other.mean - m.mean
+	m.mean += delta * other.n / n
+	m.m2 += other.m2 + delta*delta*m.n*other.n/n
+	m.n = n
+}
+
+func (m moments) stddev() float64 {
+	if m.n < 2 {
+		return 0
+	}
+	return math.Sqrt(m.m2 / (m.n - 1))
+}
+
+// columnProfiler accumulates the statistics of a column seen by a worker
+type columnProfiler struct {
+	typ      inferredType
+	values   int
+	nulls    int
+	min, max string
+	moments  moments
+	digest   tDigest
+	// times summarizes the Unix times of the values which are times
+	times tDigest
+	// numMin and numMax are the smallest and largest numbers along with their original text
+	numMin, numMax       float64
+	numMinRaw, numMaxRaw string
+	counts               map[string]int
+	overflow             bool
+	// distinct is allocated once counts overflows
+	distinct *hyperLogLog
+}
+
+func newColumnProfiler() *columnProfiler {
+	return &columnProfiler{counts: map[string]int{}}
+}
+
+// overflowed turns to estimating the distinct values, which are not counted exactly anymore
+func (c *columnProfiler) overflowed() {
+	if c.distinct != nil {
+		return
+	}
+	c.overflow = true
+	c.distinct = newHyperLogLog()
+	for value := range c.counts {
+		c.distinct.add(value)
+	}
+}
+
+func (c *columnProfiler) observe(value string) {
+	c.values++
+	value = strings.TrimSpace(value)
+	if value == "" {
+		c.nulls++
+		return
+	}
+
+	first := c.values-c.nulls == 1
+	inferred := infer(value)
+	c.typ = c.typ.join(inferred)
+	if inferred.typ == TimeType {
+		parsed, _ := time.Parse(inferred.layout, value)
+		c.times.add(float64(parsed.UnixNano()) / float64(time.Second))
+	}
+	if first || value < c.min {
+		c.min = cloneString(value)
+	}
+	if first || value > c.max {
+		c.max = cloneString(value)
+	}
+
+	if x, err := strconv.ParseFloat(value, 64); err == nil {
+		if c.moments.n == 0 || x < c.numMin {
+			c.numMin, c.numMinRaw = x, cloneString(value)
+		}
+		if c.moments.n == 0 || x > c.numMax {
+			c.numMax, c.numMaxRaw = x, cloneString(value)
+		}
+		c.moments.add(x)
+		c.digest.add(x)
+	}
+
+	if _, ok := c.counts[value]; ok || len(c.counts) < MaxProfiledValues {
+		c.counts[cloneString(value)]++
+	} else {
+		c.overflowed()
+	}
+	if c.distinct != nil {
+		c.distinct.add(value)
+	}
+}
+
+func (c *columnProfiler) merge(other *columnProfiler) {
+	if other.values-other.nulls > 0 {
+		empty := c.values-c.nulls == 0
+		if empty || other.min < c.min {
+			c.min = other.min
+		}
+		if empty || other.max > c.max {
+			c.max = other.max
+		}
+	}
+	if other.moments.n > 0 {
+		if c.moments.n == 0 || other.numMin < c.numMin {
+			c.numMin, c.numMinRaw = other.numMin, other.numMinRaw
+		}
+		if c.moments.n == 0 || other.numMax > c.numMax {
+			c.numMax, c.numMaxRaw = other.numMax, other.numMaxRaw
+		}
+	}
+
+	c.values += other.values
+	c.nulls += other.nulls
+	c.typ = c.typ.join(other.typ)
+	c.moments.merge(other.moments)
+	c.digest.merge(&other.digest)
+	c.times.merge(&other.times)
+
+	if other.overflow {
+		c.overflowed()
+		c.distinct.merge(other.distinct)
+	}
+	for value, count := range other.counts {
+		if _, ok := c.counts[value]; ok || len(c.counts) < MaxProfiledValues {
+			c.counts[value] += count
+		} else {
+			c.overflowed()
+		}
+		if c.distinct != nil {
+			c.distinct.add(value)
+		}
+	}
+}
+
+func (c *columnProfiler) profile(name string, rows int, histogram HistogramKind, bins int) ColumnProfile {
+	profile := ColumnProfile{
+		Name:          name,
+		Type:          StringType,
+		Count:         c.values - c.nulls,
+		Nulls:         c.nulls + rows - c.values,
+		Distinct:      len(c.counts),
+		DistinctExact: !c.overflow,
+		Cardinality:   int64(len(c.counts)),
+		Min:           c.min,
+		Max:           c.max,
+	}
+	if c.typ.typ != "" {
+		profile.Type = c.typ.typ
+	}
+	if c.distinct != nil {
+		// the estimate may be below the values counted exactly
+		if estimate := c.distinct.count(); estimate > profile.Cardinality {
+			profile.Cardinality = estimate
+		}
+	}
+
+	if profile.Type == IntegerType || profile.Type == FloatType {
+		profile.Min, profile.Max = c.numMinRaw, c.numMaxRaw
+		profile.Numeric = &NumericProfile{
+			Min:    c.numMin,
+			Max:    c.numMax,
+			Mean:   c.moments.mean,
+			StdDev: c.moments.stddev(),
+			P50:    c.digest.quantile(0.5),
+			P95:    c.digest.quantile(0.95),
+			P99:    c.digest.quantile(0.99),
+		}
+		profile.Histogram = c.digest.histogram(histogram, bins)
+	}
+	if profile.Type == TimeType {
+		if profile.Histogram = c.times.histogram(histogram, bins); profile.Histogram != nil {
+			profile.Histogram.Time = true
+		}
+	}
+
+	for value, count := range c.counts {
+		profile.TopValues = append(profile.TopValues, ValueCount{Value: value, Count: count})
+	}
+	sort.Slice(profile.TopValues, func(i, j int) bool {
+		a, b := profile.TopValues[i], profile.TopValues[j]
+		return a.Count > b.Count || a.Count == b.Count && a.Value < b.Value
+	})
+	if len(profile.TopValues) > MaxTopValues {
+		profile.TopValues = profile.TopValues[:MaxTopValues]
+	}
+
+	return profile
+}
+
+// Profile computes the statistics of every column, like csvstat. Each worker aggregates the
+// rows it processes, partial results are merged at the end
+func (p processor) Profile() (*ProfileReport, error) {
+	partials := make([][]*columnProfiler, p.config.NumberOfWorkers)
+
+	err := p.RunChunks(func(chunk Chunk) error {
+		columns := partials[chunk.Worker]
+		for _, row := range chunk.Rows {
+			if chunk.Cancelled() {
+				return errCancelled
+			}
+			for j, value := range p.split(row) {
+				if j == len(columns) {
+					columns = append(columns, newColumnProfiler())
+				}
+				columns[j].observe(value)
+			}
+		}
+		partials[chunk.Worker] = columns
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var columns []*columnProfiler
+	for _, partial := range partials {
+		for j, column := range partial {
+			if j == len(columns) {
+				columns = append(columns, newColumnProfiler())
+			}
+			columns[j].merge(column)
+		}
+	}
+
+	report := &ProfileReport{Rows: int(p.Stats().RowsDelivered)}
+	for len(columns) < len(p.header) {
+		columns = append(columns, newColumnProfiler())
+	}
+	for i, column := range columns {
+		name := columnName(i)
+		if i < len(p.header) {
+			name = p.header[i]
+		}
+		report.Columns = append(report.Columns, column.profile(name, report.Rows, p.config.Histogram, p.config.HistogramBins))
+	}
+
+	return report, nil
+}
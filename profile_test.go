@@ -0,0 +1,100 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestProfile(t *testing.T) {
+	input := "city,temperature\nrome,20\nmilan,10\nrome,30\n,40\nrome,\nnaples,25\n"
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 16
+	p := NewProcessor(strings.NewReader(input), &config)
+
+	report, err := p.Profile()
+	assert.Nil(t, err)
+	assert.Equal(t, 6, report.Rows)
+
+	city := report.Columns[0]
+	assert.Equal(t, "city", city.Name)
+	assert.Equal(t, StringType, city.Type)
+	assert.Equal(t, 5, city.Count)
+	assert.Equal(t, 1, city.Nulls)
+	assert.Equal(t, 3, city.Distinct)
+	assert.True(t, city.DistinctExact)
+	assert.Equal(t, "milan", city.Min)
+	assert.Equal(t, "rome", city.Max)
+	assert.Nil(t, city.Numeric)
+	assert.Equal(t, []ValueCount{{"rome", 3}, {"milan", 1}, {"naples", 1}}, city.TopValues)
+
+	temperature := report.Columns[1]
+	assert.Equal(t, IntegerType, temperature.Type)
+	assert.Equal(t, 1, temperature.Nulls)
+	assert.Equal(t, "10", temperature.Min)
+	assert.Equal(t, "40", temperature.Max)
+	assert.Equal(t, 25.0, temperature.Numeric.Mean)
+	assert.InDelta(t, 11.1803, temperature.Numeric.StdDev, 0.0001)
+	assert.Equal(t, 25.0, temperature.Numeric.P50)
+	assert.InDelta(t, 38.5, temperature.Numeric.P95, 1.5)
+}
+
+func TestMomentsMerge(t *testing.T) {
+	all, left, right := moments{}, moments{}, moments{}
+	for i, x := range []float64{3, 1, 4, 1, 5, 9, 2, 6} {
+		all.add(x)
+		if i < 3 {
+			left.add(x)
+		} else {
+			right.add(x)
+		}
+	}
+
+	left.merge(right)
+	assert.InDelta(t, all.mean, left.mean, 1e-9)
+	assert.InDelta(t, all.stddev(), left.stddev(), 1e-9)
+}
+
+func TestProfileFile(t *testing.T) {
+	file := openFile("testdata/mid.csv")
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 32 * KB
+	p := NewProcessor(file, &config)
+
+	report, err := p.Profile()
+	assert.Nil(t, err)
+	assert.Equal(t, 25000, report.Rows)
+
+	index := report.Columns[0]
+	assert.Equal(t, MaxProfiledValues, index.Distinct)
+	assert.False(t, index.DistinctExact)
+	assert.Equal(t, "1", index.Min)
+	assert.Equal(t, "25000", index.Max)
+	assert.InDelta(t, 12500.5, index.Numeric.Mean, 1e-6)
+}
+
+func TestProfileCardinality(t *testing.T) {
+	input := strings.Builder{}
+	input.WriteString("id,group,parity\n")
+	rows := 3 * MaxProfiledValues
+	for i := 0; i < rows; i++ {
+		input.WriteString(strconv.Itoa(i) + "," + strconv.Itoa(i/2) + "," + strconv.Itoa(i%2) + "\n")
+	}
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 4 * KB
+	p := NewProcessor(strings.NewReader(input.String()), &config)
+
+	report, err := p.Profile()
+	assert.Nil(t, err)
+
+	id, group, parity := report.Columns[0], report.Columns[1], report.Columns[2]
+	assert.False(t, id.DistinctExact)
+	assert.InDelta(t, rows, id.Cardinality, 0.03*float64(rows))
+	assert.False(t, group.DistinctExact)
+	assert.InDelta(t, rows/2, group.Cardinality, 0.03*float64(rows/2))
+	assert.True(t, parity.DistinctExact)
+	assert.Equal(t, int64(2), parity.Cardinality)
+
+	assert.Equal(t, []string{"id"}, report.KeyCandidates())
+}
@@ -0,0 +1,177 @@
+package parallel_csv
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// UnsupportedFieldError is returned by a ProtobufSink mapping a column to a repeated, map or
+// message field
+const UnsupportedFieldError = Error("unsupported protobuf field")
+
+// ProtobufSink encodes each row into a protobuf message, setting the fields named like the
+// columns of the header. Empty values leave their field unset. The messages are written to a
+// stream, each one after its size as a varint like protodelim does, or handed to Publish
+type ProtobufSink struct {
+	// Message is a message of the type to encode, such as &pb.Person{} or a dynamicpb message
+	Message proto.Message
+	// FieldMap renames the columns of the header to the fields of the message. Columns mapped
+	// to an empty name are not encoded, the others are matched by name or JSON name
+	FieldMap map[string]string
+	// IgnoreUnknown skips the columns without a field, which fail Open otherwise
+	IgnoreUnknown bool
+	// Publish receives the messages of each Write in place of the stream, to send them to a
+	// message broker
+	Publish func(messages [][]byte) error
+	w       *bufio.Writer
+	fields  []protoreflect.FieldDescriptor
+	options proto.MarshalOptions
+}
+
+// NewProtobufSink creates a sink writing length-prefixed messages of the type of message to w
+func NewProtobufSink(w io.Writer, message proto.Message) *ProtobufSink {
+	return &ProtobufSink{Message: message, w: bufio.NewWriter(w)}
+}
+
+// Open resolves the columns of the header against the fields of the message
+func (s *ProtobufSink) Open(header []string) error {
+	descriptor := s.Message.ProtoReflect().Descriptor()
+	s.fields = make([]protoreflect.FieldDescriptor, len(header))
+	for i, column := range header {
+		name := column
+		if mapped, ok := s.FieldMap[column]; ok {
+			name = mapped
+		}
+		if name == "" {
+			continue
+		}
+
+		field := descriptor.Fields().ByName(protoreflect.Name(name))
+		if field == nil {
+			field = descriptor.Fields().ByJSONName(name)
+		}
+		if field == nil {
+			if s.IgnoreUnknown {
+				continue
+			}
+			return fmt.Errorf("%w: %s has no field %s", ColumnNotFoundError, descriptor.FullName(), name)
+		}
+		if field.IsList() || field.IsMap() || field.Kind() == protoreflect.MessageKind || field.Kind() == protoreflect.GroupKind {
+			return fmt.Errorf("%w: %s", UnsupportedFieldError, field.FullName())
+		}
+		s.fields[i] = field
+	}
+	return nil
+}
+
+func (s *ProtobufSink) Write(rows [][]string) error {
+	messages := make([][]byte, 0, len(rows))
+	var b []byte
+	for _, row := range rows {
+		message := s.Message.ProtoReflect().New()
+		for i, field := range row {
+			if i >= len(s.fields) || s.fields[i] == nil || field == "" {
+				continue
+			}
+			value, err := protoValue(s.fields[i], field)
+			if err != nil {
+				return err
+			}
+			message.Set(s.fields[i], value)
+		}
+
+		if s.Publish != nil {
+			encoded, err := s.options.Marshal(message.Interface())
+			if err != nil {
+				return err
+			}
+			messages = append(messages, encoded)
+			continue
+		}
+
+		var err error
+		if b, err = s.options.MarshalAppend(b[:0], message.Interface()); err != nil {
+			return err
+		}
+		var size [binary.MaxVarintLen64]byte
+		if _, err := s.w.Write(size[:binary.PutUvarint(size[:], uint64(len(b)))]); err != nil {
+			return err
+		}
+		if _, err := s.w.Write(b); err != nil {
+			return err
+		}
+	}
+
+	if s.Publish != nil && len(messages) > 0 {
+		return s.Publish(messages)
+	}
+	return nil
+}
+
+// Close flushes the buffered messages, the underlying writer is left open
+func (s *ProtobufSink) Close() error {
+	if s.w == nil {
+		return nil
+	}
+	return s.w.Flush()
+}
+
+// protoValue converts a field to the kind of the protobuf field
+func protoValue(descriptor protoreflect.FieldDescriptor, field string) (protoreflect.Value, error) {
+	var value protoreflect.Value
+	var err error
+	switch descriptor.Kind() {
+	case protoreflect.BoolKind:
+		var v bool
+		v, err = strconv.ParseBool(field)
+		value = protoreflect.ValueOfBool(v)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		var v int64
+		v, err = strconv.ParseInt(field, 10, 32)
+		value = protoreflect.ValueOfInt32(int32(v))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		var v int64
+		v, err = strconv.ParseInt(field, 10, 64)
+		value = protoreflect.ValueOfInt64(v)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		var v uint64
+		v, err = strconv.ParseUint(field, 10, 32)
+		value = protoreflect.ValueOfUint32(uint32(v))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		var v uint64
+		v, err = strconv.ParseUint(field, 10, 64)
+		value = protoreflect.ValueOfUint64(v)
+	case protoreflect.FloatKind:
+		var v float64
+		v, err = strconv.ParseFloat(field, 32)
+		value = protoreflect.ValueOfFloat32(float32(v))
+	case protoreflect.DoubleKind:
+		var v float64
+		v, err = strconv.ParseFloat(field, 64)
+		value = protoreflect.ValueOfFloat64(v)
+	case protoreflect.StringKind:
+		value = protoreflect.ValueOfString(field)
+	case protoreflect.BytesKind:
+		value = protoreflect.ValueOfBytes([]byte(field))
+	case protoreflect.EnumKind:
+		// enums are given by name or number
+		if v := descriptor.Enum().Values().ByName(protoreflect.Name(field)); v != nil {
+			value = protoreflect.ValueOfEnum(v.Number())
+		} else {
+			var v int64
+			v, err = strconv.ParseInt(field, 10, 32)
+			value = protoreflect.ValueOfEnum(protoreflect.EnumNumber(v))
+		}
+	}
+
+	if err != nil {
+		return value, fmt.Errorf("%w: field %s: %q", TypeMismatchError, descriptor.Name(), field)
+	}
+	return value, nil
+}
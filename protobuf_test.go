@@ -0,0 +1,114 @@
+package parallel_csv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// personMessage builds a Person message type with a name, an age, a score and a status
+func personMessage(t *testing.T) proto.Message {
+	field := func(name string, number int32, kind descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(strings.ReplaceAll(name, "_name", "Name")),
+			Number:   proto.Int32(number),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     kind.Enum(),
+		}
+	}
+	status := field("status", 4, descriptorpb.FieldDescriptorProto_TYPE_ENUM)
+	status.TypeName = proto.String(".test.Status")
+
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("person.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Status"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("UNKNOWN"), Number: proto.Int32(0)},
+				{Name: proto.String("ACTIVE"), Number: proto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Person"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("full_name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+				field("age", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32),
+				field("score", 3, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE),
+				status,
+			},
+		}},
+	}, nil)
+	assert.Nil(t, err)
+	return dynamicpb.NewMessage(file.Messages().ByName("Person"))
+}
+
+// decodePeople reads the length-prefixed messages of a stream
+func decodePeople(t *testing.T, message proto.Message, data []byte) []protoreflect.Message {
+	var messages []protoreflect.Message
+	for len(data) > 0 {
+		size, n := binary.Uvarint(data)
+		decoded := message.ProtoReflect().New()
+		assert.Nil(t, proto.Unmarshal(data[n:n+int(size)], decoded.Interface()))
+		messages = append(messages, decoded)
+		data = data[n+int(size):]
+	}
+	return messages
+}
+
+func TestProtobufSink(t *testing.T) {
+	message := personMessage(t)
+	out := &bytes.Buffer{}
+	sink := NewProtobufSink(out, message)
+	sink.FieldMap = map[string]string{"name": "fullName", "note": ""}
+
+	input := "name,age,note,score,status\nMario,42,x,1.5,ACTIVE\nLuigi,,y,,1\n"
+	assert.Nil(t, NewProcessor(strings.NewReader(input), nil).Copy(sink))
+
+	people := decodePeople(t, message, out.Bytes())
+	assert.Len(t, people, 2)
+	fields := message.ProtoReflect().Descriptor().Fields()
+	assert.Equal(t, "Mario", people[0].Get(fields.ByName("full_name")).String())
+	assert.Equal(t, int64(42), people[0].Get(fields.ByName("age")).Int())
+	assert.Equal(t, 1.5, people[0].Get(fields.ByName("score")).Float())
+	assert.Equal(t, protoreflect.EnumNumber(1), people[0].Get(fields.ByName("status")).Enum())
+	assert.False(t, people[1].Has(fields.ByName("age")))
+	assert.Equal(t, protoreflect.EnumNumber(1), people[1].Get(fields.ByName("status")).Enum())
+}
+
+func TestProtobufSinkPublish(t *testing.T) {
+	message := personMessage(t)
+	sink := &ProtobufSink{Message: message, IgnoreUnknown: true}
+	var published [][]byte
+	sink.Publish = func(messages [][]byte) error {
+		published = append(published, messages...)
+		return nil
+	}
+
+	input := "full_name,city\nMario,Rome\nLuigi,Milan\n"
+	assert.Nil(t, NewProcessor(strings.NewReader(input), nil).Copy(sink))
+	assert.Len(t, published, 2)
+
+	decoded := message.ProtoReflect().New()
+	assert.Nil(t, proto.Unmarshal(published[1], decoded.Interface()))
+	assert.Equal(t, "Luigi", decoded.Get(decoded.Descriptor().Fields().ByName("full_name")).String())
+}
+
+func TestProtobufSinkErrors(t *testing.T) {
+	message := personMessage(t)
+	err := NewProcessor(strings.NewReader("full_name,city\nMario,Rome\n"), nil).Copy(NewProtobufSink(&bytes.Buffer{}, message))
+	assert.ErrorIs(t, err, ColumnNotFoundError)
+
+	err = NewProcessor(strings.NewReader("full_name,age\nMario,old\n"), nil).Copy(NewProtobufSink(&bytes.Buffer{}, message))
+	assert.ErrorIs(t, err, TypeMismatchError)
+}
@@ -0,0 +1,335 @@
+package parallel_csv
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+)
+
+// QualityConfig selects the checks of a data-quality report
+type QualityConfig struct {
+	// Schema sets the expected type and constraints of its columns. Columns missing from it are
+	// expected to have the type of most of their values
+	Schema *Schema
+	// Rules are checked on every row, their violations are counted with those of the schema
+	Rules Rules
+	// KeyColumns enables the count of duplicate keys, which are kept in memory
+	KeyColumns []string
+}
+
+// ColumnQuality describes the quality of the values of a column
+type ColumnQuality struct {
+	Name string `json:"name"`
+	// Filled is the number of non-empty values, FillRate their share of the rows
+	Filled   int     `json:"filled"`
+	FillRate float64 `json:"fill_rate"`
+	// Type is the expected type, TypeConformity the share of non-empty values having it
+	Type           ColumnType `json:"type"`
+	TypeConformity float64    `json:"type_conformity"`
+	TypeMismatches int        `json:"type_mismatches"`
+	// Violations counts the values breaking a schema constraint or a rule
+	Violations int `json:"violations"`
+}
+
+// QualityReport is the result of Quality, it can be written as JSON or HTML
+type QualityReport struct {
+	Rows    int             `json:"rows"`
+	Columns []ColumnQuality `json:"columns"`
+	// FieldCounts counts the rows by number of fields
+	FieldCounts map[int]int `json:"field_counts"`
+	// ExpectedFields is the number of fields of the header, or the most common one in files
+	// without header. RowLengthAnomalies counts the rows having a different number of fields
+	ExpectedFields     int   `json:"expected_fields"`
+	RowLengthAnomalies int   `json:"row_length_anomalies"`
+	AnomalyLines       []int `json:"anomaly_lines"`
+	// DuplicateKeys is the number of keys appearing on more than one row, DuplicateRows the
+	// number of rows repeating a key seen before
+	DuplicateKeys int `json:"duplicate_keys"`
+	DuplicateRows int `json:"duplicate_rows"`
+	// Samples holds the first MaxViolationSamples violations in source order
+	Samples []Violation `json:"-"`
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *QualityReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+var qualityTemplate = template.Must(template.New("quality").Funcs(template.FuncMap{
+	"percent": func(rate float64) string {
+		return formatFloat(rate*100) + "%"
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Data quality report</title></head>
+<body>
+<h1>Data quality report</h1>
+<p>{{.Rows}} rows, {{.RowLengthAnomalies}} with a number of fields other than {{.ExpectedFields}}{{if .AnomalyLines}} (lines {{range $i, $line := .AnomalyLines}}{{if $i}}, {{end}}{{$line}}{{end}}){{end}}, {{.DuplicateRows}} repeating one of {{.DuplicateKeys}} duplicate keys.</p>
+<table border="1">
+<tr><th>Column</th><th>Fill rate</th><th>Type</th><th>Type conformity</th><th>Type mismatches</th><th>Violations</th></tr>
+{{range .Columns}}<tr><td>{{.Name}}</td><td>{{percent .FillRate}}</td><td>{{.Type}}</td><td>{{percent .TypeConformity}}</td><td>{{.TypeMismatches}}</td><td>{{.Violations}}</td></tr>
+{{end}}</table>
+{{if .Samples}}<h2>Violations</h2>
+<ul>
+{{range .Samples}}<li>line {{.Line}}, {{.Column}}: {{.Err}} ({{printf "%q" .Value}})</li>
+{{end}}</ul>
+{{end}}</body>
+</html>
+`))
+
+// WriteHTML writes the report as a standalone HTML page
+func (r *QualityReport) WriteHTML(w io.Writer) error {
+	return qualityTemplate.Execute(w, r)
+}
+
+// columnAccumulator is what a worker has seen of a column
+type columnAccumulator struct {
+	filled     int
+	types      map[ColumnType]int
+	mismatches int
+	violations int
+}
+
+// qualityAccumulator is what a worker has seen of the file
+type qualityAccumulator struct {
+	rows        int
+	columns     []*columnAccumulator
+	fieldCounts map[int]int
+	// lines holds the first lines of each number of fields
+	lines   map[int][]int
+	keys    map[string]int
+	samples []Violation
+}
+
+func newQualityAccumulator() *qualityAccumulator {
+	return &qualityAccumulator{fieldCounts: map[int]int{}, lines: map[int][]int{}, keys: map[string]int{}}
+}
+
+func (a *qualityAccumulator) column(i int) *columnAccumulator {
+	for len(a.columns) <= i {
+		a.columns = append(a.columns, &columnAccumulator{types: map[ColumnType]int{}})
+	}
+	return a.columns[i]
+}
+
+func (a *qualityAccumulator) merge(other *qualityAccumulator) {
+	a.rows += other.rows
+	for i, column := range other.columns {
+		merged := a.column(i)
+		merged.filled += column.filled
+		merged.mismatches += column.mismatches
+		merged.violations += column.violations
+		for typ, count := range column.types {
+			merged.types[typ] += count
+		}
+	}
+	for count, rows := range other.fieldCounts {
+		a.fieldCounts[count] += rows
+	}
+	for count, lines := range other.lines {
+		a.lines[count] = append(a.lines[count], lines...)
+	}
+	for key, count := range other.keys {
+		a.keys[key] += count
+	}
+	a.samples = append(a.samples, other.samples...)
+}
+
+// Quality produces a data-quality report in a single pass over the file: the fill rate and type
+// conformity of each column, the violations of the schema and rules, the rows with an unexpected
+// number of fields and the duplicate keys
+func (p processor) Quality(config QualityConfig) (*QualityReport, error) {
+	var checks []columnCheck
+	typed := map[int]compiledColumn{}
+	if config.Schema != nil {
+		columns, err := p.compileSchema(*config.Schema)
+		if err != nil {
+			return nil, err
+		}
+		for _, column := range columns {
+			column := column
+			typed[column.index] = column
+			// type mismatches are counted on their own
+			checks = append(checks, columnCheck{name: column.Name, index: column.index, check: func(value string) error {
+				if err := column.check(value); err != TypeMismatchError {
+					return err
+				}
+				return nil
+			}})
+		}
+	}
+	for _, rule := range config.Rules {
+		index := headerIndex(p.header, rule.Column)
+		if index == -1 {
+			return nil, fmt.Errorf("%w: %s", ColumnNotFoundError, rule.Column)
+		}
+		check, err := compileRule(rule.Check)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", rule.Column, err)
+		}
+		checks = append(checks, columnCheck{name: rule.Column, index: index, check: check})
+	}
+	var keyIndexes []int
+	if len(config.KeyColumns) > 0 {
+		var err error
+		if keyIndexes, err = headerIndexes(p.header, config.KeyColumns); err != nil {
+			return nil, err
+		}
+	}
+
+	accumulators := make([]*qualityAccumulator, p.config.NumberOfWorkers)
+	for i := range accumulators {
+		accumulators[i] = newQualityAccumulator()
+	}
+
+	err := p.RunChunks(func(chunk Chunk) error {
+		a := accumulators[chunk.Worker]
+		for i, row := range chunk.Rows {
+			if chunk.Cancelled() {
+				return errCancelled
+			}
+			fields := p.split(row)
+			line := chunk.Line(i)
+			a.rows++
+			a.fieldCounts[len(fields)]++
+			if lines := a.lines[len(fields)]; len(lines) < MaxViolationSamples {
+				a.lines[len(fields)] = append(lines, line)
+			}
+
+			for j, field := range fields {
+				value := strings.TrimSpace(field)
+				if value == "" {
+					continue
+				}
+				column := a.column(j)
+				column.filled++
+				if schema, ok := typed[j]; ok {
+					if !schema.accepts(value) {
+						column.mismatches++
+					}
+				} else {
+					column.types[infer(value).typ]++
+				}
+			}
+
+			for _, check := range checks {
+				value := fieldAt(fields, check.index)
+				if err := check.check(value); err != nil {
+					a.column(check.index).violations++
+					if len(a.samples) < MaxViolationSamples {
+						a.samples = append(a.samples, Violation{Line: line, Column: check.name, Value: cloneString(value), Row: cloneString(row), Err: err})
+					}
+				}
+			}
+
+			if keyIndexes != nil {
+				a.keys[keyOf(fields, keyIndexes)]++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	total := accumulators[0]
+	for _, a := range accumulators[1:] {
+		total.merge(a)
+	}
+	return p.qualityReport(total, typed), nil
+}
+
+func (p processor) qualityReport(total *qualityAccumulator, typed map[int]compiledColumn) *QualityReport {
+	report := &QualityReport{Rows: total.rows, FieldCounts: total.fieldCounts, AnomalyLines: []int{}}
+
+	report.ExpectedFields = len(p.header)
+	if report.ExpectedFields == 0 {
+		for count, rows := range total.fieldCounts {
+			if rows > total.fieldCounts[report.ExpectedFields] || (rows == total.fieldCounts[report.ExpectedFields] && count < report.ExpectedFields) {
+				report.ExpectedFields = count
+			}
+		}
+	}
+	for count, rows := range total.fieldCounts {
+		if count != report.ExpectedFields {
+			report.RowLengthAnomalies += rows
+			report.AnomalyLines = append(report.AnomalyLines, total.lines[count]...)
+		}
+	}
+	sort.Ints(report.AnomalyLines)
+	if len(report.AnomalyLines) > MaxViolationSamples {
+		report.AnomalyLines = report.AnomalyLines[:MaxViolationSamples]
+	}
+
+	width := len(p.header)
+	if len(total.columns) > width {
+		width = len(total.columns)
+	}
+	for i := 0; i < width; i++ {
+		column := total.column(i)
+		quality := ColumnQuality{Name: columnName(i), Filled: column.filled, Violations: column.violations}
+		if i < len(p.header) {
+			quality.Name = p.header[i]
+		}
+		if total.rows > 0 {
+			quality.FillRate = float64(column.filled) / float64(total.rows)
+		}
+
+		if schema, ok := typed[i]; ok {
+			quality.Type = schema.Type
+			if quality.Type == "" {
+				quality.Type = StringType
+			}
+		} else {
+			quality.Type, column.mismatches = dominantType(column.types, column.filled)
+		}
+		quality.TypeMismatches = column.mismatches
+		if column.filled > 0 {
+			quality.TypeConformity = float64(column.filled-column.mismatches) / float64(column.filled)
+		}
+		report.Columns = append(report.Columns, quality)
+	}
+
+	for _, count := range total.keys {
+		if count > 1 {
+			report.DuplicateKeys++
+			report.DuplicateRows += count - 1
+		}
+	}
+
+	sort.SliceStable(total.samples, func(i, j int) bool {
+		return total.samples[i].Line < total.samples[j].Line
+	})
+	if len(total.samples) > MaxViolationSamples {
+		total.samples = total.samples[:MaxViolationSamples]
+	}
+	report.Samples = total.samples
+	return report
+}
+
+// dominantType returns the type of most values and the number of values not having it. Integers
+// are also floats, and every value is a string
+func dominantType(types map[ColumnType]int, filled int) (ColumnType, int) {
+	if filled == 0 {
+		return StringType, 0
+	}
+
+	conforming := map[ColumnType]int{
+		IntegerType: types[IntegerType],
+		FloatType:   types[IntegerType] + types[FloatType],
+		BooleanType: types[BooleanType],
+		TimeType:    types[TimeType],
+	}
+	best, count := StringType, types[StringType]
+	for _, typ := range []ColumnType{IntegerType, FloatType, BooleanType, TimeType} {
+		if conforming[typ] > count {
+			best, count = typ, conforming[typ]
+		}
+	}
+	return best, filled - count
+}
@@ -0,0 +1,68 @@
+package parallel_csv
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+const contacts = "id,email,age,signup\n1,anna@example.com,34,2021-01-02\n2,,x,2021-01-03\n2,carla@example.com,41\n3,dan,28,2021-01-05,extra\n4,eve@example.com,,2021-01-06\n"
+
+func TestQuality(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 16
+	p := NewProcessor(strings.NewReader(contacts), &config)
+
+	report, err := p.Quality(QualityConfig{
+		Schema:     &Schema{Columns: []ColumnSchema{{Name: "age", Type: IntegerType}}},
+		Rules:      Rules{{Column: "email", Check: "regex(@)"}},
+		KeyColumns: []string{"id"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 5, report.Rows)
+	assert.Equal(t, 4, report.ExpectedFields)
+	assert.Equal(t, map[int]int{3: 1, 4: 3, 5: 1}, report.FieldCounts)
+	assert.Equal(t, 2, report.RowLengthAnomalies)
+	assert.Equal(t, []int{4, 5}, report.AnomalyLines)
+	assert.Equal(t, 1, report.DuplicateKeys)
+	assert.Equal(t, 1, report.DuplicateRows)
+
+	assert.Equal(t, ColumnQuality{Name: "email", Filled: 4, FillRate: 0.8, Type: StringType, TypeConformity: 1, Violations: 1}, report.Columns[1])
+	assert.Equal(t, ColumnQuality{Name: "age", Filled: 4, FillRate: 0.8, Type: IntegerType, TypeConformity: 0.75, TypeMismatches: 1}, report.Columns[2])
+	assert.Equal(t, ColumnQuality{Name: "signup", Filled: 4, FillRate: 0.8, Type: TimeType, TypeConformity: 1}, report.Columns[3])
+	assert.Len(t, report.Samples, 1)
+	assert.Equal(t, 5, report.Samples[0].Line)
+}
+
+func TestQualityWithoutHeader(t *testing.T) {
+	config := GetDefaultConfig()
+	config.HeaderConfig.HasHeader = false
+	p := NewProcessor(strings.NewReader("1,a\n2,b\n3\n4.5,d\n"), &config)
+
+	report, err := p.Quality(QualityConfig{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, report.ExpectedFields)
+	assert.Equal(t, 1, report.RowLengthAnomalies)
+	assert.Equal(t, "col_1", report.Columns[0].Name)
+	assert.Equal(t, FloatType, report.Columns[0].Type)
+	assert.Equal(t, 0.75, report.Columns[1].FillRate)
+}
+
+func TestQualityOutput(t *testing.T) {
+	p := NewProcessor(strings.NewReader(contacts), nil)
+	report, err := p.Quality(QualityConfig{Rules: Rules{{Column: "email", Check: "regex(@)"}}})
+	assert.Nil(t, err)
+
+	out := &bytes.Buffer{}
+	assert.Nil(t, report.WriteJSON(out))
+	decoded := QualityReport{}
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, report.Columns, decoded.Columns)
+
+	out.Reset()
+	assert.Nil(t, report.WriteHTML(out))
+	assert.Contains(t, out.String(), "<td>email</td><td>80%</td>")
+	assert.Contains(t, out.String(), "line 5, email: value does not match pattern (&#34;dan&#34;)")
+}
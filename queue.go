@@ -0,0 +1,51 @@
+package parallel_csv
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// QueueSample is the number of blocks waiting for a worker at some point of a run
+type QueueSample struct {
+	// At is the time elapsed since the start of the run
+	At    time.Duration
+	Depth int
+}
+
+// observeQueue updates the high-water mark of the queue with its current depth
+func (c *counters) observeQueue(depth int) {
+	for {
+		high := atomic.LoadInt64(&c.queueHighWater)
+		if int64(depth) <= high || atomic.CompareAndSwapInt64(&c.queueHighWater, high, int64(depth)) {
+			return
+		}
+	}
+}
+
+func (c *counters) addQueueSample(sample QueueSample) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queueSamples = append(c.queueSamples, sample)
+}
+
+func (c *counters) queueSampleList() []QueueSample {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]QueueSample(nil), c.queueSamples...)
+}
+
+// sampleQueue records the depth of the queue of blocks every Config.QueueSampleInterval until
+// stop is closed
+func (p processor) sampleQueue(stop chan struct{}) {
+	start := time.Now()
+	ticker := time.NewTicker(p.config.QueueSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.counters.addQueueSample(QueueSample{At: time.Since(start), Depth: len(p.blocks)})
+		case <-stop:
+			return
+		}
+	}
+}
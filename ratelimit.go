@@ -0,0 +1,60 @@
+package parallel_csv
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket shared by the workers, holding at most one second of tokens.
+// Takers may overdraw it, waiting until the debt is paid back, so that chunks larger than the
+// bucket still go through
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(perSecond int) *rateLimiter {
+	return &rateLimiter{
+		rate:   float64(perSecond),
+		tokens: float64(perSecond),
+		last:   time.Now(),
+	}
+}
+
+// reserve takes n tokens and returns how long the caller has to wait before using them
+func (l *rateLimiter) reserve(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// wait blocks until n tokens are available, it returns false if the run is aborted meanwhile
+func (l *rateLimiter) wait(n int, abort <-chan struct{}) bool {
+	delay := l.reserve(n)
+	if delay == 0 {
+		return true
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-abort:
+		return false
+	}
+}
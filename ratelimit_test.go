@@ -0,0 +1,50 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	limiter := newRateLimiter(100)
+	assert.Equal(t, time.Duration(0), limiter.reserve(60))
+	assert.Equal(t, time.Duration(0), limiter.reserve(40))
+
+	// the bucket is empty, 50 rows take half a second
+	delay := limiter.reserve(50)
+	assert.InDelta(t, 500*time.Millisecond, delay, float64(20*time.Millisecond))
+}
+
+func TestMaxRowsPerSecond(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 64
+	config.MaxRowsPerSecond = 1000
+	p := NewProcessor(strings.NewReader(numbers(1300)), &config)
+
+	rows := int64(0)
+	start := time.Now()
+	err := p.Run(func(header []string, chunk []string) {
+		atomic.AddInt64(&rows, int64(len(chunk)))
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1300), rows)
+	// the first second of rows goes through at once, the other 300 rows take 300ms
+	assert.GreaterOrEqual(t, time.Since(start), 250*time.Millisecond)
+}
+
+func TestMaxRowsPerSecondAbort(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 64
+	config.MaxRowsPerSecond = 50
+	p := NewProcessor(strings.NewReader(numbers(1000)), &config)
+
+	start := time.Now()
+	err := p.RunChunks(func(chunk Chunk) error {
+		return FieldCountError
+	})
+	assert.ErrorIs(t, err, FieldCountError)
+	assert.Less(t, time.Since(start), time.Second)
+}
@@ -0,0 +1,170 @@
+package parallel_csv
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"sync"
+)
+
+// Reader reads records like an encoding/csv Reader, parsing them in parallel behind the scenes.
+// Records are returned in source order. Like encoding/csv, the first line is a record unless
+// Config says the input has a header. Quoted fields cannot span several lines
+type Reader struct {
+	// Comma is the field delimiter, ',' by default. It is ignored when Config is set
+	Comma rune
+	// FieldsPerRecord is the number of fields of every record when positive. When 0 it is set to
+	// the number of fields of the first record, when negative the records may differ
+	FieldsPerRecord int
+	// Config is the configuration of the processor, the default one without header if nil
+	Config *Config
+
+	source  io.Reader
+	started bool
+	batches chan readerBatch
+	done    chan struct{}
+	once    sync.Once
+	// batch holds the records not returned yet
+	batch readerBatch
+	err   error
+
+	mu      sync.Mutex
+	next    int
+	pending map[int]readerBatch
+}
+
+// readerBatch holds the records of a chunk along with their line numbers
+type readerBatch struct {
+	records [][]string
+	lines   []int
+}
+
+// NewReader returns a Reader reading from r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{Comma: ',', source: r}
+}
+
+// start runs the processor in the background
+func (r *Reader) start() {
+	r.started = true
+	r.batches = make(chan readerBatch, 1)
+	r.done = make(chan struct{})
+	r.pending = map[int]readerBatch{}
+
+	config := GetDefaultConfig()
+	config.HeaderConfig = HeaderConfig{HasHeader: false, Separator: string(r.Comma)}
+	if r.Config != nil {
+		config = *r.Config
+	}
+	p, err := newProcessor(r.source, &config)
+	if err != nil {
+		r.err = err
+		close(r.batches)
+		return
+	}
+
+	if len(p.header) > 0 {
+		r.batch = readerBatch{records: [][]string{p.header}, lines: []int{1}}
+	}
+	go func() {
+		err := p.RunChunks(func(chunk Chunk) error {
+			batch := readerBatch{records: make([][]string, len(chunk.Rows)), lines: make([]int, len(chunk.Rows))}
+			for i, row := range chunk.Rows {
+				if chunk.Cancelled() {
+					return errCancelled
+				}
+				batch.records[i] = p.split(row)
+				if config.ReuseBuffers {
+					batch.records[i] = cloneFields(batch.records[i])
+				}
+				batch.lines[i] = chunk.Line(i)
+			}
+			return r.deliver(chunk.Index, batch)
+		})
+		if errors.Is(err, EmptyFileError) {
+			err = nil
+		}
+		r.err = err
+		close(r.batches)
+	}()
+}
+
+// deliver hands over the records of chunk index, sending the batches in order
+func (r *Reader) deliver(index int, batch readerBatch) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pending[index] = batch
+	for {
+		batch, ok := r.pending[r.next]
+		if !ok {
+			return nil
+		}
+		delete(r.pending, r.next)
+		r.next++
+
+		select {
+		case r.batches <- batch:
+		case <-r.done:
+			return errStopRun
+		}
+	}
+}
+
+// Read returns the next record, or io.EOF after the last one. A record with a wrong number of
+// fields is returned along with a *csv.ParseError wrapping csv.ErrFieldCount
+func (r *Reader) Read() ([]string, error) {
+	if !r.started {
+		r.start()
+	}
+
+	for len(r.batch.records) == 0 {
+		batch, ok := <-r.batches
+		if !ok {
+			if r.err != nil {
+				return nil, r.err
+			}
+			return nil, io.EOF
+		}
+		r.batch = batch
+	}
+
+	record, line := r.batch.records[0], r.batch.lines[0]
+	r.batch.records, r.batch.lines = r.batch.records[1:], r.batch.lines[1:]
+	if r.FieldsPerRecord == 0 {
+		r.FieldsPerRecord = len(record)
+	}
+	if r.FieldsPerRecord > 0 && len(record) != r.FieldsPerRecord {
+		return record, &csv.ParseError{StartLine: line, Line: line, Column: 1, Err: csv.ErrFieldCount}
+	}
+	return record, nil
+}
+
+// ReadAll reads the remaining records
+func (r *Reader) ReadAll() ([][]string, error) {
+	var records [][]string
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+}
+
+// Close stops the parsing of the rest of the input when the reader is abandoned before io.EOF
+func (r *Reader) Close() error {
+	if !r.started {
+		return nil
+	}
+	r.once.Do(func() {
+		close(r.done)
+		// the workers may be waiting to send their batches
+		for range r.batches {
+		}
+	})
+	return nil
+}
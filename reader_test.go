@@ -0,0 +1,65 @@
+package parallel_csv
+
+import (
+	"encoding/csv"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReaderMatchesEncodingCSV(t *testing.T) {
+	input := "name,age\n\"Rossi, Mario\",42\n\"say \"\"hi\"\"\",\n" + numbers(1000)[2:]
+
+	std := csv.NewReader(strings.NewReader(input))
+	std.FieldsPerRecord = -1
+	expected, err := std.ReadAll()
+	assert.Nil(t, err)
+
+	r := NewReader(strings.NewReader(input))
+	r.FieldsPerRecord = -1
+	r.Config = &Config{NumberOfWorkers: 4, BytesPerWorker: 64, HeaderConfig: HeaderConfig{Separator: ","}}
+	records, err := r.ReadAll()
+	assert.Nil(t, err)
+	assert.Equal(t, expected, records)
+}
+
+func TestReaderRead(t *testing.T) {
+	r := NewReader(strings.NewReader("a;b\nc;d\n"))
+	r.Comma = ';'
+
+	record, err := r.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, record)
+	record, err = r.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"c", "d"}, record)
+	_, err = r.Read()
+	assert.Equal(t, io.EOF, err)
+
+	_, err = NewReader(strings.NewReader("")).Read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReaderFieldCount(t *testing.T) {
+	r := NewReader(strings.NewReader("a,b\nc,d\ne\n"))
+	records, err := r.ReadAll()
+	assert.Nil(t, records)
+
+	parseErr := &csv.ParseError{}
+	assert.ErrorAs(t, err, &parseErr)
+	assert.ErrorIs(t, err, csv.ErrFieldCount)
+	assert.Equal(t, 3, parseErr.Line)
+}
+
+func TestReaderClose(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 64
+	r := NewReader(strings.NewReader(numbers(100000)))
+	r.Config = &config
+
+	record, err := r.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"n"}, record)
+	assert.Nil(t, r.Close())
+}
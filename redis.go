@@ -0,0 +1,117 @@
+package parallel_csv
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// DefaultRedisBatch is the number of entries of a pipeline of RedisStreamSink when not set
+const DefaultRedisBatch = 1000
+
+// RedisStreams appends entries to a stream with ids generated by the server, sending them in a
+// single pipeline. Each entry holds the name of a field followed by its value, both strings.
+// maxLen, when positive, approximately trims the stream to that many entries. With go-redis,
+// whose clients are safe for concurrent use:
+//
+//	type redisStreams struct{ *redis.Client }
+//
+//	func (c redisStreams) XAdd(ctx context.Context, stream string, maxLen int64, entries [][]interface{}) error {
+//		pipe := c.Pipeline()
+//		for _, values := range entries {
+//			pipe.XAdd(ctx, &redis.XAddArgs{Stream: stream, MaxLen: maxLen, Approx: true, Values: values})
+//		}
+//		_, err := pipe.Exec(ctx)
+//		return err
+//	}
+type RedisStreams interface {
+	XAdd(ctx context.Context, stream string, maxLen int64, entries [][]interface{}) error
+}
+
+// RedisStreamSink adds each row to a Redis stream as an entry mapping the columns to the fields.
+// Rows are sent in pipelines of BatchRows entries by several goroutines at once, so that entries
+// are not in source order when Pipelines is more than 1. Files without header have columns
+// col_1, col_2 and so on
+type RedisStreamSink struct {
+	Stream string
+	// MaxLen approximately caps the length of the stream, 0 for no limit
+	MaxLen int64
+	// OmitEmpty leaves the empty fields out of the entries
+	OmitEmpty bool
+	// BatchRows is the number of entries of a pipeline, DefaultRedisBatch if 0
+	BatchRows int
+	// Pipelines is the number of pipelines sent at once, 1 if 0
+	Pipelines int
+	ctx       context.Context
+	client    RedisStreams
+	header    []string
+	loader    *parallelLoader
+	rows      int64
+}
+
+// NewRedisStreamSink creates a sink adding the rows to stream with client
+func NewRedisStreamSink(ctx context.Context, client RedisStreams, stream string) *RedisStreamSink {
+	return &RedisStreamSink{Stream: stream, ctx: ctx, client: client}
+}
+
+func (s *RedisStreamSink) Open(header []string) error {
+	if s.BatchRows <= 0 {
+		s.BatchRows = DefaultRedisBatch
+	}
+	pipelines := s.Pipelines
+	if pipelines <= 0 {
+		pipelines = 1
+	}
+	s.header = header
+	s.loader = newParallelLoader(pipelines, s.BatchRows, s.send)
+	return nil
+}
+
+func (s *RedisStreamSink) Write(rows [][]string) error {
+	for _, row := range rows {
+		if len(s.header) > 0 && len(row) != len(s.header) {
+			return fmt.Errorf("%w: %d fields for %d columns", FieldCountError, len(row), len(s.header))
+		}
+
+		entry := make([]interface{}, 0, 2*len(row))
+		for i, field := range row {
+			if s.OmitEmpty && field == "" {
+				continue
+			}
+			name := columnName(i)
+			if len(s.header) > 0 {
+				name = s.header[i]
+			}
+			entry = append(entry, name, cloneString(field))
+		}
+		// streams cannot hold entries without fields
+		if len(entry) == 0 {
+			continue
+		}
+		if err := s.loader.add(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close sends the last pipeline and waits for every pipeline to end, the client is left open
+func (s *RedisStreamSink) Close() error {
+	if s.loader == nil {
+		return nil
+	}
+	return s.loader.close()
+}
+
+// Rows returns the number of entries added so far
+func (s *RedisStreamSink) Rows() int64 {
+	return atomic.LoadInt64(&s.rows)
+}
+
+func (s *RedisStreamSink) send(worker int, entries [][]interface{}) error {
+	if err := s.client.XAdd(s.ctx, s.Stream, s.MaxLen, entries); err != nil {
+		return fmt.Errorf("add to %s: %w", s.Stream, err)
+	}
+	atomic.AddInt64(&s.rows, int64(len(entries)))
+	return nil
+}
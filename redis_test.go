@@ -0,0 +1,74 @@
+package parallel_csv
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRedis keeps the pipelines sent to each stream, failing the ones once fail is set
+type fakeRedis struct {
+	mu        sync.Mutex
+	pipelines map[string][][][]interface{}
+	maxLen    int64
+	fail      bool
+}
+
+func (r *fakeRedis) XAdd(ctx context.Context, stream string, maxLen int64, entries [][]interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail {
+		return errors.New("OOM command not allowed")
+	}
+	if r.pipelines == nil {
+		r.pipelines = map[string][][][]interface{}{}
+	}
+	r.pipelines[stream] = append(r.pipelines[stream], entries)
+	r.maxLen = maxLen
+	return nil
+}
+
+func TestRedisStreamSink(t *testing.T) {
+	client := &fakeRedis{}
+	sink := NewRedisStreamSink(context.Background(), client, "numbers")
+	sink.BatchRows = 10
+	sink.Pipelines = 4
+	sink.MaxLen = 1000
+
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 64
+	assert.Nil(t, NewProcessor(strings.NewReader(numbers(95)), &config).Copy(sink))
+	assert.Equal(t, int64(95), sink.Rows())
+	assert.Len(t, client.pipelines["numbers"], 10)
+	assert.Equal(t, int64(1000), client.maxLen)
+
+	total := 0
+	for _, pipeline := range client.pipelines["numbers"] {
+		total += len(pipeline)
+	}
+	assert.Equal(t, 95, total)
+}
+
+func TestRedisStreamSinkEntries(t *testing.T) {
+	client := &fakeRedis{}
+	sink := NewRedisStreamSink(context.Background(), client, "people")
+	sink.OmitEmpty = true
+
+	input := "name,country\nMario,IT\nLuigi,\n,\n"
+	assert.Nil(t, NewProcessor(strings.NewReader(input), nil).Copy(sink))
+	assert.Equal(t, [][]interface{}{{"name", "Mario", "country", "IT"}, {"name", "Luigi"}}, client.pipelines["people"][0])
+
+	config := GetDefaultConfig()
+	config.HeaderConfig.HasHeader = false
+	sink = NewRedisStreamSink(context.Background(), client, "raw")
+	assert.Nil(t, NewProcessor(strings.NewReader("a,b\n"), &config).Copy(sink))
+	assert.Equal(t, [][]interface{}{{"col_1", "a", "col_2", "b"}}, client.pipelines["raw"][0])
+
+	client.fail = true
+	err := NewProcessor(strings.NewReader(numbers(10)), nil).Copy(NewRedisStreamSink(context.Background(), client, "numbers"))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "OOM")
+}
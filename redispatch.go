@@ -0,0 +1,46 @@
+package parallel_csv
+
+import "sync/atomic"
+
+// next returns the block a worker processes next: a redispatched chunk if any, otherwise the
+// next block read. It returns false once every block has been read and no chunk waits to run
+// again
+func (s *runState) next(blocks chan workerData) (workerData, bool) {
+	select {
+	case data := <-s.redispatched:
+		return data, true
+	default:
+	}
+
+	select {
+	case data, ok := <-blocks:
+		if ok {
+			return data, true
+		}
+	case data := <-s.redispatched:
+		return data, true
+	}
+
+	// the worker redispatching a chunk after the last block asks for one more, so that chunk is
+	// always taken by some worker
+	select {
+	case data := <-s.redispatched:
+		return data, true
+	default:
+		return workerData{}, false
+	}
+}
+
+// redispatch hands the chunk whose job failed with err back to the workers. The chunk keeps its
+// buffer until it runs again
+func (p processor) redispatch(state *runState, data workerData, chunk Chunk, err error) {
+	data.attempts++
+	data.prepared = &chunk
+	data.buffer.retain()
+	atomic.AddInt64(&p.counters.redispatched, 1)
+	p.config.log(p.config.LogLevels.Retry, LogWarn, "chunk redispatched", "index", chunk.Index,
+		"worker", chunk.Worker, "attempt", data.attempts, "error", err)
+
+	// workers take redispatched chunks first, so there are never more waiting than workers
+	state.redispatched <- data
+}
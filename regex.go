@@ -0,0 +1,71 @@
+package parallel_csv
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// RegexFilter keeps the rows whose column matches Pattern, or whose raw line does when Column is
+// empty. Invert keeps the rows not matching instead, like grep -v. Raw lines are matched as they
+// appear in the input, quotes included
+type RegexFilter struct {
+	Column  string
+	Pattern *regexp.Regexp
+	Invert  bool
+}
+
+// FilterRegex compiles a filter matching pattern against a column, against the raw line when
+// column is empty
+func FilterRegex(column string, pattern string) (RegexFilter, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return RegexFilter{}, err
+	}
+	return RegexFilter{Column: column, Pattern: re}, nil
+}
+
+// boundRegex is a RegexFilter whose column has been resolved to a field index, -1 for raw lines
+type boundRegex struct {
+	index   int
+	pattern *regexp.Regexp
+	invert  bool
+}
+
+// bindRegexps resolves the columns of the filters against the header
+func (p processor) bindRegexps(filters []RegexFilter) ([]boundRegex, error) {
+	bound := make([]boundRegex, len(filters))
+	for i, filter := range filters {
+		if filter.Pattern == nil {
+			return nil, fmt.Errorf("regex filter %d has no pattern", i)
+		}
+		bound[i] = boundRegex{index: -1, pattern: filter.Pattern, invert: filter.Invert}
+		if filter.Column != "" {
+			if bound[i].index = p.ColumnIndex(filter.Column); bound[i].index == -1 {
+				return nil, fmt.Errorf("%w: %s", ColumnNotFoundError, filter.Column)
+			}
+		}
+	}
+	return bound, nil
+}
+
+// matchRegexps tells whether a row satisfies every filter. The row is split only if a filter
+// matches a column
+func (p processor) matchRegexps(filters []boundRegex, row string) bool {
+	var fields []string
+	for _, filter := range filters {
+		value := row
+		if filter.index >= 0 {
+			if fields == nil {
+				fields = p.split(row)
+			}
+			value = ""
+			if filter.index < len(fields) {
+				value = fields[filter.index]
+			}
+		}
+		if filter.pattern.MatchString(value) == filter.invert {
+			return false
+		}
+	}
+	return true
+}
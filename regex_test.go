@@ -0,0 +1,41 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRegexps(t *testing.T) {
+	name, err := FilterRegex("name", "^(anna|bob)")
+	assert.Nil(t, err)
+	line, err := FilterRegex("", `"`)
+	assert.Nil(t, err)
+
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 8
+	config.Regexps = []RegexFilter{name, {Pattern: line.Pattern, Invert: true}}
+	p := NewProcessor(strings.NewReader(customers), &config)
+
+	sink := &memorySink{}
+	assert.Nil(t, p.Copy(sink))
+	assert.Equal(t, [][]string{{"c1", "anna", "IT"}}, sink.rows)
+	stats := p.Stats()
+	assert.Equal(t, int64(1), stats.RowsMatched)
+	assert.Equal(t, int64(2), stats.RowsFiltered)
+	assert.Nil(t, stats.reconcile(int64(len("id,name,country\n"))))
+}
+
+func TestRegexpsErrors(t *testing.T) {
+	_, err := FilterRegex("name", "(")
+	assert.NotNil(t, err)
+
+	config := GetDefaultConfig()
+	config.Regexps = []RegexFilter{{Column: "missing", Pattern: regexp.MustCompile("a")}}
+	err = NewProcessor(strings.NewReader(customers), &config).Run(func([]string, []string) {})
+	assert.ErrorIs(t, err, ColumnNotFoundError)
+
+	_, err = From(strings.NewReader(customers)).FilterRegex("", "[").Build()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,129 @@
+package parallel_csv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// DefaultRemoteRetry is the retry policy of RemoteReader when not set
+var DefaultRemoteRetry = RetryPolicy{Attempts: 5, Backoff: time.Second, MaxBackoff: 30 * time.Second}
+
+// RemoteClient reads files from a server, such as an SFTP or FTP one. With pkg/sftp, Open dials
+// the server, opens the file and seeks it to offset, Size returns the size of its Stat. With
+// jlaffaye/ftp, Open dials the server and calls ServerConn.RetrFrom, Size calls FileSize
+type RemoteClient interface {
+	// Open returns the content of the file from offset, closing it ends the connection
+	Open(ctx context.Context, path string, offset int64) (io.ReadCloser, error)
+	Size(ctx context.Context, path string) (int64, error)
+}
+
+// RemoteReader streams a file from a server into a processor. When the connection drops, the
+// file is opened again where reading stopped, as many times as Retry allows in a row. It is an
+// io.Seeker, so that ResumeFrom skips the rows already processed without reading them
+//
+//	r := NewRemoteReader(ctx, client, "/outbound/orders.csv")
+//	defer r.Close()
+//	err := NewProcessor(r, config).Copy(sink)
+type RemoteReader struct {
+	// Retry reopens the file after a failure, DefaultRemoteRetry if it has no attempts
+	Retry      RetryPolicy
+	ctx        context.Context
+	client     RemoteClient
+	path       string
+	body       io.ReadCloser
+	offset     int64
+	reconnects int
+}
+
+// NewRemoteReader creates a reader of the file at path on the server of client
+func NewRemoteReader(ctx context.Context, client RemoteClient, path string) *RemoteReader {
+	return &RemoteReader{ctx: ctx, client: client, path: path}
+}
+
+func (r *RemoteReader) Read(b []byte) (int, error) {
+	retry := r.Retry
+	if retry.Attempts == 0 {
+		retry = DefaultRemoteRetry
+	}
+
+	n, eof := 0, false
+	err := retry.do(r.ctx.Done(), r.reconnected, func() (err error) {
+		if r.body == nil {
+			body, err := r.client.Open(r.ctx, r.path, r.offset)
+			if err != nil {
+				return err
+			}
+			r.body = body
+		}
+
+		n, err = r.body.Read(b)
+		r.offset += int64(n)
+		if errors.Is(err, io.EOF) {
+			eof = true
+			return nil
+		}
+		if err != nil {
+			// the connection is gone, the next read opens the file where this one stopped
+			r.body.Close()
+			r.body = nil
+			if n > 0 {
+				return nil
+			}
+		}
+		return err
+	})
+
+	if err != nil {
+		return n, fmt.Errorf("read %s at byte %d: %w", r.path, r.offset, err)
+	}
+	if eof {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (r *RemoteReader) reconnected(int, error) {
+	r.reconnects++
+}
+
+// Seek moves to offset, the file being opened there by the next read
+func (r *RemoteReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		size, err := r.client.Size(r.ctx, r.path)
+		if err != nil {
+			return r.offset, err
+		}
+		offset += size
+	}
+	if offset < 0 {
+		return r.offset, fmt.Errorf("seek %s: negative offset %d", r.path, offset)
+	}
+
+	if offset != r.offset && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// Reconnects returns the number of times the file has been opened again after a failure
+func (r *RemoteReader) Reconnects() int {
+	return r.reconnects
+}
+
+// Close ends the connection, if any
+func (r *RemoteReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
@@ -0,0 +1,74 @@
+package parallel_csv
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServer serves content, dropping the connections after drop bytes for the first drops opens
+type fakeServer struct {
+	content string
+	drop    int
+	drops   int
+	opens   []int64
+}
+
+func (s *fakeServer) Open(ctx context.Context, path string, offset int64) (io.ReadCloser, error) {
+	s.opens = append(s.opens, offset)
+	var r io.Reader = strings.NewReader(s.content[offset:])
+	if s.drops > 0 {
+		s.drops--
+		r = io.MultiReader(io.LimitReader(r, int64(s.drop)), &failingReader{})
+	}
+	return io.NopCloser(r), nil
+}
+
+func (s *fakeServer) Size(ctx context.Context, path string) (int64, error) {
+	return int64(len(s.content)), nil
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset by peer")
+}
+
+func TestRemoteReader(t *testing.T) {
+	server := &fakeServer{content: numbers(10000), drop: 5000, drops: 3}
+	r := NewRemoteReader(context.Background(), server, "/feed.csv")
+	r.Retry = RetryPolicy{Attempts: 2, Backoff: time.Millisecond}
+
+	sink := &memorySink{}
+	assert.Nil(t, NewProcessor(r, nil).Copy(sink))
+	assert.Len(t, sink.rows, 10000)
+	assert.Equal(t, "10000", sink.rows[9999][0])
+	assert.Equal(t, 3, r.Reconnects())
+	assert.Equal(t, []int64{0, 5000, 10000, 15000}, server.opens)
+	assert.Nil(t, r.Close())
+
+	// the file is never available
+	server = &fakeServer{content: numbers(10), drop: 0, drops: 10}
+	r = NewRemoteReader(context.Background(), server, "/feed.csv")
+	r.Retry = RetryPolicy{Attempts: 3}
+	_, err := io.ReadAll(r)
+	assert.NotNil(t, err)
+	assert.Len(t, server.opens, 3)
+}
+
+func TestRemoteReaderSeek(t *testing.T) {
+	server := &fakeServer{content: "n\n1\n2\n3\n"}
+	r := NewRemoteReader(context.Background(), server, "/feed.csv")
+
+	position, err := r.Seek(-4, io.SeekEnd)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), position)
+	content, err := io.ReadAll(r)
+	assert.Nil(t, err)
+	assert.Equal(t, "2\n3\n", string(content))
+	assert.Equal(t, []int64{4}, server.opens)
+}
@@ -0,0 +1,76 @@
+package parallel_csv
+
+import (
+	"errors"
+	"time"
+)
+
+// RetryPolicy retries the operations failing with transient errors, waiting longer after each
+// failure. The zero value does not retry
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts, the first one included
+	Attempts int
+	// Backoff is the delay before the first retry, doubled before each of the following ones
+	Backoff time.Duration
+	// MaxBackoff caps the delay between two attempts, 0 means no cap
+	MaxBackoff time.Duration
+	// Retryable tells whether an error is transient. When nil every error is, except panics
+	Retryable func(err error) bool
+}
+
+// permanentError is an error which must not be retried, such as one already retried
+type permanentError struct {
+	error
+}
+
+func (e permanentError) Unwrap() error {
+	return e.error
+}
+
+// retryable tells whether err is worth another attempt. Early stops and panics never are
+func (r RetryPolicy) retryable(err error) bool {
+	var permanent permanentError
+	if err == nil || errors.Is(err, errStopRun) || errors.Is(err, errCancelled) || errors.As(err, &permanent) {
+		return false
+	}
+	if r.Retryable != nil {
+		return r.Retryable(err)
+	}
+	var panicErr *PanicError
+	return !errors.As(err, &panicErr)
+}
+
+// delay returns the time to wait before retry number n, starting from 1
+func (r RetryPolicy) delay(n int) time.Duration {
+	delay := r.Backoff
+	for i := 1; i < n; i++ {
+		delay *= 2
+		if r.MaxBackoff > 0 && delay >= r.MaxBackoff {
+			break
+		}
+	}
+	if r.MaxBackoff > 0 && delay > r.MaxBackoff {
+		delay = r.MaxBackoff
+	}
+	return delay
+}
+
+// do runs fn until it succeeds, fails with an error which is not retryable or runs out of
+// attempts, calling retried with the number of the attempt and the previous error before each
+// retry. It stops waiting and returns the last error when abort is closed
+func (r RetryPolicy) do(abort <-chan struct{}, retried func(attempt int, err error), fn func() error) error {
+	err := fn()
+	for attempt := 1; attempt < r.Attempts && r.retryable(err); attempt++ {
+		timer := time.NewTimer(r.delay(attempt))
+		select {
+		case <-timer.C:
+		case <-abort:
+			timer.Stop()
+			return err
+		}
+
+		retried(attempt+1, err)
+		err = fn()
+	}
+	return err
+}
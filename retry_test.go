@@ -0,0 +1,184 @@
+package parallel_csv
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errTransient = errors.New("connection reset")
+
+func TestRetryDelay(t *testing.T) {
+	retry := RetryPolicy{Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	assert.Equal(t, 10*time.Millisecond, retry.delay(1))
+	assert.Equal(t, 20*time.Millisecond, retry.delay(2))
+	assert.Equal(t, 40*time.Millisecond, retry.delay(3))
+	assert.Equal(t, 50*time.Millisecond, retry.delay(4))
+	assert.Equal(t, 50*time.Millisecond, retry.delay(100))
+}
+
+func TestRetryJobs(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 64
+	config.Retry = RetryPolicy{Attempts: 3, Backoff: time.Millisecond}
+	p := NewProcessor(strings.NewReader(numbers(100)), &config)
+
+	mu := sync.Mutex{}
+	failures := map[int]int{}
+	rows := 0
+	err := p.RunChunks(func(chunk Chunk) error {
+		mu.Lock()
+		defer mu.Unlock()
+		// the first chunk fails twice
+		if chunk.Index == 0 && failures[0] < 2 {
+			failures[0]++
+			return errTransient
+		}
+		rows += len(chunk.Rows)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 100, rows)
+	assert.Equal(t, int64(2), p.Stats().Retries)
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	config := GetDefaultConfig()
+	config.Retry = RetryPolicy{
+		Attempts: 5,
+		Retryable: func(err error) bool {
+			return errors.Is(err, errTransient)
+		},
+	}
+
+	attempts := 0
+	p := NewProcessor(strings.NewReader(numbers(10)), &config)
+	err := p.RunChunks(func(chunk Chunk) error {
+		attempts++
+		return errTransient
+	})
+	assert.ErrorIs(t, err, errTransient)
+	assert.Equal(t, 5, attempts)
+
+	attempts = 0
+	p = NewProcessor(strings.NewReader(numbers(10)), &config)
+	err = p.RunChunks(func(chunk Chunk) error {
+		attempts++
+		return FieldCountError
+	})
+	assert.ErrorIs(t, err, FieldCountError)
+	assert.Equal(t, 1, attempts)
+}
+
+// flakySink fails the first writes
+type flakySink struct {
+	memorySink
+	failures int
+}
+
+func (s *flakySink) Write(rows [][]string) error {
+	if s.failures > 0 {
+		s.failures--
+		return errTransient
+	}
+	return s.memorySink.Write(rows)
+}
+
+func TestRetrySinkWrites(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 64
+	config.Retry = RetryPolicy{Attempts: 3}
+	p := NewProcessor(strings.NewReader(numbers(100)), &config)
+
+	sink := &flakySink{failures: 2}
+	assert.Nil(t, p.Copy(sink))
+	assert.Len(t, sink.rows, 100)
+	assert.Equal(t, "1", sink.rows[0][0])
+	assert.Equal(t, int64(2), p.Stats().Retries)
+
+	p = NewProcessor(strings.NewReader(numbers(100)), &config)
+	sink = &flakySink{failures: 3}
+	assert.ErrorIs(t, p.Copy(sink), errTransient)
+}
+
+func TestRedispatchChunks(t *testing.T) {
+	config := GetDefaultConfig()
+	config.NumberOfWorkers = 4
+	config.BytesPerWorker = 64
+	config.Idempotent, config.Redispatch = true, 2
+	p := NewProcessor(strings.NewReader(numbers(100)), &config)
+
+	mu := sync.Mutex{}
+	failures := 0
+	rows := 0
+	err := p.RunChunks(func(chunk Chunk) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if chunk.Index == 0 && failures < 2 {
+			failures++
+			return errTransient
+		}
+		rows += len(chunk.Rows)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 100, rows)
+	stats := p.Stats()
+	assert.Equal(t, int64(2), stats.Redispatched)
+	assert.Equal(t, int64(100), stats.RowsDelivered)
+	assert.Nil(t, stats.reconcile(p.(*processor).headerBytes))
+}
+
+func TestRedispatchGivesUp(t *testing.T) {
+	config := GetDefaultConfig()
+	config.Idempotent, config.Redispatch = true, 1
+	config.Retry = RetryPolicy{Attempts: 2}
+
+	var attempts int32
+	p := NewProcessor(strings.NewReader(numbers(10)), &config)
+	err := p.RunChunks(func(chunk Chunk) error {
+		atomic.AddInt32(&attempts, 1)
+		return errTransient
+	})
+	assert.ErrorIs(t, err, errTransient)
+	// each dispatch is retried
+	assert.Equal(t, int32(4), attempts)
+	assert.Equal(t, int64(1), p.Stats().Redispatched)
+}
+
+// flakyTransform fails once on the row holding value
+type flakyTransform struct {
+	value  string
+	failed *int32
+}
+
+func (f flakyTransform) Bind(header []string) ([]string, RowFunc, error) {
+	return header, func(fields []string) ([]string, error) {
+		if fields[0] == f.value && atomic.CompareAndSwapInt32(f.failed, 0, 1) {
+			return nil, errTransient
+		}
+		return fields, nil
+	}, nil
+}
+
+func TestRedispatchCopy(t *testing.T) {
+	config := GetDefaultConfig()
+	config.NumberOfWorkers = 4
+	config.BytesPerWorker = 64
+	config.Idempotent, config.Redispatch = true, 1
+	config.Transforms = []Transform{flakyTransform{value: "50", failed: new(int32)}}
+	p := NewProcessor(strings.NewReader(numbers(100)), &config)
+
+	sink := &memorySink{}
+	assert.Nil(t, p.Copy(sink))
+	assert.Len(t, sink.rows, 100)
+	for i, row := range sink.rows {
+		assert.Equal(t, strconv.Itoa(i+1), row[0])
+	}
+	assert.Equal(t, int64(1), p.Stats().Redispatched)
+}
@@ -0,0 +1,47 @@
+package parallel_csv
+
+import "strconv"
+
+// RowNumbering is the column of numbers prepended by Copy to the rows it writes
+type RowNumbering int
+
+const (
+	// NoRowNumber leaves the rows unchanged
+	NoRowNumber RowNumbering = iota
+	// RowNumberSequence numbers the rows written from 1, the rows dropped along the way not
+	// counting. The numbers are assigned as the rows are written, in source order
+	RowNumberSequence
+	// RowNumberLine holds the source line of each row, header included
+	RowNumberLine
+)
+
+// defaultColumn is the name of the column when Config.RowNumberColumn is empty
+func (r RowNumbering) defaultColumn() string {
+	if r == RowNumberLine {
+		return "line"
+	}
+	return "row_number"
+}
+
+// numberingSink fills the first field of the rows with their position among the rows written.
+// The rows of a sink are written in order and never concurrently
+type numberingSink struct {
+	Sink
+	written int64
+}
+
+func (s *numberingSink) Write(rows [][]string) error {
+	for i, row := range rows {
+		row[0] = strconv.FormatInt(s.written+int64(i)+1, 10)
+	}
+	// a failed write may be retried with the same numbers
+	if err := s.Sink.Write(rows); err != nil {
+		return err
+	}
+	s.written += int64(len(rows))
+	return nil
+}
+
+func (s *numberingSink) Abort(err error) {
+	abortSink(s.Sink, err)
+}
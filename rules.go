@@ -0,0 +1,233 @@
+package parallel_csv
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+const RuleSyntaxError = Error("invalid rule")
+const OutOfRangeError = Error("value is out of range")
+const EnumError = Error("value is not allowed")
+const LengthError = Error("value has the wrong length")
+
+// Rule is a constraint on the values of a column, written as name(arguments):
+//
+//	required()            the value cannot be empty
+//	regex(^[a-z]+$)       the value matches the regular expression, written as is
+//	range(0, 1e6)         the value is a number between the bounds, included
+//	enum(A, B, C)         the value is one of those listed
+//	length(1, 20)         the number of characters is between the bounds, included
+//	type(integer)         the value has the type, one of those of ColumnType. The layout of
+//	                      times can follow: type(time, 2006-01-02)
+//
+// Every rule but required accepts empty values. Columns of files without header are named
+// col_1, col_2 and so on
+type Rule struct {
+	Column string `json:"column" yaml:"column"`
+	Check  string `json:"check" yaml:"check"`
+}
+
+// Rules are checked on every row by ValidateRules
+type Rules []Rule
+
+// ParseRules reads rules from YAML, mapping each column to a rule or a list of rules:
+//
+//	email: regex(^[^@]+@[^@]+$)
+//	amount:
+//	  - required()
+//	  - range(0, 1e6)
+//	status: enum(A, B, C)
+func ParseRules(data []byte) (Rules, error) {
+	document := yaml.Node{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	if len(document.Content) == 0 {
+		return nil, nil
+	}
+
+	// a mapping keeps the columns in the order they are written
+	mapping := document.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%w: line %d: expected a mapping of columns to rules", RuleSyntaxError, mapping.Line)
+	}
+
+	var rules Rules
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		column, value := mapping.Content[i], mapping.Content[i+1]
+		var checks []string
+		switch value.Kind {
+		case yaml.ScalarNode:
+			checks = []string{value.Value}
+		case yaml.SequenceNode:
+			if err := value.Decode(&checks); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("%w: line %d: expected a rule or a list of rules", RuleSyntaxError, value.Line)
+		}
+
+		for _, check := range checks {
+			rules = append(rules, Rule{Column: column.Value, Check: check})
+		}
+	}
+	return rules, nil
+}
+
+// ValidateRules checks every row against the rules and reports the violations found. Rules are
+// compiled once, before reading the file
+func (p processor) ValidateRules(rules Rules) (*ValidationReport, error) {
+	checks := make([]columnCheck, len(rules))
+	for i, rule := range rules {
+		index := headerIndex(p.header, rule.Column)
+		if index == -1 {
+			return nil, fmt.Errorf("%w: %s", ColumnNotFoundError, rule.Column)
+		}
+
+		check, err := compileRule(rule.Check)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", rule.Column, err)
+		}
+		checks[i] = columnCheck{name: rule.Column, index: index, check: check}
+	}
+
+	return p.validate(checks)
+}
+
+// compileRule turns the text of a rule into the function checking a value
+func compileRule(rule string) (func(value string) error, error) {
+	rule = strings.TrimSpace(rule)
+	open := strings.IndexByte(rule, '(')
+	if open == -1 || !strings.HasSuffix(rule, ")") {
+		return nil, fmt.Errorf("%w: %q, expected name(arguments)", RuleSyntaxError, rule)
+	}
+	name, arguments := strings.TrimSpace(rule[:open]), rule[open+1:len(rule)-1]
+
+	var check func(value string) error
+	switch name {
+	case "required":
+		return func(value string) error {
+			if strings.TrimSpace(value) == "" {
+				return RequiredValueError
+			}
+			return nil
+		}, nil
+	case "regex":
+		pattern, err := regexp.Compile(arguments)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", RuleSyntaxError, err)
+		}
+		check = func(value string) error {
+			if !pattern.MatchString(value) {
+				return PatternMismatchError
+			}
+			return nil
+		}
+	case "range":
+		low, high, err := ruleBounds(rule, arguments, func(s string) (float64, error) {
+			return strconv.ParseFloat(s, 64)
+		})
+		if err != nil {
+			return nil, err
+		}
+		check = func(value string) error {
+			number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				return TypeMismatchError
+			}
+			if number < low || number > high {
+				return OutOfRangeError
+			}
+			return nil
+		}
+	case "length":
+		low, high, err := ruleBounds(rule, arguments, func(s string) (float64, error) {
+			n, err := strconv.Atoi(s)
+			return float64(n), err
+		})
+		if err != nil {
+			return nil, err
+		}
+		check = func(value string) error {
+			length := float64(utf8.RuneCountInString(strings.TrimSpace(value)))
+			if length < low || length > high {
+				return LengthError
+			}
+			return nil
+		}
+	case "enum":
+		allowed := map[string]bool{}
+		for _, value := range ruleArguments(arguments) {
+			allowed[value] = true
+		}
+		check = func(value string) error {
+			if !allowed[strings.TrimSpace(value)] {
+				return EnumError
+			}
+			return nil
+		}
+	case "type":
+		args := ruleArguments(arguments)
+		if len(args) == 0 || len(args) > 2 {
+			return nil, fmt.Errorf("%w: %q, expected a type and an optional layout", RuleSyntaxError, rule)
+		}
+		column := compiledColumn{ColumnSchema: ColumnSchema{Type: ColumnType(args[0])}}
+		switch column.Type {
+		case StringType, IntegerType, FloatType, BooleanType:
+		case TimeType:
+			column.Layout = time.RFC3339
+			if len(args) == 2 {
+				column.Layout = args[1]
+			}
+		default:
+			return nil, fmt.Errorf("%w: unknown type %q", RuleSyntaxError, args[0])
+		}
+		check = column.check
+	default:
+		return nil, fmt.Errorf("%w: unknown rule %q", RuleSyntaxError, name)
+	}
+
+	// empty values are only checked by required
+	return func(value string) error {
+		if strings.TrimSpace(value) == "" {
+			return nil
+		}
+		return check(value)
+	}, nil
+}
+
+// ruleArguments splits the comma separated arguments of a rule
+func ruleArguments(arguments string) []string {
+	if strings.TrimSpace(arguments) == "" {
+		return nil
+	}
+	args := strings.Split(arguments, ",")
+	for i := range args {
+		args[i] = strings.TrimSpace(args[i])
+	}
+	return args
+}
+
+// ruleBounds parses the two bounds of a rule
+func ruleBounds(rule string, arguments string, parse func(s string) (float64, error)) (float64, float64, error) {
+	args := ruleArguments(arguments)
+	if len(args) != 2 {
+		return 0, 0, fmt.Errorf("%w: %q, expected two bounds", RuleSyntaxError, rule)
+	}
+
+	low, err := parse(args[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %q, invalid bound %s", RuleSyntaxError, rule, args[0])
+	}
+	high, err := parse(args[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %q, invalid bound %s", RuleSyntaxError, rule, args[1])
+	}
+	return low, high, nil
+}
@@ -0,0 +1,85 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+const payments = "email,amount,status,day\nanna@example.com,10.5,A,2021-01-02\nbob,2000000,B,2021-01-03\n,,Z,02/01/2021\ncarla@example.com,abc,C,\n"
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules([]byte(`
+email: regex(^[^@]+@[^@]+$)
+amount:
+  - required()
+  - range(0, 1e6)
+status: enum(A, B, C)
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, Rules{
+		{Column: "email", Check: "regex(^[^@]+@[^@]+$)"},
+		{Column: "amount", Check: "required()"},
+		{Column: "amount", Check: "range(0, 1e6)"},
+		{Column: "status", Check: "enum(A, B, C)"},
+	}, rules)
+
+	_, err = ParseRules([]byte("- required()"))
+	assert.ErrorIs(t, err, RuleSyntaxError)
+}
+
+func TestValidateRules(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 16
+	p := NewProcessor(strings.NewReader(payments), &config)
+
+	report, err := p.ValidateRules(Rules{
+		{Column: "email", Check: "regex(^[^@]+@[^@]+$)"},
+		{Column: "amount", Check: "required()"},
+		{Column: "amount", Check: "range(0, 1e6)"},
+		{Column: "status", Check: "enum(A,B,C)"},
+		{Column: "day", Check: "type(time, 2006-01-02)"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, report.Rows)
+	assert.Equal(t, 3, report.InvalidRows)
+	assert.Equal(t, map[string]int{"email": 1, "amount": 3, "status": 1, "day": 1}, report.Violations)
+
+	errs := []error{}
+	for _, v := range report.Samples {
+		errs = append(errs, v.Err)
+	}
+	assert.Equal(t, []error{PatternMismatchError, OutOfRangeError, RequiredValueError, EnumError, TypeMismatchError, TypeMismatchError}, errs)
+}
+
+func TestCompileRule(t *testing.T) {
+	tests := []struct {
+		rule     string
+		value    string
+		expected error
+	}{
+		{"length(2, 3)", "abcd", LengthError},
+		{"length(2, 3)", " ab ", nil},
+		{"type(integer)", "1.5", TypeMismatchError},
+		{"type(boolean)", "true", nil},
+		{"regex(^a,b$)", "a,b", nil},
+		{"enum(x)", "", nil},
+	}
+
+	for _, test := range tests {
+		check, err := compileRule(test.rule)
+		assert.Nil(t, err, test.rule)
+		assert.Equal(t, test.expected, check(test.value), test.rule)
+	}
+
+	for _, rule := range []string{"unique()", "range(1)", "range(a, 2)", "type(date)", "regex([)", "required"} {
+		_, err := compileRule(rule)
+		assert.ErrorIs(t, err, RuleSyntaxError, rule)
+	}
+}
+
+func TestValidateRulesUnknownColumn(t *testing.T) {
+	p := NewProcessor(strings.NewReader(payments), nil)
+	_, err := p.ValidateRules(Rules{{Column: "iban", Check: "required()"}})
+	assert.ErrorIs(t, err, ColumnNotFoundError)
+}
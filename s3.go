@@ -0,0 +1,205 @@
+package parallel_csv
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const S3TooManyPartsError = Error("too many parts for an S3 multipart upload")
+
+const (
+	// DefaultS3PartBytes is the size of the parts uploaded by S3Sink when not set
+	DefaultS3PartBytes = 8 * MB
+	// DefaultS3Concurrency is the number of parts uploaded at once by S3Sink when not set
+	DefaultS3Concurrency = 4
+	// s3MaxParts is the largest number of parts of a multipart upload
+	s3MaxParts = 10000
+)
+
+// S3Client runs multipart uploads. With the AWS SDK for Go, the methods wrap the
+// CreateMultipartUpload, UploadPart, CompleteMultipartUpload and AbortMultipartUpload calls of
+// s3.Client
+type S3Client interface {
+	// CreateMultipartUpload starts an upload to the object, returning its id
+	CreateMultipartUpload(ctx context.Context, bucket string, key string) (uploadID string, err error)
+	// UploadPart uploads the part numbered number, from 1, returning its ETag. It is called by
+	// several goroutines at once
+	UploadPart(ctx context.Context, bucket string, key string, uploadID string, number int, body []byte) (etag string, err error)
+	// CompleteMultipartUpload creates the object out of the parts, sorted by number
+	CompleteMultipartUpload(ctx context.Context, bucket string, key string, uploadID string, parts []S3Part) error
+	// AbortMultipartUpload discards the parts uploaded
+	AbortMultipartUpload(ctx context.Context, bucket string, key string, uploadID string) error
+}
+
+// S3Part is a part of a multipart upload
+type S3Part struct {
+	Number int
+	ETag   string
+}
+
+// S3Sink writes rows as CSV straight to an S3 object, through a multipart upload. The output is
+// cut in parts of PartBytes, Concurrency of them being uploaded at once while the rows keep
+// coming, so that neither the whole output nor a local file is needed. The object is created once
+// the run succeeds, the upload is aborted if it fails
+type S3Sink struct {
+	*CSVSink
+	Bucket string
+	Key    string
+	// PartBytes is the size of the parts, DefaultS3PartBytes if 0. S3 wants at least 5MB but
+	// for the last part, and allows 10000 parts
+	PartBytes int
+	// Concurrency is the number of parts uploaded at once, DefaultS3Concurrency if 0
+	Concurrency int
+	ctx         context.Context
+	client      S3Client
+	uploadID    string
+	part        []byte
+	sent        int
+	queue       chan s3Upload
+	wg          sync.WaitGroup
+	// mu guards parts and err, updated by the uploads
+	mu    sync.Mutex
+	parts []S3Part
+	err   error
+}
+
+// NewS3Sink creates a sink uploading the rows to the object key of bucket through client, using
+// separator between fields
+func NewS3Sink(ctx context.Context, client S3Client, bucket string, key string, separator string) *S3Sink {
+	return &S3Sink{CSVSink: NewCSVSink(nil, separator), Bucket: bucket, Key: key, ctx: ctx, client: client}
+}
+
+func (s *S3Sink) Open(header []string) error {
+	if s.PartBytes <= 0 {
+		s.PartBytes = DefaultS3PartBytes
+	}
+	if s.Concurrency <= 0 {
+		s.Concurrency = DefaultS3Concurrency
+	}
+
+	uploadID, err := s.client.CreateMultipartUpload(s.ctx, s.Bucket, s.Key)
+	if err != nil {
+		return fmt.Errorf("upload to s3://%s/%s: %w", s.Bucket, s.Key, err)
+	}
+	s.uploadID = uploadID
+	// parts are handed over to idle uploads only, so that at most Concurrency are held at once
+	s.queue = make(chan s3Upload)
+	s.wg.Add(s.Concurrency)
+	for i := 0; i < s.Concurrency; i++ {
+		go s.upload()
+	}
+
+	s.CSVSink.w.Reset(s3Writer{s})
+	return s.CSVSink.Open(header)
+}
+
+// s3Upload is a part waiting for its upload
+type s3Upload struct {
+	number int
+	body   []byte
+}
+
+// s3Writer cuts the output of the CSVSink in parts
+type s3Writer struct {
+	sink *S3Sink
+}
+
+func (w s3Writer) Write(b []byte) (int, error) {
+	s := w.sink
+	s.part = append(s.part, b...)
+	if len(s.part) >= s.PartBytes {
+		if err := s.send(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// send queues the current part for upload, failing once an upload has failed
+func (s *S3Sink) send() error {
+	if err := s.failed(); err != nil {
+		return err
+	}
+	if s.sent == s3MaxParts {
+		return fmt.Errorf("%w: s3://%s/%s, use a larger PartBytes", S3TooManyPartsError, s.Bucket, s.Key)
+	}
+
+	s.sent++
+	s.queue <- s3Upload{number: s.sent, body: s.part}
+	s.part = make([]byte, 0, len(s.part))
+	return nil
+}
+
+// upload uploads the queued parts until the queue is closed. After a failure the remaining
+// parts are dropped
+func (s *S3Sink) upload() {
+	defer s.wg.Done()
+	for upload := range s.queue {
+		if s.failed() != nil {
+			continue
+		}
+
+		etag, err := s.client.UploadPart(s.ctx, s.Bucket, s.Key, s.uploadID, upload.number, upload.body)
+		s.mu.Lock()
+		if err != nil && s.err == nil {
+			s.err = fmt.Errorf("upload part %d to s3://%s/%s: %w", upload.number, s.Bucket, s.Key, err)
+		}
+		s.parts = append(s.parts, S3Part{Number: upload.number, ETag: etag})
+		s.mu.Unlock()
+	}
+}
+
+func (s *S3Sink) failed() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// wait stops the uploads once the queued parts are done, it returns the error of the first one
+// failing
+func (s *S3Sink) wait() error {
+	close(s.queue)
+	s.wg.Wait()
+	s.queue = nil
+	return s.failed()
+}
+
+// Close uploads the last part and creates the object, or aborts the upload if a part failed
+func (s *S3Sink) Close() error {
+	if s.queue == nil {
+		return nil
+	}
+
+	err := s.CSVSink.Close()
+	// an empty output is a single empty part
+	if err == nil && (len(s.part) > 0 || s.sent == 0) {
+		err = s.send()
+	}
+	if waitErr := s.wait(); err == nil {
+		err = waitErr
+	}
+	if err == nil {
+		sort.Slice(s.parts, func(i, j int) bool {
+			return s.parts[i].Number < s.parts[j].Number
+		})
+		err = s.client.CompleteMultipartUpload(s.ctx, s.Bucket, s.Key, s.uploadID, s.parts)
+		if err != nil {
+			err = fmt.Errorf("complete s3://%s/%s: %w", s.Bucket, s.Key, err)
+		}
+	}
+	if err != nil {
+		s.client.AbortMultipartUpload(s.ctx, s.Bucket, s.Key, s.uploadID)
+	}
+	return err
+}
+
+// Abort discards the parts uploaded, no object is created
+func (s *S3Sink) Abort(error) {
+	if s.queue == nil {
+		return
+	}
+	s.wait()
+	s.client.AbortMultipartUpload(s.ctx, s.Bucket, s.Key, s.uploadID)
+}
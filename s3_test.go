@@ -0,0 +1,88 @@
+package parallel_csv
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 keeps the parts of a single upload, failing the upload of part fail
+type fakeS3 struct {
+	mu        sync.Mutex
+	parts     map[int][]byte
+	object    []byte
+	aborted   bool
+	completed bool
+	fail      int
+}
+
+func (f *fakeS3) CreateMultipartUpload(ctx context.Context, bucket string, key string) (string, error) {
+	f.parts = map[int][]byte{}
+	return "upload-1", nil
+}
+
+func (f *fakeS3) UploadPart(ctx context.Context, bucket string, key string, uploadID string, number int, body []byte) (string, error) {
+	if number == f.fail {
+		return "", errors.New("connection reset")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.parts[number] = append([]byte(nil), body...)
+	return string(rune('a' + number)), nil
+}
+
+func (f *fakeS3) CompleteMultipartUpload(ctx context.Context, bucket string, key string, uploadID string, parts []S3Part) error {
+	for i, part := range parts {
+		if part.Number != i+1 || part.ETag != string(rune('a'+part.Number)) {
+			return errors.New("invalid part")
+		}
+		f.object = append(f.object, f.parts[part.Number]...)
+	}
+	f.completed = true
+	return nil
+}
+
+func (f *fakeS3) AbortMultipartUpload(ctx context.Context, bucket string, key string, uploadID string) error {
+	f.aborted = true
+	return nil
+}
+
+func TestS3Sink(t *testing.T) {
+	client := &fakeS3{}
+	sink := NewS3Sink(context.Background(), client, "bucket", "out.csv", ",")
+	sink.PartBytes = 100
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 64
+	assert.Nil(t, NewProcessor(strings.NewReader(numbers(10000)), &config).Copy(sink))
+
+	assert.True(t, client.completed)
+	assert.False(t, client.aborted)
+	assert.Greater(t, len(client.parts), 10)
+	assert.Equal(t, numbers(10000), string(client.object))
+
+	// an empty output is still an object
+	client = &fakeS3{}
+	sink = NewS3Sink(context.Background(), client, "bucket", "out.csv", ",")
+	assert.Nil(t, sink.Open(nil))
+	assert.Nil(t, sink.Close())
+	assert.True(t, client.completed)
+	assert.Empty(t, client.object)
+}
+
+func TestS3SinkFailure(t *testing.T) {
+	client := &fakeS3{fail: 3}
+	sink := NewS3Sink(context.Background(), client, "bucket", "out.csv", ",")
+	sink.PartBytes = 100
+	sink.Concurrency = 2
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 64
+	err := NewProcessor(strings.NewReader(numbers(10000)), &config).Copy(sink)
+
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "upload part 3")
+	assert.True(t, client.aborted)
+	assert.False(t, client.completed)
+}
@@ -0,0 +1,95 @@
+package parallel_csv
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// reservoir is a uniform sample of the rows seen by a worker
+type reservoir struct {
+	rows []sortRow
+	seen int
+	rand *rand.Rand
+}
+
+// add keeps each row with probability size/seen, replacing a random one (algorithm R)
+func (r *reservoir) add(row string, line int, size int) {
+	r.seen++
+	if len(r.rows) < size {
+		r.rows = append(r.rows, sortRow{row: cloneString(row), line: line})
+		return
+	}
+	if i := r.rand.Intn(r.seen); i < size {
+		r.rows[i] = sortRow{row: cloneString(row), line: line}
+	}
+}
+
+// merge combines two reservoirs in a uniform sample of the union of their rows: each pick comes
+// from one reservoir or the other with a probability proportional to the rows they stand for
+func (r *reservoir) merge(other *reservoir, size int) {
+	seen, otherSeen := r.seen, other.seen
+	mine, theirs := r.rows, other.rows
+	merged := make([]sortRow, 0, size)
+
+	for len(merged) < size && seen+otherSeen > 0 {
+		from := &mine
+		if r.rand.Intn(seen+otherSeen) < otherSeen {
+			from, otherSeen = &theirs, otherSeen-1
+		} else {
+			seen--
+		}
+		if len(*from) == 0 {
+			break
+		}
+
+		i := r.rand.Intn(len(*from))
+		merged = append(merged, (*from)[i])
+		(*from)[i] = (*from)[len(*from)-1]
+		*from = (*from)[:len(*from)-1]
+	}
+
+	r.rows = merged
+	r.seen += other.seen
+}
+
+// Sample returns n rows picked uniformly at random, in source order, in a single pass. Each
+// worker keeps a reservoir of the rows it processes, reservoirs are merged at the end
+func (p processor) Sample(n int) ([]string, error) {
+	return p.sample(n, time.Now().UnixNano())
+}
+
+func (p processor) sample(n int, seed int64) ([]string, error) {
+	reservoirs := make([]*reservoir, p.config.NumberOfWorkers)
+	for i := range reservoirs {
+		reservoirs[i] = &reservoir{rand: rand.New(rand.NewSource(seed + int64(i)))}
+	}
+
+	err := p.RunChunks(func(chunk Chunk) error {
+		r := reservoirs[chunk.Worker]
+		for i, row := range chunk.Rows {
+			if chunk.Cancelled() {
+				return errCancelled
+			}
+			r.add(row, chunk.Line(i), n)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range reservoirs[1:] {
+		reservoirs[0].merge(r, n)
+	}
+
+	sampled := reservoirs[0].rows
+	sort.Slice(sampled, func(i, j int) bool {
+		return sampled[i].line < sampled[j].line
+	})
+	rows := make([]string, len(sampled))
+	for i, row := range sampled {
+		rows[i] = row.row
+	}
+	return rows, nil
+}
@@ -0,0 +1,66 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSample(t *testing.T) {
+	file := openFile("testdata/mid.csv")
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 16 * KB
+	p := NewProcessor(file, &config)
+
+	rows, err := p.Sample(100)
+	assert.Nil(t, err)
+	assert.Len(t, rows, 100)
+
+	previous := 0
+	for _, row := range rows {
+		index, err := strconv.Atoi(strings.Split(row, ",")[0])
+		assert.Nil(t, err)
+		assert.Greater(t, index, previous)
+		previous = index
+	}
+}
+
+func TestSampleMoreThanRows(t *testing.T) {
+	p := NewProcessor(openFile("testdata/very-small.csv"), nil)
+
+	rows, err := p.Sample(10)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1, 65.78, 112.99", "2, 71.52, 136.49", "3, 69.40, 153.03"}, rows)
+}
+
+func TestSampleUniform(t *testing.T) {
+	// every row of a 200 rows file should be sampled about as often as the others
+	hits := make([]int, 200)
+	runs := 400
+	for seed := 0; seed < runs; seed++ {
+		config := GetDefaultConfig()
+		config.BytesPerWorker = 512
+		config.HeaderConfig.HasHeader = false
+		p := NewProcessor(openFile("testdata/without_header.csv"), &config).(*processor)
+
+		rows, err := p.sample(20, int64(seed))
+		assert.Nil(t, err)
+		for _, row := range rows {
+			index, _ := strconv.Atoi(strings.Split(row, ",")[0])
+			hits[index-1]++
+		}
+	}
+
+	// each row is expected runs*20/200 = 40 times
+	firstHalf, secondHalf := 0, 0
+	for i, h := range hits {
+		if i < 100 {
+			firstHalf += h
+		} else {
+			secondHalf += h
+		}
+	}
+	assert.Less(t, math.Abs(float64(firstHalf-secondHalf))/float64(firstHalf+secondHalf), 0.05)
+}
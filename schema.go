@@ -0,0 +1,302 @@
+package parallel_csv
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const ColumnNotFoundError = Error("column not found")
+const RequiredValueError = Error("value is required")
+const MaxLengthError = Error("value is too long")
+const PatternMismatchError = Error("value does not match pattern")
+const TypeMismatchError = Error("value has the wrong type")
+
+// MaxViolationSamples is the number of offending rows kept by a ValidationReport
+const MaxViolationSamples = 10
+
+// ColumnType is the type of the values of a column
+type ColumnType string
+
+const (
+	StringType  ColumnType = "string"
+	IntegerType ColumnType = "integer"
+	FloatType   ColumnType = "float"
+	BooleanType ColumnType = "boolean"
+	TimeType    ColumnType = "time"
+)
+
+// ColumnSchema describes the constraints on a single column
+type ColumnSchema struct {
+	Name     string     `json:"name"`
+	Type     ColumnType `json:"type"`
+	Required bool       `json:"required"`
+	// MaxLength is the maximum number of characters of a value, 0 means no limit
+	MaxLength int `json:"max_length,omitempty"`
+	// Pattern is a regular expression every non-empty value must match
+	Pattern string `json:"pattern,omitempty"`
+	// Layout is the time.Parse layout of TimeType columns, time.RFC3339 if empty
+	Layout string `json:"layout,omitempty"`
+}
+
+// Schema describes the expected columns of a file. Columns are matched to the header by name,
+// or by position when the file has no header
+type Schema struct {
+	Columns []ColumnSchema `json:"columns"`
+	// Decoders parse the values of the named columns in place of their type, see RegisterDecoder
+	Decoders map[string]Decoder `json:"-"`
+}
+
+// Decoder parses a non-empty value of a column, without surrounding spaces, into a Go value.
+// An error makes the value a TypeMismatchError
+type Decoder func(field []byte) (interface{}, error)
+
+// RegisterDecoder makes decoder parse the values of column, such as enums or amounts with a
+// currency symbol. The typed APIs, like the database sinks, receive what it returns: sinks
+// building typed columns expect the Go type of the column Type. Validation reports the values
+// it fails to decode. The column needs no entry in Columns
+func (s *Schema) RegisterDecoder(column string, decoder Decoder) {
+	if s.Decoders == nil {
+		s.Decoders = map[string]Decoder{}
+	}
+	s.Decoders[column] = decoder
+}
+
+// Violation is a value breaking a schema constraint
+type Violation struct {
+	Line   int
+	Column string
+	Value  string
+	Row    string
+	Err    error
+}
+
+// ValidationReport summarizes the violations found by Validate
+type ValidationReport struct {
+	Rows        int
+	InvalidRows int
+	// Violations counts the violations of each column
+	Violations map[string]int
+	// Samples holds the first MaxViolationSamples violations in source order
+	Samples []Violation
+}
+
+// Valid tells whether no violation has been found
+func (r *ValidationReport) Valid() bool {
+	return r.InvalidRows == 0
+}
+
+func (r *ValidationReport) merge(other *ValidationReport) {
+	r.Rows += other.Rows
+	r.InvalidRows += other.InvalidRows
+	for column, count := range other.Violations {
+		r.Violations[column] += count
+	}
+
+	r.Samples = append(r.Samples, other.Samples...)
+	sort.SliceStable(r.Samples, func(i, j int) bool {
+		return r.Samples[i].Line < r.Samples[j].Line
+	})
+	if len(r.Samples) > MaxViolationSamples {
+		r.Samples = r.Samples[:MaxViolationSamples]
+	}
+}
+
+// compiledColumn is a ColumnSchema bound to a field index
+type compiledColumn struct {
+	ColumnSchema
+	index   int
+	pattern *regexp.Regexp
+	decoder Decoder
+}
+
+func (p processor) compileSchema(schema Schema) ([]compiledColumn, error) {
+	return compileColumns(p.header, schema)
+}
+
+// compileColumns binds the columns of the schema to the header, by position if it is empty
+func compileColumns(header []string, schema Schema) ([]compiledColumn, error) {
+	// the decoders of columns missing from the schema add columns without constraints, found by
+	// name even without header, as col_1, col_2 and so on
+	all := schema.Columns
+	names := make([]string, 0, len(schema.Decoders))
+	for name := range schema.Decoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !schema.hasColumn(name) {
+			all = append(all[:len(all):len(all)], ColumnSchema{Name: name})
+		}
+	}
+
+	columns := make([]compiledColumn, len(all))
+	for i, column := range all {
+		index := i
+		if len(header) > 0 || i >= len(schema.Columns) {
+			index = headerIndex(header, column.Name)
+			if index == -1 {
+				return nil, fmt.Errorf("%w: %s", ColumnNotFoundError, column.Name)
+			}
+		}
+
+		columns[i] = compiledColumn{ColumnSchema: column, index: index, decoder: schema.Decoders[column.Name]}
+		if column.Pattern != "" {
+			pattern, err := regexp.Compile(column.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", column.Name, err)
+			}
+			columns[i].pattern = pattern
+		}
+	}
+
+	return columns, nil
+}
+
+func (s Schema) hasColumn(name string) bool {
+	for _, column := range s.Columns {
+		if column.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns the constraint broken by value, if any. Surrounding spaces are ignored,
+// except by the pattern
+func (c compiledColumn) check(value string) error {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		if c.Required {
+			return RequiredValueError
+		}
+		return nil
+	}
+
+	if c.MaxLength > 0 && utf8.RuneCountInString(trimmed) > c.MaxLength {
+		return MaxLengthError
+	}
+	if c.pattern != nil && !c.pattern.MatchString(value) {
+		return PatternMismatchError
+	}
+	if !c.accepts(trimmed) {
+		return TypeMismatchError
+	}
+
+	return nil
+}
+
+// accepts tells whether value can be parsed as a value of the column type, or by its decoder
+func (c compiledColumn) accepts(value string) bool {
+	if c.decoder != nil {
+		_, err := c.decoder([]byte(value))
+		return err == nil
+	}
+
+	var err error
+	switch c.Type {
+	case IntegerType:
+		_, err = strconv.ParseInt(value, 10, 64)
+	case FloatType:
+		_, err = strconv.ParseFloat(value, 64)
+	case BooleanType:
+		_, err = strconv.ParseBool(value)
+	case TimeType:
+		layout := c.Layout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		_, err = time.Parse(layout, value)
+	}
+	return err == nil
+}
+
+// Validate checks every row against the schema and reports the violations found
+func (p processor) Validate(schema Schema) (*ValidationReport, error) {
+	columns, err := p.compileSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	checks := make([]columnCheck, len(columns))
+	for i, column := range columns {
+		checks[i] = columnCheck{name: column.Name, index: column.index, check: column.check}
+	}
+	return p.validate(checks)
+}
+
+// columnCheck is a constraint on the values of the column at index
+type columnCheck struct {
+	name  string
+	index int
+	check func(value string) error
+}
+
+// validate runs the checks on every row. Rows failing any of them are sent to the dead letter
+func (p processor) validate(checks []columnCheck) (*ValidationReport, error) {
+	report := &ValidationReport{Violations: map[string]int{}}
+	mu := sync.Mutex{}
+
+	err := p.RunChunks(func(chunk Chunk) error {
+		partial := &ValidationReport{Violations: map[string]int{}}
+		for i, row := range chunk.Rows {
+			if chunk.Cancelled() {
+				return errCancelled
+			}
+			fields := p.split(row)
+			var reasons []string
+
+			for _, column := range checks {
+				value := ""
+				if column.index < len(fields) {
+					value = fields[column.index]
+				}
+
+				err := column.check(value)
+				if err == nil {
+					continue
+				}
+
+				reasons = append(reasons, column.name+": "+err.Error())
+				partial.Violations[column.name]++
+				if len(partial.Samples) < MaxViolationSamples {
+					partial.Samples = append(partial.Samples, Violation{
+						Line:   chunk.Line(i),
+						Column: column.name,
+						Value:  cloneString(value),
+						Row:    cloneString(row),
+						Err:    err,
+					})
+				}
+			}
+
+			partial.Rows++
+			if len(reasons) == 0 {
+				continue
+			}
+
+			partial.InvalidRows++
+			if p.deadLetter != nil {
+				reason := Error(strings.Join(reasons, "; "))
+				if err := p.deadLetter.write(row, reason); err != nil {
+					return err
+				}
+			}
+		}
+
+		mu.Lock()
+		report.merge(partial)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
@@ -0,0 +1,95 @@
+package parallel_csv
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	input := "id,name,score\n1,anna,7.5\nx,bob,8\n3,,9\n4,carlotta,high\n"
+	p := NewProcessor(strings.NewReader(input), nil)
+
+	report, err := p.Validate(Schema{Columns: []ColumnSchema{
+		{Name: "id", Type: IntegerType, Required: true},
+		{Name: "name", Type: StringType, Required: true, MaxLength: 5, Pattern: "^[a-z]+$"},
+		{Name: "score", Type: FloatType},
+	}})
+	assert.Nil(t, err)
+	assert.False(t, report.Valid())
+	assert.Equal(t, 4, report.Rows)
+	assert.Equal(t, 3, report.InvalidRows)
+	assert.Equal(t, map[string]int{"id": 1, "name": 2, "score": 1}, report.Violations)
+
+	assert.Len(t, report.Samples, 4)
+	assert.Equal(t, 3, report.Samples[0].Line)
+	assert.ErrorIs(t, report.Samples[0].Err, TypeMismatchError)
+	assert.ErrorIs(t, report.Samples[1].Err, RequiredValueError)
+}
+
+func TestValidateFile(t *testing.T) {
+	file := openFile("testdata/mid.csv")
+	p := NewProcessor(file, nil)
+
+	report, err := p.Validate(Schema{Columns: []ColumnSchema{
+		{Name: "Index", Type: IntegerType, Required: true},
+		{Name: "Height(Inches)", Type: FloatType, Required: true},
+		{Name: "Weight(Pounds)", Type: FloatType, Required: true},
+	}})
+	assert.Nil(t, err)
+	assert.True(t, report.Valid())
+	assert.Equal(t, 25000, report.Rows)
+}
+
+func TestValidateUnknownColumn(t *testing.T) {
+	file := openFile("testdata/very-small.csv")
+	p := NewProcessor(file, nil)
+
+	_, err := p.Validate(Schema{Columns: []ColumnSchema{{Name: "Age"}}})
+	assert.ErrorIs(t, err, ColumnNotFoundError)
+}
+
+// parseEuros decodes amounts such as "€1,250.50" into cents
+func parseEuros(field []byte) (interface{}, error) {
+	amount := strings.ReplaceAll(strings.TrimPrefix(string(field), "€"), ",", "")
+	euros, err := strconv.ParseFloat(amount, 64)
+	if err != nil {
+		return nil, err
+	}
+	return int64(math.Round(euros * 100)), nil
+}
+
+func TestSchemaDecoders(t *testing.T) {
+	schema := Schema{Columns: []ColumnSchema{{Name: "status", Type: IntegerType, Required: true}}}
+	schema.RegisterDecoder("status", func(field []byte) (interface{}, error) {
+		switch string(field) {
+		case "active":
+			return int64(1), nil
+		case "closed":
+			return int64(0), nil
+		}
+		return nil, errors.New("unknown status")
+	})
+	schema.RegisterDecoder("amount", parseEuros)
+
+	header := []string{"id", "status", "amount"}
+	types, err := bindTypes(header, &schema)
+	assert.Nil(t, err)
+	values, err := types([]string{"1", "active", " €1,250.50"})
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"1", int64(1), int64(125050)}, values)
+	values, err = types([]string{"2", "closed", ""})
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"2", int64(0), nil}, values)
+	_, err = types([]string{"3", "open", "1"})
+	assert.ErrorIs(t, err, TypeMismatchError)
+
+	input := "id,status,amount\n1,active,€10\n2,open,€5\n3,,abc\n"
+	report, err := NewProcessor(strings.NewReader(input), nil).Validate(schema)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, report.InvalidRows)
+	assert.Equal(t, map[string]int{"status": 2, "amount": 1}, report.Violations)
+}
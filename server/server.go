@@ -0,0 +1,559 @@
+// Package server runs processor pipelines as a REST service. Clients submit a CSV file, uploaded
+// or fetched from a URL, to one of the pipelines registered, then follow the job and download its
+// result:
+//
+//	POST   /jobs?pipeline=name        submit the multipart "file" field, or the body itself
+//	POST   /jobs?pipeline=name&url=u  submit the file at u, fetched by the server
+//	GET    /jobs/{id}                 the status of the job as JSON
+//	GET    /jobs/{id}/events          the progress of the job as server-sent events
+//	GET    /jobs/{id}/result          the output of the job once done
+//	DELETE /jobs/{id}                 forget the job and delete its files
+//	GET    /pipelines                 the names of the pipelines
+//
+// Jobs run in the background, at most Server.MaxJobs at once, the others waiting their turn.
+// Finished jobs are forgotten and their files deleted once Server.JobTTL has passed. Inputs are
+// fetched only from the URLs accepted by Server.AllowURL, none by default.
+//
+// The server has no access control: whoever knows the ID of a job can follow it, download its
+// result and delete it. IDs are random and cannot be guessed, but a server reachable by untrusted
+// clients should run behind a proxy authenticating them.
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	pcsv "github.com/jacopoRufini/parallel-csv"
+)
+
+// Pipeline processes the rows of a job, writing its result to out
+type Pipeline func(p pcsv.Processor, out io.Writer) error
+
+// DefaultJobTTL is the time finished jobs are kept when Server.JobTTL is 0
+const DefaultJobTTL = 24 * time.Hour
+
+// Job statuses
+const (
+	Queued  = "queued"
+	Running = "running"
+	Done    = "done"
+	Failed  = "failed"
+)
+
+// Server holds the pipelines and the jobs submitted to them
+type Server struct {
+	// Config is the configuration of the processors of the jobs, the default one if nil
+	Config *pcsv.Config
+	// Dir holds the inputs uploaded and the results, the temporary directory if empty
+	Dir string
+	// MaxBytes limits the size of the inputs, 0 means no limit
+	MaxBytes int64
+	// MaxJobs is the number of jobs running at once, 1 if 0
+	MaxJobs int
+	// ContentType is the media type of the results, text/csv if empty
+	ContentType string
+	// Client fetches the inputs given by URL, http.DefaultClient if nil
+	Client *http.Client
+	// AllowURL tells whether an input can be fetched from u, redirects included, returning the
+	// reason why not otherwise. When nil no input is fetched: letting clients choose the URLs
+	// fetched by the server gives them access to the hosts of its network. See AllowHosts
+	AllowURL func(u *url.URL) error
+	// JobTTL is the time finished jobs are kept, DefaultJobTTL if 0
+	JobTTL time.Duration
+
+	once      sync.Once
+	mu        sync.Mutex
+	pipelines map[string]Pipeline
+	jobs      map[string]*job
+	slots     chan struct{}
+}
+
+// AllowHosts accepts the http and https URLs of the hosts listed, such as "example.com" or
+// "example.com:8080" for a port other than the default one
+func AllowHosts(hosts ...string) func(u *url.URL) error {
+	allowed := map[string]bool{}
+	for _, host := range hosts {
+		allowed[strings.ToLower(host)] = true
+	}
+	return func(u *url.URL) error {
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("unsupported scheme %q", u.Scheme)
+		}
+		if !allowed[strings.ToLower(u.Host)] {
+			return fmt.Errorf("host %q is not allowed", u.Host)
+		}
+		return nil
+	}
+}
+
+// New creates a server running pipelines, more can be added with Register
+func New(pipelines map[string]Pipeline) *Server {
+	s := &Server{pipelines: map[string]Pipeline{}}
+	for name, pipeline := range pipelines {
+		s.Register(name, pipeline)
+	}
+	return s
+}
+
+// Register adds a pipeline, replacing the one with the same name
+func (s *Server) Register(name string, pipeline Pipeline) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pipelines == nil {
+		s.pipelines = map[string]Pipeline{}
+	}
+	s.pipelines[name] = pipeline
+}
+
+// ListenAndServe serves pipelines on the TCP address addr
+func ListenAndServe(addr string, pipelines map[string]Pipeline) error {
+	return New(pipelines).ListenAndServe(addr)
+}
+
+// ListenAndServe serves the server on the TCP address addr
+func (s *Server) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, s)
+}
+
+// job is a job submitted to the server, guarded by the server mutex
+type job struct {
+	Status
+	input    string
+	output   string
+	progress *pcsv.ProgressStream
+}
+
+// Status is the JSON description of a job
+type Status struct {
+	ID       string     `json:"id"`
+	Pipeline string     `json:"pipeline"`
+	Status   string     `json:"status"`
+	Error    string     `json:"error,omitempty"`
+	Created  time.Time  `json:"created"`
+	Finished *time.Time `json:"finished,omitempty"`
+	// Progress is the progress of the run, once started
+	Progress *pcsv.ProgressEvent `json:"progress,omitempty"`
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.once.Do(func() {
+		if s.jobs == nil {
+			s.jobs = map[string]*job{}
+		}
+		jobs := s.MaxJobs
+		if jobs <= 0 {
+			jobs = 1
+		}
+		s.slots = make(chan struct{}, jobs)
+	})
+	s.expire(time.Now())
+
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+	switch {
+	case path == "pipelines" && r.Method == http.MethodGet:
+		s.listPipelines(w)
+	case path == "jobs" && r.Method == http.MethodPost:
+		s.submit(w, r)
+	case len(parts) == 2 && parts[0] == "jobs" && r.Method == http.MethodGet:
+		s.status(w, parts[1])
+	case len(parts) == 2 && parts[0] == "jobs" && r.Method == http.MethodDelete:
+		s.delete(w, parts[1])
+	case len(parts) == 3 && parts[0] == "jobs" && parts[2] == "result" && r.Method == http.MethodGet:
+		s.result(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "jobs" && parts[2] == "events" && r.Method == http.MethodGet:
+		s.events(w, r, parts[1])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) listPipelines(w http.ResponseWriter) {
+	s.mu.Lock()
+	names := make([]string, 0, len(s.pipelines))
+	for name := range s.pipelines {
+		names = append(names, name)
+	}
+	s.mu.Unlock()
+	sort.Strings(names)
+	writeJSON(w, http.StatusOK, names)
+}
+
+// submit stores the input of a new job, or checks its URL, and queues it
+func (s *Server) submit(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("pipeline")
+	s.mu.Lock()
+	pipeline, ok := s.pipelines[name]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown pipeline %q", name))
+		return
+	}
+
+	id, err := newID()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	j := &job{Status: Status{
+		ID:       id,
+		Pipeline: name,
+		Status:   Queued,
+		Created:  time.Now().UTC(),
+	}}
+
+	var source *url.URL
+	if raw := r.URL.Query().Get("url"); raw == "" {
+		if err := s.store(j, r); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, pcsv.InputTooLargeError) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			writeError(w, status, err)
+			return
+		}
+	} else {
+		if source, err = url.Parse(raw); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := s.allowURL(source); err != nil {
+			writeError(w, http.StatusForbidden, fmt.Errorf("url %q: %w", raw, err))
+			return
+		}
+	}
+
+	s.mu.Lock()
+	s.jobs[j.ID] = j
+	status := j.Status
+	s.mu.Unlock()
+
+	go s.run(j, pipeline, source)
+	w.Header().Set("Location", "/jobs/"+j.ID)
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+// newID returns a random job ID
+func newID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// allowURL checks that an input can be fetched from u
+func (s *Server) allowURL(u *url.URL) error {
+	if s.AllowURL == nil {
+		return errors.New("the server does not fetch inputs")
+	}
+	return s.AllowURL(u)
+}
+
+// store saves the uploaded input of a job: the "file" field of a multipart body, or the body
+func (s *Server) store(j *job, r *http.Request) error {
+	var input io.Reader = r.Body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			return err
+		}
+		for input == r.Body {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return errors.New("the upload has no file field")
+			}
+			if err != nil {
+				return err
+			}
+			if part.FormName() == "file" {
+				input = part
+			}
+		}
+	}
+	if s.MaxBytes > 0 {
+		input = pcsv.LimitReader(input, s.MaxBytes)
+	}
+
+	f, err := os.CreateTemp(s.Dir, "job-"+j.ID+"-*.csv")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, input)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	j.input = f.Name()
+	return nil
+}
+
+// run waits for a slot, then runs the pipeline of the job on its input, deleted once done
+func (s *Server) run(j *job, pipeline Pipeline, source *url.URL) {
+	s.slots <- struct{}{}
+	defer func() { <-s.slots }()
+
+	s.mu.Lock()
+	j.Status.Status = Running
+	s.mu.Unlock()
+
+	err := s.process(j, pipeline, source)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	finished := time.Now().UTC()
+	j.Finished = &finished
+	j.Status.Status = Done
+	if err != nil {
+		j.Status.Status, j.Error = Failed, err.Error()
+		// a failed job has no result to download
+		j.removeOutput()
+	}
+	j.removeInput()
+	if j.progress != nil {
+		j.progress.Finish(err)
+	}
+}
+
+func (s *Server) process(j *job, pipeline Pipeline, source *url.URL) (err error) {
+	var input io.Reader
+	size := int64(0)
+	if source != nil {
+		response, err := s.client().Get(source.String())
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return fmt.Errorf("fetch %s: %s", source, response.Status)
+		}
+		if s.MaxBytes > 0 && response.ContentLength > s.MaxBytes {
+			return pcsv.InputTooLargeError
+		}
+		input, size = response.Body, response.ContentLength
+		if s.MaxBytes > 0 {
+			// the length of the body may be unknown
+			input = pcsv.LimitReader(input, s.MaxBytes)
+		}
+	} else {
+		f, err := os.Open(j.input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if info, err := f.Stat(); err == nil {
+			size = info.Size()
+		}
+		input = f
+	}
+	if size < 0 {
+		size = 0
+	}
+
+	out, err := os.CreateTemp(s.Dir, "job-"+j.ID+"-*.out")
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	j.output = out.Name()
+	s.mu.Unlock()
+	defer func() {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	config := pcsv.GetDefaultConfig()
+	if s.Config != nil {
+		config = *s.Config
+	}
+	var progress *pcsv.ProgressStream
+	handler := config.ErrorHandler
+	config.ErrorHandler = func(err error) {
+		progress.Skipped(err)
+		if handler != nil {
+			handler(err)
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	p, err := pcsv.From(input).Config(config).Build()
+	if err != nil {
+		return err
+	}
+	progress = pcsv.NewProgressStream(p, size)
+	s.mu.Lock()
+	j.progress = progress
+	s.mu.Unlock()
+	return pipeline(p, out)
+}
+
+// client returns the client fetching the inputs, checking the URLs of the redirects too
+func (s *Server) client() *http.Client {
+	client := http.DefaultClient
+	if s.Client != nil {
+		client = s.Client
+	}
+	checked := *client
+	checked.CheckRedirect = func(r *http.Request, via []*http.Request) error {
+		if err := s.allowURL(r.URL); err != nil {
+			return fmt.Errorf("redirect to %s: %w", r.URL, err)
+		}
+		if client.CheckRedirect != nil {
+			return client.CheckRedirect(r, via)
+		}
+		// the default policy of http.Client
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	return &checked
+}
+
+// find returns the job and its status, writing a 404 if there is none
+func (s *Server) find(w http.ResponseWriter, id string) (*job, Status, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("job %s not found", id))
+		return nil, Status{}, false
+	}
+	status := j.Status
+	return j, status, true
+}
+
+func (s *Server) status(w http.ResponseWriter, id string) {
+	j, status, ok := s.find(w, id)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	progress := j.progress
+	s.mu.Unlock()
+	if progress != nil {
+		event := progress.Event()
+		status.Progress = &event
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *Server) events(w http.ResponseWriter, r *http.Request, id string) {
+	j, _, ok := s.find(w, id)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	progress := j.progress
+	s.mu.Unlock()
+	if progress == nil {
+		writeError(w, http.StatusConflict, fmt.Errorf("job %s has not started", id))
+		return
+	}
+	progress.ServeHTTP(w, r)
+}
+
+func (s *Server) result(w http.ResponseWriter, r *http.Request, id string) {
+	j, status, ok := s.find(w, id)
+	if !ok {
+		return
+	}
+	if status.Status != Done {
+		writeError(w, http.StatusConflict, fmt.Errorf("job %s is %s", id, status.Status))
+		return
+	}
+
+	s.mu.Lock()
+	output := j.output
+	s.mu.Unlock()
+	f, err := os.Open(output)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer f.Close()
+
+	contentType := s.ContentType
+	if contentType == "" {
+		contentType = "text/csv"
+	}
+	w.Header().Set("Content-Type", contentType)
+	http.ServeContent(w, r, "", *status.Finished, f)
+}
+
+// delete forgets a job which is not running, deleting its files
+func (s *Server) delete(w http.ResponseWriter, id string) {
+	j, status, ok := s.find(w, id)
+	if !ok {
+		return
+	}
+	if status.Status == Queued || status.Status == Running {
+		writeError(w, http.StatusConflict, fmt.Errorf("job %s is %s", id, status.Status))
+		return
+	}
+
+	s.mu.Lock()
+	delete(s.jobs, id)
+	j.removeOutput()
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// expire forgets the jobs finished for longer than JobTTL, deleting their results
+func (s *Server) expire(now time.Time) {
+	ttl := s.JobTTL
+	if ttl <= 0 {
+		ttl = DefaultJobTTL
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, j := range s.jobs {
+		if j.Finished != nil && now.Sub(*j.Finished) > ttl {
+			delete(s.jobs, id)
+			j.removeOutput()
+		}
+	}
+}
+
+// removeInput deletes the uploaded input of the job, if any
+func (j *job) removeInput() {
+	if j.input != "" {
+		os.Remove(j.input)
+		j.input = ""
+	}
+}
+
+// removeOutput deletes the result of the job, if any
+func (j *job) removeOutput() {
+	if j.output != "" {
+		os.Remove(j.output)
+		j.output = ""
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	pcsv "github.com/jacopoRufini/parallel-csv"
+)
+
+const people = "name,age,country\nanna,34,IT\nbob,28,FR\ncarla,41,IT\n"
+
+func newServer(t *testing.T, options ...func(s *Server)) *httptest.Server {
+	s := New(map[string]Pipeline{
+		"copy": func(p pcsv.Processor, out io.Writer) error {
+			return p.Copy(pcsv.NewCSVSink(out, ";"))
+		},
+		"fail": func(p pcsv.Processor, out io.Writer) error {
+			return errors.New("pipeline failed")
+		},
+	})
+	s.Dir = t.TempDir()
+	s.MaxBytes = 1024
+	for _, option := range options {
+		option(s)
+	}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// submit posts a job and returns its status
+func submit(t *testing.T, server *httptest.Server, query string, contentType string, body io.Reader) (int, Status) {
+	response, err := http.Post(server.URL+"/jobs?"+query, contentType, body)
+	assert.Nil(t, err)
+	defer response.Body.Close()
+	status := Status{}
+	json.NewDecoder(response.Body).Decode(&status)
+	return response.StatusCode, status
+}
+
+// wait polls the job until it is over
+func wait(t *testing.T, server *httptest.Server, id string) Status {
+	for {
+		response, err := http.Get(server.URL + "/jobs/" + id)
+		assert.Nil(t, err)
+		status := Status{}
+		assert.Nil(t, json.NewDecoder(response.Body).Decode(&status))
+		response.Body.Close()
+		if status.Status == Done || status.Status == Failed {
+			return status
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func get(t *testing.T, url string) (int, string) {
+	response, err := http.Get(url)
+	assert.Nil(t, err)
+	defer response.Body.Close()
+	body, _ := io.ReadAll(response.Body)
+	return response.StatusCode, string(body)
+}
+
+func TestUpload(t *testing.T) {
+	server := newServer(t)
+
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	part, _ := form.CreateFormFile("file", "people.csv")
+	part.Write([]byte(people))
+	form.Close()
+	code, status := submit(t, server, "pipeline=copy", form.FormDataContentType(), body)
+	assert.Equal(t, http.StatusAccepted, code)
+	assert.Equal(t, "copy", status.Pipeline)
+
+	status = wait(t, server, status.ID)
+	assert.Equal(t, Done, status.Status)
+	assert.Equal(t, int64(3), status.Progress.Rows)
+	assert.True(t, status.Progress.Done)
+	code, result := get(t, server.URL+"/jobs/"+status.ID+"/result")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "name;age;country\nanna;34;IT\nbob;28;FR\ncarla;41;IT\n", result)
+
+	request, _ := http.NewRequest(http.MethodDelete, server.URL+"/jobs/"+status.ID, nil)
+	response, err := http.DefaultClient.Do(request)
+	assert.Nil(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	code, _ = get(t, server.URL+"/jobs/"+status.ID)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestURL(t *testing.T) {
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/people.csv" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, people)
+	}))
+	defer files.Close()
+	host, _ := url.Parse(files.URL)
+	server := newServer(t, func(s *Server) {
+		s.AllowURL = AllowHosts(host.Host)
+	})
+
+	_, status := submit(t, server, "pipeline=copy&url="+url.QueryEscape(files.URL+"/people.csv"), "", nil)
+	status = wait(t, server, status.ID)
+	assert.Equal(t, Done, status.Status)
+	_, result := get(t, server.URL+"/jobs/"+status.ID+"/result")
+	assert.Equal(t, "name;age;country\nanna;34;IT\nbob;28;FR\ncarla;41;IT\n", result)
+
+	_, status = submit(t, server, "pipeline=copy&url="+url.QueryEscape(files.URL+"/missing.csv"), "", nil)
+	status = wait(t, server, status.ID)
+	assert.Equal(t, Failed, status.Status)
+	assert.Contains(t, status.Error, "404")
+}
+
+func TestErrors(t *testing.T) {
+	server := newServer(t)
+
+	code, _ := submit(t, server, "pipeline=unknown", "text/csv", strings.NewReader(people))
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = submit(t, server, "pipeline=copy", "text/csv", strings.NewReader(strings.Repeat("a\n", 1000)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+	code, _ = submit(t, server, "pipeline=copy&url=file:///etc/passwd", "", nil)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	_, status := submit(t, server, "pipeline=fail", "text/csv", strings.NewReader(people))
+	status = wait(t, server, status.ID)
+	assert.Equal(t, Failed, status.Status)
+	assert.Equal(t, "pipeline failed", status.Error)
+	code, _ = get(t, server.URL+"/jobs/"+status.ID+"/result")
+	assert.Equal(t, http.StatusConflict, code)
+
+	_, status = submit(t, server, "pipeline=copy", "text/csv", strings.NewReader(""))
+	status = wait(t, server, status.ID)
+	assert.Equal(t, Failed, status.Status)
+
+	code, names := get(t, server.URL+"/pipelines")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "[\"copy\",\"fail\"]\n", names)
+}
+
+func TestInvalidConfig(t *testing.T) {
+	server := newServer(t, func(s *Server) {
+		config := pcsv.GetDefaultConfig()
+		config.NumberOfWorkers = 0
+		s.Config = &config
+	})
+
+	_, status := submit(t, server, "pipeline=copy", "text/csv", strings.NewReader(people))
+	status = wait(t, server, status.ID)
+	assert.Equal(t, Failed, status.Status)
+	assert.Contains(t, status.Error, "NumberOfWorkers")
+}
+
+func TestURLTooLarge(t *testing.T) {
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "n\n")
+		// flushing sends the body without Content-Length
+		w.(http.Flusher).Flush()
+		io.WriteString(w, strings.Repeat("1\n", 1000))
+	}))
+	defer files.Close()
+	host, _ := url.Parse(files.URL)
+	server := newServer(t, func(s *Server) {
+		s.AllowURL = AllowHosts(host.Host)
+	})
+
+	_, status := submit(t, server, "pipeline=copy&url="+url.QueryEscape(files.URL+"/numbers.csv"), "", nil)
+	status = wait(t, server, status.ID)
+	assert.Equal(t, Failed, status.Status)
+	assert.Contains(t, status.Error, "too large")
+}
+
+func TestURLNotAllowed(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, people)
+	}))
+	defer internal.Close()
+	files := httptest.NewServer(http.RedirectHandler(internal.URL, http.StatusFound))
+	defer files.Close()
+	source := url.QueryEscape(internal.URL + "/people.csv")
+
+	// no URL is fetched by default
+	code, _ := submit(t, newServer(t), "pipeline=copy&url="+source, "", nil)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	host, _ := url.Parse(files.URL)
+	server := newServer(t, func(s *Server) {
+		s.AllowURL = AllowHosts(host.Host)
+	})
+	code, _ = submit(t, server, "pipeline=copy&url="+source, "", nil)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	// redirects are checked too
+	_, status := submit(t, server, "pipeline=copy&url="+url.QueryEscape(files.URL+"/people.csv"), "", nil)
+	status = wait(t, server, status.ID)
+	assert.Equal(t, Failed, status.Status)
+	assert.Contains(t, status.Error, "not allowed")
+}
+
+func TestExpire(t *testing.T) {
+	s := New(map[string]Pipeline{
+		"copy": func(p pcsv.Processor, out io.Writer) error {
+			return p.Copy(pcsv.NewCSVSink(out, ","))
+		},
+	})
+	s.Dir = t.TempDir()
+	s.JobTTL = time.Hour
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	_, first := submit(t, server, "pipeline=copy", "text/csv", strings.NewReader(people))
+	_, second := submit(t, server, "pipeline=copy", "text/csv", strings.NewReader(people))
+	assert.Len(t, first.ID, 32)
+	assert.NotEqual(t, first.ID, second.ID)
+	wait(t, server, first.ID)
+	wait(t, server, second.ID)
+
+	// the inputs are deleted once the jobs are over, the results once they expire
+	files, _ := os.ReadDir(s.Dir)
+	assert.Len(t, files, 2)
+	s.expire(time.Now().Add(time.Hour - time.Minute))
+	code, _ := get(t, server.URL+"/jobs/"+first.ID+"/result")
+	assert.Equal(t, http.StatusOK, code)
+
+	s.expire(time.Now().Add(time.Hour + time.Minute))
+	code, _ = get(t, server.URL+"/jobs/"+first.ID)
+	assert.Equal(t, http.StatusNotFound, code)
+	files, _ = os.ReadDir(s.Dir)
+	assert.Len(t, files, 0)
+}
@@ -0,0 +1,127 @@
+package parallel_csv
+
+import (
+	"fmt"
+	"os"
+)
+
+// ShardedSink writes CSV files named Prefix followed by a number from 000001 and .csv, rolling to
+// the next file once one reaches MaxRows rows or MaxBytes bytes. Every file starts with the header.
+// Files are written to a temporary file renamed once complete, the one being written when the run
+// fails being removed
+type ShardedSink struct {
+	// Prefix is the path of the files up to their number, such as "out-"
+	Prefix    string
+	Separator string
+	// MaxRows is the maximum number of rows per file, 0 for no limit
+	MaxRows int
+	// MaxBytes is the maximum size of a file, header included, 0 for no limit. A row larger than
+	// MaxBytes on its own is written to a file of its own, exceeding it
+	MaxBytes int64
+	// Quoting and QuoteChar are passed to the CSVSink of each file
+	Quoting   QuotingPolicy
+	QuoteChar string
+	// Files are the paths of the files completed so far
+	Files []string
+
+	header []string
+	// path is the name of the file being written to file, a temporary one
+	path  string
+	file  *os.File
+	csv   *CSVSink
+	rows  int
+	bytes int64
+}
+
+// NewShardedSink creates a sink writing files named prefix followed by their number, using
+// separator between fields
+func NewShardedSink(prefix string, separator string, maxRows int, maxBytes int64) *ShardedSink {
+	return &ShardedSink{
+		Prefix:    prefix,
+		Separator: separator,
+		MaxRows:   maxRows,
+		MaxBytes:  maxBytes,
+	}
+}
+
+func (s *ShardedSink) Open(header []string) error {
+	s.header = header
+	return nil
+}
+
+func (s *ShardedSink) Write(rows [][]string) error {
+	for _, row := range rows {
+		if s.csv == nil {
+			if err := s.next(); err != nil {
+				return err
+			}
+		}
+
+		size := int64(s.csv.size(row))
+		full := s.MaxRows > 0 && s.rows >= s.MaxRows ||
+			s.MaxBytes > 0 && s.rows > 0 && s.bytes+size > s.MaxBytes
+		if full {
+			if err := s.next(); err != nil {
+				return err
+			}
+		}
+
+		if err := s.csv.writeRow(row); err != nil {
+			return err
+		}
+		s.rows++
+		s.bytes += size
+	}
+	return nil
+}
+
+// next completes the current file and creates the following one, writing the header
+func (s *ShardedSink) next() error {
+	if err := s.closeFile(); err != nil {
+		return err
+	}
+
+	path := fmt.Sprintf("%s%06d.csv", s.Prefix, len(s.Files)+1)
+	file, err := createTemp(path)
+	if err != nil {
+		return err
+	}
+	s.path, s.file, s.csv, s.rows = path, file, NewCSVSink(file, s.Separator), 0
+	s.csv.Quoting, s.csv.QuoteChar = s.Quoting, s.QuoteChar
+	s.bytes = 0
+	if len(s.header) > 0 {
+		s.bytes = int64(s.csv.size(s.header))
+	}
+	return s.csv.Open(s.header)
+}
+
+// closeFile flushes the current file, if any, and renames it
+func (s *ShardedSink) closeFile() error {
+	if s.csv == nil {
+		return nil
+	}
+	err := commitTemp(s.file, s.path, s.csv.Close())
+	if err == nil {
+		s.Files = append(s.Files, s.path)
+	}
+	s.file, s.csv = nil, nil
+	return err
+}
+
+// Close completes the last file. A file holding the header only is created if there were no rows
+func (s *ShardedSink) Close() error {
+	if len(s.Files) == 0 && s.csv == nil {
+		if err := s.next(); err != nil {
+			return err
+		}
+	}
+	return s.closeFile()
+}
+
+// Abort removes the file being written, the completed ones are kept
+func (s *ShardedSink) Abort(error) {
+	if s.csv != nil {
+		discardTemp(s.file)
+		s.file, s.csv = nil, nil
+	}
+}
@@ -0,0 +1,50 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readShards(t *testing.T, files []string) []string {
+	var contents []string
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		assert.Nil(t, err)
+		contents = append(contents, string(data))
+	}
+	return contents
+}
+
+func TestShardedSinkRows(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 8
+	prefix := filepath.Join(t.TempDir(), "out-")
+	sink := NewShardedSink(prefix, ",", 2, 0)
+
+	assert.Nil(t, NewProcessor(strings.NewReader("a,b\n1,2\n3,4\n5,6\n"), &config).Copy(sink))
+	assert.Equal(t, []string{prefix + "000001.csv", prefix + "000002.csv"}, sink.Files)
+	assert.Equal(t, []string{"a,b\n1,2\n3,4\n", "a,b\n5,6\n"}, readShards(t, sink.Files))
+}
+
+func TestShardedSinkBytes(t *testing.T) {
+	prefix := filepath.Join(t.TempDir(), "out-")
+	// the header takes 4 bytes, each row 4 or more
+	sink := NewShardedSink(prefix, ",", 0, 12)
+	assert.Nil(t, sink.Open([]string{"a", "b"}))
+	assert.Nil(t, sink.Write([][]string{{"1", "2"}, {"3", "4"}, {"5", "6"}, {"long value", "7"}}))
+	assert.Nil(t, sink.Close())
+
+	assert.Equal(t, []string{"a,b\n1,2\n3,4\n", "a,b\n5,6\n", "a,b\nlong value,7\n"}, readShards(t, sink.Files))
+}
+
+func TestShardedSinkEmpty(t *testing.T) {
+	prefix := filepath.Join(t.TempDir(), "out-")
+	sink := NewShardedSink(prefix, ";", 10, 0)
+	assert.Nil(t, sink.Open([]string{"a", "b"}))
+	assert.Nil(t, sink.Close())
+
+	assert.Equal(t, []string{"a;b\n"}, readShards(t, sink.Files))
+}
@@ -0,0 +1,159 @@
+package parallel_csv
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DefaultSheetPageRows is the number of rows read or written at once by SheetReader and
+// SheetSink when not set
+const DefaultSheetPageRows = 1000
+
+// SheetsClient reads and writes the values of a Google spreadsheet. With
+// google.golang.org/api/sheets/v4, Get calls Spreadsheets.Values.Get and returns the formatted
+// values, Update calls Spreadsheets.Values.Update with the RAW input option and Clear calls
+// Spreadsheets.Values.Clear
+type SheetsClient interface {
+	// Get returns the rows of the range in A1 notation, such as "Orders!1:1000". Rows end at
+	// their last non-empty cell and the empty rows at the end of the range are left out
+	Get(ctx context.Context, spreadsheetID string, readRange string) ([][]string, error)
+	// Update writes the rows to the range starting at the cell in A1 notation
+	Update(ctx context.Context, spreadsheetID string, writeRange string, rows [][]string) error
+	// Clear empties the range
+	Clear(ctx context.Context, spreadsheetID string, clearRange string) error
+}
+
+// SheetReader reads a sheet of a Google spreadsheet as CSV, separated by commas, so that it can
+// be processed like a file delivered as such. The rows are fetched PageRows at a time, and
+// padded with empty fields to the width of the first one. Reading stops at the first page which
+// is not full
+type SheetReader struct {
+	// PageRows is the number of rows fetched at once, DefaultSheetPageRows if 0
+	PageRows      int
+	ctx           context.Context
+	client        SheetsClient
+	spreadsheetID string
+	sheet         string
+	buffer        bytes.Buffer
+	next          int
+	width         int
+	done          bool
+}
+
+// NewSheetReader creates a reader of sheet in the spreadsheet through client
+func NewSheetReader(ctx context.Context, client SheetsClient, spreadsheetID string, sheet string) *SheetReader {
+	return &SheetReader{ctx: ctx, client: client, spreadsheetID: spreadsheetID, sheet: sheet, next: 1}
+}
+
+func (r *SheetReader) Read(b []byte) (int, error) {
+	for r.buffer.Len() == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.fetch(); err != nil {
+			return 0, err
+		}
+	}
+	return r.buffer.Read(b)
+}
+
+// fetch reads the next page into the buffer
+func (r *SheetReader) fetch() error {
+	pageRows := r.PageRows
+	if pageRows <= 0 {
+		pageRows = DefaultSheetPageRows
+	}
+	readRange := fmt.Sprintf("%s!%d:%d", sheetName(r.sheet), r.next, r.next+pageRows-1)
+	rows, err := r.client.Get(r.ctx, r.spreadsheetID, readRange)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", readRange, err)
+	}
+	r.next += pageRows
+	r.done = len(rows) < pageRows
+
+	for _, row := range rows {
+		if r.width == 0 {
+			r.width = len(row)
+		}
+		for i := 0; i < len(row) || i < r.width; i++ {
+			if i > 0 {
+				r.buffer.WriteByte(',')
+			}
+			if i < len(row) {
+				r.buffer.WriteString(quoteField(row[i], ","))
+			}
+		}
+		r.buffer.WriteString(LineBreak)
+	}
+	return nil
+}
+
+// SheetSink writes the rows to a sheet of a Google spreadsheet, PageRows at a time. The sheet is
+// cleared when the sink is opened, so that it holds the output of the last run only
+type SheetSink struct {
+	// PageRows is the number of rows written at once, DefaultSheetPageRows if 0
+	PageRows      int
+	ctx           context.Context
+	client        SheetsClient
+	spreadsheetID string
+	sheet         string
+	page          [][]string
+	next          int
+}
+
+// NewSheetSink creates a sink writing to sheet in the spreadsheet through client
+func NewSheetSink(ctx context.Context, client SheetsClient, spreadsheetID string, sheet string) *SheetSink {
+	return &SheetSink{ctx: ctx, client: client, spreadsheetID: spreadsheetID, sheet: sheet, next: 1}
+}
+
+func (s *SheetSink) Open(header []string) error {
+	if s.PageRows <= 0 {
+		s.PageRows = DefaultSheetPageRows
+	}
+	if err := s.client.Clear(s.ctx, s.spreadsheetID, sheetName(s.sheet)); err != nil {
+		return fmt.Errorf("clear %s: %w", s.sheet, err)
+	}
+	if len(header) == 0 {
+		return nil
+	}
+	return s.Write([][]string{header})
+}
+
+// Write copies the rows, which are sent once a page is full
+func (s *SheetSink) Write(rows [][]string) error {
+	for _, row := range rows {
+		s.page = append(s.page, cloneFields(row))
+		if len(s.page) == s.PageRows {
+			if err := s.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *SheetSink) flush() error {
+	if len(s.page) == 0 {
+		return nil
+	}
+	writeRange := fmt.Sprintf("%s!A%d", sheetName(s.sheet), s.next)
+	if err := s.client.Update(s.ctx, s.spreadsheetID, writeRange, s.page); err != nil {
+		return fmt.Errorf("write %s: %w", writeRange, err)
+	}
+	s.next += len(s.page)
+	s.page = s.page[:0]
+	return nil
+}
+
+// Close writes the last page
+func (s *SheetSink) Close() error {
+	return s.flush()
+}
+
+// sheetName quotes the name of a sheet for the A1 notation
+func sheetName(sheet string) string {
+	return "'" + strings.ReplaceAll(sheet, "'", "''") + "'"
+}
@@ -0,0 +1,82 @@
+package parallel_csv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// fakeSheets holds the rows of a single sheet, trimming their empty cells at the end like the API
+type fakeSheets struct {
+	rows    [][]string
+	ranges  []string
+	fail    bool
+	cleared bool
+}
+
+func (f *fakeSheets) Get(ctx context.Context, spreadsheetID string, readRange string) ([][]string, error) {
+	f.ranges = append(f.ranges, readRange)
+	var first, last int
+	fmt.Sscanf(readRange[strings.Index(readRange, "!")+1:], "%d:%d", &first, &last)
+	var rows [][]string
+	for i := first - 1; i < last && i < len(f.rows); i++ {
+		row := f.rows[i]
+		for len(row) > 0 && row[len(row)-1] == "" {
+			row = row[:len(row)-1]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func (f *fakeSheets) Update(ctx context.Context, spreadsheetID string, writeRange string, rows [][]string) error {
+	if f.fail {
+		return errors.New("quota exceeded")
+	}
+	f.ranges = append(f.ranges, writeRange)
+	var first int
+	fmt.Sscanf(writeRange[strings.Index(writeRange, "!A")+2:], "%d", &first)
+	for len(f.rows) < first-1+len(rows) {
+		f.rows = append(f.rows, nil)
+	}
+	copy(f.rows[first-1:], rows)
+	return nil
+}
+
+func (f *fakeSheets) Clear(ctx context.Context, spreadsheetID string, clearRange string) error {
+	f.rows, f.cleared = nil, true
+	return nil
+}
+
+func TestSheetReader(t *testing.T) {
+	sheets := &fakeSheets{rows: [][]string{{"id", "name", "note"}, {"1", "a, b", ""}, {"2", "c", "\"x\""}, {"3"}}}
+	r := NewSheetReader(context.Background(), sheets, "sheet-1", "Orders")
+	r.PageRows = 2
+
+	sink := &memorySink{}
+	assert.Nil(t, NewProcessor(r, nil).Copy(sink))
+	assert.Equal(t, []string{"id", "name", "note"}, sink.header)
+	assert.Equal(t, [][]string{{"1", "a, b", ""}, {"2", "c", "\"x\""}, {"3", "", ""}}, sink.rows)
+	assert.Equal(t, []string{"'Orders'!1:2", "'Orders'!3:4", "'Orders'!5:6"}, sheets.ranges)
+}
+
+func TestSheetSink(t *testing.T) {
+	sheets := &fakeSheets{rows: [][]string{{"old"}}}
+	sink := NewSheetSink(context.Background(), sheets, "sheet-1", "It's")
+	sink.PageRows = 4
+
+	assert.Nil(t, NewProcessor(strings.NewReader(numbers(10)), nil).Copy(sink))
+	assert.True(t, sheets.cleared)
+	assert.Len(t, sheets.rows, 11)
+	assert.Equal(t, []string{"n"}, sheets.rows[0])
+	assert.Equal(t, []string{"10"}, sheets.rows[10])
+	assert.Equal(t, []string{"'It''s'!A1", "'It''s'!A5", "'It''s'!A9"}, sheets.ranges)
+
+	sheets = &fakeSheets{fail: true}
+	err := NewProcessor(strings.NewReader(numbers(10)), nil).Copy(NewSheetSink(context.Background(), sheets, "sheet-1", "Out"))
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "quota exceeded")
+}
@@ -0,0 +1,71 @@
+package parallel_csv
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// stopSignal is closed once by Stop
+type stopSignal struct {
+	once sync.Once
+	ch   chan struct{}
+}
+
+func newStopSignal() *stopSignal {
+	return &stopSignal{ch: make(chan struct{})}
+}
+
+// Stop ends the current run, or the next one, gracefully: no more chunks are dispatched, the ones
+// in flight are completed and written to the sink, if any, the checkpoint is saved and the run
+// returns without error, with Stats.Stopped set. It can be called from any goroutine, more than
+// once
+func (p processor) Stop() {
+	p.stop.once.Do(func() {
+		close(p.stop.ch)
+	})
+}
+
+// stopRequested tells whether Stop has been called
+func (p processor) stopRequested() bool {
+	select {
+	case <-p.stop.ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// stopped records that the reader stopped because of Stop
+func (p processor) stopped() {
+	if atomic.CompareAndSwapInt32(&p.counters.stopped, 0, 1) {
+		p.config.log(p.config.LogLevels.Run, LogInfo, "run stopped")
+	}
+}
+
+// RunUntilSignal is like RunChunks, but calls Stop when one of the signals is received, SIGINT and
+// SIGTERM if none is given. It returns the stats of the run, partial if it has been stopped, in
+// which case the checkpoint, if any, tells where to resume
+func (p processor) RunUntilSignal(job ChunkJob, signals ...os.Signal) (Stats, error) {
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+	defer signal.Stop(received)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-received:
+			p.Stop()
+		case <-done:
+		}
+	}()
+
+	err := p.RunChunks(job)
+	return p.Stats(), err
+}
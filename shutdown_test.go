@@ -0,0 +1,69 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestStop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	config := GetDefaultConfig()
+	config.NumberOfWorkers = 2
+	config.BytesPerWorker = 16
+	config.CheckpointPath = path
+	p := NewProcessor(strings.NewReader(numbers(1000)), &config)
+
+	var chunks int32
+	sink := &memorySink{}
+	err := p.Copy(stoppingSink{Sink: sink, stop: func() {
+		if atomic.AddInt32(&chunks, 1) == 5 {
+			p.Stop()
+		}
+	}})
+	assert.Nil(t, err)
+	assert.True(t, sink.closed)
+	assert.True(t, p.Stats().Stopped)
+	assert.Less(t, len(sink.rows), 1000)
+
+	// the checkpoint resumes right after the rows written
+	checkpoint, err := LoadCheckpoint(path)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(sink.rows)), checkpoint.Rows)
+}
+
+// stoppingSink calls stop after each write
+type stoppingSink struct {
+	Sink
+	stop func()
+}
+
+func (s stoppingSink) Write(rows [][]string) error {
+	err := s.Sink.Write(rows)
+	s.stop()
+	return err
+}
+
+func TestRunUntilSignal(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 16
+	p := NewProcessor(strings.NewReader(numbers(1000)), &config)
+
+	var chunks int32
+	stats, err := p.RunUntilSignal(func(chunk Chunk) error {
+		if atomic.AddInt32(&chunks, 1) == 3 {
+			syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+		}
+		time.Sleep(time.Millisecond)
+		return nil
+	}, syscall.SIGUSR1)
+	assert.Nil(t, err)
+	assert.True(t, stats.Stopped)
+	assert.Less(t, stats.RowsDelivered, int64(1000))
+	assert.Equal(t, stats.RowsRead, stats.RowsDelivered)
+}
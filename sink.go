@@ -0,0 +1,279 @@
+package parallel_csv
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Sink receives the rows produced by a processor. Calls are never concurrent: rows are written in
+// batches, one per chunk, in source order
+type Sink interface {
+	// Open is called once with the header of the rows, before any Write
+	Open(header []string) error
+	Write(rows [][]string) error
+	// Close is called once after the last Write, even if the run failed
+	Close() error
+}
+
+// AbortableSink is a sink which must know whether the run succeeded, such as one replacing a file
+// only once complete. Abort is called instead of Close when the run failed, with its error
+type AbortableSink interface {
+	Sink
+	Abort(err error)
+}
+
+// closeSink closes the sink, or aborts it if err is not nil. It returns err, or the error of
+// closing the sink
+func closeSink(sink Sink, err error) error {
+	if err != nil && !errors.Is(err, errStopRun) {
+		abortSink(sink, err)
+		return err
+	}
+	if closeErr := sink.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// abortSink aborts the sink if it is an AbortableSink, otherwise it closes it
+func abortSink(sink Sink, err error) {
+	if abortable, ok := sink.(AbortableSink); ok {
+		abortable.Abort(err)
+	} else {
+		sink.Close()
+	}
+}
+
+// QuotingPolicy tells which fields a CSVSink quotes
+type QuotingPolicy int
+
+const (
+	// QuoteMinimal quotes the fields containing a separator, a quote or a line break
+	QuoteMinimal QuotingPolicy = iota
+	// QuoteAll quotes every field, header included
+	QuoteAll
+	// QuoteNonNumeric quotes every field but the numbers, empty fields included
+	QuoteNonNumeric
+)
+
+// CSVSink writes rows as CSV to an io.Writer
+type CSVSink struct {
+	Separator string
+	// NoHeader skips the header line
+	NoHeader bool
+	// Quoting is the policy deciding which fields are quoted, QuoteMinimal by default
+	Quoting QuotingPolicy
+	// QuoteChar quotes the fields, Quote if empty. The quotes inside a field are doubled
+	QuoteChar string
+	w         *bufio.Writer
+}
+
+// NewCSVSink creates a sink writing to w, using separator between fields
+func NewCSVSink(w io.Writer, separator string) *CSVSink {
+	return &CSVSink{
+		Separator: separator,
+		w:         bufio.NewWriter(w),
+	}
+}
+
+func (s *CSVSink) Open(header []string) error {
+	if s.NoHeader || len(header) == 0 {
+		return nil
+	}
+	return s.writeRow(header)
+}
+
+func (s *CSVSink) Write(rows [][]string) error {
+	for _, row := range rows {
+		if err := s.writeRow(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *CSVSink) writeRow(row []string) error {
+	for i, field := range row {
+		if i > 0 {
+			if _, err := s.w.WriteString(s.Separator); err != nil {
+				return err
+			}
+		}
+		if _, err := s.w.WriteString(s.quote(field)); err != nil {
+			return err
+		}
+	}
+	_, err := s.w.WriteString(LineBreak)
+	return err
+}
+
+// size is the number of bytes writeRow writes for row
+func (s *CSVSink) size(row []string) int {
+	n := len(LineBreak)
+	for i, field := range row {
+		if i > 0 {
+			n += len(s.Separator)
+		}
+		n += len(s.quote(field))
+	}
+	return n
+}
+
+// quote quotes a field as required by the policy
+func (s *CSVSink) quote(field string) string {
+	quote := s.QuoteChar
+	if quote == "" {
+		quote = Quote
+	}
+	switch s.Quoting {
+	case QuoteAll:
+		return quoteFieldWith(field, s.Separator, quote, true)
+	case QuoteNonNumeric:
+		_, err := strconv.ParseFloat(field, 64)
+		return quoteFieldWith(field, s.Separator, quote, err != nil)
+	default:
+		return quoteFieldWith(field, s.Separator, quote, false)
+	}
+}
+
+// Close flushes the buffered rows, the underlying writer is left open
+func (s *CSVSink) Close() error {
+	return s.w.Flush()
+}
+
+// orderedSink writes the rows of each chunk in source order, whichever worker produced them
+// first. The worker completing a sequence of chunks writes it
+type orderedSink struct {
+	mu      sync.Mutex
+	sink    Sink
+	next    int
+	pending map[int]pendingRows
+	err     error
+	// retry runs again the writes failing with transient errors, calling retried each time
+	retry   RetryPolicy
+	retried func(attempt int, err error)
+}
+
+// pendingRows are rows waiting for their turn, release is called once they have been written and
+// ack, if set, once an AckSink has delivered them
+type pendingRows struct {
+	rows    [][]string
+	release func()
+	ack     func(err error)
+}
+
+func newOrderedSink(sink Sink) *orderedSink {
+	return &orderedSink{
+		sink:    sink,
+		pending: map[int]pendingRows{},
+		retried: func(int, error) {},
+	}
+}
+
+// write hands over the rows of chunk index, it returns the first error of the sink
+func (o *orderedSink) write(index int, rows [][]string, release func()) error {
+	return o.writeAck(index, rows, release, nil)
+}
+
+// writeAck is write calling ack once the rows have been delivered
+func (o *orderedSink) writeAck(index int, rows [][]string, release func(), ack func(err error)) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.pending[index] = pendingRows{rows: rows, release: release, ack: ack}
+	for {
+		pending, ok := o.pending[o.next]
+		if !ok {
+			return o.err
+		}
+		delete(o.pending, o.next)
+		o.next++
+		o.writePending(pending)
+	}
+}
+
+func (o *orderedSink) writePending(pending pendingRows) {
+	ackSink, acking := o.sink.(AckSink)
+	acking = acking && pending.ack != nil
+	written := false
+	if o.err == nil && len(pending.rows) > 0 {
+		o.err = o.retry.do(nil, o.retried, func() error {
+			if acking {
+				return ackSink.WriteAck(pending.rows, pending.ack)
+			}
+			return o.sink.Write(pending.rows)
+		})
+		written = o.err == nil
+	}
+	pending.release()
+
+	// the sink acknowledges the rows it has accepted, the others are up to the worker
+	if pending.ack != nil && !(acking && written) {
+		pending.ack(nil)
+	}
+}
+
+// flush writes the rows still waiting for a chunk which never came, because it failed
+func (o *orderedSink) flush() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	indexes := make([]int, 0, len(o.pending))
+	for index := range o.pending {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	for _, index := range indexes {
+		o.writePending(o.pending[index])
+		delete(o.pending, index)
+	}
+	return o.err
+}
+
+// runSink opens the sink, runs job writing each chunk through an orderedSink and closes the sink.
+// The job returns the rows to write for its chunk
+func (p processor) runSink(sink Sink, header []string, job func(chunk Chunk) ([][]string, error)) error {
+	if err := sink.Open(header); err != nil {
+		return closeSink(sink, err)
+	}
+
+	ordered := newOrderedSink(sink)
+	ordered.retry, ordered.retried = p.config.Retry, p.retried
+	err := p.RunChunks(func(chunk Chunk) error {
+		// rows may point into the chunk buffer and be written after the job returns
+		release := chunk.Retain()
+		var rows [][]string
+		err := p.config.Retry.do(nil, p.retried, func() (err error) {
+			rows, err = job(chunk)
+			return err
+		})
+		// another worker runs the chunk again, which is handed over only then
+		if err != nil && chunk.redispatch {
+			release()
+			return permanentError{err}
+		}
+		// the chunk is handed over even when failing, so that the following ones are not held back
+		var ack func(err error)
+		if _, ok := sink.(AckSink); ok && chunk.ack != nil {
+			ack = chunk.ack.hold()
+		}
+		if writeErr := ordered.writeAck(chunk.Index, rows, release, ack); err == nil {
+			err = writeErr
+		}
+		// the chunk cannot be handed over twice, so the worker must not retry it
+		if err != nil {
+			return permanentError{err}
+		}
+		return nil
+	})
+
+	if flushErr := ordered.flush(); err == nil {
+		err = flushErr
+	}
+	return closeSink(sink, err)
+}
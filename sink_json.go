@@ -0,0 +1,90 @@
+package parallel_csv
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+// JSONLinesSink writes each row as a JSON object on its own line, keyed by column name.
+// Columns of files without header are named col_1, col_2 and so on
+type JSONLinesSink struct {
+	header []string
+	w      *bufio.Writer
+}
+
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{w: bufio.NewWriter(w)}
+}
+
+func (s *JSONLinesSink) Open(header []string) error {
+	s.header = header
+	return nil
+}
+
+func (s *JSONLinesSink) Write(rows [][]string) error {
+	for _, row := range rows {
+		line, err := json.Marshal(rowObject(s.header, row))
+		if err != nil {
+			return err
+		}
+		if _, err := s.w.Write(line); err != nil {
+			return err
+		}
+		if _, err := s.w.WriteString(LineBreak); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close flushes the buffered rows, the underlying writer is left open
+func (s *JSONLinesSink) Close() error {
+	return s.w.Flush()
+}
+
+// orderedObject maps the column names to the fields of a row, keeping the header order when encoded
+type orderedObject struct {
+	keys   []string
+	values []interface{}
+}
+
+func rowObject(header []string, row []string) orderedObject {
+	values := make([]interface{}, len(row))
+	for i, field := range row {
+		values[i] = field
+	}
+	return typedObject(header, values)
+}
+
+// typedObject is like rowObject for the values converted by bindTypes
+func typedObject(header []string, values []interface{}) orderedObject {
+	object := orderedObject{values: values}
+	for i := range values {
+		if i < len(header) {
+			object.keys = append(object.keys, header[i])
+		} else {
+			object.keys = append(object.keys, columnName(i))
+		}
+	}
+	return object
+}
+
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	b := []byte{'{'}
+	for i, key := range o.keys {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		b = append(append(append(b, k...), ':'), v...)
+	}
+	return append(b, '}'), nil
+}
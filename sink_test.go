@@ -0,0 +1,74 @@
+package parallel_csv
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// memorySink keeps the rows written to it
+type memorySink struct {
+	header []string
+	rows   [][]string
+	closed bool
+}
+
+func (s *memorySink) Open(header []string) error {
+	s.header = header
+	return nil
+}
+
+func (s *memorySink) Write(rows [][]string) error {
+	s.rows = append(s.rows, rows...)
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestOrderedSink(t *testing.T) {
+	sink := &memorySink{}
+	ordered := newOrderedSink(sink)
+	released := 0
+	release := func() { released++ }
+
+	assert.Nil(t, ordered.write(1, [][]string{{"b"}}, release))
+	assert.Nil(t, ordered.write(3, [][]string{{"d"}}, release))
+	assert.Empty(t, sink.rows)
+
+	assert.Nil(t, ordered.write(0, [][]string{{"a"}}, release))
+	assert.Equal(t, [][]string{{"a"}, {"b"}}, sink.rows)
+	assert.Equal(t, 2, released)
+
+	// chunk 2 never comes
+	assert.Nil(t, ordered.flush())
+	assert.Equal(t, [][]string{{"a"}, {"b"}, {"d"}}, sink.rows)
+	assert.Equal(t, 3, released)
+}
+
+func TestCSVSinkQuoting(t *testing.T) {
+	tests := []struct {
+		quoting   QuotingPolicy
+		quoteChar string
+		expected  string
+	}{
+		{QuoteMinimal, "", "id,name,amount\n1,\"bob, jr\",10.5\n2,,\"say \"\"hi\"\"\"\n"},
+		{QuoteAll, "", "\"id\",\"name\",\"amount\"\n\"1\",\"bob, jr\",\"10.5\"\n\"2\",\"\",\"say \"\"hi\"\"\"\n"},
+		{QuoteNonNumeric, "", "\"id\",\"name\",\"amount\"\n1,\"bob, jr\",10.5\n2,\"\",\"say \"\"hi\"\"\"\n"},
+		{QuoteMinimal, "'", "id,name,amount\n1,'bob, jr',10.5\n2,,say \"hi\"\n"},
+		{QuoteAll, "'", "'id','name','amount'\n'1','bob, jr','10.5'\n'2','','say \"hi\"'\n"},
+	}
+
+	for _, test := range tests {
+		out := &bytes.Buffer{}
+		sink := NewCSVSink(out, ",")
+		sink.Quoting, sink.QuoteChar = test.quoting, test.quoteChar
+
+		assert.Nil(t, sink.Open([]string{"id", "name", "amount"}))
+		assert.Nil(t, sink.Write([][]string{{"1", "bob, jr", "10.5"}, {"2", "", `say "hi"`}}))
+		assert.Nil(t, sink.Close())
+		assert.Equal(t, test.expected, out.String())
+	}
+}
@@ -0,0 +1,362 @@
+package parallel_csv
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxMergeFanIn is the number of runs merged at once, more runs are merged in several passes
+const maxMergeFanIn = 64
+
+// SortKey is a column to sort by
+type SortKey struct {
+	Column string
+	// Type decides how values are compared: IntegerType and FloatType as numbers, TimeType as
+	// times in Layout (time.RFC3339 if empty), anything else as strings. Empty values and the
+	// ones which cannot be parsed sort last, whatever the direction
+	Type       ColumnType
+	Layout     string
+	Descending bool
+}
+
+// sortValue is a parsed key value, empty values and values of the wrong type are not valid
+type sortValue struct {
+	valid  bool
+	number float64
+	time   time.Time
+	text   string
+}
+
+type boundSortKey struct {
+	SortKey
+	index int
+}
+
+func (k boundSortKey) parse(fields []string) sortValue {
+	if k.index >= len(fields) {
+		return sortValue{}
+	}
+
+	value := strings.TrimSpace(fields[k.index])
+	if value == "" {
+		return sortValue{}
+	}
+
+	switch k.Type {
+	case IntegerType, FloatType:
+		x, err := strconv.ParseFloat(value, 64)
+		return sortValue{valid: err == nil, number: x}
+	case TimeType:
+		layout := k.Layout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.Parse(layout, value)
+		return sortValue{valid: err == nil, time: t}
+	default:
+		return sortValue{valid: true, text: value}
+	}
+}
+
+func (k boundSortKey) compare(a, b sortValue) int {
+	if !a.valid || !b.valid {
+		// not reversed by Descending
+		return boolCompare(b.valid, a.valid)
+	}
+
+	c := 0
+	switch {
+	case k.Type == IntegerType || k.Type == FloatType:
+		c = compareFloats(a.number, b.number)
+	case k.Type == TimeType:
+		c = compareTimes(a.time, b.time)
+	default:
+		c = strings.Compare(a.text, b.text)
+	}
+
+	if k.Descending {
+		return -c
+	}
+	return c
+}
+
+func boolCompare(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareTimes(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// sortRow is a row with its parsed keys and source line, used to keep the sort stable
+type sortRow struct {
+	row  string
+	line int
+	keys []sortValue
+}
+
+type rowSorter struct {
+	p    processor
+	keys []boundSortKey
+}
+
+func (s rowSorter) row(row string, line int) sortRow {
+	fields := s.p.split(row)
+	values := make([]sortValue, len(s.keys))
+	for i, key := range s.keys {
+		values[i] = key.parse(fields)
+	}
+	return sortRow{row: row, line: line, keys: values}
+}
+
+func (s rowSorter) less(a, b sortRow) bool {
+	for i, key := range s.keys {
+		if c := key.compare(a.keys[i], b.keys[i]); c != 0 {
+			return c < 0
+		}
+	}
+	return a.line < b.line
+}
+
+// Sort writes the rows to out, header first, sorted by the keys. Sorting rows happens in
+// parallel: each chunk is sorted by a worker and saved as a run in a temporary file, in
+// Config.SpillDir or in the default directory for temporary files. Runs are then merged, so
+// that the file does not need to fit in memory
+func (p processor) Sort(keys []SortKey, out io.Writer) error {
+	sorter := rowSorter{p: p}
+	for _, key := range keys {
+		index := p.ColumnIndex(key.Column)
+		if index == -1 {
+			return fmt.Errorf("%w: %s", ColumnNotFoundError, key.Column)
+		}
+		sorter.keys = append(sorter.keys, boundSortKey{SortKey: key, index: index})
+	}
+
+	dir, err := os.MkdirTemp(p.config.SpillDir, "parallel-csv-sort-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	var runs []string
+	mu := sync.Mutex{}
+	err = p.RunChunks(func(chunk Chunk) error {
+		rows := make([]sortRow, len(chunk.Rows))
+		for i, row := range chunk.Rows {
+			if chunk.Cancelled() {
+				return errCancelled
+			}
+			rows[i] = sorter.row(row, chunk.Line(i))
+		}
+		sort.Slice(rows, func(i, j int) bool {
+			return sorter.less(rows[i], rows[j])
+		})
+
+		run, err := writeRun(dir, rows)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		runs = append(runs, run)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// merge the runs in several passes until they are few enough to be merged in the output
+	for len(runs) > maxMergeFanIn {
+		var merged []string
+		for i := 0; i < len(runs); i += maxMergeFanIn {
+			end := i + maxMergeFanIn
+			if end > len(runs) {
+				end = len(runs)
+			}
+
+			run, err := os.CreateTemp(dir, "run-")
+			if err != nil {
+				return err
+			}
+			w := bufio.NewWriter(run)
+			err = mergeRuns(sorter, runs[i:end], func(row sortRow) error {
+				return writeRecord(w, []string{strconv.Itoa(row.line), row.row})
+			})
+			if err == nil {
+				err = w.Flush()
+			}
+			run.Close()
+			if err != nil {
+				return err
+			}
+			merged = append(merged, run.Name())
+		}
+		runs = merged
+	}
+
+	w := bufio.NewWriter(out)
+	if len(p.header) > 0 {
+		if _, err := w.WriteString(p.formatRow(p.header) + LineBreak); err != nil {
+			return err
+		}
+	}
+	err = mergeRuns(sorter, runs, func(row sortRow) error {
+		_, err := w.WriteString(row.row + LineBreak)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// formatRow joins fields with the separator, quoting them when needed
+func (p processor) formatRow(fields []string) string {
+	separator := p.config.HeaderConfig.Separator
+	quoted := make([]string, len(fields))
+	for i, field := range fields {
+		quoted[i] = quoteField(field, separator)
+	}
+	return strings.Join(quoted, separator)
+}
+
+// writeRun saves sorted rows in a temporary file and returns its name
+func writeRun(dir string, rows []sortRow) (string, error) {
+	file, err := os.CreateTemp(dir, "run-")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	for _, row := range rows {
+		if err := writeRecord(w, []string{strconv.Itoa(row.line), row.row}); err != nil {
+			return "", err
+		}
+	}
+	return file.Name(), w.Flush()
+}
+
+// runReader reads back the rows of a run
+type runReader struct {
+	file    *os.File
+	reader  *bufio.Reader
+	current sortRow
+}
+
+func (r *runReader) next(sorter rowSorter) (bool, error) {
+	record, err := readRecord(r.reader)
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	line, err := strconv.Atoi(record[0])
+	if err != nil {
+		return false, err
+	}
+	r.current = sorter.row(record[1], line)
+	return true, nil
+}
+
+// runHeap orders the run readers by their current row
+type runHeap struct {
+	sorter  rowSorter
+	readers []*runReader
+}
+
+func (h *runHeap) Len() int { return len(h.readers) }
+func (h *runHeap) Less(i, j int) bool {
+	return h.sorter.less(h.readers[i].current, h.readers[j].current)
+}
+func (h *runHeap) Swap(i, j int)      { h.readers[i], h.readers[j] = h.readers[j], h.readers[i] }
+func (h *runHeap) Push(x interface{}) { h.readers = append(h.readers, x.(*runReader)) }
+func (h *runHeap) Pop() interface{} {
+	last := h.readers[len(h.readers)-1]
+	h.readers = h.readers[:len(h.readers)-1]
+	return last
+}
+
+// mergeRuns performs a k-way merge of sorted runs, emitting rows in order
+func mergeRuns(sorter rowSorter, runs []string, emit func(row sortRow) error) error {
+	h := &runHeap{sorter: sorter}
+	defer func() {
+		for _, r := range h.readers {
+			r.file.Close()
+		}
+	}()
+
+	for _, run := range runs {
+		file, err := os.Open(run)
+		if err != nil {
+			return err
+		}
+
+		r := &runReader{file: file, reader: bufio.NewReader(file)}
+		ok, err := r.next(sorter)
+		if err != nil || !ok {
+			file.Close()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		h.readers = append(h.readers, r)
+	}
+	heap.Init(h)
+
+	for h.Len() > 0 {
+		r := h.readers[0]
+		if err := emit(r.current); err != nil {
+			return err
+		}
+
+		ok, err := r.next(sorter)
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(h, 0)
+		} else {
+			r.file.Close()
+			heap.Pop(h)
+		}
+	}
+	return nil
+}
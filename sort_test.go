@@ -0,0 +1,88 @@
+package parallel_csv
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSort(t *testing.T) {
+	input := "name,age,joined\n" +
+		"carla,30,2021-03-01\n" +
+		"anna,25,2020-01-15\n" +
+		"bob,30,2019-07-30\n" +
+		"dario,,2022-11-02\n" +
+		"elena,9,2021-03-01\n"
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 24
+
+	tests := []struct {
+		keys     []SortKey
+		expected []string
+	}{
+		{[]SortKey{{Column: "name"}}, []string{"anna", "bob", "carla", "dario", "elena"}},
+		{[]SortKey{{Column: "age", Type: IntegerType}}, []string{"elena", "anna", "carla", "bob", "dario"}},
+		{[]SortKey{{Column: "age", Type: IntegerType, Descending: true}, {Column: "name"}}, []string{"bob", "carla", "anna", "elena", "dario"}},
+		{[]SortKey{{Column: "joined", Type: TimeType, Layout: "2006-01-02"}}, []string{"bob", "anna", "carla", "elena", "dario"}},
+	}
+
+	for _, test := range tests {
+		out := &bytes.Buffer{}
+		p := NewProcessor(strings.NewReader(input), &config)
+		assert.Nil(t, p.Sort(test.keys, out))
+
+		lines := strings.Split(strings.TrimSuffix(out.String(), LineBreak), LineBreak)
+		assert.Equal(t, "name,age,joined", lines[0])
+
+		var names []string
+		for _, line := range lines[1:] {
+			names = append(names, strings.Split(line, ",")[0])
+		}
+		assert.Equal(t, test.expected, names)
+	}
+}
+
+func TestSortInvalidValues(t *testing.T) {
+	// empty values and the ones which are not numbers sort last in both directions
+	input := "name,age\nanna,25\nbob,n/a\ncarla,31\ndario,\nelena,9\n"
+	for _, descending := range []bool{false, true} {
+		out := &bytes.Buffer{}
+		p := NewProcessor(strings.NewReader(input), nil)
+		assert.Nil(t, p.Sort([]SortKey{{Column: "age", Type: IntegerType, Descending: descending}}, out))
+
+		expected := "name,age\nelena,9\nanna,25\ncarla,31\nbob,n/a\ndario,\n"
+		if descending {
+			expected = "name,age\ncarla,31\nanna,25\nelena,9\nbob,n/a\ndario,\n"
+		}
+		assert.Equal(t, expected, out.String(), descending)
+	}
+}
+
+func TestSortMultiplePasses(t *testing.T) {
+	content, err := os.ReadFile("testdata/mid.csv")
+	assert.Nil(t, err)
+
+	// small blocks produce more runs than can be merged at once
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 4 * KB
+	config.SpillDir = t.TempDir()
+	p := NewProcessor(bytes.NewReader(content), &config)
+
+	out := &bytes.Buffer{}
+	err = p.Sort([]SortKey{{Column: "Weight(Pounds)", Type: FloatType}}, out)
+	assert.Nil(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), LineBreak), LineBreak)
+	assert.Len(t, lines, 25001)
+	weights := make([]float64, 0, 25000)
+	for _, line := range lines[1:] {
+		weight, err := strconv.ParseFloat(strings.TrimSpace(strings.Split(line, ",")[2]), 64)
+		assert.Nil(t, err)
+		weights = append(weights, weight)
+	}
+	assert.True(t, sort.Float64sAreSorted(weights))
+}
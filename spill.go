@@ -0,0 +1,138 @@
+package parallel_csv
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/fnv"
+	"io"
+	"os"
+	"sync"
+)
+
+// spillPartitions is the number of files a spill splits its records into
+const spillPartitions = 64
+
+// spill hash-partitions records by their first field in temporary files, so that all the records
+// sharing a key end up in the same partition and each partition can be processed in memory
+type spill struct {
+	dir        string
+	partitions []*spillPartition
+}
+
+type spillPartition struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+}
+
+func newSpill(dir string) (*spill, error) {
+	dir, err := os.MkdirTemp(dir, "parallel-csv-")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &spill{dir: dir}
+	for i := 0; i < spillPartitions; i++ {
+		file, err := os.CreateTemp(dir, "partition-")
+		if err != nil {
+			s.close()
+			return nil, err
+		}
+		s.partitions = append(s.partitions, &spillPartition{file: file, writer: bufio.NewWriter(file)})
+	}
+
+	return s, nil
+}
+
+// write appends a record to the partition of its first field, it is safe for concurrent use
+func (s *spill) write(record ...string) error {
+	h := fnv.New32a()
+	h.Write([]byte(record[0]))
+	partition := s.partitions[h.Sum32()%spillPartitions]
+
+	partition.mu.Lock()
+	defer partition.mu.Unlock()
+	return writeRecord(partition.writer, record)
+}
+
+// each calls fn on every record of partition i
+func (s *spill) each(i int, fn func(record []string) error) error {
+	partition := s.partitions[i]
+	if err := partition.writer.Flush(); err != nil {
+		return err
+	}
+	if _, err := partition.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(partition.file)
+	for {
+		record, err := readRecord(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+}
+
+// close removes every temporary file
+func (s *spill) close() error {
+	for _, partition := range s.partitions {
+		partition.file.Close()
+	}
+	return os.RemoveAll(s.dir)
+}
+
+// writeRecord encodes a record as its number of fields followed by each length-prefixed field
+func writeRecord(w *bufio.Writer, record []string) error {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, uint64(len(record)))
+	if _, err := w.Write(buf[:n]); err != nil {
+		return err
+	}
+
+	for _, field := range record {
+		n = binary.PutUvarint(buf, uint64(len(field)))
+		if _, err := w.Write(buf[:n]); err != nil {
+			return err
+		}
+		if _, err := w.WriteString(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readRecord(r *bufio.Reader) ([]string, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+
+	record := make([]string, size)
+	for i := range record {
+		length, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		field := make([]byte, length)
+		if _, err := io.ReadFull(r, field); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		record[i] = string(field)
+	}
+	return record, nil
+}
+
+// unexpectedEOF reports an EOF in the middle of a record as a truncated record
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
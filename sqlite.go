@@ -0,0 +1,196 @@
+package parallel_csv
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SQLiteDriver is the name of the database/sql driver opening the databases of ImportSQLite:
+// "sqlite3" for mattn/go-sqlite3, "sqlite" for modernc.org/sqlite. The program must import it
+var SQLiteDriver = "sqlite3"
+
+// ImportSQLite copies the rows into table of the SQLite database at dbPath, which is created if
+// missing. The table is created as well, its columns typed after InferSchema: INTEGER for integers
+// and booleans, REAL for floats and TEXT for the others, times keeping their text. Inputs
+// implementing io.Seeker, such as files, are read twice, to infer the types from every row first.
+// The types of the others are inferred from what is already buffered. Rows are inserted in
+// transactions of DefaultBatchRows rows, a failing transaction being rolled back: the ones
+// committed before it stay. Empty values are inserted as NULL
+func (p *processor) ImportSQLite(dbPath string, table string) error {
+	schema, err := p.importSchema()
+	if err != nil {
+		return err
+	}
+
+	db, err := sql.Open(SQLiteDriver, dbPath)
+	if err != nil {
+		return err
+	}
+	// SQLite has a single writer
+	db.SetMaxOpenConns(1)
+	err = p.Copy(&sqliteSink{ctx: context.Background(), db: db, table: table, inferred: schema})
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// importSchema infers the types of the columns with a processor of its own, reading the rows
+// again from a seekable input or the ones buffered from the others
+func (p *processor) importSchema() (*InferredSchema, error) {
+	var rows io.Reader
+	if seeker, ok := p.source.(io.ReadSeeker); ok {
+		position, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := seeker.Seek(p.start.Offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		// the buffered reader goes on from where it was
+		defer seeker.Seek(position, io.SeekStart)
+		rows = seeker
+	} else {
+		// Peek fails when the input is shorter than the buffer, returning what there is
+		sample, err := p.reader.Peek(p.reader.Size())
+		if err == nil {
+			sample = sample[:p.parser.RecordsEnd(sample)]
+		}
+		rows = bytes.NewReader(sample)
+	}
+
+	other, err := newProcessor(rows, &Config{
+		NumberOfWorkers: p.config.NumberOfWorkers,
+		BytesPerWorker:  p.config.BytesPerWorker,
+		HeaderConfig:    HeaderConfig{Separator: p.config.HeaderConfig.Separator},
+		Parser:          p.config.Parser,
+	})
+	if err != nil {
+		return nil, err
+	}
+	schema, err := other.InferSchema()
+	if err == EmptyFileError {
+		return &InferredSchema{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range schema.Columns {
+		if i < len(p.header) {
+			schema.Columns[i].Name = p.header[i]
+		}
+	}
+	return schema, nil
+}
+
+// sqliteSink creates the table of ImportSQLite and inserts the rows in transactions
+type sqliteSink struct {
+	ctx       context.Context
+	db        *sql.DB
+	table     string
+	inferred  *InferredSchema
+	statement string
+	columns   int
+	types     typedRow
+	loader    *parallelLoader
+}
+
+func (s *sqliteSink) Open(header []string) error {
+	// files without header have the columns named by InferSchema
+	if len(header) == 0 {
+		for _, column := range s.inferred.Columns {
+			header = append(header, column.Name)
+		}
+	}
+	if len(header) == 0 {
+		return fmt.Errorf("sqlite table %s needs the columns of files without header", s.table)
+	}
+	// the columns added by transforms are not inferred, and stay TEXT
+	types := map[string]ColumnType{}
+	for _, column := range s.inferred.Columns {
+		types[column.Name] = column.Type
+	}
+	schema := &Schema{}
+	definitions := make([]string, len(header))
+	placeholders := make([]string, len(header))
+	for i, column := range header {
+		typ := types[column]
+		switch typ {
+		case IntegerType, BooleanType:
+			definitions[i] = quoteIdentifier(column) + " INTEGER"
+		case FloatType:
+			definitions[i] = quoteIdentifier(column) + " REAL"
+		default:
+			// times keep their text, which SQLite date functions read
+			typ = ""
+			definitions[i] = quoteIdentifier(column) + " TEXT"
+		}
+		if typ != "" {
+			schema.Columns = append(schema.Columns, ColumnSchema{Name: column, Type: typ})
+		}
+		placeholders[i] = "?"
+	}
+	create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", quoteIdentifier(s.table), strings.Join(definitions, ", "))
+	if _, err := s.db.ExecContext(s.ctx, create); err != nil {
+		return fmt.Errorf("create table %s: %w", s.table, err)
+	}
+	s.statement = fmt.Sprintf("INSERT INTO %s VALUES (%s)", quoteIdentifier(s.table), strings.Join(placeholders, ", "))
+	s.columns = len(header)
+
+	var err error
+	if s.types, err = bindTypes(header, schema); err != nil {
+		return err
+	}
+	s.loader = newParallelLoader(1, DefaultBatchRows, s.insert)
+	return nil
+}
+
+func (s *sqliteSink) Write(rows [][]string) error {
+	for _, row := range rows {
+		values, err := s.types(row)
+		if err != nil {
+			return err
+		}
+		// missing fields are NULL and extra ones are dropped
+		for len(values) < s.columns {
+			values = append(values, nil)
+		}
+		if err := s.loader.add(values[:s.columns]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteSink) Close() error {
+	if s.loader == nil {
+		return nil
+	}
+	return s.loader.close()
+}
+
+// insert inserts a batch in a transaction
+func (s *sqliteSink) insert(worker int, batch [][]interface{}) error {
+	tx, err := s.db.BeginTx(s.ctx, nil)
+	if err != nil {
+		return err
+	}
+	statement, err := tx.PrepareContext(s.ctx, s.statement)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, values := range batch {
+		if _, err := statement.ExecContext(s.ctx, values...); err != nil {
+			statement.Close()
+			tx.Rollback()
+			return fmt.Errorf("insert into %s: %w", s.table, err)
+		}
+	}
+	statement.Close()
+	return tx.Commit()
+}
@@ -0,0 +1,143 @@
+package parallel_csv
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSQLite is a database/sql driver keeping the statements run and the rows committed by
+// database path
+type fakeSQLite struct {
+	mu        sync.Mutex
+	creates   map[string]string
+	rows      map[string][][]driver.Value
+	failValue driver.Value
+}
+
+var sqliteDatabases = &fakeSQLite{}
+
+func init() {
+	sql.Register("fakesqlite", sqliteDatabases)
+}
+
+func (d *fakeSQLite) Open(name string) (driver.Conn, error) {
+	return &fakeSQLiteConn{db: d, name: name}, nil
+}
+
+type fakeSQLiteConn struct {
+	db      *fakeSQLite
+	name    string
+	pending [][]driver.Value
+}
+
+func (c *fakeSQLiteConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeSQLiteStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeSQLiteConn) Close() error {
+	return nil
+}
+
+func (c *fakeSQLiteConn) Begin() (driver.Tx, error) {
+	c.pending = nil
+	return c, nil
+}
+
+func (c *fakeSQLiteConn) Commit() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.rows[c.name] = append(c.db.rows[c.name], c.pending...)
+	return nil
+}
+
+func (c *fakeSQLiteConn) Rollback() error {
+	c.pending = nil
+	return nil
+}
+
+type fakeSQLiteStmt struct {
+	conn  *fakeSQLiteConn
+	query string
+}
+
+func (s *fakeSQLiteStmt) Close() error {
+	return nil
+}
+
+func (s *fakeSQLiteStmt) NumInput() int {
+	return strings.Count(s.query, "?")
+}
+
+func (s *fakeSQLiteStmt) Exec(args []driver.Value) (driver.Result, error) {
+	db := s.conn.db
+	if strings.HasPrefix(s.query, "CREATE") {
+		db.mu.Lock()
+		db.creates[s.conn.name] = s.query
+		db.mu.Unlock()
+		return driver.RowsAffected(0), nil
+	}
+	for _, arg := range args {
+		if db.failValue != nil && arg == db.failValue {
+			return nil, errors.New("constraint failed")
+		}
+	}
+	s.conn.pending = append(s.conn.pending, args)
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeSQLiteStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, io.EOF
+}
+
+func TestImportSQLite(t *testing.T) {
+	SQLiteDriver = "fakesqlite"
+	defer func() { SQLiteDriver = "sqlite3" }()
+	sqliteDatabases.creates, sqliteDatabases.rows = map[string]string{}, map[string][][]driver.Value{}
+
+	// the float only appears in the last row, which a seekable input infers anyway
+	path := filepath.Join(t.TempDir(), "people.csv")
+	input := "id,name,score,active,born\n1,Mario,3,true,2021-01-02\n2,\"Luigi, jr\",,false,\n3,Peach,4.5,true,2020-05-06\n"
+	assert.Nil(t, os.WriteFile(path, []byte(input), 0o644))
+	f, err := os.Open(path)
+	assert.Nil(t, err)
+	defer f.Close()
+
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 32
+	p := NewProcessor(f, &config)
+	assert.Nil(t, p.ImportSQLite("people.db", "people"))
+	assert.Equal(t, `CREATE TABLE IF NOT EXISTS "people" ("id" INTEGER, "name" TEXT, "score" REAL, "active" INTEGER, "born" TEXT)`,
+		sqliteDatabases.creates["people.db"])
+	assert.Equal(t, [][]driver.Value{
+		{int64(1), "Mario", 3.0, true, "2021-01-02"},
+		{int64(2), "Luigi, jr", nil, false, nil},
+		{int64(3), "Peach", 4.5, true, "2020-05-06"},
+	}, sqliteDatabases.rows["people.db"])
+
+	// other inputs are inferred from what is buffered, here the whole file
+	assert.Nil(t, NewProcessor(strings.NewReader(numbers(10)), nil).ImportSQLite("numbers.db", "numbers"))
+	assert.Equal(t, `CREATE TABLE IF NOT EXISTS "numbers" ("n" INTEGER)`, sqliteDatabases.creates["numbers.db"])
+	assert.Len(t, sqliteDatabases.rows["numbers.db"], 10)
+}
+
+func TestImportSQLiteRollback(t *testing.T) {
+	SQLiteDriver = "fakesqlite"
+	defer func() { SQLiteDriver = "sqlite3" }()
+	sqliteDatabases.creates, sqliteDatabases.rows = map[string]string{}, map[string][][]driver.Value{}
+
+	sqliteDatabases.failValue = int64(DefaultBatchRows + 5)
+	defer func() { sqliteDatabases.failValue = nil }()
+	err := NewProcessor(strings.NewReader(numbers(3*DefaultBatchRows)), nil).ImportSQLite("failing.db", "numbers")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "constraint failed")
+	// the first transaction has been committed, the second one rolled back
+	assert.Len(t, sqliteDatabases.rows["failing.db"], DefaultBatchRows)
+}
@@ -0,0 +1,174 @@
+package parallel_csv
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultProgressInterval is the time between two events of a ProgressStream when not set
+const DefaultProgressInterval = 500 * time.Millisecond
+
+// ProgressEvent is the data of the events of a ProgressStream, encoded as JSON
+type ProgressEvent struct {
+	BytesRead int64 `json:"bytes_read"`
+	// TotalBytes is the size of the input, 0 when unknown. Percent and ETASeconds need it
+	TotalBytes int64   `json:"total_bytes,omitempty"`
+	Percent    float64 `json:"percent,omitempty"`
+	Rows       int64   `json:"rows"`
+	// RowsSkipped counts the rows dropped by the error policy, Errors the errors it skipped
+	RowsSkipped int64  `json:"rows_skipped"`
+	Errors      int64  `json:"errors"`
+	LastError   string `json:"last_error,omitempty"`
+	// ElapsedSeconds is the time since the stream was created, ETASeconds the time left at the
+	// current speed
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	ETASeconds     float64 `json:"eta_seconds,omitempty"`
+	// Done tells that the run has ended, Error holding why it failed
+	Done  bool   `json:"done"`
+	Error string `json:"error,omitempty"`
+}
+
+// ProgressStream is an http.Handler streaming the progress of a run as server-sent events, for
+// as long as the client stays connected. A "progress" event is sent every Interval, and a "done"
+// event once Finish is called, which ends the stream. Any number of clients can follow the same
+// run. The stream must be created right before the run, which times it:
+//
+//	stream := NewProgressStream(p, size)
+//	go func() { stream.Finish(p.RunChunks(job)) }()
+//
+// Calling Skipped from Config.ErrorHandler counts the errors skipped as well
+type ProgressStream struct {
+	// Interval is the time between two events, DefaultProgressInterval if 0
+	Interval   time.Duration
+	processor  Processor
+	totalBytes int64
+	// start is when the stream was created, startBytes what had been read already, the header
+	start      time.Time
+	startBytes int64
+	mu         sync.Mutex
+	errors     int64
+	lastError  error
+	done       chan struct{}
+	err        error
+}
+
+// NewProgressStream creates a stream of the progress of the run of p, whose input has totalBytes
+// bytes, 0 if unknown
+func NewProgressStream(p Processor, totalBytes int64) *ProgressStream {
+	return &ProgressStream{
+		processor:  p,
+		totalBytes: totalBytes,
+		start:      time.Now(),
+		startBytes: p.Stats().BytesRead,
+		done:       make(chan struct{}),
+	}
+}
+
+// Skipped counts an error skipped by the error policy, it can be used as Config.ErrorHandler
+func (s *ProgressStream) Skipped(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors++
+	s.lastError = err
+}
+
+// Finish ends the streams with the error of the run, nil if it succeeded
+func (s *ProgressStream) Finish(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.done:
+	default:
+		s.err = err
+		close(s.done)
+	}
+}
+
+// Event returns the progress of the run up to now
+func (s *ProgressStream) Event() ProgressEvent {
+	stats := s.processor.Stats()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event := ProgressEvent{
+		BytesRead:      stats.BytesRead,
+		TotalBytes:     s.totalBytes,
+		Rows:           stats.RowsDelivered,
+		RowsSkipped:    stats.RowsSkipped,
+		Errors:         s.errors,
+		ElapsedSeconds: time.Since(s.start).Seconds(),
+	}
+	if s.lastError != nil {
+		event.LastError = s.lastError.Error()
+	}
+	select {
+	case <-s.done:
+		event.Done = true
+		if s.err != nil {
+			event.Error = s.err.Error()
+		}
+	default:
+	}
+
+	if s.totalBytes > 0 {
+		event.Percent = 100 * float64(event.BytesRead) / float64(s.totalBytes)
+		if event.Percent > 100 || event.Done {
+			event.Percent = 100
+		}
+		if read := event.BytesRead - s.startBytes; read > 0 && !event.Done {
+			left := float64(s.totalBytes - event.BytesRead)
+			if left > 0 {
+				event.ETASeconds = event.ElapsedSeconds * left / float64(read)
+			}
+		}
+	}
+	return event
+}
+
+func (s *ProgressStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	interval := s.Interval
+	if interval <= 0 {
+		interval = DefaultProgressInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		event := s.Event()
+		name := "progress"
+		if event.Done {
+			name = "done"
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			return
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+			return
+		}
+		flusher.Flush()
+		if event.Done {
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.done:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
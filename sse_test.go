@@ -0,0 +1,96 @@
+package parallel_csv
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readEvents reads the server-sent events of a response until it ends
+func readEvents(t *testing.T, r *http.Response, received chan<- ProgressEvent) {
+	defer close(received)
+	scanner := bufio.NewScanner(r.Body)
+	name := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			event := ProgressEvent{}
+			assert.Nil(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event))
+			assert.Equal(t, name == "done", event.Done)
+			received <- event
+		}
+	}
+}
+
+func TestProgressStream(t *testing.T) {
+	input := numbers(1000)
+	var stream *ProgressStream
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 512
+	config.ErrorPolicy = SkipOnError
+	config.ErrorHandler = func(err error) { stream.Skipped(err) }
+	p := NewProcessor(strings.NewReader(input), &config)
+	stream = NewProgressStream(p, int64(len(input)))
+	stream.Interval = 10 * time.Millisecond
+
+	server := httptest.NewServer(stream)
+	defer server.Close()
+	response, err := http.Get(server.URL)
+	assert.Nil(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	received := make(chan ProgressEvent)
+	go readEvents(t, response, received)
+	first := <-received
+	assert.False(t, first.Done)
+	assert.Equal(t, int64(len(input)), first.TotalBytes)
+
+	release := make(chan struct{})
+	go func() {
+		stream.Finish(p.RunChunks(func(chunk Chunk) error {
+			<-release
+			for _, row := range chunk.Rows {
+				if row == "500" {
+					return errors.New("bad row")
+				}
+			}
+			return nil
+		}))
+	}()
+	close(release)
+
+	var last ProgressEvent
+	for event := range received {
+		last = event
+	}
+	assert.True(t, last.Done)
+	assert.Empty(t, last.Error)
+	assert.Equal(t, 100.0, last.Percent)
+	assert.Equal(t, int64(1), last.Errors)
+	assert.Equal(t, "bad row", last.LastError)
+	assert.Equal(t, int64(len(input)), last.BytesRead)
+}
+
+func TestProgressStreamETA(t *testing.T) {
+	p := NewProcessor(strings.NewReader(numbers(10)), nil)
+	stream := NewProgressStream(p, 100)
+	event := stream.Event()
+	assert.False(t, event.Done)
+	assert.Zero(t, event.ETASeconds)
+
+	stream.Finish(errors.New("disk full"))
+	recorder := httptest.NewRecorder()
+	stream.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/progress", nil))
+	assert.True(t, strings.HasPrefix(recorder.Body.String(), "event: done\ndata: {"))
+	assert.Contains(t, recorder.Body.String(), `"error":"disk full"`)
+}
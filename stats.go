@@ -0,0 +1,171 @@
+package parallel_csv
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const IntegrityError = Error("processed data does not match the input")
+
+// Stats reports how much data went through the processor
+type Stats struct {
+	// BytesRead counts the bytes read from the input, header included
+	BytesRead int64
+	// BytesDispatched counts the bytes sent to the workers, line breaks included
+	BytesDispatched int64
+	// RowsRead counts the rows found in the dispatched bytes
+	RowsRead int64
+	// RowsDelivered counts the rows handed to jobs
+	RowsDelivered int64
+	// RowsSkipped counts the rows dropped by the error policy
+	RowsSkipped int64
+	// RowsFiltered counts the rows dropped by Config.Filter or because they do not match
+	// Config.Regexps or Config.Where
+	RowsFiltered int64
+	// RowsMatched counts the rows matching Config.Regexps
+	RowsMatched int64
+	Chunks      int64
+	// Retries counts the jobs and sink writes run again after a transient error
+	Retries int64
+	// Redispatched counts the chunks handed back to the workers after their job failed
+	Redispatched int64
+	// RowsIgnored and BytesIgnored count the rows passed over because of Config.SkipDataRows,
+	// which are not among the rows read
+	RowsIgnored  int64
+	BytesIgnored int64
+	// Exhausted tells that reading stopped because Config.MaxRows or Config.MaxBytes was reached
+	Exhausted bool
+	// Stopped tells that the run has been ended early by Processor.Stop
+	Stopped bool
+	// QueueCapacity is the number of blocks read ahead of the workers, QueueDepth the number
+	// waiting for a worker and QueueHighWater the largest it has been. A queue usually full means
+	// that the workers are the bottleneck, a queue usually empty that the reader is.
+	// QueueSamples holds its depth every Config.QueueSampleInterval
+	QueueCapacity  int
+	QueueDepth     int
+	QueueHighWater int
+	QueueSamples   []QueueSample
+	// Workers holds the counters of each worker, by index. Workers much busier than the others
+	// got larger chunks: a smaller BytesPerWorker spreads the rows more evenly
+	Workers []WorkerStats
+}
+
+// WorkerStats are the counters of a worker
+type WorkerStats struct {
+	Worker int
+	// Chunks counts the chunks processed by the worker, Rows the rows they held
+	Chunks int64
+	Rows   int64
+	// Busy is the time spent processing chunks, Idle the time spent waiting for them
+	Busy time.Duration
+	Idle time.Duration
+}
+
+// counters are updated concurrently by the reader and the workers
+type counters struct {
+	bytesRead       int64
+	bytesDispatched int64
+	rowsRead        int64
+	rowsDelivered   int64
+	rowsSkipped     int64
+	rowsFiltered    int64
+	rowsMatched     int64
+	chunks          int64
+	retries         int64
+	redispatched    int64
+	rowsIgnored     int64
+	bytesIgnored    int64
+	exhausted       int32
+	stopped         int32
+	workers         []workerCounters
+	queue           chan workerData
+	queueHighWater  int64
+	// mu guards queueSamples
+	mu           sync.Mutex
+	queueSamples []QueueSample
+}
+
+// workerCounters are updated by a worker, and read concurrently by Stats
+type workerCounters struct {
+	chunks int64
+	rows   int64
+	busy   int64
+	idle   int64
+}
+
+func newCounters(workers int, queue chan workerData) *counters {
+	return &counters{workers: make([]workerCounters, workers), queue: queue}
+}
+
+func (c *counters) snapshot() Stats {
+	return Stats{
+		BytesRead:       atomic.LoadInt64(&c.bytesRead),
+		BytesDispatched: atomic.LoadInt64(&c.bytesDispatched),
+		RowsRead:        atomic.LoadInt64(&c.rowsRead),
+		RowsDelivered:   atomic.LoadInt64(&c.rowsDelivered),
+		RowsSkipped:     atomic.LoadInt64(&c.rowsSkipped),
+		RowsFiltered:    atomic.LoadInt64(&c.rowsFiltered),
+		RowsMatched:     atomic.LoadInt64(&c.rowsMatched),
+		Chunks:          atomic.LoadInt64(&c.chunks),
+		Retries:         atomic.LoadInt64(&c.retries),
+		Redispatched:    atomic.LoadInt64(&c.redispatched),
+		RowsIgnored:     atomic.LoadInt64(&c.rowsIgnored),
+		BytesIgnored:    atomic.LoadInt64(&c.bytesIgnored),
+		Exhausted:       atomic.LoadInt32(&c.exhausted) == 1,
+		Stopped:         atomic.LoadInt32(&c.stopped) == 1,
+		QueueCapacity:   cap(c.queue),
+		QueueDepth:      len(c.queue),
+		QueueHighWater:  int(atomic.LoadInt64(&c.queueHighWater)),
+		QueueSamples:    c.queueSampleList(),
+		Workers:         c.workerStats(),
+	}
+}
+
+func (c *counters) workerStats() []WorkerStats {
+	stats := make([]WorkerStats, len(c.workers))
+	for i := range c.workers {
+		w := &c.workers[i]
+		stats[i] = WorkerStats{
+			Worker: i,
+			Chunks: atomic.LoadInt64(&w.chunks),
+			Rows:   atomic.LoadInt64(&w.rows),
+			Busy:   time.Duration(atomic.LoadInt64(&w.busy)),
+			Idle:   time.Duration(atomic.LoadInt64(&w.idle)),
+		}
+	}
+	return stats
+}
+
+// processed counts a chunk of rows processed by worker in busy time, after waiting for it idle
+func (c *counters) processed(worker int, rows int, busy time.Duration, idle time.Duration) {
+	w := &c.workers[worker]
+	atomic.AddInt64(&w.chunks, 1)
+	atomic.AddInt64(&w.rows, int64(rows))
+	atomic.AddInt64(&w.busy, int64(busy))
+	c.waited(worker, idle)
+}
+
+// waited counts the time worker waited for a chunk
+func (c *counters) waited(worker int, idle time.Duration) {
+	atomic.AddInt64(&c.workers[worker].idle, int64(idle))
+}
+
+// reconcile checks that every byte read has been dispatched or ignored and every row dispatched
+// has been either delivered, skipped or filtered
+func (s Stats) reconcile(headerBytes int64) error {
+	if s.BytesRead != headerBytes+s.BytesDispatched+s.BytesIgnored {
+		return fmt.Errorf("%w: read %d bytes, dispatched %d, ignored %d",
+			IntegrityError, s.BytesRead-headerBytes, s.BytesDispatched, s.BytesIgnored)
+	}
+	if s.RowsRead != s.RowsDelivered+s.RowsSkipped+s.RowsFiltered {
+		return fmt.Errorf("%w: read %d rows, delivered %d, skipped %d, filtered %d",
+			IntegrityError, s.RowsRead, s.RowsDelivered, s.RowsSkipped, s.RowsFiltered)
+	}
+	return nil
+}
+
+func (p processor) Stats() Stats {
+	return p.counters.snapshot()
+}
@@ -0,0 +1,98 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	file := openFile("testdata/mid.csv")
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 10 * KB
+	p := NewProcessor(file, &config)
+
+	err := p.Run(func(header []string, rows []string) {})
+	assert.Nil(t, err)
+
+	stats := p.Stats()
+	assert.Equal(t, int64(633344), stats.BytesRead)
+	assert.Equal(t, int64(633344-36), stats.BytesDispatched)
+	assert.Equal(t, int64(25000), stats.RowsRead)
+	assert.Equal(t, int64(25000), stats.RowsDelivered)
+	assert.Zero(t, stats.RowsSkipped)
+	assert.Greater(t, stats.Chunks, int64(1))
+}
+
+func TestWorkerStats(t *testing.T) {
+	config := GetDefaultConfig()
+	config.NumberOfWorkers = 3
+	config.BytesPerWorker = 64
+	p := NewProcessor(strings.NewReader(numbers(1000)), &config)
+
+	err := p.Run(func(header []string, rows []string) {
+		time.Sleep(time.Millisecond)
+	})
+	assert.Nil(t, err)
+
+	stats := p.Stats()
+	assert.Len(t, stats.Workers, 3)
+	var chunks, rows int64
+	for i, worker := range stats.Workers {
+		assert.Equal(t, i, worker.Worker)
+		chunks += worker.Chunks
+		rows += worker.Rows
+		if worker.Chunks > 0 {
+			assert.GreaterOrEqual(t, worker.Busy, time.Duration(worker.Chunks)*time.Millisecond)
+		}
+	}
+	assert.Equal(t, stats.Chunks, chunks)
+	assert.Equal(t, int64(1000), rows)
+	assert.Greater(t, stats.Workers[0].Idle, time.Duration(0))
+}
+
+func TestQueueStats(t *testing.T) {
+	config := GetDefaultConfig()
+	config.NumberOfWorkers = 2
+	config.BytesPerWorker = 64
+	config.QueueSampleInterval = time.Millisecond
+	p := NewProcessor(strings.NewReader(numbers(1000)), &config)
+
+	// slow workers leave the queue full
+	err := p.Run(func(header []string, rows []string) {
+		time.Sleep(2 * time.Millisecond)
+	})
+	assert.Nil(t, err)
+
+	stats := p.Stats()
+	assert.Equal(t, 2, stats.QueueCapacity)
+	assert.Equal(t, 2, stats.QueueHighWater)
+	assert.Zero(t, stats.QueueDepth)
+	assert.NotEmpty(t, stats.QueueSamples)
+	for i, sample := range stats.QueueSamples {
+		assert.LessOrEqual(t, sample.Depth, 2)
+		if i > 0 {
+			assert.Greater(t, sample.At, stats.QueueSamples[i-1].At)
+		}
+	}
+}
+
+func TestStatsSkippedRows(t *testing.T) {
+	p := NewProcessor(strings.NewReader(malformed), malformedConfig(SkipOnError, nil))
+
+	err := p.Run(func(header []string, rows []string) {})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), p.Stats().RowsRead)
+	assert.Equal(t, int64(2), p.Stats().RowsDelivered)
+	assert.Equal(t, int64(2), p.Stats().RowsSkipped)
+}
+
+func TestReconcile(t *testing.T) {
+	stats := Stats{BytesRead: 100, BytesDispatched: 90, RowsRead: 10, RowsDelivered: 10}
+	assert.Nil(t, stats.reconcile(10))
+	assert.ErrorIs(t, stats.reconcile(0), IntegrityError)
+
+	stats.RowsDelivered = 9
+	assert.ErrorIs(t, stats.reconcile(10), IntegrityError)
+}
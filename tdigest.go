@@ -0,0 +1,127 @@
+package parallel_csv
+
+import (
+	"math"
+	"sort"
+)
+
+// tDigestCompression bounds the number of centroids of a tDigest. The larger, the more accurate
+// the quantiles
+const tDigestCompression = 100
+
+// centroid is the mean of some values along with their number
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+// tDigest is a t-digest, a sketch estimating the quantiles of a set of numbers in bounded memory.
+// Values are buffered and merged in the centroids in batches. Centroids are kept small near the
+// extremes, making the estimates of the tail quantiles the most accurate
+type tDigest struct {
+	centroids []centroid
+	pending   []centroid
+	// total is the weight of the centroids, the pending ones excluded
+	total    float64
+	min, max float64
+}
+
+func (t *tDigest) add(x float64) {
+	if len(t.centroids) == 0 && len(t.pending) == 0 || x < t.min {
+		t.min = x
+	}
+	if len(t.centroids) == 0 && len(t.pending) == 0 || x > t.max {
+		t.max = x
+	}
+	t.pending = append(t.pending, centroid{mean: x, weight: 1})
+	if len(t.pending) >= 8*tDigestCompression {
+		t.compress()
+	}
+}
+
+// merge adds the values of other, as if they had been added to t
+func (t *tDigest) merge(other *tDigest) {
+	other.compress()
+	if len(other.centroids) == 0 {
+		return
+	}
+	if t.empty() || other.min < t.min {
+		t.min = other.min
+	}
+	if t.empty() || other.max > t.max {
+		t.max = other.max
+	}
+	t.pending = append(t.pending, other.centroids...)
+	t.compress()
+}
+
+func (t *tDigest) empty() bool {
+	return len(t.centroids) == 0 && len(t.pending) == 0
+}
+
+// compress merges the pending centroids with the others, joining the neighbours as long as they
+// span less than a unit of the scale function k, which stretches the quantiles near the extremes
+func (t *tDigest) compress() {
+	if len(t.pending) == 0 {
+		return
+	}
+	all := make([]centroid, 0, len(t.centroids)+len(t.pending))
+	all = append(append(all, t.centroids...), t.pending...)
+	sort.Slice(all, func(i, j int) bool { return all[i].mean < all[j].mean })
+
+	total := 0.0
+	for _, c := range all {
+		total += c.weight
+	}
+
+	merged := all[:1]
+	// before is the weight of the centroids preceding the last one merged
+	before := 0.0
+	for _, c := range all[1:] {
+		last := &merged[len(merged)-1]
+		weight := last.weight + c.weight
+		if tDigestScale((before+weight)/total)-tDigestScale(before/total) <= 1 {
+			last.mean += (c.mean - last.mean) * c.weight / weight
+			last.weight = weight
+		} else {
+			before += last.weight
+			merged = append(merged, c)
+		}
+	}
+
+	t.centroids, t.pending, t.total = merged, t.pending[:0], total
+}
+
+// tDigestScale is the scale function k1 of the t-digest paper
+func tDigestScale(q float64) float64 {
+	return tDigestCompression / (2 * math.Pi) * math.Asin(2*math.Min(q, 1)-1)
+}
+
+// quantile estimates the value below which falls the fraction q of the values, NaN if there are
+// none
+func (t *tDigest) quantile(q float64) float64 {
+	t.compress()
+	if len(t.centroids) == 0 {
+		return math.NaN()
+	}
+
+	// each centroid stands at the middle of its values, the extremes at the ends
+	target := q * t.total
+	position, previous, previousMean := 0.0, 0.0, t.min
+	for _, c := range t.centroids {
+		center := position + c.weight/2
+		if target < center {
+			return interpolate(previousMean, c.mean, (target-previous)/(center-previous))
+		}
+		position += c.weight
+		previous, previousMean = center, c.mean
+	}
+	if t.total == previous {
+		return t.max
+	}
+	return interpolate(previousMean, t.max, (target-previous)/(t.total-previous))
+}
+
+func interpolate(a, b, fraction float64) float64 {
+	return a + (b-a)*fraction
+}
@@ -0,0 +1,49 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestTDigest(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	values := make([]float64, 100000)
+	digests := []*tDigest{{}, {}, {}}
+	for i := range values {
+		values[i] = random.ExpFloat64()
+		digests[i%len(digests)].add(values[i])
+	}
+	sort.Float64s(values)
+
+	digest := &tDigest{}
+	for _, d := range digests {
+		digest.merge(d)
+	}
+	assert.LessOrEqual(t, len(digest.centroids), tDigestCompression)
+	// the error is measured on the rank of the estimate, relatively to the distance from the
+	// closest extreme
+	for _, q := range []float64{0.001, 0.01, 0.5, 0.95, 0.99, 0.999} {
+		rank := float64(sort.SearchFloat64s(values, digest.quantile(q))) / float64(len(values))
+		assert.InDelta(t, q, rank, 0.1*math.Min(q, 1-q)+0.0005, "q = %v", q)
+	}
+	assert.Equal(t, values[0], digest.quantile(0))
+	assert.Equal(t, values[len(values)-1], digest.quantile(1))
+}
+
+func TestTDigestSmall(t *testing.T) {
+	digest := &tDigest{}
+	assert.True(t, math.IsNaN(digest.quantile(0.5)))
+
+	digest.add(7)
+	assert.Equal(t, 7.0, digest.quantile(0.5))
+
+	for _, x := range []float64{1, 2, 3, 4} {
+		digest.add(x)
+	}
+	assert.Equal(t, 3.0, digest.quantile(0.5))
+	assert.Equal(t, 1.0, digest.quantile(0))
+	assert.Equal(t, 7.0, digest.quantile(1))
+}
@@ -0,0 +1,103 @@
+package parallel_csv
+
+import (
+	"fmt"
+	"time"
+)
+
+const TimeFormatError = Error("value does not match the time layout")
+
+// TimeNormalization is a Transform parsing the timestamps of Columns and rewriting them in UTC as
+// RFC 3339, with fractional seconds when there are any. Empty values are left empty, the others
+// must match Layout.
+//
+// Values carrying their offset are converted as they are. The others are wall clock times of
+// Location: those skipped when clocks go forward are moved forward by the gap, as 02:30 becoming
+// 03:30, and those happening twice when clocks go back are taken at their first occurrence
+// unless LaterAmbiguous is set
+type TimeNormalization struct {
+	Columns []string
+	// Layout is the layout of the values, as for time.Parse
+	Layout string
+	// Location is the time zone of the values without offset, UTC if nil
+	Location       *time.Location
+	LaterAmbiguous bool
+}
+
+// NormalizeTime returns a TimeNormalization of the columns, whose values have the given layout
+// and are in location unless they say otherwise
+func NormalizeTime(layout string, location *time.Location, columns ...string) Transform {
+	return TimeNormalization{Columns: columns, Layout: layout, Location: location}
+}
+
+func (n TimeNormalization) Bind(header []string) ([]string, RowFunc, error) {
+	indexes, err := headerIndexes(header, n.Columns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return header, func(fields []string) ([]string, error) {
+		for _, index := range indexes {
+			if index >= len(fields) || fields[index] == "" {
+				continue
+			}
+			t, err := n.parse(fields[index])
+			if err != nil {
+				return nil, err
+			}
+			fields[index] = t.UTC().Format(time.RFC3339Nano)
+		}
+		return fields, nil
+	}, nil
+}
+
+// parse returns the instant of a value
+func (n TimeNormalization) parse(value string) (time.Time, error) {
+	wall, err := time.Parse(n.Layout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q is not like %q", TimeFormatError, value, n.Layout)
+	}
+	location := n.Location
+	if location == nil || location == time.UTC {
+		return wall, nil
+	}
+	// a value with an offset is the same instant whatever the location it is parsed in
+	if in, err := time.ParseInLocation(n.Layout, value, location); err == nil && in.Equal(wall) {
+		return wall, nil
+	}
+	return n.resolve(wall, location), nil
+}
+
+// resolve returns the instant a wall clock time, read as UTC, stands for in location. The offsets
+// in effect a day before and after are the only candidates around a transition
+func (n TimeNormalization) resolve(wall time.Time, location *time.Location) time.Time {
+	_, before := wall.Add(-24 * time.Hour).In(location).Zone()
+	_, after := wall.Add(24 * time.Hour).In(location).Zone()
+
+	var matches []time.Time
+	for _, offset := range []int{before, after} {
+		t := wall.Add(-time.Duration(offset) * time.Second)
+		local := t.In(location)
+		if sameWallClock(local, wall) && (len(matches) == 0 || !matches[0].Equal(t)) {
+			matches = append(matches, t)
+		}
+	}
+
+	switch {
+	case len(matches) == 0:
+		// skipped by the clocks going forward, the offset before the gap moves it after it
+		return wall.Add(-time.Duration(before) * time.Second)
+	case len(matches) == 2 && (matches[1].Before(matches[0]) != n.LaterAmbiguous):
+		// the second match is the occurrence asked for, the earlier one unless LaterAmbiguous
+		return matches[1]
+	default:
+		return matches[0]
+	}
+}
+
+func sameWallClock(local time.Time, wall time.Time) bool {
+	y, m, d := local.Date()
+	wy, wm, wd := wall.Date()
+	return y == wy && m == wm && d == wd && local.Hour() == wall.Hour() &&
+		local.Minute() == wall.Minute() && local.Second() == wall.Second()
+}
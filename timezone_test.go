@@ -0,0 +1,52 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestNormalizeTime(t *testing.T) {
+	rome, err := time.LoadLocation("Europe/Rome")
+	assert.Nil(t, err)
+
+	tests := []struct {
+		value    string
+		later    bool
+		expected string
+	}{
+		{"2021-07-01 12:00:00", false, "2021-07-01T10:00:00Z"},
+		{"2021-01-15 12:00:00", false, "2021-01-15T11:00:00Z"},
+		// skipped when clocks went forward
+		{"2021-03-28 02:30:00", false, "2021-03-28T01:30:00Z"},
+		// happened twice when clocks went back
+		{"2021-10-31 02:30:00", false, "2021-10-31T00:30:00Z"},
+		{"2021-10-31 02:30:00", true, "2021-10-31T01:30:00Z"},
+		{"2021-10-31 02:30:00.25", false, "2021-10-31T00:30:00.25Z"},
+	}
+	for _, test := range tests {
+		n := TimeNormalization{Columns: []string{"at"}, Layout: "2006-01-02 15:04:05", Location: rome, LaterAmbiguous: test.later}
+		_, fn, err := n.Bind([]string{"at"})
+		assert.Nil(t, err)
+		fields, err := fn([]string{test.value})
+		assert.Nil(t, err)
+		assert.Equal(t, test.expected, fields[0], test.value)
+	}
+}
+
+func TestNormalizeTimeCopy(t *testing.T) {
+	rome, _ := time.LoadLocation("Europe/Rome")
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 8
+	config.Transforms = []Transform{NormalizeTime(time.RFC3339, rome, "at")}
+
+	input := "id,at\n1,2021-07-01T12:00:00+05:00\n2,\n3,2021-07-01T12:00:00Z\n"
+	sink := &memorySink{}
+	assert.Nil(t, NewProcessor(strings.NewReader(input), &config).Copy(sink))
+	assert.Equal(t, [][]string{{"1", "2021-07-01T07:00:00Z"}, {"2", ""}, {"3", "2021-07-01T12:00:00Z"}}, sink.rows)
+
+	err := NewProcessor(strings.NewReader("id,at\n1,yesterday\n"), &config).Copy(&memorySink{})
+	assert.ErrorIs(t, err, TimeFormatError)
+}
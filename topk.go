@@ -0,0 +1,195 @@
+package parallel_csv
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+)
+
+// TopValuesFactor is the number of values tracked by TopValues for each one reported
+const TopValuesFactor = 10
+
+// FrequentValue is a value reported by TopValues and the number of rows it appears on, which is
+// overestimated by at most Error
+type FrequentValue struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+	Error int    `json:"error"`
+}
+
+// frequencyCounter is a value tracked by a spaceSaving summary
+type frequencyCounter struct {
+	value string
+	count int
+	error int
+	// index is the position of the counter in the heap
+	index int
+}
+
+// frequencyHeap orders the counters by increasing count
+type frequencyHeap []*frequencyCounter
+
+func (h frequencyHeap) Len() int           { return len(h) }
+func (h frequencyHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+func (h frequencyHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+
+func (h *frequencyHeap) Push(x interface{}) {
+	counter := x.(*frequencyCounter)
+	counter.index = len(*h)
+	*h = append(*h, counter)
+}
+
+func (h *frequencyHeap) Pop() interface{} {
+	old := *h
+	counter := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return counter
+}
+
+// spaceSaving is the Space-Saving summary of the most frequent values of a stream. It tracks up
+// to capacity values: a new value takes the place of the least frequent one, inheriting its
+// count as error. Any value appearing more than once every capacity values is tracked
+type spaceSaving struct {
+	capacity int
+	counters map[string]*frequencyCounter
+	heap     frequencyHeap
+}
+
+func newSpaceSaving(capacity int) *spaceSaving {
+	return &spaceSaving{capacity: capacity, counters: map[string]*frequencyCounter{}}
+}
+
+// add counts count more occurrences of value, which may be overestimated by overcount
+func (s *spaceSaving) add(value string, count int, overcount int) {
+	if counter, ok := s.counters[value]; ok {
+		counter.count += count
+		counter.error += overcount
+		heap.Fix(&s.heap, counter.index)
+		return
+	}
+
+	if len(s.heap) < s.capacity {
+		counter := &frequencyCounter{value: cloneString(value), count: count, error: overcount}
+		s.counters[counter.value] = counter
+		heap.Push(&s.heap, counter)
+		return
+	}
+
+	evicted := s.heap[0]
+	delete(s.counters, evicted.value)
+	evicted.value = cloneString(value)
+	evicted.error = evicted.count + overcount
+	evicted.count += count
+	s.counters[evicted.value] = evicted
+	heap.Fix(&s.heap, 0)
+}
+
+// merge adds the values tracked by other
+func (s *spaceSaving) merge(other *spaceSaving) {
+	for _, counter := range other.heap {
+		s.add(counter.value, counter.count, counter.error)
+	}
+}
+
+// top returns the k most frequent values, the most frequent first
+func (s *spaceSaving) top(k int) []FrequentValue {
+	values := make([]FrequentValue, 0, len(s.heap))
+	for _, counter := range s.heap {
+		values = append(values, FrequentValue{Value: counter.value, Count: counter.count, Error: counter.error})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		a, b := values[i], values[j]
+		return a.Count > b.Count || a.Count == b.Count && a.Value < b.Value
+	})
+	if len(values) > k {
+		values = values[:k]
+	}
+	return values
+}
+
+// TopValues returns the k most frequent values of the columns, keyed by column name, of every
+// column if none is given. Each worker keeps a Space-Saving summary of TopValuesFactor*k values
+// per column, merged at the end: the counts are exact in columns with fewer distinct values,
+// otherwise the values appearing on more than a row every TopValuesFactor*k are never missed
+func (p processor) TopValues(k int, columns ...string) (map[string][]FrequentValue, error) {
+	if k < 1 {
+		return nil, fmt.Errorf("k must be positive, got %d", k)
+	}
+	indexes, err := p.keyIndexes(columns)
+	if err != nil {
+		return nil, err
+	}
+	// every column is summarized when none is given, the rows telling how many there are
+	all := len(columns) == 0
+
+	partials := make([][]*spaceSaving, p.config.NumberOfWorkers)
+	err = p.RunChunks(func(chunk Chunk) error {
+		summaries := partials[chunk.Worker]
+		for _, row := range chunk.Rows {
+			if chunk.Cancelled() {
+				return errCancelled
+			}
+			fields := p.split(row)
+			if all {
+				for len(summaries) < len(fields) {
+					summaries = append(summaries, newSpaceSaving(TopValuesFactor*k))
+				}
+				for j, field := range fields {
+					summaries[j].add(field, 1, 0)
+				}
+				continue
+			}
+
+			if summaries == nil {
+				for range indexes {
+					summaries = append(summaries, newSpaceSaving(TopValuesFactor*k))
+				}
+			}
+			for j, index := range indexes {
+				if index < len(fields) {
+					summaries[j].add(fields[index], 1, 0)
+				}
+			}
+		}
+		partials[chunk.Worker] = summaries
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var merged []*spaceSaving
+	for _, partial := range partials {
+		for j, summary := range partial {
+			if j == len(merged) {
+				merged = append(merged, newSpaceSaving(TopValuesFactor*k))
+			}
+			merged[j].merge(summary)
+		}
+	}
+
+	if all {
+		for i := len(merged); i < len(p.header); i++ {
+			merged = append(merged, newSpaceSaving(TopValuesFactor*k))
+		}
+		for i := range merged {
+			name := columnName(i)
+			if i < len(p.header) {
+				name = p.header[i]
+			}
+			columns = append(columns, name)
+		}
+	}
+
+	top := make(map[string][]FrequentValue, len(columns))
+	for i, column := range columns {
+		top[column] = []FrequentValue{}
+		if i < len(merged) {
+			top[column] = merged[i].top(k)
+		}
+	}
+	return top, nil
+}
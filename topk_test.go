@@ -0,0 +1,60 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestTopValues(t *testing.T) {
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 8
+	p := NewProcessor(strings.NewReader(orders), &config)
+
+	top, err := p.TopValues(1, "customer")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]FrequentValue{"customer": {{Value: "c1", Count: 2}}}, top)
+
+	p = NewProcessor(strings.NewReader(orders), &config)
+	top, err = p.TopValues(2)
+	assert.Nil(t, err)
+	assert.Len(t, top, 3)
+	assert.Equal(t, []FrequentValue{{Value: "10", Count: 1}, {Value: "20", Count: 1}}, top["amount"])
+	assert.Equal(t, []FrequentValue{{Value: "c1", Count: 2}, {Value: "c2", Count: 1}}, top["customer"])
+	assert.Equal(t, []FrequentValue{{Value: "1", Count: 1}, {Value: "2", Count: 1}}, top["order"])
+
+	_, err = NewProcessor(strings.NewReader(orders), &config).TopValues(1, "missing")
+	assert.ErrorIs(t, err, ColumnNotFoundError)
+	_, err = NewProcessor(strings.NewReader(orders), &config).TopValues(0)
+	assert.NotNil(t, err)
+}
+
+func TestTopValuesSkewed(t *testing.T) {
+	// a few heavy values among many rare ones
+	random := rand.New(rand.NewSource(1))
+	input := strings.Builder{}
+	input.WriteString("v\n")
+	counts := map[string]int{}
+	for i := 0; i < 50000; i++ {
+		value := "rare" + strconv.Itoa(random.Intn(20000))
+		if i%10 < 3 {
+			value = "heavy" + strconv.Itoa(i%3)
+		}
+		counts[value]++
+		input.WriteString(value + "\n")
+	}
+
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 4 * KB
+	top, err := NewProcessor(strings.NewReader(input.String()), &config).TopValues(3)
+	assert.Nil(t, err)
+
+	assert.Len(t, top["v"], 3)
+	for _, value := range top["v"] {
+		assert.True(t, strings.HasPrefix(value.Value, "heavy"), value.Value)
+		assert.GreaterOrEqual(t, value.Count, counts[value.Value])
+		assert.LessOrEqual(t, value.Count-value.Error, counts[value.Value])
+	}
+}
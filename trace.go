@@ -0,0 +1,79 @@
+package parallel_csv
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+const TraceGapError = Error("chunks are not contiguous")
+
+// Trace records the boundaries of the chunks processed, to diagnose rows missing or repeated
+// near them. Set it as Config.Trace, the chunks of every run are added to it
+type Trace struct {
+	mu     sync.Mutex
+	chunks []TraceChunk
+}
+
+// TraceChunk is a chunk as seen by the worker which processed it
+type TraceChunk struct {
+	Index  int `json:"index"`
+	Worker int `json:"worker"`
+	// Start and End are the offsets of the first byte of the chunk and of the one following it
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	// FirstLine and LastLine are the source lines of the first and last rows
+	FirstLine int `json:"first_line"`
+	LastLine  int `json:"last_line"`
+	Rows      int `json:"rows"`
+	// Duration is the time spent validating, filtering and running the job on the chunk
+	Duration time.Duration `json:"duration"`
+	// Completed is false when the chunk failed or the run was aborted while processing it
+	Completed bool `json:"completed"`
+}
+
+func NewTrace() *Trace {
+	return &Trace{}
+}
+
+func (t *Trace) add(chunk TraceChunk) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.chunks = append(t.chunks, chunk)
+}
+
+// Chunks returns the chunks recorded, sorted by position in the source
+func (t *Trace) Chunks() []TraceChunk {
+	t.mu.Lock()
+	chunks := append([]TraceChunk(nil), t.chunks...)
+	t.mu.Unlock()
+
+	sort.SliceStable(chunks, func(i, j int) bool {
+		return chunks[i].Start < chunks[j].Start
+	})
+	return chunks
+}
+
+// Check returns a TraceGapError for the first two consecutive chunks which leave bytes or lines
+// out, or overlap
+func (t *Trace) Check() error {
+	chunks := t.Chunks()
+	for i := 1; i < len(chunks); i++ {
+		previous, chunk := chunks[i-1], chunks[i]
+		if chunk.Start != previous.End || chunk.FirstLine != previous.LastLine+1 {
+			return fmt.Errorf("%w: chunk %d ends at offset %d, line %d, chunk %d starts at offset %d, line %d",
+				TraceGapError, previous.Index, previous.End, previous.LastLine, chunk.Index, chunk.Start, chunk.FirstLine)
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the chunks recorded as a JSON array, sorted by position in the source
+func (t *Trace) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(t.Chunks())
+}
@@ -0,0 +1,49 @@
+package parallel_csv
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	input := numbers(100)
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 16
+	config.Trace = NewTrace()
+	assert.Nil(t, NewProcessor(strings.NewReader(input), &config).Run(func(header []string, rows []string) {}))
+
+	chunks := config.Trace.Chunks()
+	assert.Greater(t, len(chunks), 10)
+	assert.Equal(t, int64(2), chunks[0].Start)
+	assert.Equal(t, 2, chunks[0].FirstLine)
+	assert.Equal(t, int64(len(input)), chunks[len(chunks)-1].End)
+	assert.Equal(t, 101, chunks[len(chunks)-1].LastLine)
+
+	rows := 0
+	for i, chunk := range chunks {
+		assert.Equal(t, i, chunk.Index)
+		assert.True(t, chunk.Completed)
+		rows += chunk.Rows
+	}
+	assert.Equal(t, 100, rows)
+	assert.Nil(t, config.Trace.Check())
+
+	out := &bytes.Buffer{}
+	assert.Nil(t, config.Trace.WriteJSON(out))
+	var dumped []TraceChunk
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &dumped))
+	assert.Equal(t, chunks, dumped)
+}
+
+func TestTraceCheck(t *testing.T) {
+	trace := NewTrace()
+	trace.add(TraceChunk{Index: 1, Start: 10, End: 20, FirstLine: 3, LastLine: 4})
+	trace.add(TraceChunk{Index: 0, Start: 0, End: 10, FirstLine: 1, LastLine: 2})
+	assert.Nil(t, trace.Check())
+
+	trace.add(TraceChunk{Index: 2, Start: 21, End: 30, FirstLine: 5, LastLine: 6})
+	assert.ErrorIs(t, trace.Check(), TraceGapError)
+}
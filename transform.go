@@ -0,0 +1,71 @@
+package parallel_csv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Transform rewrites the rows written by Copy. Transforms are listed in Config.Transforms and
+// chained, each one receiving the rows produced by the previous
+type Transform interface {
+	// Bind resolves the transform against the header of the rows it receives. It returns the
+	// header of the rows it produces and the function rewriting them
+	Bind(header []string) ([]string, RowFunc, error)
+}
+
+// RowFunc rewrites the fields of a row, it may modify them in place. Returning nil fields drops
+// the row, which the following transforms do not see. It is called by several workers at once
+type RowFunc func(fields []string) ([]string, error)
+
+// bindTransforms chains the transforms, returning the final header and the function applying
+// all of them
+func bindTransforms(header []string, transforms []Transform) ([]string, RowFunc, error) {
+	funcs := make([]RowFunc, len(transforms))
+	for i, transform := range transforms {
+		var err error
+		header, funcs[i], err = transform.Bind(header)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return header, func(fields []string) ([]string, error) {
+		var err error
+		for _, fn := range funcs {
+			if fields, err = fn(fields); err != nil || fields == nil {
+				return nil, err
+			}
+		}
+		return fields, nil
+	}, nil
+}
+
+// headerIndex returns the position of the column in the header, -1 if absent. Columns of
+// files without header are named col_1, col_2 and so on
+func headerIndex(header []string, name string) int {
+	for i, column := range header {
+		if column == name {
+			return i
+		}
+	}
+
+	if len(header) == 0 && strings.HasPrefix(name, "col_") {
+		if n, err := strconv.Atoi(name[len("col_"):]); err == nil && n > 0 {
+			return n - 1
+		}
+	}
+	return -1
+}
+
+// headerIndexes resolves the columns against the header
+func headerIndexes(header []string, columns []string) ([]int, error) {
+	indexes := make([]int, len(columns))
+	for i, column := range columns {
+		indexes[i] = headerIndex(header, column)
+		if indexes[i] == -1 {
+			return nil, fmt.Errorf("%w: %s", ColumnNotFoundError, column)
+		}
+	}
+	return indexes, nil
+}
@@ -0,0 +1,28 @@
+package parallel_csv
+
+import "io"
+
+// Transformed streams as CSV the rows written by Copy, after rewriting each of them with fn, so
+// that the result can be handed to any API taking a reader without being buffered. A nil fn
+// leaves the rows unchanged. The run starts in the background and waits while the reader is not
+// read, its error is returned by Read in place of io.EOF. Closing the reader early stops the run
+func (p processor) Transformed(fn RowFunc) io.ReadCloser {
+	if fn != nil {
+		config := *p.config
+		config.Transforms = append(config.Transforms[:len(config.Transforms):len(config.Transforms)], funcTransform(fn))
+		p.config = &config
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(p.Copy(NewCSVSink(w, p.config.HeaderConfig.Separator)))
+	}()
+	return r
+}
+
+// funcTransform applies a RowFunc, leaving the header unchanged
+type funcTransform RowFunc
+
+func (t funcTransform) Bind(header []string) ([]string, RowFunc, error) {
+	return header, RowFunc(t), nil
+}
@@ -0,0 +1,203 @@
+package parallel_csv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+const UploadTooLargeError = Error("upload is too large")
+const InputTooLargeError = Error("input is too large")
+const TooManyRowsError = Error("upload has too many rows")
+const UploadFileNotFoundError = Error("upload has no file")
+
+// MaxUploadErrors is the number of skipped errors kept by an UploadResult
+const MaxUploadErrors = 100
+
+// Upload describes how a multipart CSV upload is processed
+type Upload struct {
+	// Config is the configuration of the processor, the default one if nil
+	Config *Config
+	// Field is the name of the form field holding the file, "file" if empty
+	Field string
+	// MaxBytes limits the size of the request body, 0 means no limit
+	MaxBytes int64
+	// MaxRows limits the number of rows of the file, 0 means no limit. The reader stops past it,
+	// through Config.MaxRows, so that the job never receives more than MaxRows rows
+	MaxRows int64
+	// Job receives the rows of the file
+	Job ChunkJob
+}
+
+// UploadResult is the JSON outcome of an upload
+type UploadResult struct {
+	File string `json:"file,omitempty"`
+	// Rows counts the rows handed to the job
+	Rows int64 `json:"rows"`
+	// Skipped counts the rows dropped by the error policy
+	Skipped int64 `json:"skipped"`
+	// Errors holds the first MaxUploadErrors errors skipped by Config.ErrorPolicy
+	Errors []string `json:"errors,omitempty"`
+	// Error is the error ending the upload, if any
+	Error string `json:"error,omitempty"`
+}
+
+// ProcessUpload streams the file of a multipart request into a processor running the upload job,
+// without storing it. Parts before the file are discarded, the ones after it are not read
+func ProcessUpload(r *http.Request, upload Upload) (*UploadResult, error) {
+	result := &UploadResult{}
+	if upload.MaxBytes > 0 {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{LimitReader(r.Body, upload.MaxBytes), r.Body}
+	}
+
+	field := upload.Field
+	if field == "" {
+		field = "file"
+	}
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return result, err
+	}
+	var file io.Reader
+	for file == nil {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return result, UploadFileNotFoundError
+		}
+		if err != nil {
+			return result, uploadError(err)
+		}
+		if part.FormName() == field {
+			result.File = part.FileName()
+			file = part
+		}
+	}
+
+	config := GetDefaultConfig()
+	if upload.Config != nil {
+		config = *upload.Config
+	}
+	mu := sync.Mutex{}
+	handler := config.ErrorHandler
+	config.ErrorHandler = func(err error) {
+		mu.Lock()
+		if len(result.Errors) < MaxUploadErrors {
+			result.Errors = append(result.Errors, err.Error())
+		}
+		mu.Unlock()
+		if handler != nil {
+			handler(err)
+		}
+	}
+
+	if upload.MaxRows > 0 && (config.MaxRows == 0 || config.MaxRows > upload.MaxRows) {
+		// the reader stops at the first row past the limit, which tells that there are too many
+		config.MaxRows = upload.MaxRows + 1
+	}
+	p, err := newProcessor(file, &config)
+	if err != nil {
+		return result, uploadError(err)
+	}
+
+	// the row past the limit is the last one read, at this line or after it once filtered out
+	over := p.start.Line + config.SkipDataRows + int(upload.MaxRows)
+	dropped := int64(0)
+	err = p.RunChunks(func(chunk Chunk) error {
+		if n := len(chunk.Rows); upload.MaxRows > 0 && n > 0 && chunk.Line(n-1) >= over {
+			chunk.Rows = chunk.Rows[:n-1]
+			if chunk.lines != nil {
+				chunk.lines = chunk.lines[:n-1]
+			}
+			atomic.AddInt64(&dropped, 1)
+			if n == 1 {
+				return nil
+			}
+		}
+		return upload.Job(chunk)
+	})
+
+	stats := p.Stats()
+	result.Rows, result.Skipped = stats.RowsDelivered-atomic.LoadInt64(&dropped), stats.RowsSkipped
+	if upload.MaxRows > 0 && stats.RowsRead > upload.MaxRows {
+		return result, fmt.Errorf("%w: more than %d", TooManyRowsError, upload.MaxRows)
+	}
+	return result, uploadError(err)
+}
+
+// uploadError reports the errors due to the size limit as UploadTooLargeError
+func uploadError(err error) error {
+	if errors.Is(err, InputTooLargeError) {
+		return UploadTooLargeError
+	}
+	return err
+}
+
+// UploadHandler processes the multipart uploads posted to it and replies with their UploadResult
+// as JSON. Uploads over the limits get a 413 status, invalid ones a 400 and the ones failing
+// while processed a 422
+func UploadHandler(upload Upload) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		result, err := ProcessUpload(r, upload)
+		status := http.StatusOK
+		if err != nil {
+			result.Error = err.Error()
+			status = uploadStatus(err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(result)
+	})
+}
+
+func uploadStatus(err error) int {
+	switch {
+	case errors.Is(err, UploadTooLargeError), errors.Is(err, TooManyRowsError):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, UploadFileNotFoundError), errors.Is(err, http.ErrNotMultipart),
+		errors.Is(err, http.ErrMissingBoundary), errors.Is(err, EmptyFileError), errors.Is(err, HeaderNotFoundError):
+		return http.StatusBadRequest
+	default:
+		return http.StatusUnprocessableEntity
+	}
+}
+
+// LimitReader returns a reader of r failing with InputTooLargeError once it goes past n bytes,
+// unlike io.LimitReader which ends silently and would let a cut input look complete
+func LimitReader(r io.Reader, n int64) io.Reader {
+	return &limitReader{Reader: r, left: n}
+}
+
+type limitReader struct {
+	io.Reader
+	left int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.left < 0 {
+		return 0, InputTooLargeError
+	}
+	// one more byte than allowed is read to tell an input of exactly the limit from a longer one
+	if int64(len(p)) > l.left+1 {
+		p = p[:l.left+1]
+	}
+	n, err := l.Reader.Read(p)
+	l.left -= int64(n)
+	if l.left < 0 {
+		return n, InputTooLargeError
+	}
+	return n, err
+}
@@ -0,0 +1,106 @@
+package parallel_csv
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// uploadRequest builds a multipart request with a text field followed by the file
+func uploadRequest(content string) *http.Request {
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	form.WriteField("name", "test")
+	part, _ := form.CreateFormFile("file", "numbers.csv")
+	part.Write([]byte(content))
+	form.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/upload", body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	return r
+}
+
+func TestUploadHandler(t *testing.T) {
+	rows := int64(0)
+	handler := UploadHandler(Upload{Job: func(chunk Chunk) error {
+		atomic.AddInt64(&rows, int64(len(chunk.Rows)))
+		return nil
+	}})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, uploadRequest(numbers(1000)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int64(1000), rows)
+
+	result := UploadResult{}
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&result))
+	assert.Equal(t, UploadResult{File: "numbers.csv", Rows: 1000}, result)
+}
+
+func TestUploadSkippedErrors(t *testing.T) {
+	config := GetDefaultConfig()
+	config.ValidateFieldCount = true
+	config.ErrorPolicy = SkipOnError
+	upload := Upload{Config: &config, Job: func(chunk Chunk) error { return nil }}
+
+	result, err := ProcessUpload(uploadRequest("a,b\n1,2\n3\n4,5\n"), upload)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), result.Rows)
+	assert.Equal(t, int64(1), result.Skipped)
+	assert.Len(t, result.Errors, 1)
+}
+
+func TestUploadLimits(t *testing.T) {
+	job := func(chunk Chunk) error { return nil }
+
+	result, err := ProcessUpload(uploadRequest(numbers(1000)), Upload{MaxBytes: 1000, Job: job})
+	assert.ErrorIs(t, err, UploadTooLargeError)
+	assert.NotNil(t, result)
+
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 64
+	_, err = ProcessUpload(uploadRequest(numbers(1000)), Upload{Config: &config, MaxRows: 100, Job: job})
+	assert.ErrorIs(t, err, TooManyRowsError)
+
+	_, err = ProcessUpload(uploadRequest(numbers(100)), Upload{MaxRows: 100, MaxBytes: 10000, Job: job})
+	assert.Nil(t, err)
+
+	// the rows past the limit are not handed to the job
+	for _, size := range []int{16, 64, 1024} {
+		config.BytesPerWorker = size
+		rows := int64(0)
+		counting := func(chunk Chunk) error {
+			atomic.AddInt64(&rows, int64(len(chunk.Rows)))
+			return nil
+		}
+		result, err = ProcessUpload(uploadRequest(numbers(1000)), Upload{Config: &config, MaxRows: 100, Job: counting})
+		assert.ErrorIs(t, err, TooManyRowsError)
+		assert.Equal(t, int64(100), rows, size)
+		assert.Equal(t, int64(100), result.Rows, size)
+	}
+	// the row past the limit is found even when filtered out
+	config.Where = MustParseWhere("n <= 100")
+	result, err = ProcessUpload(uploadRequest(numbers(1000)), Upload{Config: &config, MaxRows: 100, Job: job})
+	assert.ErrorIs(t, err, TooManyRowsError)
+	assert.Equal(t, int64(100), result.Rows)
+
+	w := httptest.NewRecorder()
+	UploadHandler(Upload{MaxRows: 10, Job: job}).ServeHTTP(w, uploadRequest(numbers(100)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestUploadWithoutFile(t *testing.T) {
+	w := httptest.NewRecorder()
+	upload := Upload{Field: "other", Job: func(chunk Chunk) error { return nil }}
+	UploadHandler(upload).ServeHTTP(w, uploadRequest(numbers(10)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	UploadHandler(upload).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/upload", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
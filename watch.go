@@ -0,0 +1,240 @@
+package parallel_csv
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultWatchInterval is the time between two scans of a watched directory when not set
+const DefaultWatchInterval = time.Second
+
+// WatchResult is the outcome of the processing of a file found by a Watcher
+type WatchResult struct {
+	File  string
+	Stats Stats
+	// Err is the error of the file, which has then been moved to FailedDir
+	Err      error
+	Duration time.Duration
+}
+
+// Watcher processes the files appearing in a directory. The directory is scanned every Interval
+// and a file is processed once its size and modification time are the same in two scans, so
+// that files still being written are left alone. Files present when the watcher starts are
+// processed too.
+//
+// The directory is polled on purpose rather than watched through filesystem notifications: a
+// notification tells that a file was created or written, not that its writer is done, so the
+// files would have to be checked again over time anyway. Polling also works on the network and
+// mounted filesystems which deliver no notifications, and needs no platform specific code
+type Watcher struct {
+	Dir string
+	// Pattern is the glob matched by the names of the files to process, "*.csv" if empty
+	Pattern string
+	// Interval is the time between two scans, DefaultWatchInterval if 0
+	Interval time.Duration
+	// Concurrency is the number of files processed at once, 1 if 0
+	Concurrency int
+	// DoneDir receives the files processed successfully, which are left in place when empty
+	DoneDir string
+	// FailedDir receives the files whose processing failed, which are left in place when empty
+	FailedDir string
+	// Config is the configuration of the processors, the default one if nil. Each file gets a
+	// copy of it: the funcs, writers and pointers it holds, such as ErrorHandler, Filter,
+	// Logger, Parser or Transforms, are shared by the files processed at once and must be safe
+	// for concurrent use, as they are across the workers of a run. Configure gives each file
+	// its own DeadLetter, Trace or CheckpointPath
+	Config *Config
+	// Configure adjusts the copy of Config used for a file, if not nil
+	Configure func(file string, config *Config)
+	// Process runs the pipeline on the processor of a file
+	Process func(file string, p Processor) error
+	// OnResult is called after each file, once it has been moved. Calls may be concurrent
+	OnResult func(result WatchResult)
+	// seen holds the size and modification time of the files found by the last scan
+	seen map[string]fileState
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// Run watches the directory until ctx is done, then waits for the files being processed. It
+// returns an error only when the directory cannot be read
+func (w *Watcher) Run(ctx context.Context) error {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	concurrency := w.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	for _, dir := range []string{w.DoneDir, w.FailedDir} {
+		if dir != "" {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+		}
+	}
+
+	w.seen = map[string]fileState{}
+	// processing holds the files queued or being processed, done the ones left in place once
+	// processed, with their state then: a file written again or re-created is processed again
+	processing := map[string]bool{}
+	done := map[string]fileState{}
+	mu := sync.Mutex{}
+	slots := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		ready, err := w.scan()
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		for file := range done {
+			if _, ok := w.seen[file]; !ok {
+				delete(done, file)
+			}
+		}
+		mu.Unlock()
+
+		for _, file := range ready {
+			state := w.seen[file]
+			mu.Lock()
+			previous, processed := done[file]
+			busy := processing[file] || processed && previous == state
+			if !busy {
+				processing[file] = true
+			}
+			mu.Unlock()
+			if busy {
+				continue
+			}
+
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return nil
+			}
+			wg.Add(1)
+			go func(file string, state fileState) {
+				defer wg.Done()
+				moved := w.handle(file)
+				mu.Lock()
+				if !moved {
+					done[file] = state
+				}
+				delete(processing, file)
+				mu.Unlock()
+				<-slots
+			}(file, state)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// scan lists the files of the directory and returns the ones not changed since the last scan
+func (w *Watcher) scan() ([]string, error) {
+	pattern := w.Pattern
+	if pattern == "" {
+		pattern = "*.csv"
+	}
+	files, err := filepath.Glob(filepath.Join(w.Dir, pattern))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var ready []string
+	seen := map[string]fileState{}
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil || !info.Mode().IsRegular() {
+			// the file may have been moved meanwhile
+			continue
+		}
+
+		state := fileState{size: info.Size(), modTime: info.ModTime()}
+		if previous, ok := w.seen[file]; ok && previous == state {
+			ready = append(ready, file)
+		}
+		seen[file] = state
+	}
+	w.seen = seen
+	return ready, nil
+}
+
+// handle processes a file and moves it, it returns false if the file has been left in place
+func (w *Watcher) handle(file string) bool {
+	start := time.Now()
+	result := WatchResult{File: file}
+	result.Stats, result.Err = w.process(file)
+
+	dir := w.DoneDir
+	if result.Err != nil {
+		dir = w.FailedDir
+	}
+	moved := false
+	if dir != "" {
+		err := os.Rename(file, filepath.Join(dir, filepath.Base(file)))
+		moved = err == nil
+		if err != nil && result.Err == nil {
+			result.Err = err
+		}
+	}
+
+	result.Duration = time.Since(start)
+	if w.OnResult != nil {
+		w.OnResult(result)
+	}
+	return moved
+}
+
+func (w *Watcher) process(file string) (Stats, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer f.Close()
+
+	config := GetDefaultConfig()
+	if w.Config != nil {
+		config = *w.Config
+	}
+	if w.Configure != nil {
+		w.Configure(file, &config)
+	}
+	p, err := newProcessor(f, &config)
+	if err != nil {
+		return Stats{}, fmt.Errorf("%s: %w", file, err)
+	}
+
+	err = runProcess(w.Process, file, p)
+	return p.Stats(), err
+}
+
+// runProcess runs the pipeline on a file, turning a panic into a PanicError
+func runProcess(process func(file string, p Processor) error, file string, p Processor) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r}
+		}
+	}()
+	return process(file, p)
+}
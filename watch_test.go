@@ -0,0 +1,170 @@
+package parallel_csv
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.csv"), []byte(numbers(10)), 0644)
+	os.WriteFile(filepath.Join(dir, "ignored.txt"), []byte(numbers(10)), 0644)
+
+	mu := sync.Mutex{}
+	results := map[string]WatchResult{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := &Watcher{
+		Dir:         dir,
+		Interval:    10 * time.Millisecond,
+		Concurrency: 2,
+		DoneDir:     filepath.Join(dir, "done"),
+		FailedDir:   filepath.Join(dir, "failed"),
+		Process: func(file string, p Processor) error {
+			if filepath.Base(file) == "bad.csv" {
+				return errors.New("bad file")
+			}
+			return p.Run(func(header []string, rows []string) {})
+		},
+		OnResult: func(result WatchResult) {
+			mu.Lock()
+			results[filepath.Base(result.File)] = result
+			if len(results) == 2 {
+				cancel()
+			}
+			mu.Unlock()
+		},
+	}
+
+	errs := make(chan error)
+	go func() { errs <- w.Run(ctx) }()
+	time.Sleep(30 * time.Millisecond)
+	os.WriteFile(filepath.Join(dir, "bad.csv"), []byte(numbers(5)), 0644)
+
+	select {
+	case err := <-errs:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("watcher did not process the files")
+	}
+
+	assert.Nil(t, results["a.csv"].Err)
+	assert.Equal(t, int64(10), results["a.csv"].Stats.RowsDelivered)
+	assert.NotNil(t, results["bad.csv"].Err)
+	assert.FileExists(t, filepath.Join(dir, "done", "a.csv"))
+	assert.FileExists(t, filepath.Join(dir, "failed", "bad.csv"))
+	assert.FileExists(t, filepath.Join(dir, "ignored.txt"))
+}
+
+func TestWatcherLeavesFilesInPlace(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.csv"), []byte(numbers(10)), 0644)
+
+	processed := 0
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	w := &Watcher{
+		Dir:      dir,
+		Interval: 10 * time.Millisecond,
+		Process: func(file string, p Processor) error {
+			processed++
+			return nil
+		},
+	}
+
+	assert.Nil(t, w.Run(ctx))
+	assert.Equal(t, 1, processed)
+	assert.FileExists(t, filepath.Join(dir, "a.csv"))
+}
+
+func TestWatcherRecreatedFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.csv")
+	os.WriteFile(file, []byte(numbers(10)), 0644)
+
+	mu := sync.Mutex{}
+	processed := 0
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := &Watcher{
+		Dir:      dir,
+		Interval: 10 * time.Millisecond,
+		Process: func(file string, p Processor) error {
+			mu.Lock()
+			processed++
+			if processed == 2 {
+				cancel()
+			}
+			mu.Unlock()
+			return nil
+		},
+	}
+
+	errs := make(chan error)
+	go func() { errs <- w.Run(ctx) }()
+	time.Sleep(50 * time.Millisecond)
+	// the same name with the same content, the modification time telling them apart
+	os.Remove(file)
+	os.WriteFile(file, []byte(numbers(10)), 0644)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(file, later, later)
+
+	select {
+	case err := <-errs:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("watcher did not process the re-created file")
+	}
+	assert.Equal(t, 2, processed)
+}
+
+func TestWatcherConfigure(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.csv"), []byte(numbers(10)), 0644)
+	os.WriteFile(filepath.Join(dir, "b.csv"), []byte(numbers(3000)), 0644)
+
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 1 * KB
+	mu := sync.Mutex{}
+	traces := map[string]*Trace{}
+	finished := 0
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := &Watcher{
+		Dir:         dir,
+		Interval:    10 * time.Millisecond,
+		Concurrency: 2,
+		Config:      &config,
+		Configure: func(file string, config *Config) {
+			mu.Lock()
+			defer mu.Unlock()
+			config.Trace = NewTrace()
+			traces[filepath.Base(file)] = config.Trace
+		},
+		Process: func(file string, p Processor) error {
+			return p.Run(func(header []string, rows []string) {})
+		},
+		OnResult: func(result WatchResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			if finished++; finished == 2 {
+				cancel()
+			}
+		},
+	}
+	assert.Nil(t, w.Run(ctx))
+
+	// each file has a trace of its own, the shared config is left untouched
+	assert.Nil(t, config.Trace)
+	assert.Len(t, traces["a.csv"].Chunks(), 1)
+	assert.Greater(t, len(traces["b.csv"].Chunks()), 1)
+	assert.Nil(t, traces["b.csv"].Check())
+}
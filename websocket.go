@@ -0,0 +1,181 @@
+package parallel_csv
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultWebSocketBatch is the number of rows of a message of WebSocketSink when not set
+	DefaultWebSocketBatch = 100
+	// DefaultWebSocketBuffer is the number of messages queued by WebSocketSink when not set
+	DefaultWebSocketBuffer = 16
+)
+
+// message types and close code of RFC 6455, the same as the constants of gorilla/websocket
+const (
+	webSocketText        = 1
+	webSocketClose       = 8
+	webSocketNormalClose = 1000
+)
+
+// WebSocketConn is a WebSocket connection, such as the *websocket.Conn of gorilla/websocket
+type WebSocketConn interface {
+	WriteMessage(messageType int, data []byte) error
+	SetWriteDeadline(t time.Time) error
+}
+
+// WebSocketBackpressure decides what WebSocketSink does when the client reads slower than the rows
+// are processed
+type WebSocketBackpressure int
+
+const (
+	// WebSocketBlock waits for the client once the buffer is full, slowing down the processing
+	WebSocketBlock WebSocketBackpressure = iota
+	// WebSocketDrop drops the messages not fitting in the buffer, so that the processing goes on
+	// at full speed. The rows dropped are counted by WebSocketSink.Dropped
+	WebSocketDrop
+)
+
+// WebSocketSink streams the rows to a WebSocket client as text messages, each one a JSON array of
+// BatchRows objects keyed by column name, in source order. Messages are queued in a buffer sent by
+// a goroutine of their own, Backpressure deciding what happens once it is full. Close sends the
+// last message followed by a close frame, the connection is left open. Columns of files without
+// header are named col_1, col_2 and so on
+type WebSocketSink struct {
+	// BatchRows is the number of rows of a message, DefaultWebSocketBatch if 0
+	BatchRows int
+	// Buffer is the number of messages waiting for the client, DefaultWebSocketBuffer if 0
+	Buffer       int
+	Backpressure WebSocketBackpressure
+	// WriteTimeout fails the sink when a message takes longer to be sent, 0 for no limit
+	WriteTimeout time.Duration
+	conn         WebSocketConn
+	header       []string
+	batch        []orderedObject
+	queue        chan webSocketMessage
+	// failed is closed once a message could not be sent, err holding why
+	failed  chan struct{}
+	err     error
+	wg      sync.WaitGroup
+	dropped int64
+}
+
+// webSocketMessage is a message waiting in the buffer
+type webSocketMessage struct {
+	data []byte
+	rows int
+}
+
+// NewWebSocketSink creates a sink streaming the rows to conn
+func NewWebSocketSink(conn WebSocketConn) *WebSocketSink {
+	return &WebSocketSink{conn: conn}
+}
+
+func (s *WebSocketSink) Open(header []string) error {
+	if s.BatchRows <= 0 {
+		s.BatchRows = DefaultWebSocketBatch
+	}
+	if s.Buffer <= 0 {
+		s.Buffer = DefaultWebSocketBuffer
+	}
+
+	s.header = header
+	s.queue = make(chan webSocketMessage, s.Buffer)
+	s.failed = make(chan struct{})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for message := range s.queue {
+			if s.err != nil {
+				continue
+			}
+			if s.err = s.send(webSocketText, message.data); s.err != nil {
+				close(s.failed)
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *WebSocketSink) Write(rows [][]string) error {
+	for _, row := range rows {
+		// the objects are encoded after the chunk buffer is released
+		s.batch = append(s.batch, rowObject(s.header, cloneFields(row)))
+		if len(s.batch) == s.BatchRows {
+			if err := s.enqueue(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// enqueue encodes the current batch and queues it, waiting or dropping it when the buffer is full
+func (s *WebSocketSink) enqueue() error {
+	if len(s.batch) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(s.batch)
+	if err != nil {
+		return err
+	}
+	message := webSocketMessage{data: data, rows: len(s.batch)}
+	s.batch = s.batch[:0]
+
+	if s.Backpressure == WebSocketDrop {
+		select {
+		case s.queue <- message:
+		case <-s.failed:
+			return s.err
+		default:
+			atomic.AddInt64(&s.dropped, int64(message.rows))
+		}
+		return nil
+	}
+	select {
+	case s.queue <- message:
+		return nil
+	case <-s.failed:
+		return s.err
+	}
+}
+
+// send writes a message within WriteTimeout
+func (s *WebSocketSink) send(messageType int, data []byte) error {
+	if s.WriteTimeout > 0 {
+		if err := s.conn.SetWriteDeadline(time.Now().Add(s.WriteTimeout)); err != nil {
+			return err
+		}
+	}
+	if err := s.conn.WriteMessage(messageType, data); err != nil {
+		return fmt.Errorf("websocket: %w", err)
+	}
+	return nil
+}
+
+// Close sends the messages left and a close frame, once every row has been sent
+func (s *WebSocketSink) Close() error {
+	if s.queue == nil {
+		return nil
+	}
+	err := s.enqueue()
+	close(s.queue)
+	s.wg.Wait()
+	s.queue = nil
+	if err == nil {
+		err = s.err
+	}
+	if err != nil {
+		return err
+	}
+	return s.send(webSocketClose, []byte{webSocketNormalClose >> 8, webSocketNormalClose & 0xff})
+}
+
+// Dropped returns the number of rows dropped by WebSocketDrop
+func (s *WebSocketSink) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
@@ -0,0 +1,89 @@
+package parallel_csv
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeWebSocket keeps the messages sent. Once gate is set, each message waits for it to be
+// readable, and fail makes the writes fail
+type fakeWebSocket struct {
+	mu        sync.Mutex
+	messages  [][]byte
+	types     []int
+	deadlines int
+	gate      chan struct{}
+	fail      bool
+}
+
+func (c *fakeWebSocket) WriteMessage(messageType int, data []byte) error {
+	if c.gate != nil && messageType == webSocketText {
+		<-c.gate
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fail {
+		return errors.New("broken pipe")
+	}
+	c.messages = append(c.messages, data)
+	c.types = append(c.types, messageType)
+	return nil
+}
+
+func (c *fakeWebSocket) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadlines++
+	return nil
+}
+
+func TestWebSocketSink(t *testing.T) {
+	conn := &fakeWebSocket{}
+	sink := NewWebSocketSink(conn)
+	sink.BatchRows = 4
+	sink.WriteTimeout = time.Second
+
+	input := "name,age\nMario,42\nLuigi,40\n"
+	assert.Nil(t, NewProcessor(strings.NewReader(input), nil).Copy(sink))
+	assert.Equal(t, `[{"name":"Mario","age":"42"},{"name":"Luigi","age":"40"}]`, string(conn.messages[0]))
+	assert.Equal(t, []int{webSocketText, webSocketClose}, conn.types)
+	assert.Equal(t, []byte{0x03, 0xe8}, conn.messages[1])
+	assert.Equal(t, 2, conn.deadlines)
+
+	conn = &fakeWebSocket{}
+	sink = NewWebSocketSink(conn)
+	sink.BatchRows = 10
+	assert.Nil(t, NewProcessor(strings.NewReader(numbers(95)), nil).Copy(sink))
+	assert.Len(t, conn.messages, 11)
+	var rows []map[string]string
+	assert.Nil(t, json.Unmarshal(conn.messages[9], &rows))
+	assert.Equal(t, []map[string]string{{"n": "91"}, {"n": "92"}, {"n": "93"}, {"n": "94"}, {"n": "95"}}, rows)
+}
+
+func TestWebSocketSinkBackpressure(t *testing.T) {
+	// a client reading nothing until the end drops what does not fit in the buffer
+	conn := &fakeWebSocket{gate: make(chan struct{})}
+	sink := NewWebSocketSink(conn)
+	sink.BatchRows = 10
+	sink.Buffer = 2
+	sink.Backpressure = WebSocketDrop
+	assert.Nil(t, sink.Open([]string{"n"}))
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, sink.Write(make([][]string, 10)))
+	}
+	close(conn.gate)
+	assert.Nil(t, sink.Close())
+	// the message being sent and the buffered ones went through
+	assert.Equal(t, int64(100)-int64(len(conn.messages)-1)*10, sink.Dropped())
+	assert.LessOrEqual(t, len(conn.messages)-1, 3)
+
+	conn = &fakeWebSocket{fail: true}
+	err := NewProcessor(strings.NewReader(numbers(1000)), nil).Copy(&WebSocketSink{conn: conn, BatchRows: 1, Buffer: 1})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "broken pipe")
+}
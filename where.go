@@ -0,0 +1,547 @@
+package parallel_csv
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const WhereSyntaxError = Error("invalid where expression")
+
+// Where is a compiled filter expression such as `age > 30 AND country = 'IT'`.
+//
+// Columns are referred to by name, quoted with double quotes or backticks when they are not plain
+// identifiers. Strings are enclosed in single quotes. Supported operators are =, !=, <>, <, <=, >,
+// >=, [NOT] IN (...), [NOT] LIKE with % and _ wildcards, IS [NOT] NULL, AND, OR, NOT and
+// parentheses. Values are compared as numbers when both sides are numbers, as strings otherwise.
+// An empty value is NULL: as in SQL, comparisons, IN and LIKE are unknown when an operand is NULL,
+// NOT of unknown is unknown and the rows whose condition is unknown do not match. Only IS NULL
+// matches empty values
+type Where struct {
+	expr string
+	root whereNode
+}
+
+// ParseWhere compiles a filter expression
+func ParseWhere(expr string) (*Where, error) {
+	tokens, err := lexWhere(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &whereParser{tokens: tokens}
+	root, err := parser.or()
+	if err != nil {
+		return nil, err
+	}
+	if !parser.done() {
+		return nil, parser.errorf("unexpected %q", parser.peek().text)
+	}
+
+	return &Where{expr: expr, root: root}, nil
+}
+
+// MustParseWhere is like ParseWhere but panics if the expression is invalid
+func MustParseWhere(expr string) *Where {
+	w, err := ParseWhere(expr)
+	if err != nil {
+		panic(err)
+	}
+	return w
+}
+
+func (w *Where) String() string {
+	return w.expr
+}
+
+// bind resolves the column names of the expression against a header
+func (w *Where) bind(p processor) (*boundWhere, error) {
+	bound := &boundWhere{root: w.root, indexes: map[string]int{}}
+	for _, name := range w.root.columns(nil) {
+		index := p.ColumnIndex(name)
+		if index == -1 {
+			return nil, fmt.Errorf("%w: %s", ColumnNotFoundError, name)
+		}
+		bound.indexes[name] = index
+	}
+	return bound, nil
+}
+
+// boundWhere is an expression whose column names have been resolved to field indexes
+type boundWhere struct {
+	root    whereNode
+	indexes map[string]int
+}
+
+// match tells whether a row satisfies the expression
+func (b *boundWhere) match(fields []string) bool {
+	return b.root.eval(b, fields) == truthTrue
+}
+
+func (b *boundWhere) value(column string, fields []string) string {
+	index := b.indexes[column]
+	if index >= len(fields) {
+		return ""
+	}
+	return strings.TrimSpace(fields[index])
+}
+
+// truth is the value of a condition in the three-valued logic of SQL, ordered so that AND is the
+// minimum of its operands and OR the maximum
+type truth int8
+
+const (
+	truthFalse truth = iota
+	// truthUnknown is the value of the conditions on NULL
+	truthUnknown
+	truthTrue
+)
+
+func truthOf(b bool) truth {
+	if b {
+		return truthTrue
+	}
+	return truthFalse
+}
+
+type whereNode interface {
+	eval(b *boundWhere, fields []string) truth
+	columns(names []string) []string
+}
+
+// operand is either a column or a literal
+type operand struct {
+	text     string
+	isColumn bool
+}
+
+func (o operand) value(b *boundWhere, fields []string) string {
+	if o.isColumn {
+		return b.value(o.text, fields)
+	}
+	return o.text
+}
+
+func (o operand) columns(names []string) []string {
+	if o.isColumn {
+		return append(names, o.text)
+	}
+	return names
+}
+
+type logicalNode struct {
+	and         bool
+	left, right whereNode
+}
+
+func (n logicalNode) eval(b *boundWhere, fields []string) truth {
+	left := n.left.eval(b, fields)
+	if n.and {
+		if left == truthFalse {
+			return truthFalse
+		}
+		if right := n.right.eval(b, fields); right < left {
+			return right
+		}
+		return left
+	}
+	if left == truthTrue {
+		return truthTrue
+	}
+	if right := n.right.eval(b, fields); right > left {
+		return right
+	}
+	return left
+}
+
+func (n logicalNode) columns(names []string) []string {
+	return n.right.columns(n.left.columns(names))
+}
+
+type notNode struct {
+	node whereNode
+}
+
+func (n notNode) eval(b *boundWhere, fields []string) truth {
+	return truthTrue - n.node.eval(b, fields)
+}
+
+func (n notNode) columns(names []string) []string {
+	return n.node.columns(names)
+}
+
+type compareNode struct {
+	op          string
+	left, right operand
+}
+
+func (n compareNode) eval(b *boundWhere, fields []string) truth {
+	left, right := n.left.value(b, fields), n.right.value(b, fields)
+	if left == "" || right == "" {
+		return truthUnknown
+	}
+	c := compareValues(left, right)
+	switch n.op {
+	case "=":
+		return truthOf(c == 0)
+	case "!=", "<>":
+		return truthOf(c != 0)
+	case "<":
+		return truthOf(c < 0)
+	case "<=":
+		return truthOf(c <= 0)
+	case ">":
+		return truthOf(c > 0)
+	default:
+		return truthOf(c >= 0)
+	}
+}
+
+func (n compareNode) columns(names []string) []string {
+	return n.right.columns(n.left.columns(names))
+}
+
+// compareValues compares two values as numbers if both are numbers, as strings otherwise
+func compareValues(a, b string) int {
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		return compareFloats(x, y)
+	}
+	return strings.Compare(a, b)
+}
+
+type inNode struct {
+	operand operand
+	values  []operand
+}
+
+func (n inNode) eval(b *boundWhere, fields []string) truth {
+	value := n.operand.value(b, fields)
+	if value == "" {
+		return truthUnknown
+	}
+	// a NULL in the list makes a missing value unknown
+	result := truthFalse
+	for _, candidate := range n.values {
+		other := candidate.value(b, fields)
+		if other == "" {
+			result = truthUnknown
+		} else if compareValues(value, other) == 0 {
+			return truthTrue
+		}
+	}
+	return result
+}
+
+func (n inNode) columns(names []string) []string {
+	names = n.operand.columns(names)
+	for _, value := range n.values {
+		names = value.columns(names)
+	}
+	return names
+}
+
+type likeNode struct {
+	operand operand
+	pattern *regexp.Regexp
+}
+
+func (n likeNode) eval(b *boundWhere, fields []string) truth {
+	value := n.operand.value(b, fields)
+	if value == "" {
+		return truthUnknown
+	}
+	return truthOf(n.pattern.MatchString(value))
+}
+
+func (n likeNode) columns(names []string) []string {
+	return n.operand.columns(names)
+}
+
+type nullNode struct {
+	operand operand
+}
+
+func (n nullNode) eval(b *boundWhere, fields []string) truth {
+	return truthOf(n.operand.value(b, fields) == "")
+}
+
+func (n nullNode) columns(names []string) []string {
+	return n.operand.columns(names)
+}
+
+// likePattern turns a LIKE pattern in an anchored regular expression
+func likePattern(pattern string) *regexp.Regexp {
+	b := strings.Builder{}
+	b.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+type tokenKind int
+
+const (
+	identToken tokenKind = iota
+	keywordToken
+	stringToken
+	numberToken
+	operatorToken
+	punctToken
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+	// quoted is set for the tokens written within quotes
+	quoted bool
+}
+
+var whereKeywords = map[string]bool{"AND": true, "OR": true, "NOT": true, "IN": true, "LIKE": true, "IS": true, "NULL": true}
+
+func lexWhere(expr string) ([]token, error) {
+	var tokens []token
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')' || r == ',':
+			tokens = append(tokens, token{kind: punctToken, text: string(r), pos: i})
+			i++
+		case strings.ContainsRune("=!<>", r):
+			j := i + 1
+			if j < len(runes) && (runes[j] == '=' || r == '<' && runes[j] == '>') {
+				j++
+			}
+			op := string(runes[i:j])
+			if op == "!" {
+				return nil, fmt.Errorf("%w: unexpected ! at %d", WhereSyntaxError, i+1)
+			}
+			tokens = append(tokens, token{kind: operatorToken, text: op, pos: i})
+			i = j
+		case r == '\'' || r == '"' || r == '`':
+			text, end, ok := lexQuoted(runes, i)
+			if !ok {
+				return nil, fmt.Errorf("%w: unterminated quote at %d", WhereSyntaxError, i+1)
+			}
+			kind := identToken
+			if r == '\'' {
+				kind = stringToken
+			}
+			tokens = append(tokens, token{kind: kind, text: text, pos: i})
+			i = end
+		case unicode.IsDigit(r) || r == '-' || r == '.':
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || strings.ContainsRune(".eE+-", runes[j])) {
+				j++
+			}
+			tokens = append(tokens, token{kind: numberToken, text: string(runes[i:j]), pos: i})
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			text := string(runes[i:j])
+			if whereKeywords[strings.ToUpper(text)] {
+				tokens = append(tokens, token{kind: keywordToken, text: strings.ToUpper(text), pos: i})
+			} else {
+				tokens = append(tokens, token{kind: identToken, text: text, pos: i})
+			}
+			i = j
+		default:
+			return nil, fmt.Errorf("%w: unexpected %q at %d", WhereSyntaxError, r, i+1)
+		}
+	}
+	return tokens, nil
+}
+
+// lexQuoted reads a quoted token starting at i, a doubled quote stands for the quote itself
+func lexQuoted(runes []rune, i int) (string, int, bool) {
+	quote := runes[i]
+	b := strings.Builder{}
+	for j := i + 1; j < len(runes); j++ {
+		if runes[j] != quote {
+			b.WriteRune(runes[j])
+			continue
+		}
+		if j+1 < len(runes) && runes[j+1] == quote {
+			b.WriteRune(quote)
+			j++
+			continue
+		}
+		return b.String(), j + 1, true
+	}
+	return "", 0, false
+}
+
+type whereParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *whereParser) done() bool {
+	return p.pos == len(p.tokens)
+}
+
+func (p *whereParser) peek() token {
+	if p.done() {
+		return token{kind: punctToken, text: "end of expression"}
+	}
+	return p.tokens[p.pos]
+}
+
+// accept consumes the next token if it is the given keyword or punctuation
+func (p *whereParser) accept(text string) bool {
+	t := p.peek()
+	if !p.done() && (t.kind == keywordToken || t.kind == punctToken) && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *whereParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", WhereSyntaxError, fmt.Sprintf(format, args...))
+}
+
+func (p *whereParser) or() (whereNode, error) {
+	left, err := p.and()
+	for err == nil && p.accept("OR") {
+		var right whereNode
+		right, err = p.and()
+		left = logicalNode{left: left, right: right}
+	}
+	return left, err
+}
+
+func (p *whereParser) and() (whereNode, error) {
+	left, err := p.not()
+	for err == nil && p.accept("AND") {
+		var right whereNode
+		right, err = p.not()
+		left = logicalNode{and: true, left: left, right: right}
+	}
+	return left, err
+}
+
+func (p *whereParser) not() (whereNode, error) {
+	if p.accept("NOT") {
+		node, err := p.not()
+		return notNode{node: node}, err
+	}
+	return p.predicate()
+}
+
+func (p *whereParser) predicate() (whereNode, error) {
+	if p.accept("(") {
+		node, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf("missing )")
+		}
+		return node, nil
+	}
+
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.accept("IS") {
+		negate := p.accept("NOT")
+		if !p.accept("NULL") {
+			return nil, p.errorf("expected NULL, found %q", p.peek().text)
+		}
+		return negated(nullNode{operand: left}, negate), nil
+	}
+
+	negate := p.accept("NOT")
+	switch {
+	case p.accept("IN"):
+		if !p.accept("(") {
+			return nil, p.errorf("expected ( after IN")
+		}
+		node := inNode{operand: left}
+		for {
+			value, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			node.values = append(node.values, value)
+			if p.accept(")") {
+				return negated(node, negate), nil
+			}
+			if !p.accept(",") {
+				return nil, p.errorf("expected , or ) in IN list")
+			}
+		}
+	case p.accept("LIKE"):
+		t := p.peek()
+		if t.kind != stringToken {
+			return nil, p.errorf("LIKE expects a string, found %q", t.text)
+		}
+		p.pos++
+		return negated(likeNode{operand: left, pattern: likePattern(t.text)}, negate), nil
+	case negate:
+		return nil, p.errorf("expected IN or LIKE after NOT")
+	}
+
+	t := p.peek()
+	if t.kind != operatorToken {
+		return nil, p.errorf("expected an operator, found %q", t.text)
+	}
+	p.pos++
+
+	right, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	return compareNode{op: t.text, left: left, right: right}, nil
+}
+
+func (p *whereParser) operand() (operand, error) {
+	t := p.peek()
+	switch {
+	case p.done():
+		return operand{}, p.errorf("unexpected end of expression")
+	case t.kind == identToken:
+		p.pos++
+		return operand{text: t.text, isColumn: true}, nil
+	case t.kind == stringToken:
+		p.pos++
+		return operand{text: t.text}, nil
+	case t.kind == numberToken:
+		if _, err := strconv.ParseFloat(t.text, 64); err != nil {
+			return operand{}, p.errorf("invalid number %q", t.text)
+		}
+		p.pos++
+		return operand{text: t.text}, nil
+	default:
+		return operand{}, p.errorf("unexpected %q", t.text)
+	}
+}
+
+func negated(node whereNode, negate bool) whereNode {
+	if negate {
+		return notNode{node: node}
+	}
+	return node
+}
@@ -0,0 +1,128 @@
+package parallel_csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestWhere(t *testing.T) {
+	header := []string{"name", "age", "country", "Height(Inches)"}
+	p := processor{header: header, config: &Config{HeaderConfig: HeaderConfig{Separator: ","}}}
+	row := strings.Split("anna, 34,IT,65.5", ",")
+
+	tests := []struct {
+		expr  string
+		match bool
+	}{
+		{"age > 30", true},
+		{"age > 30 AND country = 'IT'", true},
+		{"age > 30 AND country = 'FR'", false},
+		{"age > 40 OR country <> 'FR'", true},
+		{"NOT (age >= 34)", false},
+		{"age = 34.0", true},
+		{"country IN ('FR', 'IT')", true},
+		{"country NOT IN ('FR', 'DE')", true},
+		{"name LIKE 'an%'", true},
+		{"name LIKE '_nn'", false},
+		{"name NOT LIKE 'b%'", true},
+		{`"Height(Inches)" < 70`, true},
+		{"`Height(Inches)` > 70", false},
+		{"country IS NULL", false},
+		{"country IS NOT NULL", true},
+		{"name = 'it''s'", false},
+		{"age > 9", true},
+		{"name > 'bob'", false},
+	}
+
+	for _, test := range tests {
+		where, err := ParseWhere(test.expr)
+		assert.Nil(t, err, test.expr)
+
+		bound, err := where.bind(p)
+		assert.Nil(t, err, test.expr)
+		assert.Equal(t, test.match, bound.match(row), test.expr)
+	}
+}
+
+func TestWhereNull(t *testing.T) {
+	p := processor{header: []string{"name", "age"}, config: &Config{HeaderConfig: HeaderConfig{Separator: ","}}}
+	row := []string{"", " "}
+
+	tests := []struct {
+		expr  string
+		match bool
+	}{
+		{"age < 30", false},
+		{"age >= 30", false},
+		{"NOT (age < 30)", false},
+		{"name != 'x'", false},
+		{"name = ''", false},
+		{"age IN (30, 40)", false},
+		{"age NOT IN (30, 40)", false},
+		{"name LIKE '%'", false},
+		{"name NOT LIKE 'x%'", false},
+		{"age IS NULL", true},
+		{"age IS NOT NULL", false},
+		{"age < 30 OR name IS NULL", true},
+		{"NOT (age < 30 AND name = 'x')", false},
+		{"NOT (age < 30 AND name IS NOT NULL)", true},
+	}
+	for _, test := range tests {
+		bound, err := MustParseWhere(test.expr).bind(p)
+		assert.Nil(t, err, test.expr)
+		assert.Equal(t, test.match, bound.match(row), test.expr)
+	}
+
+	// a NULL in the list of NOT IN leaves the values not found unknown
+	bound, _ := MustParseWhere("age NOT IN (30, name)").bind(p)
+	assert.False(t, bound.match([]string{"", "40"}))
+	assert.True(t, bound.match([]string{"x", "40"}))
+}
+
+func TestWhereNullRows(t *testing.T) {
+	config := GetDefaultConfig()
+	config.Where = MustParseWhere("age < 30")
+	p := NewProcessor(strings.NewReader("name,age\na,\nb,40\nc,20\n"), &config)
+
+	var matched []string
+	err := p.Run(func(header []string, rows []string) {
+		matched = append(matched, rows...)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"c,20"}, matched)
+}
+
+func TestWhereSyntaxError(t *testing.T) {
+	for _, expr := range []string{"", "age >", "age > 30 AND", "(age > 30", "age 30", "name LIKE 3", "age ! 3", "name = 'x", "age IN (1, 2"} {
+		_, err := ParseWhere(expr)
+		assert.ErrorIs(t, err, WhereSyntaxError, expr)
+	}
+}
+
+func TestWhereConfig(t *testing.T) {
+	file := openFile("testdata/mid.csv")
+	config := GetDefaultConfig()
+	config.BytesPerWorker = 16 * KB
+	config.Where = MustParseWhere(`Index <= 100 AND "Weight(Pounds)" > 0`)
+	p := NewProcessor(file, &config)
+
+	ch := make(chan string, 25000)
+	err := p.Run(func(header []string, rows []string) {
+		for _, row := range rows {
+			ch <- row
+		}
+	})
+	assert.Nil(t, err)
+	assert.Len(t, ch, 100)
+	assert.Equal(t, int64(24900), p.Stats().RowsFiltered)
+}
+
+func TestWhereUnknownColumn(t *testing.T) {
+	config := GetDefaultConfig()
+	config.Where = MustParseWhere("age > 3")
+	p := NewProcessor(openFile("testdata/very-small.csv"), &config)
+
+	err := p.Run(func(header []string, rows []string) {})
+	assert.ErrorIs(t, err, ColumnNotFoundError)
+}